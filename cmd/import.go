@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"wx_channel/internal/config"
+	"wx_channel/internal/database"
+	"wx_channel/internal/services"
+	"wx_channel/internal/utils"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importFormat   string
+	importStrategy string
+	importDBPath   string
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import <browse|downloads> <file>",
+	Short: "导入浏览记录或下载记录",
+	Long: `从 JSON、CSV 导出文件导入浏览记录或下载记录，下载记录还支持旧版 download_records.csv。
+已存在的记录按 --strategy 处理：skip 保留已有记录，overwrite 覆盖，merge 互动数取较大值并补齐空字段。`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		kind, file := args[0], args[1]
+		if kind != services.ImportKindBrowse && kind != services.ImportKindDownloads {
+			color.Red("未知的记录类型: %s（可选 browse、downloads）\n", kind)
+			os.Exit(1)
+		}
+
+		format, err := services.ParseImportFormat(importFormat)
+		if err != nil {
+			color.Red("%v\n", err)
+			os.Exit(1)
+		}
+		strategy, err := services.ParseImportStrategy(importStrategy)
+		if err != nil {
+			color.Red("%v\n", err)
+			os.Exit(1)
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			color.Red("读取文件失败: %v\n", err)
+			os.Exit(1)
+		}
+
+		dbPath := importDBPath
+		if dbPath == "" {
+			cfg := config.Load()
+			downloadsDir, err := utils.ResolveDownloadDir(cfg.DownloadsDir)
+			if err != nil {
+				color.Red("解析下载目录失败: %v\n", err)
+				os.Exit(1)
+			}
+			dbPath = filepath.Join(downloadsDir, "records.db")
+		}
+		if err := database.Initialize(&database.Config{DBPath: dbPath}); err != nil {
+			color.Red("初始化数据库失败: %v\n", err)
+			os.Exit(1)
+		}
+		defer database.Close()
+
+		color.Yellow("正在导入 %s 到 %s ...\n", file, dbPath)
+		report, err := services.NewImportService().Import(kind, data, format, strategy)
+		if err != nil {
+			color.Red("导入失败: %v\n", err)
+			database.Close()
+			os.Exit(1)
+		}
+
+		color.Green("✓ 导入完成：共 %d 条，新增 %d，更新 %d，跳过 %d，失败 %d\n",
+			report.Total, report.Created, report.Updated, report.Skipped, report.Failed)
+		for _, msg := range report.Errors {
+			color.Red("  %s\n", msg)
+		}
+	},
+}
+
+func init() {
+	importCmd.Flags().StringVar(&importFormat, "format", "", "文件格式：json 或 csv（默认根据内容识别）")
+	importCmd.Flags().StringVar(&importStrategy, "strategy", "skip", "已存在记录的处理方式：skip、overwrite 或 merge")
+	importCmd.Flags().StringVar(&importDBPath, "db", "", "数据库文件路径（默认为下载目录下的 records.db）")
+	rootCmd.AddCommand(importCmd)
+}
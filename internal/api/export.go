@@ -27,9 +27,10 @@ func (h *ExportAPI) HandleExportDownloadRecords(w http.ResponseWriter, r *http.R
 		formatStr = "csv"
 	}
 
-	// 解析 ID（可选）
+	// 解析 ID 和标签（可选）
 	// 支持查询字符串中的 ids 参数（逗号分隔）或 JSON 请求体
 	var ids []string
+	tag := r.URL.Query().Get("tag")
 	if r.Method == http.MethodGet {
 		idsStr := r.URL.Query().Get("ids")
 		if idsStr != "" {
@@ -39,6 +40,7 @@ func (h *ExportAPI) HandleExportDownloadRecords(w http.ResponseWriter, r *http.R
 		var req struct {
 			IDs    []string `json:"ids"`
 			Format string   `json:"format"`
+			Tag    string   `json:"tag"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err == nil {
 			if len(req.IDs) > 0 {
@@ -47,6 +49,9 @@ func (h *ExportAPI) HandleExportDownloadRecords(w http.ResponseWriter, r *http.R
 			if req.Format != "" {
 				formatStr = req.Format
 			}
+			if req.Tag != "" {
+				tag = req.Tag
+			}
 		}
 	} else {
 		response.ErrorWithStatus(w, http.StatusMethodNotAllowed, http.StatusMethodNotAllowed, "Method not allowed")
@@ -55,12 +60,12 @@ func (h *ExportAPI) HandleExportDownloadRecords(w http.ResponseWriter, r *http.R
 
 	// 确定格式
 	format := services.ExportFormat(strings.ToLower(formatStr))
-	if format != services.ExportFormatCSV && format != services.ExportFormatJSON {
+	if format != services.ExportFormatCSV && format != services.ExportFormatJSON && format != services.ExportFormatLegacyCSV {
 		format = services.ExportFormatCSV
 	}
 
 	// 执行导出
-	result, err := h.service.ExportDownloadRecords(format, ids)
+	result, err := h.service.ExportDownloadRecords(format, ids, tag)
 	if err != nil {
 		response.Error(w, http.StatusInternalServerError, err.Error())
 		return
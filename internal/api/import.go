@@ -0,0 +1,94 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"wx_channel/internal/response"
+	"wx_channel/internal/services"
+)
+
+// maxImportBytes 导入文件的最大大小
+const maxImportBytes = 64 << 20
+
+type ImportAPI struct {
+	service *services.ImportService
+}
+
+func NewImportAPI() *ImportAPI {
+	return &ImportAPI{
+		service: services.NewImportService(),
+	}
+}
+
+// HandleImportBrowseHistory 导入浏览历史
+func (h *ImportAPI) HandleImportBrowseHistory(w http.ResponseWriter, r *http.Request) {
+	h.handleImport(w, r, services.ImportKindBrowse)
+}
+
+// HandleImportDownloadRecords 导入下载记录
+func (h *ImportAPI) HandleImportDownloadRecords(w http.ResponseWriter, r *http.Request) {
+	h.handleImport(w, r, services.ImportKindDownloads)
+}
+
+// handleImport 读取请求体或表单中的 file 字段并导入，format 和 strategy 从查询参数读取
+func (h *ImportAPI) handleImport(w http.ResponseWriter, r *http.Request, kind string) {
+	if r.Method != http.MethodPost {
+		response.ErrorWithStatus(w, http.StatusMethodNotAllowed, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	format, err := services.ParseImportFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	strategy, err := services.ParseImportStrategy(r.URL.Query().Get("strategy"))
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	data, err := readImportData(w, r)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := h.service.Import(kind, data, format, strategy)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidImportData) {
+			response.Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	response.Success(w, report)
+}
+
+// readImportData 读取导入数据，支持 multipart/form-data 的 file 字段或原始请求体
+func readImportData(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+
+	var reader io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import data: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("import data is empty")
+	}
+	return data, nil
+}
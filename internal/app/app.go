@@ -62,6 +62,9 @@ type App struct {
 	WSHub          *websocket.Hub
 	SearchService  *api.SearchService
 	GopeedService  *services.GopeedService // Add GopeedService
+	QueueScheduler *services.QueueScheduler
+	TrashService   *services.TrashService
+	BackupService  *services.BackupService
 	CloudConnector *cloud.Connector
 
 	// 路由器
@@ -122,6 +125,23 @@ func (app *App) initDownloadRecords() error {
 		return fmt.Errorf("初始化数据库失败: %v", err)
 	}
 
+	// FTS5 需要使用 sqlite_fts5 标签编译（普通 go build 不包含），未启用时全文索引迁移被跳过，明确提示而不是静默降级
+	if !database.NewSearchRepository().FTSEnabled() {
+		utils.Warn("当前版本的 SQLite 未启用 FTS5（必须使用 go build -tags sqlite_fts5 编译），未创建全文索引，全局搜索将回退到 LIKE 匹配")
+	}
+
+	// 从设置加载带宽限制
+	if settings, err := database.NewSettingsRepository().Load(); err == nil {
+		services.GetBandwidthLimiter().ApplySettings(settings)
+	}
+
+	// 一次性迁移旧版 download_records.csv，之后数据库是唯一的下载记录来源
+	if report, err := services.NewImportService().MigrateLegacyDownloadRecords(app.Cfg.GetRecordsPath()); err != nil {
+		utils.Warn("迁移旧版下载记录失败: %v", err)
+	} else if report != nil {
+		utils.Info("已迁移旧版下载记录: 共 %d 条，新增 %d 条，已存在 %d 条", report.Total, report.Created, report.Skipped)
+	}
+
 	// Initialize Gopeed Service
 	app.GopeedService = services.NewGopeedService(downloadsDir)
 	// app.GopeedService.Start() // Removed
@@ -143,6 +163,15 @@ func (app *App) Run() {
 		sig := <-signalChan
 		color.Red("\n正在关闭服务...%v\n\n", sig)
 		utils.LogSystemShutdown(fmt.Sprintf("收到信号: %v", sig))
+		if app.QueueScheduler != nil {
+			app.QueueScheduler.Stop()
+		}
+		if app.TrashService != nil {
+			app.TrashService.Stop()
+		}
+		if app.BackupService != nil {
+			app.BackupService.Stop()
+		}
 		database.Close()
 		if os_env == "darwin" {
 			proxy.DisableProxyInMacOS(proxy.ProxySettings{
@@ -203,8 +232,8 @@ func (app *App) Run() {
 	app.RecordHandler = handlers.NewRecordHandler(app.Cfg)
 	app.CommentHandler = handlers.NewCommentHandler(app.Cfg)
 
-	// BatchHandler (Injecting GopeedService)
-	app.BatchHandler = handlers.NewBatchHandler(app.Cfg, app.GopeedService)
+	// BatchHandler（批量下载任务由下载队列执行）
+	app.BatchHandler = handlers.NewBatchHandler(app.Cfg)
 
 	// ScriptHandler
 	app.ScriptHandler = handlers.NewScriptHandler(
@@ -299,6 +328,19 @@ func (app *App) Run() {
 	go app.WSHub.Run()
 	utils.Info("✓ WebSocket Hub 已启动")
 
+	// 启动下载队列调度器
+	if database.GetDB() != nil {
+		app.startQueueScheduler()
+
+		// 定期永久删除回收站中过期的文件
+		app.TrashService = services.NewTrashService()
+		app.TrashService.Start()
+
+		// 按设置的间隔定期备份数据库
+		app.BackupService = services.NewBackupService()
+		app.BackupService.Start()
+	}
+
 	wsPort := app.Port + 1
 	go app.startWebSocketServer(wsPort)
 
@@ -357,6 +399,22 @@ func (app *App) Run() {
 	<-done
 }
 
+// startQueueScheduler 创建分片下载器和队列调度器，并将状态变化转发到控制台 WebSocket
+func (app *App) startQueueScheduler() {
+	queueService := services.NewQueueService()
+	downloader := services.NewChunkedDownloader(queueService)
+	// 下载地址过期时通过已连接的视频号页面重新获取地址
+	downloader.SetURLResolver(services.NewURLResolver(app.WSHub))
+
+	consoleHub := handlers.GetWebSocketHub()
+	consoleHub.StartProgressForwarder(downloader.ProgressChannel())
+
+	app.QueueScheduler = services.NewQueueScheduler(queueService, downloader)
+	app.QueueScheduler.SetUpdateCallback(consoleHub.BroadcastQueueUpdate)
+	app.QueueScheduler.Start()
+	utils.Info("✓ 下载队列调度器已启动")
+}
+
 // GlobalHttpCallback 桥接到单例 app 实例
 func GlobalHttpCallback(Conn *SunnyNet.HttpConn) {
 	if globalApp != nil {
@@ -423,6 +481,8 @@ func (app *App) printEnvConfig() {
 		utils.PrintLabelValue("💾", "保存页面快照", fmt.Sprintf("%v", app.Cfg.SavePageSnapshot))
 		utils.PrintLabelValue("🔍", "保存搜索数据", fmt.Sprintf("%v", app.Cfg.SaveSearchData))
 		utils.PrintLabelValue("📄", "保存JS文件", fmt.Sprintf("%v", app.Cfg.SavePageJS))
+		utils.PrintLabelValue("🗂️", "保存元数据文件", fmt.Sprintf("JSON=%v NFO=%v 封面=%v", app.Cfg.SaveMetadataJSON, app.Cfg.SaveMetadataNFO, app.Cfg.SavePoster))
+		utils.PrintLabelValue("⏩", "下载后 faststart", fmt.Sprintf("%v", app.Cfg.FastStart))
 		utils.PrintLabelValue("🖼️", "显示日志按钮", fmt.Sprintf("%v", app.Cfg.ShowLogButton))
 		utils.PrintLabelValue("📤", "分片上传并发", app.Cfg.UploadChunkConcurrency)
 		utils.PrintLabelValue("🔀", "分片合并并发", app.Cfg.UploadMergeConcurrency)
@@ -202,7 +202,14 @@ async function __wx_channels_handle_click_download__(spec) {
     likeCount: _profile.likeCount || 0,
     commentCount: _profile.commentCount || 0,
     forwardCount: _profile.forwardCount || 0,
-    favCount: _profile.favCount || 0
+    favCount: _profile.favCount || 0,
+    // 以下字段用于写入元数据 sidecar 文件
+    coverUrl: _profile.coverUrl || _profile.thumbUrl || '',
+    duration: _profile.duration || 0,
+    playCount: _profile.readCount || 0,
+    createTime: _profile.createtime ? String(_profile.createtime) : '',
+    ipRegion: (_profile.ipRegionInfo && _profile.ipRegionInfo.regionText) || '',
+    pageUrl: location.href
   };
 
   var headers = { 'Content-Type': 'application/json' };
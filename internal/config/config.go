@@ -63,6 +63,14 @@ type Config struct {
 	SaveSearchData   bool `mapstructure:"save_search_data"`
 	SavePageJS       bool `mapstructure:"save_page_js"`
 
+	// 元数据 sidecar 文件开关（写在视频文件旁边，供媒体库工具使用）
+	SaveMetadataJSON bool `mapstructure:"save_metadata_json"` // 写入 <视频名>.json
+	SaveMetadataNFO  bool `mapstructure:"save_metadata_nfo"`  // 写入 Kodi/Jellyfin 风格的 <视频名>.nfo
+	SavePoster       bool `mapstructure:"save_poster"`        // 下载封面为 poster.jpg
+
+	// 下载完成后将 moov 移到文件开头，便于控制台边下边播
+	FastStart bool `mapstructure:"faststart"`
+
 	// UI 功能开关
 	ShowLogButton bool `mapstructure:"show_log_button"`
 
@@ -210,6 +218,10 @@ func setDefaults() {
 	viper.SetDefault("save_page_snapshot", false)
 	viper.SetDefault("save_search_data", false)
 	viper.SetDefault("save_page_js", false)
+	viper.SetDefault("save_metadata_json", false)
+	viper.SetDefault("save_metadata_nfo", false)
+	viper.SetDefault("save_poster", false)
+	viper.SetDefault("faststart", false)
 	viper.SetDefault("show_log_button", false)
 
 	viper.SetDefault("cloud_enabled", false) // 默认不启用云端管理
@@ -439,6 +451,18 @@ func loadFromDatabase(config *Config) {
 	if val, err := dbLoader.GetBool("save_page_js", config.SavePageJS); err == nil {
 		config.SavePageJS = val
 	}
+	if val, err := dbLoader.GetBool("save_metadata_json", config.SaveMetadataJSON); err == nil {
+		config.SaveMetadataJSON = val
+	}
+	if val, err := dbLoader.GetBool("save_metadata_nfo", config.SaveMetadataNFO); err == nil {
+		config.SaveMetadataNFO = val
+	}
+	if val, err := dbLoader.GetBool("save_poster", config.SavePoster); err == nil {
+		config.SavePoster = val
+	}
+	if val, err := dbLoader.GetBool("faststart", config.FastStart); err == nil {
+		config.FastStart = val
+	}
 	if val, err := dbLoader.GetBool("show_log_button", config.ShowLogButton); err == nil {
 		config.ShowLogButton = val
 	}
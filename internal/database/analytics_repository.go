@@ -0,0 +1,249 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// minTrendingSpanDays 计算增长速度所需的最短时间跨度（天），避免短时间内的两次快照放大速度
+const minTrendingSpanDays = 1.0 / 24
+
+// pageSourcePatterns 浏览记录页面来源对应的 page_url 匹配模式
+var pageSourcePatterns = map[string]string{
+	"feed":    "%/web/pages/feed%",
+	"home":    "%/web/pages/home%",
+	"profile": "%/web/pages/profile%",
+	"search":  "%/web/pages/s%",
+}
+
+// IsValidPageSource 检查页面来源是否有效，空字符串表示不限制
+func IsValidPageSource(source string) bool {
+	if source == "" {
+		return true
+	}
+	_, ok := pageSourcePatterns[source]
+	return ok
+}
+
+// 时长分布的分组（浏览记录的时长单位为毫秒）
+const durationBucketExpr = `CASE
+		WHEN COALESCE(duration, 0) <= 0 THEN 'unknown'
+		WHEN duration < 30000 THEN '<30s'
+		WHEN duration < 60000 THEN '30s-1m'
+		WHEN duration < 180000 THEN '1m-3m'
+		WHEN duration < 300000 THEN '3m-5m'
+		WHEN duration < 600000 THEN '5m-10m'
+		ELSE '>=10m'
+	END`
+
+// durationBucketOrder 时长分组的显示顺序
+var durationBucketOrder = []string{"<30s", "30s-1m", "1m-3m", "3m-5m", "5m-10m", ">=10m", "unknown"}
+
+// AnalyticsRepository 基于浏览记录和互动数据快照生成统计报表
+type AnalyticsRepository struct {
+	db *sql.DB
+}
+
+// NewAnalyticsRepository 创建一个新的 AnalyticsRepository
+func NewAnalyticsRepository() *AnalyticsRepository {
+	return &AnalyticsRepository{db: GetDB()}
+}
+
+// browseConditions 返回按时间列和页面来源筛选浏览记录的条件，b 为 browse_history 的别名
+func browseConditions(params *AnalyticsParams, timeColumn string) ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if params.StartDate != nil {
+		conditions = append(conditions, timeColumn+" >= ?")
+		args = append(args, *params.StartDate)
+	}
+	if params.EndDate != nil {
+		conditions = append(conditions, timeColumn+" <= ?")
+		args = append(args, *params.EndDate)
+	}
+	if pattern, ok := pageSourcePatterns[params.PageSource]; ok {
+		conditions = append(conditions, "b.page_url LIKE ?")
+		args = append(args, pattern)
+	}
+	return conditions, args
+}
+
+// joinConditions 将条件组合为 WHERE 子句，没有条件时返回空字符串
+func joinConditions(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
+
+// analyticsLimit 返回排行榜条数，默认 20，最多 100
+func analyticsLimit(limit int) int {
+	if limit < 1 {
+		return 20
+	}
+	if limit > 100 {
+		return 100
+	}
+	return limit
+}
+
+// GetTrendingVideos 按点赞（byComments 为 false）或评论的每日增长速度排序视频
+// 使用时间范围内每个视频的第一次和最后一次快照，跨度不足一小时的视频不参与排序
+func (r *AnalyticsRepository) GetTrendingVideos(params *AnalyticsParams, byComments bool) ([]TrendingVideo, error) {
+	conditions, args := browseConditions(params, "s.captured_at")
+	orderBy := "like_velocity DESC, comment_velocity DESC"
+	if byComments {
+		orderBy = "comment_velocity DESC, like_velocity DESC"
+	}
+
+	query := fmt.Sprintf(`
+		WITH ranged AS (
+			SELECT s.video_id, s.like_count, s.comment_count, s.captured_at,
+				ROW_NUMBER() OVER (PARTITION BY s.video_id ORDER BY s.captured_at ASC, s.id ASC) as first_rank,
+				ROW_NUMBER() OVER (PARTITION BY s.video_id ORDER BY s.captured_at DESC, s.id DESC) as last_rank
+			FROM video_metrics_snapshots s
+			JOIN browse_history b ON b.id = s.video_id
+			%s
+		), spans AS (
+			SELECT f.video_id, f.captured_at as first_at, l.captured_at as last_at,
+				l.like_count, l.comment_count,
+				l.like_count - f.like_count as like_growth, l.comment_count - f.comment_count as comment_growth,
+				julianday(l.captured_at) - julianday(f.captured_at) as span_days
+			FROM ranged f
+			JOIN ranged l ON l.video_id = f.video_id AND l.last_rank = 1
+			WHERE f.first_rank = 1
+		)
+		SELECT p.video_id, b.title, b.author, COALESCE(b.author_id, '') as author_id, COALESCE(b.cover_url, '') as cover_url,
+			p.like_count, p.comment_count, p.like_growth, p.comment_growth,
+			p.like_growth / p.span_days as like_velocity, p.comment_growth / p.span_days as comment_velocity,
+			p.first_at, p.last_at
+		FROM spans p
+		JOIN browse_history b ON b.id = p.video_id
+		WHERE p.span_days >= ?
+		ORDER BY %s
+		LIMIT ?
+	`, joinConditions(conditions), orderBy)
+	args = append(args, minTrendingSpanDays, analyticsLimit(params.Limit))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trending videos: %w", err)
+	}
+	defer rows.Close()
+
+	videos := []TrendingVideo{}
+	for rows.Next() {
+		var v TrendingVideo
+		err := rows.Scan(
+			&v.VideoID, &v.Title, &v.Author, &v.AuthorID, &v.CoverURL,
+			&v.LikeCount, &v.CommentCount, &v.LikeGrowth, &v.CommentGrowth,
+			&v.LikeVelocity, &v.CommentVelocity, &v.FirstCapturedAt, &v.LastCapturedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trending video: %w", err)
+		}
+		videos = append(videos, v)
+	}
+	return videos, rows.Err()
+}
+
+// GetTopAuthors 按互动总数排序作者，统计时间范围内浏览过的视频
+// 没有作者 ID 的旧记录按作者昵称分组
+func (r *AnalyticsRepository) GetTopAuthors(params *AnalyticsParams) ([]AuthorEngagement, error) {
+	conditions, args := browseConditions(params, "b.browse_time")
+
+	query := fmt.Sprintf(`
+		SELECT COALESCE(b.author_id, '') as author_id,
+			COALESCE(MAX(a.nickname), MAX(b.author), '') as author,
+			COUNT(*) as videos,
+			SUM(COALESCE(b.like_count, 0)) as total_likes, SUM(COALESCE(b.comment_count, 0)) as total_comments,
+			SUM(COALESCE(b.fav_count, 0)) as total_favs, SUM(COALESCE(b.forward_count, 0)) as total_forwards
+		FROM browse_history b
+		LEFT JOIN authors a ON a.id = b.author_id
+		%s
+		GROUP BY CASE WHEN COALESCE(b.author_id, '') != '' THEN 'id:' || b.author_id ELSE 'name:' || b.author END
+		ORDER BY total_likes + total_comments + total_favs + total_forwards DESC, videos DESC
+		LIMIT ?
+	`, joinConditions(conditions))
+	args = append(args, analyticsLimit(params.Limit))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top authors: %w", err)
+	}
+	defer rows.Close()
+
+	authors := []AuthorEngagement{}
+	for rows.Next() {
+		var a AuthorEngagement
+		if err := rows.Scan(&a.AuthorID, &a.Author, &a.Videos, &a.TotalLikes, &a.TotalComments, &a.TotalFavs, &a.TotalForwards); err != nil {
+			return nil, fmt.Errorf("failed to scan author engagement: %w", err)
+		}
+		a.Engagement = a.TotalLikes + a.TotalComments + a.TotalFavs + a.TotalForwards
+		if a.Videos > 0 {
+			a.AvgEngagement = float64(a.Engagement) / float64(a.Videos)
+		}
+		authors = append(authors, a)
+	}
+	return authors, rows.Err()
+}
+
+// GetResolutionDistribution 统计时间范围内浏览过的视频的分辨率分布，按数量降序
+func (r *AnalyticsRepository) GetResolutionDistribution(params *AnalyticsParams) ([]DistributionBucket, error) {
+	conditions, args := browseConditions(params, "b.browse_time")
+	query := fmt.Sprintf(`
+		SELECT COALESCE(NULLIF(b.resolution, ''), 'unknown') as label, COUNT(*) as count
+		FROM browse_history b
+		%s
+		GROUP BY label
+		ORDER BY count DESC, label ASC
+	`, joinConditions(conditions))
+	return r.queryBuckets(query, args, "resolution")
+}
+
+// GetDurationDistribution 统计时间范围内浏览过的视频的时长分布，按时长从短到长排列，省略没有视频的分组
+func (r *AnalyticsRepository) GetDurationDistribution(params *AnalyticsParams) ([]DistributionBucket, error) {
+	conditions, args := browseConditions(params, "b.browse_time")
+	query := fmt.Sprintf(`
+		SELECT %s as label, COUNT(*) as count
+		FROM browse_history b
+		%s
+		GROUP BY label
+	`, durationBucketExpr, joinConditions(conditions))
+	buckets, err := r.queryBuckets(query, args, "duration")
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(buckets))
+	for _, bucket := range buckets {
+		counts[bucket.Label] = bucket.Count
+	}
+	ordered := []DistributionBucket{}
+	for _, label := range durationBucketOrder {
+		if count := counts[label]; count > 0 {
+			ordered = append(ordered, DistributionBucket{Label: label, Count: count})
+		}
+	}
+	return ordered, nil
+}
+
+// queryBuckets 执行返回 (label, count) 的分组查询
+func (r *AnalyticsRepository) queryBuckets(query string, args []interface{}, name string) ([]DistributionBucket, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s distribution: %w", name, err)
+	}
+	defer rows.Close()
+
+	buckets := []DistributionBucket{}
+	for rows.Next() {
+		var bucket DistributionBucket
+		if err := rows.Scan(&bucket.Label, &bucket.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan %s distribution: %w", name, err)
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, rows.Err()
+}
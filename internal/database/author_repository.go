@@ -0,0 +1,212 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// authorRecordCondition 匹配作者下载记录的条件（参数为两次作者 ID）
+// 没有作者 ID 的旧记录按作者使用过的昵称匹配
+const authorRecordCondition = `(author_id = ? OR (COALESCE(author_id, '') = '' AND author IN (SELECT nickname FROM author_nicknames WHERE author_id = ?)))`
+
+// authorStatsQuery 查询作者及其浏览、下载汇总，浏览汇总按作者 ID 统计，下载汇总只统计已完成的下载
+const authorStatsQuery = `
+	SELECT a.id, COALESCE(a.nickname, '') as nickname, COALESCE(a.avatar_url, '') as avatar_url,
+		a.first_seen_at, a.last_seen_at, a.updated_at,
+		COALESCE(b.browsed, 0) as videos_browsed, COALESCE(d.downloaded, 0) as videos_downloaded,
+		COALESCE(d.bytes, 0) as bytes_stored,
+		COALESCE(b.avg_likes, 0) as avg_likes, COALESCE(b.avg_comments, 0) as avg_comments,
+		COALESCE(b.avg_favs, 0) as avg_favs, COALESCE(b.avg_forwards, 0) as avg_forwards
+	FROM authors a
+	LEFT JOIN (
+		SELECT author_id, COUNT(*) as browsed,
+			AVG(COALESCE(like_count, 0)) as avg_likes, AVG(COALESCE(comment_count, 0)) as avg_comments,
+			AVG(COALESCE(fav_count, 0)) as avg_favs, AVG(COALESCE(forward_count, 0)) as avg_forwards
+		FROM browse_history WHERE author_id != '' GROUP BY author_id
+	) b ON b.author_id = a.id
+	LEFT JOIN (
+		SELECT a2.id as author_id, COUNT(DISTINCT COALESCE(NULLIF(d.video_id, ''), d.id)) as downloaded,
+			SUM(d.file_size) as bytes
+		FROM authors a2
+		JOIN download_records d ON d.author_id = a2.id OR (COALESCE(d.author_id, '') = ''
+			AND d.author IN (SELECT n.nickname FROM author_nicknames n WHERE n.author_id = a2.id))
+		WHERE d.status = 'completed'
+		GROUP BY a2.id
+	) d ON d.author_id = a.id`
+
+// AuthorRepository 处理作者数据库操作
+type AuthorRepository struct {
+	db *sql.DB
+}
+
+// NewAuthorRepository 创建一个新的 AuthorRepository
+func NewAuthorRepository() *AuthorRepository {
+	return &AuthorRepository{db: GetDB()}
+}
+
+// scanAuthorStats 将一行查询结果扫描为作者汇总
+func scanAuthorStats(row rowScanner) (*AuthorStats, error) {
+	stats := &AuthorStats{}
+	err := row.Scan(
+		&stats.ID, &stats.Nickname, &stats.AvatarURL,
+		&stats.FirstSeenAt, &stats.LastSeenAt, &stats.UpdatedAt,
+		&stats.VideosBrowsed, &stats.VideosDownloaded, &stats.BytesStored,
+		&stats.AvgLikes, &stats.AvgComments, &stats.AvgFavs, &stats.AvgForwards,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Observe 记录一次看到作者：不存在时创建，存在时更新最近的昵称、头像和时间，并记录昵称历史
+// 昵称或头像为空时保留原值
+func (r *AuthorRepository) Observe(id, nickname, avatarURL string, seenAt time.Time) error {
+	if id == "" {
+		return fmt.Errorf("author id is required")
+	}
+	if seenAt.IsZero() {
+		seenAt = time.Now()
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO authors (id, nickname, avatar_url, first_seen_at, last_seen_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			nickname = CASE WHEN excluded.nickname != '' AND excluded.last_seen_at >= authors.last_seen_at
+				THEN excluded.nickname ELSE authors.nickname END,
+			avatar_url = CASE WHEN excluded.avatar_url != '' AND excluded.last_seen_at >= authors.last_seen_at
+				THEN excluded.avatar_url ELSE authors.avatar_url END,
+			first_seen_at = MIN(authors.first_seen_at, excluded.first_seen_at),
+			last_seen_at = MAX(authors.last_seen_at, excluded.last_seen_at),
+			updated_at = excluded.updated_at
+	`, id, nickname, avatarURL, seenAt, seenAt, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save author: %w", err)
+	}
+
+	if nickname != "" {
+		_, err = tx.Exec(`
+			INSERT INTO author_nicknames (author_id, nickname, first_seen_at, last_seen_at)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT(author_id, nickname) DO UPDATE SET
+				first_seen_at = MIN(author_nicknames.first_seen_at, excluded.first_seen_at),
+				last_seen_at = MAX(author_nicknames.last_seen_at, excluded.last_seen_at)
+		`, id, nickname, seenAt, seenAt)
+		if err != nil {
+			return fmt.Errorf("failed to save author nickname: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit author: %w", err)
+	}
+	return nil
+}
+
+// GetStats 获取作者及其汇总，不存在时返回 nil
+func (r *AuthorRepository) GetStats(id string) (*AuthorStats, error) {
+	stats, err := scanAuthorStats(r.db.QueryRow(authorStatsQuery+" WHERE a.id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get author: %w", err)
+	}
+	return stats, nil
+}
+
+// GetNicknames 获取作者使用过的昵称，最近使用的在前
+func (r *AuthorRepository) GetNicknames(id string) ([]AuthorNickname, error) {
+	rows, err := r.db.Query(`
+		SELECT nickname, first_seen_at, last_seen_at
+		FROM author_nicknames WHERE author_id = ?
+		ORDER BY last_seen_at DESC
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get author nicknames: %w", err)
+	}
+	defer rows.Close()
+
+	nicknames := []AuthorNickname{}
+	for rows.Next() {
+		var n AuthorNickname
+		if err := rows.Scan(&n.Nickname, &n.FirstSeenAt, &n.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("failed to scan author nickname: %w", err)
+		}
+		nicknames = append(nicknames, n)
+	}
+	return nicknames, rows.Err()
+}
+
+// List 获取分页、排序的作者汇总，Query 匹配作者 ID、当前昵称或历史昵称
+func (r *AuthorRepository) List(params *FilterParams) (*PagedResult[AuthorStats], error) {
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.PageSize < 1 {
+		params.PageSize = 20
+	}
+	if params.PageSize > 100 {
+		params.PageSize = 100
+	}
+
+	// Validate sort column
+	sortColumns := map[string]string{
+		"last_seen_at": "a.last_seen_at", "first_seen_at": "a.first_seen_at", "nickname": "a.nickname",
+		"videos_browsed": "videos_browsed", "videos_downloaded": "videos_downloaded",
+		"bytes_stored": "bytes_stored", "avg_likes": "avg_likes",
+	}
+	sortColumn, ok := sortColumns[params.SortBy]
+	if !ok {
+		params.SortBy = "last_seen_at"
+		sortColumn = sortColumns[params.SortBy]
+	}
+
+	whereClause := ""
+	var args []interface{}
+	if params.Query != "" {
+		pattern := "%" + escapeLike(params.Query) + "%"
+		whereClause = ` WHERE (a.id = ? OR a.nickname LIKE ? ESCAPE '\'
+			OR a.id IN (SELECT author_id FROM author_nicknames WHERE nickname LIKE ? ESCAPE '\'))`
+		args = append(args, params.Query, pattern, pattern)
+	}
+
+	var total int64
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM authors a"+whereClause, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count authors: %w", err)
+	}
+
+	sortOrder := "DESC"
+	if !params.SortDesc {
+		sortOrder = "ASC"
+	}
+	offset := (params.Page - 1) * params.PageSize
+	query := fmt.Sprintf("%s%s ORDER BY %s %s, a.id LIMIT ? OFFSET ?", authorStatsQuery, whereClause, sortColumn, sortOrder)
+	rows, err := r.db.Query(query, append(args, params.PageSize, offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list authors: %w", err)
+	}
+	defer rows.Close()
+
+	authors := []AuthorStats{}
+	for rows.Next() {
+		stats, err := scanAuthorStats(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan author: %w", err)
+		}
+		authors = append(authors, *stats)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list authors: %w", err)
+	}
+
+	return NewPagedResult(authors, total, params.Page, params.PageSize), nil
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Path 返回当前数据库文件路径，未初始化时返回空字符串
+func Path() string {
+	return dbPath
+}
+
+// Backup 使用 VACUUM INTO 将数据库的一致性快照写入 destPath，可以在应用运行时调用
+// 先写入临时文件再重命名，destPath 已存在时返回错误
+func Backup(destPath string) error {
+	if db == nil {
+		return fmt.Errorf("database is not initialized")
+	}
+	if _, err := os.Stat(destPath); err == nil {
+		return fmt.Errorf("backup file already exists: %s", destPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	tmpPath := destPath + ".tmp"
+	os.Remove(tmpPath)
+	if _, err := db.Exec("VACUUM INTO ?", tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+	if err := os.Rename(tmpPath, destPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to save backup: %w", err)
+	}
+	return nil
+}
+
+// IntegrityCheck 对当前数据库执行 PRAGMA integrity_check，数据库完好时返回空切片
+func IntegrityCheck() ([]string, error) {
+	return integrityCheck(db)
+}
+
+// integrityCheck 执行 PRAGMA integrity_check，返回发现的问题
+func integrityCheck(conn *sql.DB) ([]string, error) {
+	rows, err := conn.Query("PRAGMA integrity_check")
+	if err != nil {
+		return nil, fmt.Errorf("failed to check database integrity: %w", err)
+	}
+	defer rows.Close()
+
+	problems := []string{}
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return nil, fmt.Errorf("failed to scan integrity check result: %w", err)
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	return problems, rows.Err()
+}
+
+// InspectBackup 以只读方式打开备份文件，返回其已执行的迁移版本和完整性检查发现的问题
+func InspectBackup(path string) (applied map[int]bool, problems []string, err error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, fmt.Errorf("failed to open backup: %w", err)
+	}
+	src, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer src.Close()
+
+	problems, err = integrityCheck(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	applied, err = appliedVersions(src)
+	if err != nil {
+		return nil, problems, err
+	}
+	return applied, problems, nil
+}
+
+// RestoreFrom 使用 SQLite 在线备份 API 将备份文件的内容复制到当前数据库，然后运行迁移
+// 连接保持打开，已创建的 Repository 可以继续使用；调用前应先用 InspectBackup 检查备份
+func RestoreFrom(path string) error {
+	if db == nil {
+		return fmt.Errorf("database is not initialized")
+	}
+
+	src, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer src.Close()
+
+	ctx := context.Background()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer srcConn.Close()
+	destConn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer destConn.Close()
+
+	err = destConn.Raw(func(destDriverConn interface{}) error {
+		return srcConn.Raw(func(srcDriverConn interface{}) error {
+			dest, ok1 := destDriverConn.(*sqlite3.SQLiteConn)
+			source, ok2 := srcDriverConn.(*sqlite3.SQLiteConn)
+			if !ok1 || !ok2 {
+				return fmt.Errorf("unexpected sqlite driver connection")
+			}
+
+			backup, err := dest.Backup("main", source, "main")
+			if err != nil {
+				return err
+			}
+			if _, err := backup.Step(-1); err != nil {
+				backup.Close()
+				return err
+			}
+			return backup.Finish()
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to restore database: %w", err)
+	}
+
+	// 较旧的备份恢复后升级到当前架构
+	if err := runMigrations(); err != nil {
+		return fmt.Errorf("failed to migrate restored database: %w", err)
+	}
+	return nil
+}
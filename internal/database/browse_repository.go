@@ -17,6 +17,27 @@ func NewBrowseHistoryRepository() *BrowseHistoryRepository {
 	return &BrowseHistoryRepository{db: GetDB()}
 }
 
+// browseRecordColumns 查询浏览记录时使用的列
+const browseRecordColumns = `id, title, author, author_id, duration, size, COALESCE(resolution, '') as resolution, cover_url, video_url,
+			decrypt_key, browse_time, like_count, comment_count,
+			COALESCE(fav_count, 0) as fav_count, COALESCE(forward_count, 0) as forward_count, page_url,
+			created_at, updated_at`
+
+// scanBrowseRecord 将一行查询结果扫描为浏览记录
+func scanBrowseRecord(row rowScanner) (*BrowseRecord, error) {
+	record := &BrowseRecord{}
+	err := row.Scan(
+		&record.ID, &record.Title, &record.Author, &record.AuthorID,
+		&record.Duration, &record.Size, &record.Resolution, &record.CoverURL, &record.VideoURL,
+		&record.DecryptKey, &record.BrowseTime, &record.LikeCount, &record.CommentCount,
+		&record.FavCount, &record.ForwardCount, &record.PageURL, &record.CreatedAt, &record.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
 // Create 插入新的浏览记录
 func (r *BrowseHistoryRepository) Create(record *BrowseRecord) error {
 	now := time.Now()
@@ -215,7 +236,7 @@ func (r *BrowseHistoryRepository) List(params *PaginationParams) (*PagedResult[B
 	return NewPagedResult(records, total, params.Page, params.PageSize), nil
 }
 
-// Search 根据标题或作者搜索浏览记录
+// Search 根据标题或作者搜索浏览记录，启用 FTS5 时使用全文索引
 func (r *BrowseHistoryRepository) Search(query string, params *PaginationParams) (*PagedResult[BrowseRecord], error) {
 	// Set defaults
 	if params.Page < 1 {
@@ -228,14 +249,11 @@ func (r *BrowseHistoryRepository) Search(query string, params *PaginationParams)
 		params.PageSize = 100
 	}
 
-	searchPattern := "%" + query + "%"
+	condition, conditionArgs := searchCondition(r.db, browseSearchSource, query)
 
 	// Count total
 	var total int64
-	err := r.db.QueryRow(
-		"SELECT COUNT(*) FROM browse_history WHERE title LIKE ? OR author LIKE ?",
-		searchPattern, searchPattern,
-	).Scan(&total)
+	err := r.db.QueryRow("SELECT COUNT(*) FROM browse_history WHERE "+condition, conditionArgs...).Scan(&total)
 	if err != nil {
 		return nil, fmt.Errorf("failed to count search results: %w", err)
 	}
@@ -243,39 +261,27 @@ func (r *BrowseHistoryRepository) Search(query string, params *PaginationParams)
 	// Build query
 	offset := (params.Page - 1) * params.PageSize
 	sqlQuery := `
-		SELECT id, title, author, author_id, duration, size, COALESCE(resolution, '') as resolution, cover_url, video_url,
-			decrypt_key, browse_time, like_count, comment_count, 
-			COALESCE(fav_count, 0) as fav_count, COALESCE(forward_count, 0) as forward_count, page_url,
-			created_at, updated_at
+		SELECT ` + browseRecordColumns + `
 		FROM browse_history
-		WHERE title LIKE ? OR author LIKE ?
+		WHERE ` + condition + `
 		ORDER BY browse_time DESC
 		LIMIT ? OFFSET ?
 	`
 
-	rows, err := r.db.Query(sqlQuery, searchPattern, searchPattern, params.PageSize, offset)
+	args := append(conditionArgs, params.PageSize, offset)
+	rows, err := r.db.Query(sqlQuery, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search browse records: %w", err)
 	}
 	defer rows.Close()
 
-	var records []BrowseRecord
+	records := []BrowseRecord{}
 	for rows.Next() {
-		var record BrowseRecord
-		err := rows.Scan(
-			&record.ID, &record.Title, &record.Author, &record.AuthorID,
-			&record.Duration, &record.Size, &record.Resolution, &record.CoverURL, &record.VideoURL,
-			&record.DecryptKey, &record.BrowseTime, &record.LikeCount, &record.CommentCount,
-			&record.FavCount, &record.ForwardCount, &record.PageURL, &record.CreatedAt, &record.UpdatedAt,
-		)
+		record, err := scanBrowseRecord(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan browse record: %w", err)
 		}
-		records = append(records, record)
-	}
-
-	if records == nil {
-		records = []BrowseRecord{}
+		records = append(records, *record)
 	}
 
 	return NewPagedResult(records, total, params.Page, params.PageSize), nil
@@ -344,6 +350,16 @@ func (r *BrowseHistoryRepository) DeleteBefore(date time.Time) (int64, error) {
 	return result.RowsAffected()
 }
 
+// CountBefore 返回指定日期前的记录数
+func (r *BrowseHistoryRepository) CountBefore(date time.Time) (int64, error) {
+	var count int64
+	err := r.db.QueryRow("SELECT COUNT(*) FROM browse_history WHERE browse_time < ?", date).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count old browse records: %w", err)
+	}
+	return count, nil
+}
+
 // GetAll 获取所有浏览记录（用于导出）
 func (r *BrowseHistoryRepository) GetAll() ([]BrowseRecord, error) {
 	query := `
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// CollectionRepository 处理合集数据库操作
+type CollectionRepository struct {
+	db *sql.DB
+}
+
+// NewCollectionRepository 创建一个新的 CollectionRepository
+func NewCollectionRepository() *CollectionRepository {
+	return &CollectionRepository{db: GetDB()}
+}
+
+// collectionColumns 查询合集时使用的列（包含合集中的下载记录数）
+const collectionColumns = `c.id, c.name, COALESCE(c.description, '') as description,
+			(SELECT COUNT(*) FROM collection_records cr WHERE cr.collection_id = c.id) as record_count,
+			c.created_at, c.updated_at`
+
+// scanCollection 将一行查询结果扫描为合集
+func scanCollection(row rowScanner) (*Collection, error) {
+	c := &Collection{}
+	if err := row.Scan(&c.ID, &c.Name, &c.Description, &c.RecordCount, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Create 插入新合集
+func (r *CollectionRepository) Create(c *Collection) error {
+	now := time.Now()
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = now
+	}
+	c.UpdatedAt = now
+	_, err := r.db.Exec("INSERT INTO collections (id, name, description, created_at, updated_at) VALUES (?, ?, ?, ?, ?)",
+		c.ID, c.Name, c.Description, c.CreatedAt, c.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create collection: %w", err)
+	}
+	return nil
+}
+
+// GetByID 根据 ID 获取合集，不存在时返回 nil
+func (r *CollectionRepository) GetByID(id string) (*Collection, error) {
+	return r.getOne("c.id = ?", id)
+}
+
+// GetByName 根据名称（不区分大小写）获取合集，不存在时返回 nil
+func (r *CollectionRepository) GetByName(name string) (*Collection, error) {
+	return r.getOne("c.name = ?", name)
+}
+
+// getOne 按条件获取单个合集
+func (r *CollectionRepository) getOne(condition string, arg interface{}) (*Collection, error) {
+	c, err := scanCollection(r.db.QueryRow("SELECT "+collectionColumns+" FROM collections c WHERE "+condition, arg))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection: %w", err)
+	}
+	return c, nil
+}
+
+// List 获取所有合集，按名称排序
+func (r *CollectionRepository) List() ([]Collection, error) {
+	rows, err := r.db.Query("SELECT " + collectionColumns + " FROM collections c ORDER BY c.name COLLATE NOCASE")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+	defer rows.Close()
+
+	collections := []Collection{}
+	for rows.Next() {
+		c, err := scanCollection(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan collection: %w", err)
+		}
+		collections = append(collections, *c)
+	}
+	return collections, rows.Err()
+}
+
+// Update 更新合集的名称和描述
+func (r *CollectionRepository) Update(c *Collection) error {
+	c.UpdatedAt = time.Now()
+	result, err := r.db.Exec("UPDATE collections SET name = ?, description = ?, updated_at = ? WHERE id = ?",
+		c.Name, c.Description, c.UpdatedAt, c.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update collection: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("collection not found: %s", c.ID)
+	}
+	return nil
+}
+
+// Delete 删除合集（不删除其中的下载记录）
+func (r *CollectionRepository) Delete(id string) error {
+	result, err := r.db.Exec("DELETE FROM collections WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete collection: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("collection not found: %s", id)
+	}
+	return nil
+}
+
+// AddRecords 将下载记录加入合集，返回新加入的记录数（已在合集中的记录忽略）
+func (r *CollectionRepository) AddRecords(collectionID string, recordIDs []string) (int64, error) {
+	now := time.Now()
+	return r.updateRecords(collectionID, recordIDs, func(tx *sql.Tx, recordID string) (sql.Result, error) {
+		return tx.Exec("INSERT OR IGNORE INTO collection_records (collection_id, record_id, added_at) VALUES (?, ?, ?)",
+			collectionID, recordID, now)
+	})
+}
+
+// RemoveRecords 从合集中移除下载记录，返回移除的记录数
+func (r *CollectionRepository) RemoveRecords(collectionID string, recordIDs []string) (int64, error) {
+	return r.updateRecords(collectionID, recordIDs, func(tx *sql.Tx, recordID string) (sql.Result, error) {
+		return tx.Exec("DELETE FROM collection_records WHERE collection_id = ? AND record_id = ?", collectionID, recordID)
+	})
+}
+
+// updateRecords 在一个事务中对每条记录执行 exec，有变化时更新合集的修改时间
+func (r *CollectionRepository) updateRecords(collectionID string, recordIDs []string, exec func(tx *sql.Tx, recordID string) (sql.Result, error)) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var changed int64
+	for _, recordID := range recordIDs {
+		result, err := exec(tx, recordID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update collection records: %w", err)
+		}
+		rows, _ := result.RowsAffected()
+		changed += rows
+	}
+
+	if changed > 0 {
+		if _, err := tx.Exec("UPDATE collections SET updated_at = ? WHERE id = ?", time.Now(), collectionID); err != nil {
+			return 0, fmt.Errorf("failed to update collection: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit collection records: %w", err)
+	}
+	return changed, nil
+}
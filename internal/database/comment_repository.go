@@ -0,0 +1,112 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// CommentRepository 处理评论数据库操作
+type CommentRepository struct {
+	db *sql.DB
+}
+
+// NewCommentRepository 创建一个新的 CommentRepository
+func NewCommentRepository() *CommentRepository {
+	return &CommentRepository{db: GetDB()}
+}
+
+// commentColumns 查询评论时使用的列
+const commentColumns = `id, video_id, COALESCE(video_title, '') as video_title, COALESCE(parent_id, '') as parent_id,
+			COALESCE(nickname, '') as nickname, content, COALESCE(like_count, 0) as like_count,
+			COALESCE(ip_location, '') as ip_location, create_time, saved_at`
+
+// scanComment 将一行查询结果扫描为评论
+func scanComment(row rowScanner) (*Comment, error) {
+	comment := &Comment{}
+	var createTime sql.NullTime
+	err := row.Scan(
+		&comment.ID, &comment.VideoID, &comment.VideoTitle, &comment.ParentID,
+		&comment.Nickname, &comment.Content, &comment.LikeCount,
+		&comment.IPLocation, &createTime, &comment.SavedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if createTime.Valid {
+		comment.CreateTime = createTime.Time
+	}
+	return comment, nil
+}
+
+// SaveMany 在一个事务中保存评论，已存在的评论更新内容和点赞数
+func (r *CommentRepository) SaveMany(comments []Comment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO comments (
+			id, video_id, video_title, parent_id, nickname, content, like_count, ip_location, create_time, saved_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			video_title = excluded.video_title, nickname = excluded.nickname, content = excluded.content,
+			like_count = excluded.like_count, ip_location = excluded.ip_location, saved_at = excluded.saved_at
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare comment insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, c := range comments {
+		if c.SavedAt.IsZero() {
+			c.SavedAt = time.Now()
+		}
+		_, err := stmt.Exec(
+			c.ID, c.VideoID, c.VideoTitle, c.ParentID, c.Nickname, c.Content,
+			c.LikeCount, c.IPLocation, nullableTime(c.CreateTime), c.SavedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save comment: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit comments: %w", err)
+	}
+	return nil
+}
+
+// ListByVideo 获取视频的所有评论，按点赞数排序
+func (r *CommentRepository) ListByVideo(videoID string) ([]Comment, error) {
+	rows, err := r.db.Query("SELECT "+commentColumns+" FROM comments WHERE video_id = ? ORDER BY like_count DESC, create_time ASC", videoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list comments: %w", err)
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan comment: %w", err)
+		}
+		comments = append(comments, *comment)
+	}
+	return comments, rows.Err()
+}
+
+// Count 返回评论总数
+func (r *CommentRepository) Count() (int64, error) {
+	var count int64
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM comments").Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count comments: %w", err)
+	}
+	return count, nil
+}
@@ -13,6 +13,7 @@ import (
 // DB 是全局数据库实例
 var (
 	db          *sql.DB
+	dbPath      string
 	initialized bool
 	initMu      sync.Mutex
 )
@@ -59,6 +60,7 @@ func Initialize(cfg *Config) error {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
+	dbPath = cfg.DBPath
 	initialized = true
 	return nil
 }
@@ -75,6 +77,7 @@ func Close() error {
 	if db != nil {
 		err := db.Close()
 		db = nil
+		dbPath = ""
 		initialized = false
 		return err
 	}
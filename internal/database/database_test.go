@@ -2,8 +2,10 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -186,6 +188,80 @@ func TestDownloadRecordRepository(t *testing.T) {
 	if count != 1 {
 		t.Errorf("Expected 1 today's download, got %d", count)
 	}
+
+	// 测试内容哈希和重复分组
+	if retrieved.ContentHash != "" {
+		t.Errorf("Expected empty content hash, got '%s'", retrieved.ContentHash)
+	}
+	missing, err := repo.GetWithoutContentHash()
+	if err != nil {
+		t.Fatalf("Failed to get records without content hash: %v", err)
+	}
+	if len(missing) != 1 {
+		t.Errorf("Expected 1 record without content hash, got %d", len(missing))
+	}
+	if err := repo.SetContentHash("download-1", "abc123"); err != nil {
+		t.Fatalf("Failed to set content hash: %v", err)
+	}
+
+	duplicate := *record
+	duplicate.ID = "download-2"
+	duplicate.FilePath = "/downloads/video (1).mp4"
+	duplicate.ContentHash = "abc123"
+	if err := repo.Create(&duplicate); err != nil {
+		t.Fatalf("Failed to create duplicate record: %v", err)
+	}
+
+	byVideo, err := repo.FindByVideoID("video-1")
+	if err != nil {
+		t.Fatalf("Failed to find by video ID: %v", err)
+	}
+	if len(byVideo) != 2 {
+		t.Errorf("Expected 2 records for video, got %d", len(byVideo))
+	}
+
+	matches, err := repo.FindByContentHash("abc123")
+	if err != nil {
+		t.Fatalf("Failed to find by content hash: %v", err)
+	}
+	if len(matches) != 2 {
+		t.Errorf("Expected 2 records with content hash, got %d", len(matches))
+	}
+
+	groups, err := repo.GetDuplicateGroups()
+	if err != nil {
+		t.Fatalf("Failed to get duplicate groups: %v", err)
+	}
+	if len(groups) != 1 {
+		t.Fatalf("Expected 1 duplicate group, got %d", len(groups))
+	}
+	if groups[0].Count != 2 || groups[0].TotalSize != 2*record.FileSize {
+		t.Errorf("Expected group of 2 records totalling %d bytes, got %d records, %d bytes", 2*record.FileSize, groups[0].Count, groups[0].TotalSize)
+	}
+
+	// 测试加星和最近播放时间
+	if err := repo.SetStarred("download-1", true); err != nil {
+		t.Fatalf("Failed to star record: %v", err)
+	}
+	if err := repo.SetStarred("missing", true); err == nil {
+		t.Error("Expected error when starring a missing record")
+	}
+	playedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+	updated, err := repo.MarkPlayedByPath("/downloads/video.mp4", playedAt)
+	if err != nil {
+		t.Fatalf("Failed to mark played: %v", err)
+	}
+	if updated != 1 {
+		t.Errorf("Expected 1 record marked played, got %d", updated)
+	}
+	retrieved, _ = repo.GetByID("download-1")
+	if !retrieved.Starred || !retrieved.LastPlayedAt.Equal(playedAt) {
+		t.Errorf("Expected starred record played at %v, got starred=%v played at %v", playedAt, retrieved.Starred, retrieved.LastPlayedAt)
+	}
+	retrieved, _ = repo.GetByID("download-2")
+	if retrieved.Starred || !retrieved.LastPlayedAt.IsZero() {
+		t.Errorf("Expected unstarred, never played duplicate, got starred=%v played at %v", retrieved.Starred, retrieved.LastPlayedAt)
+	}
 }
 
 func TestQueueRepository(t *testing.T) {
@@ -233,6 +309,81 @@ func TestQueueRepository(t *testing.T) {
 		t.Errorf("Expected status '%s', got '%s'", QueueStatusDownloading, retrieved.Status)
 	}
 
+	// 测试分片进度位图
+	err = repo.UpdateChunkProgress("queue-1", "101", 5000000, 2, 1024)
+	if err != nil {
+		t.Fatalf("Failed to update chunk progress: %v", err)
+	}
+
+	retrieved, _ = repo.GetByID("queue-1")
+	if retrieved.ChunksBitmap != "101" || retrieved.ChunksCompleted != 2 {
+		t.Errorf("Expected bitmap '101' with 2 chunks, got '%s' with %d", retrieved.ChunksBitmap, retrieved.ChunksCompleted)
+	}
+
+	// 测试更新下载地址
+	err = repo.UpdateVideoSource("queue-1", "http://example.com/fresh.mp4", "12345")
+	if err != nil {
+		t.Fatalf("Failed to update video source: %v", err)
+	}
+
+	retrieved, _ = repo.GetByID("queue-1")
+	if retrieved.VideoURL != "http://example.com/fresh.mp4" || retrieved.DecryptKey != "12345" {
+		t.Errorf("Expected refreshed video source, got '%s' / '%s'", retrieved.VideoURL, retrieved.DecryptKey)
+	}
+
+	// 测试元数据写入开关
+	if retrieved.EmbedMetadata {
+		t.Error("Expected embed metadata to be disabled for new item")
+	}
+	err = repo.SetEmbedMetadata("queue-1", true)
+	if err != nil {
+		t.Fatalf("Failed to set embed metadata: %v", err)
+	}
+
+	retrieved, _ = repo.GetByID("queue-1")
+	if !retrieved.EmbedMetadata {
+		t.Error("Expected embed metadata to be enabled")
+	}
+
+	// 测试重试历史（只保留最近的 MaxRetryHistory 条）
+	if len(retrieved.RetryHistory) != 0 {
+		t.Errorf("Expected empty retry history, got %d entries", len(retrieved.RetryHistory))
+	}
+	for i := 1; i <= MaxRetryHistory+2; i++ {
+		err = repo.AppendRetryAttempt("queue-1", RetryAttempt{
+			Attempt: i,
+			Time:    time.Now(),
+			Error:   "unexpected status code: 503",
+			Class:   "transient",
+			DelayMs: 1000,
+		})
+		if err != nil {
+			t.Fatalf("Failed to append retry attempt: %v", err)
+		}
+	}
+
+	retrieved, _ = repo.GetByID("queue-1")
+	if len(retrieved.RetryHistory) != MaxRetryHistory {
+		t.Fatalf("Expected %d retry attempts, got %d", MaxRetryHistory, len(retrieved.RetryHistory))
+	}
+	if retrieved.RetryHistory[0].Attempt != 3 || retrieved.RetryHistory[MaxRetryHistory-1].Attempt != MaxRetryHistory+2 {
+		t.Errorf("Expected the oldest attempts to be dropped, got attempts %d..%d",
+			retrieved.RetryHistory[0].Attempt, retrieved.RetryHistory[MaxRetryHistory-1].Attempt)
+	}
+
+	// 整体更新时保留重试历史
+	if err := repo.Update(retrieved); err != nil {
+		t.Fatalf("Failed to update queue item: %v", err)
+	}
+	retrieved, _ = repo.GetByID("queue-1")
+	if len(retrieved.RetryHistory) != MaxRetryHistory {
+		t.Errorf("Expected retry history to survive update, got %d entries", len(retrieved.RetryHistory))
+	}
+
+	if err := repo.AppendRetryAttempt("missing", RetryAttempt{Attempt: 1}); err == nil {
+		t.Error("Expected error when appending to a missing item")
+	}
+
 	// 测试重新排序
 	item2 := &QueueItem{
 		ID:        "queue-2",
@@ -261,6 +412,87 @@ func TestQueueRepository(t *testing.T) {
 	if len(items) != 2 {
 		t.Errorf("Expected 2 items, got %d", len(items))
 	}
+
+	// 测试批量下载项目
+	batchItem := &QueueItem{
+		ID:              "queue-batch-1",
+		VideoID:         "video-3",
+		Title:           "Batch Item",
+		Author:          "Author",
+		VideoURL:        "https://example.com/video3.mp4",
+		Status:          QueueStatusPending,
+		AddedTime:       time.Now(),
+		ChunkSize:       10485760,
+		BatchID:         "batch-1",
+		PageSource:      "batch_feed",
+		DecryptorPrefix: "AAEC",
+		PrefixLen:       3,
+		BatchStats:      BatchStats{Duration: "00:22", LikeCount: "12", IPRegion: "广东"},
+	}
+	if err := repo.Add(batchItem); err != nil {
+		t.Fatalf("Failed to add batch item: %v", err)
+	}
+
+	batchItems, err := repo.ListBatch()
+	if err != nil {
+		t.Fatalf("Failed to list batch items: %v", err)
+	}
+	if len(batchItems) != 1 {
+		t.Fatalf("Expected 1 batch item, got %d", len(batchItems))
+	}
+	if batchItems[0].DecryptorPrefix != "AAEC" || batchItems[0].PrefixLen != 3 || batchItems[0].PageSource != "batch_feed" {
+		t.Errorf("Batch fields not preserved: %+v", batchItems[0])
+	}
+	if batchItems[0].BatchStats != batchItem.BatchStats {
+		t.Errorf("Expected batch stats %+v, got %+v", batchItem.BatchStats, batchItems[0].BatchStats)
+	}
+
+	removed, err := repo.RemoveBatch()
+	if err != nil {
+		t.Fatalf("Failed to remove batch items: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected 1 removed batch item, got %d", removed)
+	}
+	count, _ := repo.Count()
+	if count != 2 {
+		t.Errorf("Expected regular items to remain after removing batch, got %d items", count)
+	}
+}
+
+func TestQueueRepository_TransitionStatus(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewQueueRepository()
+	item := &QueueItem{ID: "queue-1", VideoID: "video-1", Title: "Queue Item", Status: QueueStatusPending, AddedTime: time.Now()}
+	if err := repo.Add(item); err != nil {
+		t.Fatalf("Failed to add queue item: %v", err)
+	}
+
+	// 下载协程启动前项目已被暂停，不应覆盖为 downloading
+	if err := repo.UpdateStatus("queue-1", QueueStatusPaused); err != nil {
+		t.Fatalf("Failed to pause queue item: %v", err)
+	}
+	if ok, err := repo.TransitionStatus("queue-1", QueueStatusPending, QueueStatusDownloading); err != nil || ok {
+		t.Fatalf("Expected no transition from paused, got %v (%v)", ok, err)
+	}
+	if got, _ := repo.GetByID("queue-1"); got.Status != QueueStatusPaused {
+		t.Fatalf("Expected status paused, got %s", got.Status)
+	}
+
+	if err := repo.UpdateStatus("queue-1", QueueStatusPending); err != nil {
+		t.Fatalf("Failed to reset queue item: %v", err)
+	}
+	if ok, err := repo.TransitionStatus("queue-1", QueueStatusPending, QueueStatusDownloading); err != nil || !ok {
+		t.Fatalf("Expected transition from pending, got %v (%v)", ok, err)
+	}
+	if got, _ := repo.GetByID("queue-1"); got.Status != QueueStatusDownloading {
+		t.Fatalf("Expected status downloading, got %s", got.Status)
+	}
+	if ok, _ := repo.TransitionStatus("missing", QueueStatusPending, QueueStatusDownloading); ok {
+		t.Fatal("Expected no transition for missing item")
+	}
 }
 
 func TestSettingsRepository(t *testing.T) {
@@ -315,4 +547,861 @@ func TestSettingsRepository(t *testing.T) {
 	if err == nil {
 		t.Error("Expected validation error for high concurrent limit")
 	}
+
+	// 测试文件名模板验证
+	templateSettings := DefaultSettings()
+	templateSettings.FilenameTemplate = "{author}/{unknown}.{ext}"
+	if err := repo.Validate(templateSettings); err == nil {
+		t.Error("Expected validation error for unknown template placeholder")
+	}
+
+	templateSettings.FilenameTemplate = "{author}/{yyyy-mm}/{date}_{title}_{videoId}.{ext}"
+	if err := repo.SaveAndValidate(templateSettings); err != nil {
+		t.Fatalf("Failed to save filename template: %v", err)
+	}
+	loaded, _ = repo.Load()
+	if loaded.FilenameTemplate != templateSettings.FilenameTemplate {
+		t.Errorf("Expected filename template '%s', got '%s'", templateSettings.FilenameTemplate, loaded.FilenameTemplate)
+	}
+
+	// 测试去重策略验证
+	if loaded.DedupePolicy != DedupePolicyKeepBoth {
+		t.Errorf("Expected default dedupe policy '%s', got '%s'", DedupePolicyKeepBoth, loaded.DedupePolicy)
+	}
+	templateSettings.DedupePolicy = "delete_all"
+	if err := repo.Validate(templateSettings); err == nil {
+		t.Error("Expected validation error for unknown dedupe policy")
+	}
+	templateSettings.DedupePolicy = DedupePolicyHardlink
+	if err := repo.SaveAndValidate(templateSettings); err != nil {
+		t.Fatalf("Failed to save dedupe policy: %v", err)
+	}
+	loaded, _ = repo.Load()
+	if loaded.DedupePolicy != DedupePolicyHardlink {
+		t.Errorf("Expected dedupe policy '%s', got '%s'", DedupePolicyHardlink, loaded.DedupePolicy)
+	}
+	// 测试磁盘空间阈值和下载库容量
+	if loaded.MinFreeSpace != 1024*1024*1024 || loaded.MaxLibrarySize != 0 {
+		t.Errorf("Expected default disk limits 1GB/unlimited, got %d/%d", loaded.MinFreeSpace, loaded.MaxLibrarySize)
+	}
+	loaded.MaxLibrarySize = -1
+	if err := repo.Validate(loaded); err == nil {
+		t.Error("Expected validation error for negative library size")
+	}
+	loaded.MinFreeSpace = 0
+	loaded.MaxLibrarySize = 50 * 1024 * 1024 * 1024
+	if err := repo.SaveAndValidate(loaded); err != nil {
+		t.Fatalf("Failed to save disk limits: %v", err)
+	}
+	loaded, _ = repo.Load()
+	if loaded.MinFreeSpace != 0 || loaded.MaxLibrarySize != 50*1024*1024*1024 {
+		t.Errorf("Expected disk limits 0/50GB, got %d/%d", loaded.MinFreeSpace, loaded.MaxLibrarySize)
+	}
+
+	// 测试保留策略
+	if loaded.RetentionEvictBy != RetentionEvictLeastPlayed || loaded.RetentionMaxSize != 0 || loaded.RetentionKeepPerAuthor != 0 {
+		t.Errorf("Expected default retention least_played/0/0, got %s/%d/%d", loaded.RetentionEvictBy, loaded.RetentionMaxSize, loaded.RetentionKeepPerAuthor)
+	}
+	loaded.RetentionEvictBy = "random"
+	if err := repo.Validate(loaded); err == nil {
+		t.Error("Expected validation error for unknown retention evict order")
+	}
+	loaded.RetentionEvictBy = RetentionEvictOldest
+	loaded.RetentionKeepPerAuthor = -1
+	if err := repo.Validate(loaded); err == nil {
+		t.Error("Expected validation error for negative per-author limit")
+	}
+	loaded.RetentionKeepPerAuthor = 10
+	loaded.RetentionMaxSize = 100 * 1024 * 1024 * 1024
+	if err := repo.SaveAndValidate(loaded); err != nil {
+		t.Fatalf("Failed to save retention policy: %v", err)
+	}
+	loaded, _ = repo.Load()
+	if loaded.RetentionEvictBy != RetentionEvictOldest || loaded.RetentionMaxSize != 100*1024*1024*1024 || loaded.RetentionKeepPerAuthor != 10 {
+		t.Errorf("Expected retention oldest/100GB/10, got %s/%d/%d", loaded.RetentionEvictBy, loaded.RetentionMaxSize, loaded.RetentionKeepPerAuthor)
+	}
+
+	// 测试回收站保留天数
+	if loaded.TrashRetentionDays != 30 {
+		t.Errorf("Expected default trash retention 30 days, got %d", loaded.TrashRetentionDays)
+	}
+	loaded.TrashRetentionDays = -1
+	if err := repo.Validate(loaded); err == nil {
+		t.Error("Expected validation error for negative trash retention")
+	}
+	loaded.TrashRetentionDays = 30
+
+	// 测试数据库备份设置
+	if loaded.BackupIntervalHours != 24 || loaded.BackupKeep != 7 {
+		t.Errorf("Expected default backups every 24 hours keeping 7, got %d/%d", loaded.BackupIntervalHours, loaded.BackupKeep)
+	}
+	loaded.BackupKeep = 0
+	if err := repo.Validate(loaded); err == nil {
+		t.Error("Expected validation error for keeping no backups")
+	}
+	loaded.BackupKeep = 3
+	loaded.BackupIntervalHours = 0
+	if err := repo.SaveAndValidate(loaded); err != nil {
+		t.Fatalf("Failed to save backup settings: %v", err)
+	}
+	loaded, _ = repo.Load()
+	if loaded.BackupIntervalHours != 0 || loaded.BackupKeep != 3 {
+		t.Errorf("Expected backup settings 0/3, got %d/%d", loaded.BackupIntervalHours, loaded.BackupKeep)
+	}
+}
+
+func TestTrashRepository(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewTrashRepository()
+	now := time.Now()
+
+	record := &DownloadRecord{ID: "download-1", VideoID: "video-1", Title: "Deleted Video", Author: "Author", Status: DownloadStatusCompleted}
+	items := []*TrashItem{
+		{ID: "trash-old", Title: "Old", OriginalPath: "/downloads/old.mp4", TrashPath: "/downloads/.trash/trash-old", Files: []string{"/downloads/old.mp4"}, DeletedAt: now.AddDate(0, 0, -40)},
+		{ID: "trash-new", RecordID: record.ID, Title: record.Title, OriginalPath: "/downloads/new.mp4", TrashPath: "/downloads/.trash/trash-new",
+			FileSize: 1024, Files: []string{"/downloads/new.mp4", "/downloads/new.nfo"}, Record: record, DeletedAt: now},
+	}
+	for _, item := range items {
+		if err := repo.Create(item); err != nil {
+			t.Fatalf("Failed to create trash item: %v", err)
+		}
+	}
+
+	listed, err := repo.List()
+	if err != nil {
+		t.Fatalf("Failed to list trash items: %v", err)
+	}
+	if len(listed) != 2 || listed[0].ID != "trash-new" {
+		t.Fatalf("Expected 2 items with newest first, got %+v", listed)
+	}
+
+	got, err := repo.GetByID("trash-new")
+	if err != nil {
+		t.Fatalf("Failed to get trash item: %v", err)
+	}
+	if len(got.Files) != 2 || got.Record == nil || got.Record.Title != "Deleted Video" {
+		t.Errorf("Expected files and record snapshot to round-trip, got files=%v record=%+v", got.Files, got.Record)
+	}
+	if old, _ := repo.GetByID("trash-old"); old.Record != nil {
+		t.Errorf("Expected no record snapshot, got %+v", old.Record)
+	}
+
+	expired, err := repo.GetBefore(now.AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("Failed to get expired trash items: %v", err)
+	}
+	if len(expired) != 1 || expired[0].ID != "trash-old" {
+		t.Errorf("Expected only the old item to be expired, got %+v", expired)
+	}
+
+	if err := repo.Delete("trash-old"); err != nil {
+		t.Fatalf("Failed to delete trash item: %v", err)
+	}
+	if err := repo.Delete("trash-old"); err == nil {
+		t.Error("Expected error when deleting a missing trash item")
+	}
+	if missing, _ := repo.GetByID("trash-old"); missing != nil {
+		t.Errorf("Expected deleted item to be gone, got %+v", missing)
+	}
+}
+
+func TestCommentRepository(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewCommentRepository()
+	comments := []Comment{
+		{ID: "c1", VideoID: "video-1", VideoTitle: "Cooking Show", Nickname: "Alice", Content: "Great recipe", LikeCount: 5, CreateTime: time.Now()},
+		{ID: "c2", VideoID: "video-1", ParentID: "c1", Nickname: "Bob", Content: "Agreed", LikeCount: 1},
+		{ID: "c3", VideoID: "video-2", Nickname: "Carol", Content: "Other video"},
+	}
+	if err := repo.SaveMany(comments); err != nil {
+		t.Fatalf("Failed to save comments: %v", err)
+	}
+
+	// 再次保存同一条评论时更新点赞数
+	comments[1].LikeCount = 10
+	if err := repo.SaveMany(comments[1:2]); err != nil {
+		t.Fatalf("Failed to update comment: %v", err)
+	}
+
+	listed, err := repo.ListByVideo("video-1")
+	if err != nil {
+		t.Fatalf("Failed to list comments: %v", err)
+	}
+	if len(listed) != 2 || listed[0].ID != "c2" || listed[0].ParentID != "c1" {
+		t.Fatalf("Expected 2 comments ordered by likes, got %+v", listed)
+	}
+	if listed[1].CreateTime.IsZero() || !listed[0].CreateTime.IsZero() {
+		t.Errorf("Expected create time to round-trip only when set, got %+v", listed)
+	}
+
+	count, err := repo.Count()
+	if err != nil {
+		t.Fatalf("Failed to count comments: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 comments, got %d", count)
+	}
+}
+
+func TestSearchRepository(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	browseRepo := NewBrowseHistoryRepository()
+	downloadRepo := NewDownloadRecordRepository()
+	commentRepo := NewCommentRepository()
+	repo := NewSearchRepository()
+	now := time.Now()
+
+	for i, title := range []string{"Mountain hiking guide", "City walk", "Hiking with dogs"} {
+		record := &BrowseRecord{ID: fmt.Sprintf("browse-%d", i), Title: title, Author: "Traveler", BrowseTime: now.Add(time.Duration(i) * time.Minute)}
+		if err := browseRepo.Create(record); err != nil {
+			t.Fatalf("Failed to create browse record: %v", err)
+		}
+	}
+	download := &DownloadRecord{ID: "download-1", VideoID: "video-1", Title: "Old title", Author: "Chef Wang", Status: DownloadStatusCompleted, DownloadTime: now}
+	if err := downloadRepo.Create(download); err != nil {
+		t.Fatalf("Failed to create download record: %v", err)
+	}
+	download.Title = "Noodle recipe"
+	if err := downloadRepo.Update(download); err != nil {
+		t.Fatalf("Failed to update download record: %v", err)
+	}
+	if err := commentRepo.SaveMany([]Comment{
+		{ID: "c1", VideoID: "video-1", VideoTitle: "Noodle recipe", Nickname: "Alice", Content: "The noodles look delicious"},
+		{ID: "c2", VideoID: "video-1", VideoTitle: "Noodle recipe", Nickname: "Bob", Content: "好吃的面条"},
+	}); err != nil {
+		t.Fatalf("Failed to save comments: %v", err)
+	}
+
+	browse, err := repo.SearchBrowse("hiking", &PaginationParams{Page: 1, PageSize: 1})
+	if err != nil {
+		t.Fatalf("Failed to search browse history: %v", err)
+	}
+	if browse.Total != 2 || browse.TotalPages != 2 || len(browse.Items) != 1 {
+		t.Fatalf("Expected 2 paged hits, got total=%d pages=%d items=%d", browse.Total, browse.TotalPages, len(browse.Items))
+	}
+	if hl := browse.Items[0].Highlights["title"]; !strings.Contains(hl, "<mark>") || !strings.Contains(strings.ToLower(hl), "hiking</mark>") {
+		t.Errorf("Expected highlighted title, got %q", hl)
+	}
+
+	// 更新后的标题可以搜到，旧标题不再匹配
+	downloads, err := repo.SearchDownloads("noodle", &PaginationParams{})
+	if err != nil {
+		t.Fatalf("Failed to search downloads: %v", err)
+	}
+	if downloads.Total != 1 || downloads.Items[0].Item.ID != "download-1" {
+		t.Fatalf("Expected the updated download to match, got %+v", downloads)
+	}
+	if stale, _ := repo.SearchDownloads("Old title", &PaginationParams{}); stale.Total != 0 {
+		t.Errorf("Expected old title not to match after update, got %d hits", stale.Total)
+	}
+
+	// 使用相同 ID 再次创建时覆盖原记录，全文索引中不留下旧条目
+	overwrite := &DownloadRecord{ID: "download-1", VideoID: "video-1", Title: "Noodle recipe", Author: "Chef Wang", Status: DownloadStatusCompleted, DownloadTime: now}
+	if err := downloadRepo.Create(overwrite); err != nil {
+		t.Fatalf("Failed to overwrite download record: %v", err)
+	}
+	if fts5Supported() {
+		var entries int
+		if err := db.QueryRow("SELECT COUNT(*) FROM download_records_fts").Scan(&entries); err != nil {
+			t.Fatalf("Failed to count index entries: %v", err)
+		}
+		if entries != 1 {
+			t.Errorf("Expected 1 index entry after overwrite, got %d", entries)
+		}
+	}
+
+	comments, err := repo.SearchComments("noodle alice", &PaginationParams{})
+	if err != nil {
+		t.Fatalf("Failed to search comments: %v", err)
+	}
+	if comments.Total != 1 || comments.Items[0].Item.ID != "c1" {
+		t.Fatalf("Expected all terms to match one comment, got %+v", comments)
+	}
+	if hl := comments.Items[0].Highlights["nickname"]; hl != "<mark>Alice</mark>" {
+		t.Errorf("Expected highlighted nickname, got %q", hl)
+	}
+
+	// 少于 3 个字符的关键词使用 LIKE 搜索
+	short, err := repo.SearchComments("面条", &PaginationParams{})
+	if err != nil {
+		t.Fatalf("Failed to search short term: %v", err)
+	}
+	if short.Total != 1 || short.Items[0].Highlights["content"] != "好吃的<mark>面条</mark>" {
+		t.Errorf("Expected short term to match with highlight, got %+v", short)
+	}
+
+	// 高亮结果中的原文被转义，只保留 <mark> 标记
+	if err := commentRepo.SaveMany([]Comment{
+		{ID: "c3", VideoID: "video-2", Nickname: "Mallory", Content: "<script>alert(1)</script> xss\x01<b>"},
+	}); err != nil {
+		t.Fatalf("Failed to save comments: %v", err)
+	}
+	for _, query := range []string{"script", "xs"} {
+		escaped, err := repo.SearchComments(query, &PaginationParams{})
+		if err != nil {
+			t.Fatalf("Failed to search %q: %v", query, err)
+		}
+		if escaped.Total != 1 {
+			t.Fatalf("Expected one hit for %q, got %d", query, escaped.Total)
+		}
+		hl := escaped.Items[0].Highlights["content"]
+		if strings.Contains(hl, "<script>") || strings.Contains(hl, "<b>") || strings.ContainsAny(hl, "\x01\x02") {
+			t.Errorf("Expected escaped highlight for %q, got %q", query, hl)
+		}
+		if strings.Count(hl, "<mark>") == 0 || strings.Count(hl, "<mark>") != strings.Count(hl, "</mark>") {
+			t.Errorf("Expected balanced marks for %q, got %q", query, hl)
+		}
+	}
+
+	// 删除记录后不再出现在搜索结果中
+	if err := downloadRepo.Delete("download-1"); err != nil {
+		t.Fatalf("Failed to delete download: %v", err)
+	}
+	if deleted, _ := repo.SearchDownloads("noodle", &PaginationParams{}); deleted.Total != 0 {
+		t.Errorf("Expected deleted download not to match, got %d hits", deleted.Total)
+	}
+}
+
+func TestTagRepository(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	downloadRepo := NewDownloadRecordRepository()
+	repo := NewTagRepository()
+	now := time.Now()
+
+	for _, id := range []string{"download-1", "download-2"} {
+		record := &DownloadRecord{ID: id, VideoID: id, Title: "Video " + id, Status: DownloadStatusCompleted, DownloadTime: now}
+		if err := downloadRepo.Create(record); err != nil {
+			t.Fatalf("Failed to create download record: %v", err)
+		}
+	}
+
+	project := &Tag{ID: "tag-1", Name: "Project A", Color: "#1e90ff"}
+	draft := &Tag{ID: "tag-2", Name: "draft"}
+	for _, tag := range []*Tag{project, draft} {
+		if err := repo.Create(tag); err != nil {
+			t.Fatalf("Failed to create tag: %v", err)
+		}
+	}
+	if err := repo.Create(&Tag{ID: "tag-3", Name: "PROJECT a"}); err == nil {
+		t.Error("Expected duplicate tag names to be rejected regardless of case")
+	}
+
+	if err := repo.SetRecordTags("download-1", []string{"tag-1", "tag-2"}); err != nil {
+		t.Fatalf("Failed to set record tags: %v", err)
+	}
+	if err := repo.SetRecordTags("download-2", []string{"tag-1"}); err != nil {
+		t.Fatalf("Failed to set record tags: %v", err)
+	}
+
+	got, err := repo.GetByName("project a")
+	if err != nil || got == nil {
+		t.Fatalf("Failed to get tag by name: %v", err)
+	}
+	if got.ID != "tag-1" || got.RecordCount != 2 {
+		t.Errorf("Expected tag-1 used by 2 records, got %+v", got)
+	}
+
+	tags, err := repo.GetTagNamesForRecords([]string{"download-1", "download-2"})
+	if err != nil {
+		t.Fatalf("Failed to get record tags: %v", err)
+	}
+	if len(tags["download-1"]) != 2 || tags["download-1"][0] != "draft" || len(tags["download-2"]) != 1 {
+		t.Errorf("Unexpected record tags: %v", tags)
+	}
+
+	// 按标签过滤下载记录
+	result, err := downloadRepo.List(&FilterParams{PaginationParams: PaginationParams{Page: 1, PageSize: 20}, Tag: "DRAFT"})
+	if err != nil {
+		t.Fatalf("Failed to list by tag: %v", err)
+	}
+	if result.Total != 1 || result.Items[0].ID != "download-1" {
+		t.Errorf("Expected only download-1 to have the draft tag, got %+v", result.Items)
+	}
+	byTag, err := downloadRepo.GetByTag("project a")
+	if err != nil {
+		t.Fatalf("Failed to get records by tag: %v", err)
+	}
+	if len(byTag) != 2 {
+		t.Errorf("Expected 2 records tagged Project A, got %d", len(byTag))
+	}
+
+	// INSERT OR REPLACE 重新写入记录时保留标签，删除记录时移除标签
+	if err := downloadRepo.Create(&DownloadRecord{ID: "download-1", VideoID: "download-1", Title: "Rewritten", Status: DownloadStatusCompleted, DownloadTime: now}); err != nil {
+		t.Fatalf("Failed to rewrite download record: %v", err)
+	}
+	if tags, _ := repo.GetTagNamesForRecords([]string{"download-1"}); len(tags["download-1"]) != 2 {
+		t.Errorf("Expected tags to survive rewriting the record, got %v", tags)
+	}
+	if err := downloadRepo.Delete("download-2"); err != nil {
+		t.Fatalf("Failed to delete download record: %v", err)
+	}
+	if got, _ := repo.GetByID("tag-1"); got.RecordCount != 1 {
+		t.Errorf("Expected deleted record to be untagged, got %d records", got.RecordCount)
+	}
+
+	// 删除标签时从下载记录上移除
+	if err := repo.Delete("tag-2"); err != nil {
+		t.Fatalf("Failed to delete tag: %v", err)
+	}
+	if tags, _ := repo.GetTagNamesForRecords([]string{"download-1"}); len(tags["download-1"]) != 1 {
+		t.Errorf("Expected deleted tag to be removed from records, got %v", tags)
+	}
+	if err := repo.Delete("tag-2"); err == nil {
+		t.Error("Expected error when deleting a missing tag")
+	}
+}
+
+func TestCollectionRepository(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	downloadRepo := NewDownloadRecordRepository()
+	repo := NewCollectionRepository()
+	now := time.Now()
+
+	for _, id := range []string{"download-1", "download-2", "download-3"} {
+		record := &DownloadRecord{ID: id, VideoID: id, Title: "Video " + id, Status: DownloadStatusCompleted, DownloadTime: now}
+		if err := downloadRepo.Create(record); err != nil {
+			t.Fatalf("Failed to create download record: %v", err)
+		}
+	}
+
+	collection := &Collection{ID: "collection-1", Name: "Travel", Description: "Trips"}
+	if err := repo.Create(collection); err != nil {
+		t.Fatalf("Failed to create collection: %v", err)
+	}
+
+	added, err := repo.AddRecords("collection-1", []string{"download-1", "download-2"})
+	if err != nil {
+		t.Fatalf("Failed to add records: %v", err)
+	}
+	if added != 2 {
+		t.Errorf("Expected 2 records added, got %d", added)
+	}
+	if added, _ := repo.AddRecords("collection-1", []string{"download-2", "download-3"}); added != 1 {
+		t.Errorf("Expected records already in the collection to be ignored, got %d added", added)
+	}
+
+	result, err := downloadRepo.List(&FilterParams{PaginationParams: PaginationParams{Page: 1, PageSize: 20}, CollectionID: "collection-1"})
+	if err != nil {
+		t.Fatalf("Failed to list by collection: %v", err)
+	}
+	if result.Total != 3 {
+		t.Errorf("Expected 3 records in the collection, got %d", result.Total)
+	}
+
+	removed, err := repo.RemoveRecords("collection-1", []string{"download-3", "missing"})
+	if err != nil {
+		t.Fatalf("Failed to remove records: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected 1 record removed, got %d", removed)
+	}
+
+	collection.Name = "Travel 2024"
+	if err := repo.Update(collection); err != nil {
+		t.Fatalf("Failed to update collection: %v", err)
+	}
+	listed, err := repo.List()
+	if err != nil {
+		t.Fatalf("Failed to list collections: %v", err)
+	}
+	if len(listed) != 1 || listed[0].Name != "Travel 2024" || listed[0].RecordCount != 2 {
+		t.Fatalf("Unexpected collections: %+v", listed)
+	}
+
+	// 删除合集不删除其中的下载记录
+	if err := repo.Delete("collection-1"); err != nil {
+		t.Fatalf("Failed to delete collection: %v", err)
+	}
+	if missing, _ := repo.GetByID("collection-1"); missing != nil {
+		t.Errorf("Expected deleted collection to be gone, got %+v", missing)
+	}
+	if count, _ := downloadRepo.Count(); count != 3 {
+		t.Errorf("Expected download records to be kept, got %d", count)
+	}
+}
+
+func TestAuthorRepository(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	browseRepo := NewBrowseHistoryRepository()
+	downloadRepo := NewDownloadRecordRepository()
+	repo := NewAuthorRepository()
+	now := time.Now()
+
+	browsed := []*BrowseRecord{
+		{ID: "video-1", Title: "First", Author: "Old Name", AuthorID: "finder-1", BrowseTime: now.Add(-2 * time.Hour), LikeCount: 10, CommentCount: 4},
+		{ID: "video-2", Title: "Second", Author: "New Name", AuthorID: "finder-1", BrowseTime: now, LikeCount: 30, CommentCount: 6},
+		{ID: "video-3", Title: "Other", Author: "Someone", AuthorID: "finder-2", BrowseTime: now, LikeCount: 5},
+	}
+	for _, record := range browsed {
+		if err := browseRepo.Create(record); err != nil {
+			t.Fatalf("Failed to create browse record: %v", err)
+		}
+	}
+
+	if err := repo.Observe("finder-1", "Old Name", "", now.Add(-2*time.Hour)); err != nil {
+		t.Fatalf("Failed to observe author: %v", err)
+	}
+	if err := repo.Observe("finder-1", "New Name", "https://example.com/avatar.jpg", now); err != nil {
+		t.Fatalf("Failed to observe author: %v", err)
+	}
+	// 较早的观察不覆盖当前昵称，但记入昵称历史
+	if err := repo.Observe("finder-1", "Ancient Name", "", now.Add(-24*time.Hour)); err != nil {
+		t.Fatalf("Failed to observe author: %v", err)
+	}
+	if err := repo.Observe("finder-2", "Someone", "", now); err != nil {
+		t.Fatalf("Failed to observe author: %v", err)
+	}
+
+	downloads := []*DownloadRecord{
+		// 作者 ID 从浏览记录中补全
+		{ID: "download-1", VideoID: "video-1", Title: "First", Author: "Old Name", FileSize: 100, Status: DownloadStatusCompleted, DownloadTime: now},
+		// 没有作者 ID 的旧记录按历史昵称匹配
+		{ID: "download-2", VideoID: "legacy", Title: "Legacy", Author: "Old Name", FileSize: 50, Status: DownloadStatusCompleted, DownloadTime: now},
+		{ID: "download-3", VideoID: "video-2", Title: "Second", Author: "New Name", FileSize: 70, Status: DownloadStatusFailed, DownloadTime: now},
+	}
+	for _, record := range downloads {
+		if err := downloadRepo.Create(record); err != nil {
+			t.Fatalf("Failed to create download record: %v", err)
+		}
+	}
+	if got, _ := downloadRepo.GetByID("download-1"); got == nil || got.AuthorID != "finder-1" {
+		t.Errorf("Expected author id to be filled from browse history, got %+v", got)
+	}
+
+	stats, err := repo.GetStats("finder-1")
+	if err != nil {
+		t.Fatalf("Failed to get author stats: %v", err)
+	}
+	if stats == nil {
+		t.Fatal("Expected author to exist")
+	}
+	if stats.Nickname != "New Name" || stats.AvatarURL != "https://example.com/avatar.jpg" {
+		t.Errorf("Expected latest nickname and avatar, got %q %q", stats.Nickname, stats.AvatarURL)
+	}
+	if stats.VideosBrowsed != 2 || stats.VideosDownloaded != 2 || stats.BytesStored != 150 {
+		t.Errorf("Unexpected totals: browsed=%d downloaded=%d bytes=%d", stats.VideosBrowsed, stats.VideosDownloaded, stats.BytesStored)
+	}
+	if stats.AvgLikes != 20 || stats.AvgComments != 5 {
+		t.Errorf("Unexpected engagement: likes=%v comments=%v", stats.AvgLikes, stats.AvgComments)
+	}
+
+	nicknames, err := repo.GetNicknames("finder-1")
+	if err != nil {
+		t.Fatalf("Failed to get nicknames: %v", err)
+	}
+	if len(nicknames) != 3 || nicknames[0].Nickname != "New Name" {
+		t.Errorf("Unexpected nickname history: %+v", nicknames)
+	}
+
+	result, err := downloadRepo.List(&FilterParams{PaginationParams: PaginationParams{Page: 1, PageSize: 20}, AuthorID: "finder-1"})
+	if err != nil {
+		t.Fatalf("Failed to list downloads by author: %v", err)
+	}
+	if result.Total != 3 {
+		t.Errorf("Expected 3 downloads for the author, got %d", result.Total)
+	}
+
+	listed, err := repo.List(&FilterParams{PaginationParams: PaginationParams{Page: 1, PageSize: 20, SortBy: "bytes_stored", SortDesc: true}})
+	if err != nil {
+		t.Fatalf("Failed to list authors: %v", err)
+	}
+	if listed.Total != 2 || listed.Items[0].ID != "finder-1" {
+		t.Errorf("Unexpected author list: %+v", listed)
+	}
+
+	// 按历史昵称搜索
+	found, err := repo.List(&FilterParams{PaginationParams: PaginationParams{Page: 1, PageSize: 20}, Query: "Old"})
+	if err != nil {
+		t.Fatalf("Failed to search authors: %v", err)
+	}
+	if found.Total != 1 || found.Items[0].ID != "finder-1" {
+		t.Errorf("Expected to find the author by a previous nickname, got %+v", found)
+	}
+
+	if missing, _ := repo.GetStats("missing"); missing != nil {
+		t.Errorf("Expected missing author to return nil, got %+v", missing)
+	}
+}
+
+func TestVideoMetricsRepository(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	browseRepo := NewBrowseHistoryRepository()
+	repo := NewVideoMetricsRepository()
+	start := time.Now().Add(-72 * time.Hour)
+
+	record := &BrowseRecord{ID: "video-1", Title: "Growing", Author: "Author", BrowseTime: start}
+	if err := browseRepo.Create(record); err != nil {
+		t.Fatalf("Failed to create browse record: %v", err)
+	}
+
+	for day := 0; day < 3; day++ {
+		snapshot := &VideoMetricsSnapshot{
+			LikeCount:    int64(100 * (day + 1)),
+			CommentCount: int64(10 * (day + 1)),
+			CapturedAt:   start.Add(time.Duration(day) * 24 * time.Hour),
+		}
+		if err := repo.Add("video-1", snapshot); err != nil {
+			t.Fatalf("Failed to add snapshot: %v", err)
+		}
+	}
+
+	snapshots, err := repo.List("video-1", nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to list snapshots: %v", err)
+	}
+	if len(snapshots) != 3 {
+		t.Fatalf("Expected 3 snapshots, got %d", len(snapshots))
+	}
+	if snapshots[0].LikeCount != 100 || snapshots[2].LikeCount != 300 {
+		t.Errorf("Expected snapshots in chronological order, got %+v", snapshots)
+	}
+
+	since := start.Add(12 * time.Hour)
+	ranged, err := repo.List("video-1", &since, nil)
+	if err != nil {
+		t.Fatalf("Failed to list snapshots in range: %v", err)
+	}
+	if len(ranged) != 2 {
+		t.Errorf("Expected 2 snapshots since %v, got %d", since, len(ranged))
+	}
+
+	// 快照必须属于已保存的浏览记录
+	if err := repo.Add("missing", &VideoMetricsSnapshot{}); err == nil {
+		t.Error("Expected snapshot for an unknown video to fail")
+	}
+
+	// 删除浏览记录时一并删除快照
+	if err := browseRepo.Delete("video-1"); err != nil {
+		t.Fatalf("Failed to delete browse record: %v", err)
+	}
+	if remaining, _ := repo.List("video-1", nil, nil); len(remaining) != 0 {
+		t.Errorf("Expected snapshots to be deleted with the browse record, got %d", len(remaining))
+	}
+}
+
+func TestAnalyticsRepository(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	browseRepo := NewBrowseHistoryRepository()
+	metricsRepo := NewVideoMetricsRepository()
+	repo := NewAnalyticsRepository()
+	start := time.Now().Add(-48 * time.Hour)
+
+	browsed := []*BrowseRecord{
+		{ID: "fast", Title: "Fast", Author: "A", AuthorID: "author-a", Duration: 20000, Resolution: "1080p",
+			BrowseTime: start, LikeCount: 1000, CommentCount: 10, PageURL: "https://channels.weixin.qq.com/web/pages/feed"},
+		{ID: "slow", Title: "Slow", Author: "A", AuthorID: "author-a", Duration: 90000, Resolution: "720p",
+			BrowseTime: start, LikeCount: 100, CommentCount: 50, PageURL: "https://channels.weixin.qq.com/web/pages/feed"},
+		{ID: "home", Title: "Home", Author: "B", AuthorID: "author-b", Duration: 700000, Resolution: "1080p",
+			BrowseTime: start, LikeCount: 5000, PageURL: "https://channels.weixin.qq.com/web/pages/home"},
+		{ID: "legacy", Title: "Legacy", Author: "C", BrowseTime: start, LikeCount: 1},
+	}
+	for _, record := range browsed {
+		if err := browseRepo.Create(record); err != nil {
+			t.Fatalf("Failed to create browse record: %v", err)
+		}
+	}
+
+	snapshots := map[string][][2]int64{
+		"fast": {{0, 0}, {1000, 10}}, // 两天内点赞 +1000、评论 +10
+		"slow": {{0, 0}, {100, 50}},  // 两天内点赞 +100、评论 +50
+		"home": {{5000, 0}},          // 只有一次快照，不参与排序
+	}
+	for id, points := range snapshots {
+		for i, p := range points {
+			snapshot := &VideoMetricsSnapshot{LikeCount: p[0], CommentCount: p[1], CapturedAt: start.Add(time.Duration(i) * 48 * time.Hour)}
+			if err := metricsRepo.Add(id, snapshot); err != nil {
+				t.Fatalf("Failed to add snapshot: %v", err)
+			}
+		}
+	}
+
+	trending, err := repo.GetTrendingVideos(&AnalyticsParams{}, false)
+	if err != nil {
+		t.Fatalf("Failed to get trending videos: %v", err)
+	}
+	if len(trending) != 2 || trending[0].VideoID != "fast" {
+		t.Fatalf("Expected fast video first by likes, got %+v", trending)
+	}
+	if trending[0].LikeGrowth != 1000 || trending[0].LikeVelocity < 499 || trending[0].LikeVelocity > 501 {
+		t.Errorf("Expected about 500 likes per day, got growth=%d velocity=%v", trending[0].LikeGrowth, trending[0].LikeVelocity)
+	}
+
+	byComments, err := repo.GetTrendingVideos(&AnalyticsParams{}, true)
+	if err != nil {
+		t.Fatalf("Failed to get trending videos by comments: %v", err)
+	}
+	if len(byComments) != 2 || byComments[0].VideoID != "slow" {
+		t.Errorf("Expected slow video first by comments, got %+v", byComments)
+	}
+
+	// 时间范围只包含第一次快照时无法计算增长
+	end := start.Add(time.Hour)
+	if ranged, _ := repo.GetTrendingVideos(&AnalyticsParams{EndDate: &end}, false); len(ranged) != 0 {
+		t.Errorf("Expected no trending videos with a single snapshot in range, got %+v", ranged)
+	}
+
+	authors, err := repo.GetTopAuthors(&AnalyticsParams{})
+	if err != nil {
+		t.Fatalf("Failed to get top authors: %v", err)
+	}
+	if len(authors) != 3 || authors[0].AuthorID != "author-b" || authors[0].Engagement != 5000 {
+		t.Fatalf("Unexpected top authors: %+v", authors)
+	}
+	if authors[1].AuthorID != "author-a" || authors[1].Videos != 2 || authors[1].TotalLikes != 1100 {
+		t.Errorf("Unexpected second author: %+v", authors[1])
+	}
+	if authors[2].AuthorID != "" || authors[2].Author != "C" {
+		t.Errorf("Expected records without author id to be grouped by nickname, got %+v", authors[2])
+	}
+
+	feedAuthors, err := repo.GetTopAuthors(&AnalyticsParams{PageSource: "feed"})
+	if err != nil {
+		t.Fatalf("Failed to get top authors by page source: %v", err)
+	}
+	if len(feedAuthors) != 1 || feedAuthors[0].AuthorID != "author-a" {
+		t.Errorf("Expected only feed authors, got %+v", feedAuthors)
+	}
+
+	resolutions, err := repo.GetResolutionDistribution(&AnalyticsParams{})
+	if err != nil {
+		t.Fatalf("Failed to get resolution distribution: %v", err)
+	}
+	if len(resolutions) != 3 || resolutions[0].Label != "1080p" || resolutions[0].Count != 2 {
+		t.Errorf("Unexpected resolution distribution: %+v", resolutions)
+	}
+
+	durations, err := repo.GetDurationDistribution(&AnalyticsParams{})
+	if err != nil {
+		t.Fatalf("Failed to get duration distribution: %v", err)
+	}
+	want := []DistributionBucket{{"<30s", 1}, {"1m-3m", 1}, {">=10m", 1}, {"unknown", 1}}
+	if len(durations) != len(want) {
+		t.Fatalf("Unexpected duration distribution: %+v", durations)
+	}
+	for i := range want {
+		if durations[i] != want[i] {
+			t.Errorf("Duration bucket %d = %+v, want %+v", i, durations[i], want[i])
+		}
+	}
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := NewBrowseHistoryRepository()
+	if err := repo.Create(&BrowseRecord{ID: "kept", Title: "Kept", BrowseTime: time.Now()}); err != nil {
+		t.Fatalf("Failed to create browse record: %v", err)
+	}
+
+	problems, err := IntegrityCheck()
+	if err != nil {
+		t.Fatalf("Failed to check integrity: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("Expected no integrity problems, got %v", problems)
+	}
+
+	backupPath := filepath.Join(t.TempDir(), "backup.db")
+	if err := Backup(backupPath); err != nil {
+		t.Fatalf("Failed to back up database: %v", err)
+	}
+	if err := Backup(backupPath); err == nil {
+		t.Error("Expected error when backup file already exists")
+	}
+
+	applied, problems, err := InspectBackup(backupPath)
+	if err != nil {
+		t.Fatalf("Failed to inspect backup: %v", err)
+	}
+	currentApplied, _ := AppliedMigrations()
+	if len(applied) != len(currentApplied) || len(problems) != 0 {
+		t.Errorf("Expected backup migrations %v without problems, got %v %v", currentApplied, applied, problems)
+	}
+	for version := range currentApplied {
+		if !applied[version] {
+			t.Errorf("Expected backup to include migration %d", version)
+		}
+	}
+	current, _ := GetSchemaVersion()
+	if _, _, err := InspectBackup(filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Error("Expected error for missing backup")
+	}
+
+	// 备份之后的修改在恢复后消失，恢复前创建的 Repository 仍然可用
+	if err := repo.Create(&BrowseRecord{ID: "discarded", Title: "Discarded", BrowseTime: time.Now()}); err != nil {
+		t.Fatalf("Failed to create browse record: %v", err)
+	}
+	if err := RestoreFrom(backupPath); err != nil {
+		t.Fatalf("Failed to restore database: %v", err)
+	}
+
+	if record, err := repo.GetByID("discarded"); err != nil || record != nil {
+		t.Errorf("Expected record created after backup to be gone, got %v (%v)", record, err)
+	}
+	if record, err := repo.GetByID("kept"); err != nil || record == nil {
+		t.Errorf("Expected backed up record to be restored, got %v (%v)", record, err)
+	}
+	if restored, _ := GetSchemaVersion(); restored != current {
+		t.Errorf("Expected schema version %d after restore, got %d", current, restored)
+	}
+}
+
+func TestScheduleRule(t *testing.T) {
+	// 工作日 01:00-07:00
+	weekdays := ScheduleRule{Days: []int{1, 2, 3, 4, 5}, Start: "01:00", End: "07:00"}
+	monday := time.Date(2024, 1, 1, 3, 0, 0, 0, time.Local) // 周一
+	if !weekdays.Contains(monday) {
+		t.Error("Expected Monday 03:00 to be inside window")
+	}
+	if weekdays.Contains(monday.Add(5 * time.Hour)) {
+		t.Error("Expected Monday 08:00 to be outside window")
+	}
+	if weekdays.Contains(monday.AddDate(0, 0, -1)) {
+		t.Error("Expected Sunday 03:00 to be outside window")
+	}
+
+	// 跨越午夜的窗口归属于开始的那一天
+	overnight := ScheduleRule{Days: []int{5}, Start: "23:00", End: "02:00"}
+	friday := time.Date(2024, 1, 5, 23, 30, 0, 0, time.Local)
+	if !overnight.Contains(friday) {
+		t.Error("Expected Friday 23:30 to be inside window")
+	}
+	if !overnight.Contains(friday.Add(2 * time.Hour)) {
+		t.Error("Expected Saturday 01:30 to be inside window")
+	}
+	if overnight.Contains(monday.Add(-2 * time.Hour)) {
+		t.Error("Expected Monday 01:00 to be outside window")
+	}
+
+	if err := (ScheduleRule{Start: "25:00", End: "07:00"}).Validate(); err == nil {
+		t.Error("Expected validation error for invalid start time")
+	}
+
+	// 未启用时始终允许下载
+	settings := DefaultSettings()
+	settings.ScheduleRules = []ScheduleRule{weekdays}
+	if !settings.InDownloadWindow(monday.Add(5 * time.Hour)) {
+		t.Error("Expected download allowed when schedule disabled")
+	}
+	settings.ScheduleEnabled = true
+	if settings.InDownloadWindow(monday.Add(5 * time.Hour)) {
+		t.Error("Expected download blocked outside window")
+	}
 }
@@ -3,6 +3,7 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 )
@@ -17,27 +18,81 @@ func NewDownloadRecordRepository() *DownloadRecordRepository {
 	return &DownloadRecordRepository{db: GetDB()}
 }
 
-// Create 插入新的下载记录
+// downloadRecordColumns 查询下载记录时使用的列
+const downloadRecordColumns = `id, video_id, title, author, COALESCE(author_id, '') as author_id, COALESCE(cover_url, '') as cover_url, duration, file_size, file_path,
+			format, resolution, status, download_time, error_message,
+			like_count, comment_count, forward_count, fav_count,
+			COALESCE(content_hash, '') as content_hash, COALESCE(starred, 0) as starred, last_played_at,
+			created_at, updated_at`
+
+// scanDownloadRecord 将一行查询结果扫描为下载记录
+func scanDownloadRecord(row rowScanner) (*DownloadRecord, error) {
+	record := &DownloadRecord{}
+	var filePath, format, resolution, errorMessage, coverURL sql.NullString
+	var lastPlayedAt sql.NullTime
+	err := row.Scan(
+		&record.ID, &record.VideoID, &record.Title, &record.Author, &record.AuthorID, &coverURL,
+		&record.Duration, &record.FileSize, &filePath, &format,
+		&resolution, &record.Status, &record.DownloadTime,
+		&errorMessage,
+		&record.LikeCount, &record.CommentCount, &record.ForwardCount, &record.FavCount,
+		&record.ContentHash, &record.Starred, &lastPlayedAt, &record.CreatedAt, &record.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if lastPlayedAt.Valid {
+		record.LastPlayedAt = lastPlayedAt.Time
+	}
+	record.CoverURL = coverURL.String
+	record.FilePath = filePath.String
+	record.Format = format.String
+	record.Resolution = resolution.String
+	record.ErrorMessage = errorMessage.String
+	return record, nil
+}
+
+// Create 插入新的下载记录，ID 已存在时原地更新该记录
+// 更新保留原有的 rowid，全文索引、标签和合集关联随之保持一致
+// 未指定作者 ID 时使用浏览记录中同一视频的作者 ID
 func (r *DownloadRecordRepository) Create(record *DownloadRecord) error {
 	now := time.Now()
 	record.CreatedAt = now
 	record.UpdatedAt = now
 
+	if record.AuthorID == "" && record.VideoID != "" {
+		var authorID sql.NullString
+		err := r.db.QueryRow("SELECT author_id FROM browse_history WHERE id = ?", record.VideoID).Scan(&authorID)
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to look up author id: %w", err)
+		}
+		record.AuthorID = authorID.String
+	}
+
 	query := `
-		INSERT OR REPLACE INTO download_records (
-			id, video_id, title, author, cover_url, duration, file_size, file_path,
+		INSERT INTO download_records (
+			id, video_id, title, author, author_id, cover_url, duration, file_size, file_path,
 			format, resolution, status, download_time, error_message,
 			like_count, comment_count, forward_count, fav_count,
-			created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			content_hash, starred, last_played_at, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			video_id = excluded.video_id, title = excluded.title, author = excluded.author,
+			author_id = excluded.author_id, cover_url = excluded.cover_url, duration = excluded.duration,
+			file_size = excluded.file_size, file_path = excluded.file_path, format = excluded.format,
+			resolution = excluded.resolution, status = excluded.status, download_time = excluded.download_time,
+			error_message = excluded.error_message, like_count = excluded.like_count,
+			comment_count = excluded.comment_count, forward_count = excluded.forward_count,
+			fav_count = excluded.fav_count, content_hash = excluded.content_hash, starred = excluded.starred,
+			last_played_at = excluded.last_played_at, created_at = excluded.created_at, updated_at = excluded.updated_at
 	`
 	_, err := r.db.Exec(query,
-		record.ID, record.VideoID, record.Title, record.Author, record.CoverURL,
+		record.ID, record.VideoID, record.Title, record.Author, record.AuthorID, record.CoverURL,
 		record.Duration, record.FileSize, record.FilePath, record.Format,
 		record.Resolution, record.Status, record.DownloadTime,
 		record.ErrorMessage,
 		record.LikeCount, record.CommentCount, record.ForwardCount, record.FavCount,
-		record.CreatedAt, record.UpdatedAt,
+		record.ContentHash, record.Starred, nullableTime(record.LastPlayedAt), record.CreatedAt, record.UpdatedAt,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create download record: %w", err)
@@ -48,33 +103,16 @@ func (r *DownloadRecordRepository) Create(record *DownloadRecord) error {
 // GetByID 根据 ID 获取下载记录
 func (r *DownloadRecordRepository) GetByID(id string) (*DownloadRecord, error) {
 	query := `
-		SELECT id, video_id, title, author, COALESCE(cover_url, '') as cover_url, duration, file_size, file_path,
-			format, resolution, status, download_time, error_message,
-			like_count, comment_count, forward_count, fav_count,
-			created_at, updated_at
+		SELECT ` + downloadRecordColumns + `
 		FROM download_records WHERE id = ?
 	`
-	record := &DownloadRecord{}
-	var filePath, format, resolution, errorMessage, coverURL sql.NullString
-	err := r.db.QueryRow(query, id).Scan(
-		&record.ID, &record.VideoID, &record.Title, &record.Author, &coverURL,
-		&record.Duration, &record.FileSize, &filePath, &format,
-		&resolution, &record.Status, &record.DownloadTime,
-		&errorMessage,
-		&record.LikeCount, &record.CommentCount, &record.ForwardCount, &record.FavCount,
-		&record.CreatedAt, &record.UpdatedAt,
-	)
+	record, err := scanDownloadRecord(r.db.QueryRow(query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get download record: %w", err)
 	}
-	record.CoverURL = coverURL.String
-	record.FilePath = filePath.String
-	record.Format = format.String
-	record.Resolution = resolution.String
-	record.ErrorMessage = errorMessage.String
 	return record, nil
 }
 
@@ -84,16 +122,16 @@ func (r *DownloadRecordRepository) Update(record *DownloadRecord) error {
 
 	query := `
 		UPDATE download_records SET
-			video_id = ?, title = ?, author = ?, cover_url = ?, duration = ?, file_size = ?,
+			video_id = ?, title = ?, author = ?, author_id = ?, cover_url = ?, duration = ?, file_size = ?,
 			file_path = ?, format = ?, resolution = ?, status = ?,
-			download_time = ?, error_message = ?, updated_at = ?
+			download_time = ?, error_message = ?, content_hash = ?, starred = ?, last_played_at = ?, updated_at = ?
 		WHERE id = ?
 	`
 	result, err := r.db.Exec(query,
-		record.VideoID, record.Title, record.Author, record.CoverURL, record.Duration,
+		record.VideoID, record.Title, record.Author, record.AuthorID, record.CoverURL, record.Duration,
 		record.FileSize, record.FilePath, record.Format, record.Resolution,
-		record.Status, record.DownloadTime, record.ErrorMessage,
-		record.UpdatedAt, record.ID,
+		record.Status, record.DownloadTime, record.ErrorMessage, record.ContentHash,
+		record.Starred, nullableTime(record.LastPlayedAt), record.UpdatedAt, record.ID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to update download record: %w", err)
@@ -191,9 +229,21 @@ func (r *DownloadRecordRepository) List(params *FilterParams) (*PagedResult[Down
 		args = append(args, params.Status)
 	}
 	if params.Query != "" {
-		conditions = append(conditions, "(title LIKE ? OR author LIKE ?)")
-		searchPattern := "%" + params.Query + "%"
-		args = append(args, searchPattern, searchPattern)
+		condition, conditionArgs := searchCondition(r.db, downloadSearchSource, params.Query)
+		conditions = append(conditions, "("+condition+")")
+		args = append(args, conditionArgs...)
+	}
+	if params.Tag != "" {
+		conditions = append(conditions, "id IN ("+recordIDsByTagQuery+")")
+		args = append(args, params.Tag)
+	}
+	if params.CollectionID != "" {
+		conditions = append(conditions, "id IN (SELECT record_id FROM collection_records WHERE collection_id = ?)")
+		args = append(args, params.CollectionID)
+	}
+	if params.AuthorID != "" {
+		conditions = append(conditions, authorRecordCondition)
+		args = append(args, params.AuthorID, params.AuthorID)
 	}
 
 	whereClause := ""
@@ -217,10 +267,7 @@ func (r *DownloadRecordRepository) List(params *FilterParams) (*PagedResult[Down
 	offset := (params.Page - 1) * params.PageSize
 
 	query := fmt.Sprintf(`
-		SELECT id, video_id, title, author, COALESCE(cover_url, '') as cover_url, duration, file_size, file_path,
-			format, resolution, status, download_time, error_message,
-			like_count, comment_count, forward_count, fav_count,
-			created_at, updated_at
+		SELECT `+downloadRecordColumns+`
 		FROM download_records
 		%s
 		ORDER BY %s %s
@@ -236,25 +283,11 @@ func (r *DownloadRecordRepository) List(params *FilterParams) (*PagedResult[Down
 
 	var records []DownloadRecord
 	for rows.Next() {
-		var record DownloadRecord
-		var filePath, format, resolution, errorMessage, coverURL sql.NullString
-		err := rows.Scan(
-			&record.ID, &record.VideoID, &record.Title, &record.Author, &coverURL,
-			&record.Duration, &record.FileSize, &filePath, &format,
-			&resolution, &record.Status, &record.DownloadTime,
-			&errorMessage,
-			&record.LikeCount, &record.CommentCount, &record.ForwardCount, &record.FavCount,
-			&record.CreatedAt, &record.UpdatedAt,
-		)
+		record, err := scanDownloadRecord(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan download record: %w", err)
 		}
-		record.CoverURL = coverURL.String
-		record.FilePath = filePath.String
-		record.Format = format.String
-		record.Resolution = resolution.String
-		record.ErrorMessage = errorMessage.String
-		records = append(records, record)
+		records = append(records, *record)
 	}
 
 	if records == nil {
@@ -305,10 +338,7 @@ func (r *DownloadRecordRepository) GetRecent(limit int) ([]DownloadRecord, error
 	}
 
 	query := `
-		SELECT id, video_id, title, author, COALESCE(cover_url, '') as cover_url, duration, file_size, file_path,
-			format, resolution, status, download_time, error_message,
-			like_count, comment_count, forward_count, fav_count,
-			created_at, updated_at
+		SELECT ` + downloadRecordColumns + `
 		FROM download_records
 		ORDER BY download_time DESC
 		LIMIT ?
@@ -322,25 +352,11 @@ func (r *DownloadRecordRepository) GetRecent(limit int) ([]DownloadRecord, error
 
 	var records []DownloadRecord
 	for rows.Next() {
-		var record DownloadRecord
-		var filePath, format, resolution, errorMessage, coverURL sql.NullString
-		err := rows.Scan(
-			&record.ID, &record.VideoID, &record.Title, &record.Author, &coverURL,
-			&record.Duration, &record.FileSize, &filePath, &format,
-			&resolution, &record.Status, &record.DownloadTime,
-			&errorMessage,
-			&record.LikeCount, &record.CommentCount, &record.ForwardCount, &record.FavCount,
-			&record.CreatedAt, &record.UpdatedAt,
-		)
+		record, err := scanDownloadRecord(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan download record: %w", err)
 		}
-		record.CoverURL = coverURL.String
-		record.FilePath = filePath.String
-		record.Format = format.String
-		record.Resolution = resolution.String
-		record.ErrorMessage = errorMessage.String
-		records = append(records, record)
+		records = append(records, *record)
 	}
 
 	if records == nil {
@@ -362,10 +378,7 @@ func (r *DownloadRecordRepository) DeleteBefore(date time.Time) (int64, error) {
 // GetAll 获取所有下载记录（用于导出）
 func (r *DownloadRecordRepository) GetAll() ([]DownloadRecord, error) {
 	query := `
-		SELECT id, video_id, title, author, COALESCE(cover_url, '') as cover_url, duration, file_size, file_path,
-			format, resolution, status, download_time, error_message,
-			like_count, comment_count, forward_count, fav_count,
-			created_at, updated_at
+		SELECT ` + downloadRecordColumns + `
 		FROM download_records
 		ORDER BY download_time DESC
 	`
@@ -378,25 +391,11 @@ func (r *DownloadRecordRepository) GetAll() ([]DownloadRecord, error) {
 
 	var records []DownloadRecord
 	for rows.Next() {
-		var record DownloadRecord
-		var filePath, format, resolution, errorMessage, coverURL sql.NullString
-		err := rows.Scan(
-			&record.ID, &record.VideoID, &record.Title, &record.Author, &coverURL,
-			&record.Duration, &record.FileSize, &filePath, &format,
-			&resolution, &record.Status, &record.DownloadTime,
-			&errorMessage,
-			&record.LikeCount, &record.CommentCount, &record.ForwardCount, &record.FavCount,
-			&record.CreatedAt, &record.UpdatedAt,
-		)
+		record, err := scanDownloadRecord(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan download record: %w", err)
 		}
-		record.CoverURL = coverURL.String
-		record.FilePath = filePath.String
-		record.Format = format.String
-		record.Resolution = resolution.String
-		record.ErrorMessage = errorMessage.String
-		records = append(records, record)
+		records = append(records, *record)
 	}
 
 	if records == nil {
@@ -406,6 +405,32 @@ func (r *DownloadRecordRepository) GetAll() ([]DownloadRecord, error) {
 	return records, nil
 }
 
+// GetByTag 获取带有指定标签的所有下载记录（用于导出）
+func (r *DownloadRecordRepository) GetByTag(tag string) ([]DownloadRecord, error) {
+	query := `
+		SELECT ` + downloadRecordColumns + `
+		FROM download_records
+		WHERE id IN (` + recordIDsByTagQuery + `)
+		ORDER BY download_time DESC
+	`
+
+	rows, err := r.db.Query(query, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get download records by tag: %w", err)
+	}
+	defer rows.Close()
+
+	records := []DownloadRecord{}
+	for rows.Next() {
+		record, err := scanDownloadRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan download record: %w", err)
+		}
+		records = append(records, *record)
+	}
+	return records, rows.Err()
+}
+
 // GetByIDs 根据 ID 获取下载记录
 func (r *DownloadRecordRepository) GetByIDs(ids []string) ([]DownloadRecord, error) {
 	if len(ids) == 0 {
@@ -420,10 +445,7 @@ func (r *DownloadRecordRepository) GetByIDs(ids []string) ([]DownloadRecord, err
 	}
 
 	query := fmt.Sprintf(`
-		SELECT id, video_id, title, author, COALESCE(cover_url, '') as cover_url, duration, file_size, file_path,
-			format, resolution, status, download_time, error_message,
-			like_count, comment_count, forward_count, fav_count,
-			created_at, updated_at
+		SELECT `+downloadRecordColumns+`
 		FROM download_records
 		WHERE id IN (%s)
 		ORDER BY download_time DESC
@@ -437,25 +459,11 @@ func (r *DownloadRecordRepository) GetByIDs(ids []string) ([]DownloadRecord, err
 
 	var records []DownloadRecord
 	for rows.Next() {
-		var record DownloadRecord
-		var filePath, format, resolution, errorMessage, coverURL sql.NullString
-		err := rows.Scan(
-			&record.ID, &record.VideoID, &record.Title, &record.Author, &coverURL,
-			&record.Duration, &record.FileSize, &filePath, &format,
-			&resolution, &record.Status, &record.DownloadTime,
-			&errorMessage,
-			&record.LikeCount, &record.CommentCount, &record.ForwardCount, &record.FavCount,
-			&record.CreatedAt, &record.UpdatedAt,
-		)
+		record, err := scanDownloadRecord(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan download record: %w", err)
 		}
-		record.CoverURL = coverURL.String
-		record.FilePath = filePath.String
-		record.Format = format.String
-		record.Resolution = resolution.String
-		record.ErrorMessage = errorMessage.String
-		records = append(records, record)
+		records = append(records, *record)
 	}
 
 	if records == nil {
@@ -505,3 +513,180 @@ func (r *DownloadRecordRepository) GetTotalFileSize() (int64, error) {
 	}
 	return total.Int64, nil
 }
+
+// DuplicateGroup 内容哈希相同的一组下载记录
+type DuplicateGroup struct {
+	ContentHash string           `json:"contentHash"`
+	Count       int              `json:"count"`
+	TotalSize   int64            `json:"totalSize"`
+	Records     []DownloadRecord `json:"records"`
+}
+
+// FindByVideoID 获取指定视频的所有下载记录（最新的在前）
+func (r *DownloadRecordRepository) FindByVideoID(videoID string) ([]DownloadRecord, error) {
+	query := `
+		SELECT ` + downloadRecordColumns + `
+		FROM download_records
+		WHERE video_id = ?
+		ORDER BY download_time DESC
+	`
+	rows, err := r.db.Query(query, videoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find download records by video id: %w", err)
+	}
+	defer rows.Close()
+
+	records := []DownloadRecord{}
+	for rows.Next() {
+		record, err := scanDownloadRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan download record: %w", err)
+		}
+		records = append(records, *record)
+	}
+	return records, rows.Err()
+}
+
+// FindByContentHash 获取指定内容哈希的已完成下载记录（最早的在前）
+func (r *DownloadRecordRepository) FindByContentHash(hash string) ([]DownloadRecord, error) {
+	if hash == "" {
+		return []DownloadRecord{}, nil
+	}
+
+	query := `
+		SELECT ` + downloadRecordColumns + `
+		FROM download_records
+		WHERE content_hash = ? AND status = ?
+		ORDER BY download_time ASC
+	`
+	rows, err := r.db.Query(query, hash, DownloadStatusCompleted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find download records by content hash: %w", err)
+	}
+	defer rows.Close()
+
+	records := []DownloadRecord{}
+	for rows.Next() {
+		record, err := scanDownloadRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan download record: %w", err)
+		}
+		records = append(records, *record)
+	}
+	return records, rows.Err()
+}
+
+// GetDuplicateGroups 获取内容哈希重复的下载记录分组，按重复数量降序排列
+func (r *DownloadRecordRepository) GetDuplicateGroups() ([]DuplicateGroup, error) {
+	query := `
+		SELECT ` + downloadRecordColumns + `
+		FROM download_records
+		WHERE content_hash IN (
+			SELECT content_hash FROM download_records
+			WHERE content_hash != '' AND status = ?
+			GROUP BY content_hash HAVING COUNT(*) > 1
+		) AND status = ?
+		ORDER BY content_hash, download_time ASC
+	`
+	rows, err := r.db.Query(query, DownloadStatusCompleted, DownloadStatusCompleted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get duplicate download records: %w", err)
+	}
+	defer rows.Close()
+
+	groups := []DuplicateGroup{}
+	for rows.Next() {
+		record, err := scanDownloadRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan download record: %w", err)
+		}
+		if len(groups) == 0 || groups[len(groups)-1].ContentHash != record.ContentHash {
+			groups = append(groups, DuplicateGroup{ContentHash: record.ContentHash})
+		}
+		group := &groups[len(groups)-1]
+		group.Count++
+		group.TotalSize += record.FileSize
+		group.Records = append(group.Records, *record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get duplicate download records: %w", err)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Count > groups[j].Count
+	})
+	return groups, nil
+}
+
+// GetWithoutContentHash 获取尚未计算内容哈希的已完成下载记录
+func (r *DownloadRecordRepository) GetWithoutContentHash() ([]DownloadRecord, error) {
+	query := `
+		SELECT ` + downloadRecordColumns + `
+		FROM download_records
+		WHERE COALESCE(content_hash, '') = '' AND status = ?
+		ORDER BY download_time ASC
+	`
+	rows, err := r.db.Query(query, DownloadStatusCompleted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get download records without content hash: %w", err)
+	}
+	defer rows.Close()
+
+	records := []DownloadRecord{}
+	for rows.Next() {
+		record, err := scanDownloadRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan download record: %w", err)
+		}
+		records = append(records, *record)
+	}
+	return records, rows.Err()
+}
+
+// nullableTime 将零值时间转换为 NULL
+func nullableTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+// SetStarred 设置下载记录的加星状态
+func (r *DownloadRecordRepository) SetStarred(id string, starred bool) error {
+	result, err := r.db.Exec("UPDATE download_records SET starred = ?, updated_at = ? WHERE id = ?", starred, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update starred: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("download record not found: %s", id)
+	}
+	return nil
+}
+
+// MarkPlayedByPath 更新指定文件对应下载记录的最近播放时间，返回更新的记录数
+func (r *DownloadRecordRepository) MarkPlayedByPath(filePath string, playedAt time.Time) (int64, error) {
+	result, err := r.db.Exec("UPDATE download_records SET last_played_at = ? WHERE file_path = ?", playedAt, filePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update last played time: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// SetContentHash 更新下载记录的内容哈希
+func (r *DownloadRecordRepository) SetContentHash(id, hash string) error {
+	_, err := r.db.Exec("UPDATE download_records SET content_hash = ?, updated_at = ? WHERE id = ?", hash, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update content hash: %w", err)
+	}
+	return nil
+}
+
+// SetContentHashByPath 更新指向同一文件的所有下载记录的内容哈希，返回更新的记录数
+func (r *DownloadRecordRepository) SetContentHashByPath(filePath, hash string) (int64, error) {
+	result, err := r.db.Exec("UPDATE download_records SET content_hash = ?, updated_at = ? WHERE file_path = ?", hash, time.Now(), filePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update content hash: %w", err)
+	}
+	return result.RowsAffected()
+}
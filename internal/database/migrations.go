@@ -1,7 +1,10 @@
 package database
 
 import (
+	"database/sql"
 	"fmt"
+
+	"wx_channel/internal/utils"
 )
 
 // Migration 表示数据库迁移
@@ -9,6 +12,9 @@ type Migration struct {
 	Version     int
 	Description string
 	Up          string
+	// Supported 检查迁移依赖的 SQLite 功能是否可用，为 nil 时总是执行
+	// 不可用时跳过且不记录版本，功能可用后（例如使用 sqlite_fts5 标签重新编译）下次启动时自动补上
+	Supported func() bool
 }
 
 // migrations 按顺序包含所有数据库迁移
@@ -229,6 +235,341 @@ ALTER TABLE download_records ADD COLUMN forward_count INTEGER DEFAULT 0;
 ALTER TABLE download_records ADD COLUMN fav_count INTEGER DEFAULT 0;
 `,
 	},
+	{
+		Version:     9,
+		Description: "Add chunks_bitmap column to download_queue table",
+		Up: `
+-- Per-chunk completion bitmap for parallel range downloads ('1' = completed)
+ALTER TABLE download_queue ADD COLUMN chunks_bitmap TEXT DEFAULT '';
+`,
+	},
+	{
+		Version:     10,
+		Description: "Add speed_limit column to download_queue table",
+		Up: `
+-- Per-item bandwidth cap in bytes per second (0 = use default)
+ALTER TABLE download_queue ADD COLUMN speed_limit INTEGER DEFAULT 0;
+`,
+	},
+	{
+		Version:     11,
+		Description: "Add pause_reason column to download_queue table",
+		Up: `
+-- Distinguish user pauses from schedule pauses ('user' / 'schedule')
+ALTER TABLE download_queue ADD COLUMN pause_reason TEXT DEFAULT '';
+`,
+	},
+	{
+		Version:     12,
+		Description: "Add nonce_id column to download_queue table",
+		Up: `
+-- Object nonce id used to re-resolve expired video URLs through feed_profile
+ALTER TABLE download_queue ADD COLUMN nonce_id TEXT DEFAULT '';
+`,
+	},
+	{
+		Version:     13,
+		Description: "Add embed_metadata column to download_queue table",
+		Up: `
+-- Whether to write title/author/cover into the finished MP4 file
+ALTER TABLE download_queue ADD COLUMN embed_metadata INTEGER DEFAULT 1;
+`,
+	},
+	{
+		Version:     14,
+		Description: "Add content_hash column to download_records table",
+		Up: `
+-- SHA-256 of the downloaded content, used to detect duplicate downloads
+ALTER TABLE download_records ADD COLUMN content_hash TEXT DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_download_records_content_hash ON download_records(content_hash);
+`,
+	},
+	{
+		Version:     15,
+		Description: "Add batch columns to download_queue table",
+		Up: `
+-- Batch downloads are stored as queue items so their progress survives restarts
+ALTER TABLE download_queue ADD COLUMN batch_id TEXT DEFAULT '';
+ALTER TABLE download_queue ADD COLUMN page_source TEXT DEFAULT '';
+-- Legacy decryption: base64 decryptor prefix sent by older scripts instead of a key
+ALTER TABLE download_queue ADD COLUMN decryptor_prefix TEXT DEFAULT '';
+ALTER TABLE download_queue ADD COLUMN prefix_len INTEGER DEFAULT 0;
+-- Stats strings submitted with the batch, stored as JSON
+ALTER TABLE download_queue ADD COLUMN batch_stats TEXT DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_download_queue_batch_id ON download_queue(batch_id);
+`,
+	},
+	{
+		Version:     16,
+		Description: "Add retry_history column to download_queue table",
+		Up: `
+-- Recent failed download attempts (JSON array) shown in the console
+ALTER TABLE download_queue ADD COLUMN retry_history TEXT DEFAULT '';
+`,
+	},
+	{
+		Version:     17,
+		Description: "Add starred and last_played_at columns to download_records table",
+		Up: `
+-- Starred downloads are never removed by retention policies
+ALTER TABLE download_records ADD COLUMN starred INTEGER DEFAULT 0;
+-- Last time the file was played from the console, used to evict least-recently-played files
+ALTER TABLE download_records ADD COLUMN last_played_at DATETIME;
+CREATE INDEX IF NOT EXISTS idx_download_records_author ON download_records(author);
+`,
+	},
+	{
+		Version:     18,
+		Description: "Create trash_items table",
+		Up: `
+-- Recycle bin (回收站): deleted download files moved under <DownloadsDir>/.trash
+CREATE TABLE IF NOT EXISTS trash_items (
+    id TEXT PRIMARY KEY,
+    record_id TEXT DEFAULT '',
+    title TEXT DEFAULT '',
+    author TEXT DEFAULT '',
+    original_path TEXT NOT NULL,
+    trash_path TEXT NOT NULL,
+    file_size INTEGER DEFAULT 0,
+    -- Original paths of every moved file (JSON array)
+    files TEXT DEFAULT '',
+    -- Snapshot of the download record (JSON), recreated on restore
+    record_data TEXT DEFAULT '',
+    deleted_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_trash_items_deleted_at ON trash_items(deleted_at);
+`,
+	},
+	{
+		Version:     19,
+		Description: "Create comments table",
+		Up: `
+-- Captured video comments (评论), also written to comment_data/*.json
+CREATE TABLE IF NOT EXISTS comments (
+    id TEXT PRIMARY KEY,
+    video_id TEXT NOT NULL,
+    video_title TEXT DEFAULT '',
+    parent_id TEXT DEFAULT '',
+    nickname TEXT DEFAULT '',
+    content TEXT NOT NULL DEFAULT '',
+    like_count INTEGER DEFAULT 0,
+    ip_location TEXT DEFAULT '',
+    create_time DATETIME,
+    saved_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_comments_video_id ON comments(video_id);
+`,
+	},
+	{
+		Version:     20,
+		Description: "Create FTS5 full-text indexes for browse_history, download_records and comments",
+		Supported:   fts5Supported,
+		Up: `
+-- Trigram tokenizer matches any substring of 3+ characters, which works for Chinese text without word segmentation.
+-- The indexes keep their own copy of the text keyed by the source rowid. Source rows must be overwritten with
+-- INSERT ... ON CONFLICT DO UPDATE: INSERT OR REPLACE deletes the old row without firing the delete trigger,
+-- leaving its index entry behind under the old rowid.
+CREATE VIRTUAL TABLE IF NOT EXISTS browse_history_fts USING fts5(title, author, tokenize='trigram');
+CREATE TRIGGER IF NOT EXISTS browse_history_fts_ai AFTER INSERT ON browse_history BEGIN
+    DELETE FROM browse_history_fts WHERE rowid = new.rowid;
+    INSERT INTO browse_history_fts(rowid, title, author) VALUES (new.rowid, new.title, new.author);
+END;
+CREATE TRIGGER IF NOT EXISTS browse_history_fts_au AFTER UPDATE OF title, author ON browse_history BEGIN
+    DELETE FROM browse_history_fts WHERE rowid = old.rowid;
+    INSERT INTO browse_history_fts(rowid, title, author) VALUES (new.rowid, new.title, new.author);
+END;
+CREATE TRIGGER IF NOT EXISTS browse_history_fts_ad AFTER DELETE ON browse_history BEGIN
+    DELETE FROM browse_history_fts WHERE rowid = old.rowid;
+END;
+INSERT INTO browse_history_fts(rowid, title, author) SELECT rowid, title, author FROM browse_history;
+
+CREATE VIRTUAL TABLE IF NOT EXISTS download_records_fts USING fts5(title, author, tokenize='trigram');
+CREATE TRIGGER IF NOT EXISTS download_records_fts_ai AFTER INSERT ON download_records BEGIN
+    DELETE FROM download_records_fts WHERE rowid = new.rowid;
+    INSERT INTO download_records_fts(rowid, title, author) VALUES (new.rowid, new.title, new.author);
+END;
+CREATE TRIGGER IF NOT EXISTS download_records_fts_au AFTER UPDATE OF title, author ON download_records BEGIN
+    DELETE FROM download_records_fts WHERE rowid = old.rowid;
+    INSERT INTO download_records_fts(rowid, title, author) VALUES (new.rowid, new.title, new.author);
+END;
+CREATE TRIGGER IF NOT EXISTS download_records_fts_ad AFTER DELETE ON download_records BEGIN
+    DELETE FROM download_records_fts WHERE rowid = old.rowid;
+END;
+INSERT INTO download_records_fts(rowid, title, author) SELECT rowid, title, author FROM download_records;
+
+CREATE VIRTUAL TABLE IF NOT EXISTS comments_fts USING fts5(content, nickname, video_title, tokenize='trigram');
+CREATE TRIGGER IF NOT EXISTS comments_fts_ai AFTER INSERT ON comments BEGIN
+    DELETE FROM comments_fts WHERE rowid = new.rowid;
+    INSERT INTO comments_fts(rowid, content, nickname, video_title) VALUES (new.rowid, new.content, new.nickname, new.video_title);
+END;
+CREATE TRIGGER IF NOT EXISTS comments_fts_au AFTER UPDATE OF content, nickname, video_title ON comments BEGIN
+    DELETE FROM comments_fts WHERE rowid = old.rowid;
+    INSERT INTO comments_fts(rowid, content, nickname, video_title) VALUES (new.rowid, new.content, new.nickname, new.video_title);
+END;
+CREATE TRIGGER IF NOT EXISTS comments_fts_ad AFTER DELETE ON comments BEGIN
+    DELETE FROM comments_fts WHERE rowid = old.rowid;
+END;
+INSERT INTO comments_fts(rowid, content, nickname, video_title) SELECT rowid, content, nickname, video_title FROM comments;
+`,
+	},
+	{
+		Version:     21,
+		Description: "Create tags and collections tables",
+		Up: `
+-- User-defined tags (标签) and named collections (合集) for download records
+CREATE TABLE IF NOT EXISTS tags (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    color TEXT DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS download_record_tags (
+    record_id TEXT NOT NULL,
+    tag_id TEXT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (record_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_download_record_tags_tag_id ON download_record_tags(tag_id);
+
+CREATE TABLE IF NOT EXISTS collections (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    description TEXT DEFAULT '',
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS collection_records (
+    collection_id TEXT NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    record_id TEXT NOT NULL,
+    added_at DATETIME NOT NULL,
+    PRIMARY KEY (collection_id, record_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_collection_records_record_id ON collection_records(record_id);
+
+-- record_id has no foreign key: download records are overwritten in place and must keep their tags.
+-- Links are removed by this trigger when a record is actually deleted.
+CREATE TRIGGER IF NOT EXISTS download_records_links_ad AFTER DELETE ON download_records BEGIN
+    DELETE FROM download_record_tags WHERE record_id = old.id;
+    DELETE FROM collection_records WHERE record_id = old.id;
+END;
+`,
+	},
+	{
+		Version:     22,
+		Description: "Create authors tables and link download records and queue items to authors",
+		Up: `
+-- Authors (作者) keyed by AuthorID (the finder username), so renamed accounts keep a single history
+CREATE TABLE IF NOT EXISTS authors (
+    id TEXT PRIMARY KEY,
+    nickname TEXT DEFAULT '',
+    avatar_url TEXT DEFAULT '',
+    first_seen_at DATETIME NOT NULL,
+    last_seen_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_authors_last_seen_at ON authors(last_seen_at);
+
+-- Every nickname an author has used
+CREATE TABLE IF NOT EXISTS author_nicknames (
+    author_id TEXT NOT NULL REFERENCES authors(id) ON DELETE CASCADE,
+    nickname TEXT NOT NULL,
+    first_seen_at DATETIME NOT NULL,
+    last_seen_at DATETIME NOT NULL,
+    PRIMARY KEY (author_id, nickname)
+);
+
+CREATE INDEX IF NOT EXISTS idx_author_nicknames_nickname ON author_nicknames(nickname);
+
+ALTER TABLE download_records ADD COLUMN author_id TEXT DEFAULT '';
+ALTER TABLE download_queue ADD COLUMN author_id TEXT DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_download_records_author_id ON download_records(author_id);
+CREATE INDEX IF NOT EXISTS idx_browse_history_author_id ON browse_history(author_id);
+
+-- Backfill authors and nickname history from browse history
+INSERT OR IGNORE INTO authors (id, nickname, avatar_url, first_seen_at, last_seen_at, updated_at)
+SELECT b.author_id,
+       (SELECT b2.author FROM browse_history b2 WHERE b2.author_id = b.author_id ORDER BY b2.browse_time DESC LIMIT 1),
+       '', MIN(b.browse_time), MAX(b.browse_time), CURRENT_TIMESTAMP
+FROM browse_history b
+WHERE b.author_id IS NOT NULL AND b.author_id != ''
+GROUP BY b.author_id;
+
+INSERT OR IGNORE INTO author_nicknames (author_id, nickname, first_seen_at, last_seen_at)
+SELECT author_id, author, MIN(browse_time), MAX(browse_time)
+FROM browse_history
+WHERE author_id IS NOT NULL AND author_id != '' AND author IS NOT NULL AND author != ''
+GROUP BY author_id, author;
+
+-- Link existing downloads to the author of the browsed video
+UPDATE download_records SET author_id = COALESCE(
+    (SELECT b.author_id FROM browse_history b WHERE b.id = download_records.video_id AND b.author_id != ''), '')
+WHERE author_id IS NULL OR author_id = '';
+`,
+	},
+	{
+		Version:     23,
+		Description: "Create video_metrics_snapshots table for engagement history",
+		Up: `
+-- Engagement snapshots (互动数据快照), one row per observation of a browsed video
+CREATE TABLE IF NOT EXISTS video_metrics_snapshots (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    video_id TEXT NOT NULL REFERENCES browse_history(id) ON DELETE CASCADE,
+    like_count INTEGER DEFAULT 0,
+    comment_count INTEGER DEFAULT 0,
+    fav_count INTEGER DEFAULT 0,
+    forward_count INTEGER DEFAULT 0,
+    captured_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_video_metrics_snapshots_video ON video_metrics_snapshots(video_id, captured_at);
+
+-- The last observation of each existing browse record becomes its first snapshot
+INSERT INTO video_metrics_snapshots (video_id, like_count, comment_count, fav_count, forward_count, captured_at)
+SELECT id, COALESCE(like_count, 0), COALESCE(comment_count, 0), COALESCE(fav_count, 0), COALESCE(forward_count, 0), browse_time
+FROM browse_history;
+`,
+	},
+	{
+		Version:     24,
+		Description: "Add file_path column to download_queue table",
+		Up: `
+-- Where the finished file was saved (after templating and dedupe), used to verify it later
+ALTER TABLE download_queue ADD COLUMN file_path TEXT DEFAULT '';
+
+-- Completed items take the path of the latest completed download record of the same video
+UPDATE download_queue SET file_path = COALESCE(
+    (SELECT d.file_path FROM download_records d
+     WHERE d.video_id = download_queue.video_id AND d.status = 'completed' AND d.file_path != ''
+     ORDER BY d.download_time DESC LIMIT 1), '')
+WHERE status = 'completed' AND video_id != '';
+`,
+	},
+	{
+		Version:     25,
+		Description: "Remove download_records_fts entries left behind by INSERT OR REPLACE",
+		Supported:   fts5Supported,
+		Up: `
+-- Earlier builds overwrote download records with INSERT OR REPLACE, which moved the record to a new rowid
+-- without removing the index entry of the old one
+DELETE FROM download_records_fts WHERE rowid NOT IN (SELECT rowid FROM download_records);
+`,
+	},
+}
+
+// fts5Supported 检查当前 SQLite 是否启用了 FTS5（mattn/go-sqlite3 需要使用 sqlite_fts5 构建标签）
+func fts5Supported() bool {
+	var enabled int
+	if err := db.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled); err != nil {
+		return false
+	}
+	return enabled == 1
 }
 
 // runMigrations 执行所有待处理的迁移
@@ -244,16 +585,20 @@ func runMigrations() error {
 		return fmt.Errorf("failed to create migrations table: %w", err)
 	}
 
-	// 获取当前版本
-	var currentVersion int
-	err = db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&currentVersion)
+	// 获取已执行的迁移（因功能不可用而跳过的迁移不在其中）
+	applied, err := appliedMigrations()
 	if err != nil {
-		return fmt.Errorf("failed to get current schema version: %w", err)
+		return err
 	}
 
 	// 运行待处理的迁移
 	for _, m := range migrations {
-		if m.Version > currentVersion {
+		if !applied[m.Version] {
+			if m.Supported != nil && !m.Supported() {
+				utils.Warn("Skipped migration %d: %s (not supported by this SQLite build)", m.Version, m.Description)
+				continue
+			}
+
 			// 开启事务
 			tx, err := db.Begin()
 			if err != nil {
@@ -286,10 +631,45 @@ func runMigrations() error {
 	return nil
 }
 
+// appliedMigrations 返回已执行的迁移版本
+func appliedMigrations() (map[int]bool, error) {
+	return appliedVersions(db)
+}
+
+// AppliedMigrations 返回当前数据库已执行的迁移版本
+// 迁移可能因 SQLite 功能不可用而跳过，因此已执行的版本不一定连续，比较数据库时应使用版本集合而不是最大版本
+func AppliedMigrations() (map[int]bool, error) {
+	return appliedVersions(db)
+}
+
+// appliedVersions 返回指定数据库已执行的迁移版本
+func appliedVersions(conn *sql.DB) (map[int]bool, error) {
+	rows, err := conn.Query("SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to scan migration version: %w", err)
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
 // GetSchemaVersion 返回当前架构版本
 func GetSchemaVersion() (int, error) {
+	return schemaVersion(db)
+}
+
+// schemaVersion 返回指定数据库的架构版本
+func schemaVersion(conn *sql.DB) (int, error) {
 	var version int
-	err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	err := conn.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get schema version: %w", err)
 	}
@@ -1,9 +1,148 @@
 package database
 
 import (
+	"fmt"
 	"time"
 )
 
+// Comment 表示采集保存的视频评论
+type Comment struct {
+	ID         string    `json:"id"`
+	VideoID    string    `json:"videoId"`
+	VideoTitle string    `json:"videoTitle"`
+	ParentID   string    `json:"parentId"` // 二级回复所属的一级评论 ID，一级评论为空
+	Nickname   string    `json:"nickname"`
+	Content    string    `json:"content"`
+	LikeCount  int64     `json:"likeCount"`
+	IPLocation string    `json:"ipLocation"`
+	CreateTime time.Time `json:"createTime"`
+	SavedAt    time.Time `json:"savedAt"`
+}
+
+// Author 表示视频作者，以 AuthorID（视频号 username）为主键，昵称变更后仍是同一作者
+type Author struct {
+	ID          string           `json:"id"`
+	Nickname    string           `json:"nickname"` // 最近使用的昵称
+	AvatarURL   string           `json:"avatarUrl"`
+	Nicknames   []AuthorNickname `json:"nicknames,omitempty"` // 昵称历史，仅在详情中填充
+	FirstSeenAt time.Time        `json:"firstSeenAt"`
+	LastSeenAt  time.Time        `json:"lastSeenAt"`
+	UpdatedAt   time.Time        `json:"updatedAt"`
+}
+
+// AuthorNickname 表示作者使用过的昵称
+type AuthorNickname struct {
+	Nickname    string    `json:"nickname"`
+	FirstSeenAt time.Time `json:"firstSeenAt"`
+	LastSeenAt  time.Time `json:"lastSeenAt"`
+}
+
+// AuthorStats 表示作者及其浏览、下载汇总
+type AuthorStats struct {
+	Author
+	VideosBrowsed    int64   `json:"videosBrowsed"`
+	VideosDownloaded int64   `json:"videosDownloaded"` // 已完成的下载数
+	BytesStored      int64   `json:"bytesStored"`      // 已完成下载的文件总大小
+	AvgLikes         float64 `json:"avgLikes"`         // 以下为浏览过的视频的平均互动数据
+	AvgComments      float64 `json:"avgComments"`
+	AvgFavs          float64 `json:"avgFavs"`
+	AvgForwards      float64 `json:"avgForwards"`
+}
+
+// VideoMetricsSnapshot 表示某次看到视频时的互动数据
+type VideoMetricsSnapshot struct {
+	LikeCount    int64     `json:"likeCount"`
+	CommentCount int64     `json:"commentCount"`
+	FavCount     int64     `json:"favCount"`
+	ForwardCount int64     `json:"forwardCount"`
+	CapturedAt   time.Time `json:"capturedAt"`
+}
+
+// AnalyticsParams 统计报表的筛选参数
+type AnalyticsParams struct {
+	StartDate  *time.Time `json:"startDate"`
+	EndDate    *time.Time `json:"endDate"`
+	PageSource string     `json:"pageSource"` // 浏览视频的页面：feed、home、profile、search，为空时不限制
+	Limit      int        `json:"limit"`      // 排行榜返回的条数
+}
+
+// TrendingVideo 表示时间范围内互动数据增长的视频
+type TrendingVideo struct {
+	VideoID         string    `json:"videoId"`
+	Title           string    `json:"title"`
+	Author          string    `json:"author"`
+	AuthorID        string    `json:"authorId"`
+	CoverURL        string    `json:"coverUrl"`
+	LikeCount       int64     `json:"likeCount"` // 时间范围内最后一次快照的数据
+	CommentCount    int64     `json:"commentCount"`
+	LikeGrowth      int64     `json:"likeGrowth"` // 时间范围内第一次到最后一次快照的增长
+	CommentGrowth   int64     `json:"commentGrowth"`
+	LikeVelocity    float64   `json:"likeVelocity"` // 每天增长数
+	CommentVelocity float64   `json:"commentVelocity"`
+	FirstCapturedAt time.Time `json:"firstCapturedAt"`
+	LastCapturedAt  time.Time `json:"lastCapturedAt"`
+}
+
+// AuthorEngagement 表示作者在时间范围内浏览过的视频的互动汇总
+type AuthorEngagement struct {
+	AuthorID      string  `json:"authorId"` // 旧记录可能为空
+	Author        string  `json:"author"`
+	Videos        int64   `json:"videos"`
+	TotalLikes    int64   `json:"totalLikes"`
+	TotalComments int64   `json:"totalComments"`
+	TotalFavs     int64   `json:"totalFavs"`
+	TotalForwards int64   `json:"totalForwards"`
+	Engagement    int64   `json:"engagement"`    // 点赞、评论、收藏和转发数之和
+	AvgEngagement float64 `json:"avgEngagement"` // 每个视频的平均互动数
+}
+
+// DistributionBucket 表示分布中的一个分组
+type DistributionBucket struct {
+	Label string `json:"label"`
+	Count int64  `json:"count"`
+}
+
+// Tag 表示用户定义的下载记录标签
+type Tag struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"` // 不区分大小写唯一
+	Color       string    `json:"color"`
+	RecordCount int64     `json:"recordCount"` // 使用该标签的下载记录数
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// Collection 表示命名的下载记录合集
+type Collection struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"` // 不区分大小写唯一
+	Description string    `json:"description"`
+	RecordCount int64     `json:"recordCount"` // 合集中的下载记录数
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// SearchHit 表示一条全文搜索结果
+type SearchHit[T any] struct {
+	Item       T                 `json:"item"`
+	Score      float64           `json:"score"`      // bm25 相关度得分，越小越相关；未启用 FTS5 时为 0
+	Highlights map[string]string `json:"highlights"` // 字段名 -> 匹配文本用 <mark></mark> 标记后的内容
+}
+
+// TrashItem 表示移入回收站的下载文件
+type TrashItem struct {
+	ID           string          `json:"id"`
+	RecordID     string          `json:"recordId"`
+	Title        string          `json:"title"`
+	Author       string          `json:"author"`
+	OriginalPath string          `json:"originalPath"`     // 视频文件的原始路径
+	TrashPath    string          `json:"trashPath"`        // 回收站中存放该项目文件的目录
+	FileSize     int64           `json:"fileSize"`         // 移入回收站的文件总大小
+	Files        []string        `json:"files"`            // 移入回收站的所有文件（视频、元数据、封面）的原始路径
+	Record       *DownloadRecord `json:"record,omitempty"` // 删除时的下载记录，恢复时重新创建
+	DeletedAt    time.Time       `json:"deletedAt"`
+	ExpiresAt    *time.Time      `json:"expiresAt"` // 自动永久删除的时间（按设置计算，不存储），不自动删除时为 null
+}
+
 // BrowseRecord 表示视频浏览历史记录
 type BrowseRecord struct {
 	ID           string    `json:"id"`
@@ -32,6 +171,7 @@ type DownloadRecord struct {
 	VideoID      string    `json:"videoId"`
 	Title        string    `json:"title"`
 	Author       string    `json:"author"`
+	AuthorID     string    `json:"authorId"` // 作者 ID（视频号 username），为空时按视频 ID 从浏览记录中查找
 	CoverURL     string    `json:"coverUrl"` // 封面图片 URL
 	Duration     int64     `json:"duration"`
 	FileSize     int64     `json:"fileSize"`
@@ -45,6 +185,10 @@ type DownloadRecord struct {
 	CommentCount int64     `json:"commentCount"`
 	ForwardCount int64     `json:"forwardCount"`
 	FavCount     int64     `json:"favCount"`
+	ContentHash  string    `json:"contentHash"`    // 下载内容的 SHA-256（十六进制），写入元数据等后处理之前计算
+	Starred      bool      `json:"starred"`        // 加星的下载不会被保留策略删除
+	LastPlayedAt time.Time `json:"lastPlayedAt"`   // 最近一次在控制台播放的时间，从未播放时为零值
+	Tags         []string  `json:"tags,omitempty"` // 标签名称，仅在列表、详情和导出中填充
 	CreatedAt    time.Time `json:"createdAt"`
 	UpdatedAt    time.Time `json:"updatedAt"`
 }
@@ -59,29 +203,68 @@ const (
 
 // QueueItem 表示下载队列项目
 type QueueItem struct {
-	ID              string    `json:"id"`
-	VideoID         string    `json:"videoId"`
-	Title           string    `json:"title"`
-	Author          string    `json:"author"`
-	CoverURL        string    `json:"coverUrl"` // 封面图片 URL
-	VideoURL        string    `json:"videoUrl"`
-	DecryptKey      string    `json:"decryptKey"` // 加密视频的解密密钥
-	Duration        int64     `json:"duration"`   // 视频时长（秒）
-	Resolution      string    `json:"resolution"` // 视频分辨率（例如 "1080p"）
-	TotalSize       int64     `json:"totalSize"`
-	DownloadedSize  int64     `json:"downloadedSize"`
-	Status          string    `json:"status"` // pending, downloading, paused, completed, failed
-	Priority        int       `json:"priority"`
-	AddedTime       time.Time `json:"addedTime"`
-	StartTime       time.Time `json:"startTime"`
-	Speed           int64     `json:"speed"`
-	ChunkSize       int64     `json:"chunkSize"`
-	ChunksTotal     int       `json:"chunksTotal"`
-	ChunksCompleted int       `json:"chunksCompleted"`
-	RetryCount      int       `json:"retryCount"`
-	ErrorMessage    string    `json:"errorMessage"`
-	CreatedAt       time.Time `json:"createdAt"`
-	UpdatedAt       time.Time `json:"updatedAt"`
+	ID              string         `json:"id"`
+	VideoID         string         `json:"videoId"`
+	NonceID         string         `json:"nonceId"` // 视频 nonce ID，用于重新获取过期的下载地址
+	Title           string         `json:"title"`
+	Author          string         `json:"author"`
+	AuthorID        string         `json:"authorId"` // 作者 ID（视频号 username），可以为空
+	CoverURL        string         `json:"coverUrl"` // 封面图片 URL
+	VideoURL        string         `json:"videoUrl"`
+	DecryptKey      string         `json:"decryptKey"` // 加密视频的解密密钥
+	Duration        int64          `json:"duration"`   // 视频时长（秒）
+	Resolution      string         `json:"resolution"` // 视频分辨率（例如 "1080p"）
+	TotalSize       int64          `json:"totalSize"`
+	DownloadedSize  int64          `json:"downloadedSize"`
+	Status          string         `json:"status"` // pending, downloading, paused, completed, failed
+	Priority        int            `json:"priority"`
+	AddedTime       time.Time      `json:"addedTime"`
+	StartTime       time.Time      `json:"startTime"`
+	Speed           int64          `json:"speed"`
+	ChunkSize       int64          `json:"chunkSize"`
+	ChunksTotal     int            `json:"chunksTotal"`
+	ChunksCompleted int            `json:"chunksCompleted"`
+	ChunksBitmap    string         `json:"chunksBitmap"`  // 每个分片的完成状态，'1' 表示已完成
+	SpeedLimit      int64          `json:"speedLimit"`    // 单任务限速（字节/秒），0 表示使用默认设置
+	PauseReason     string         `json:"pauseReason"`   // 暂停原因：user（用户暂停）、schedule（时间窗口外自动暂停）或 disk（磁盘空间不足）
+	EmbedMetadata   bool           `json:"embedMetadata"` // 下载完成后是否将标题、作者和封面写入 MP4 文件
+	FilePath        string         `json:"filePath"`      // 下载完成后文件的实际保存路径
+	RetryCount      int            `json:"retryCount"`
+	RetryHistory    []RetryAttempt `json:"retryHistory"` // 最近的失败尝试记录（最多 MaxRetryHistory 条）
+	ErrorMessage    string         `json:"errorMessage"`
+	// 以下字段仅用于批量下载提交的项目
+	BatchID         string     `json:"batchId"`         // 批次 ID，为空表示普通队列项目
+	PageSource      string     `json:"pageSource"`      // 发起批量下载的页面来源（batch_console/batch_feed 等）
+	DecryptorPrefix string     `json:"decryptorPrefix"` // 旧版解密前缀（Base64），未提供 DecryptKey 时使用
+	PrefixLen       int        `json:"prefixLen"`       // 旧版解密前缀长度
+	BatchStats      BatchStats `json:"batchStats"`      // 批量下载提交的统计字段
+	CreatedAt       time.Time  `json:"createdAt"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+}
+
+// MaxRetryHistory 每个队列项目保留的失败尝试记录数
+const MaxRetryHistory = 20
+
+// RetryAttempt 一次失败的下载尝试
+type RetryAttempt struct {
+	Attempt int       `json:"attempt"` // 第几次尝试（从 1 开始）
+	Time    time.Time `json:"time"`
+	Error   string    `json:"error"`
+	Class   string    `json:"class"`   // 错误分类：transient（可重试）或 permanent（不再重试）
+	DelayMs int64     `json:"delayMs"` // 下一次重试前的等待时间，0 表示不再重试
+}
+
+// BatchStats 批量下载提交的统计字段，按前端提交的字符串原样保存
+type BatchStats struct {
+	Duration     string `json:"duration,omitempty"` // 时长字符串，如 "00:22"
+	SizeMB       string `json:"sizeMB,omitempty"`   // 大小字符串，如 "28.77MB"
+	PlayCount    string `json:"playCount,omitempty"`
+	LikeCount    string `json:"likeCount,omitempty"`
+	CommentCount string `json:"commentCount,omitempty"`
+	FavCount     string `json:"favCount,omitempty"`
+	ForwardCount string `json:"forwardCount,omitempty"`
+	CreateTime   string `json:"createTime,omitempty"`
+	IPRegion     string `json:"ipRegion,omitempty"`
 }
 
 // QueueStatus 常量
@@ -93,6 +276,13 @@ const (
 	QueueStatusFailed      = "failed"
 )
 
+// PauseReason 常量
+const (
+	PauseReasonUser     = "user"
+	PauseReasonSchedule = "schedule"
+	PauseReasonDisk     = "disk" // 磁盘空间不足或超出下载库容量
+)
+
 // Settings 表示应用程序设置
 type Settings struct {
 	DownloadDir        string `json:"downloadDir"`
@@ -102,18 +292,160 @@ type Settings struct {
 	AutoCleanupDays    int    `json:"autoCleanupDays"`
 	MaxRetries         int    `json:"maxRetries"`
 	Theme              string `json:"theme"`
+	BandwidthLimit     int64  `json:"bandwidthLimit"`     // 全局下载限速（字节/秒），0 表示不限速
+	TaskBandwidthLimit int64  `json:"taskBandwidthLimit"` // 默认单任务限速（字节/秒），0 表示不限速
+
+	ScheduleEnabled bool           `json:"scheduleEnabled"` // 是否只在时间窗口内下载
+	ScheduleRules   []ScheduleRule `json:"scheduleRules"`   // 允许下载的时间窗口
+
+	FilenameTemplate  string `json:"filenameTemplate"`  // 保存路径模板，例如 {author}/{yyyy-mm}/{date}_{title}.{ext}；为空时使用 作者/标题 布局
+	FilenameMaxLength int    `json:"filenameMaxLength"` // 文件名主体和目录名的最大字符数
+
+	EmbedMetadata bool `json:"embedMetadata"` // 新加入队列的任务默认是否将元数据写入 MP4 文件
+
+	DedupePolicy string `json:"dedupePolicy"` // 下载内容与已有文件重复时的处理方式：keep_both、skip、hardlink
+
+	MinFreeSpace   int64 `json:"minFreeSpace"`   // 下载目录所在磁盘的最小可用空间（字节），低于该值时暂停队列，0 表示不检查
+	MaxLibrarySize int64 `json:"maxLibrarySize"` // 已下载文件的最大总大小（字节），0 表示不限制
+
+	RetentionMaxSize       int64  `json:"retentionMaxSize"`       // 清理时将下载库保持在该大小（字节）以下，0 表示不按大小清理
+	RetentionEvictBy       string `json:"retentionEvictBy"`       // 超出大小时优先删除的文件：least_played（最久未播放）或 oldest（最早下载）
+	RetentionKeepPerAuthor int    `json:"retentionKeepPerAuthor"` // 每个作者保留的最新视频数，0 表示不限制
+
+	TrashRetentionDays int `json:"trashRetentionDays"` // 回收站中的文件保留天数，到期后自动永久删除，0 表示不自动删除
+
+	BackupIntervalHours int `json:"backupIntervalHours"` // 自动备份数据库的间隔（小时），0 表示不自动备份
+	BackupKeep          int `json:"backupKeep"`          // 保留的数据库备份数量，超出时删除最早的备份
+}
+
+// RetentionEvictBy 常量
+const (
+	RetentionEvictLeastPlayed = "least_played" // 最久未播放（从未播放的优先）
+	RetentionEvictOldest      = "oldest"       // 最早下载
+)
+
+// DedupePolicy 常量
+const (
+	DedupePolicyKeepBoth = "keep_both" // 保留两个文件
+	DedupePolicySkip     = "skip"      // 删除新文件，记录指向已有文件
+	DedupePolicyHardlink = "hardlink"  // 新文件替换为指向已有文件的硬链接
+)
+
+// ScheduleRule 表示允许下载的时间窗口，例如工作日 01:00-07:00
+// Start 大于 End 时表示跨越午夜的窗口（例如 23:00-06:00）
+type ScheduleRule struct {
+	Days  []int  `json:"days"`  // 星期几（0=周日 ... 6=周六），为空表示每天
+	Start string `json:"start"` // 开始时间 HH:MM
+	End   string `json:"end"`   // 结束时间 HH:MM
+}
+
+// parseClock 将 HH:MM 解析为从零点开始的分钟数
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Validate 验证时间窗口规则
+func (r ScheduleRule) Validate() error {
+	start, err := parseClock(r.Start)
+	if err != nil {
+		return err
+	}
+	end, err := parseClock(r.End)
+	if err != nil {
+		return err
+	}
+	if start == end {
+		return fmt.Errorf("schedule window %s-%s is empty", r.Start, r.End)
+	}
+	for _, d := range r.Days {
+		if d < 0 || d > 6 {
+			return fmt.Errorf("invalid weekday %d, expected 0-6", d)
+		}
+	}
+	return nil
+}
+
+// hasDay 检查规则是否适用于指定星期
+func (r ScheduleRule) hasDay(day time.Weekday) bool {
+	if len(r.Days) == 0 {
+		return true
+	}
+	for _, d := range r.Days {
+		if time.Weekday(d) == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains 检查时间是否落在窗口内
+// 跨午夜的窗口归属于开始的那一天（周五 23:00-02:00 覆盖周六凌晨）
+func (r ScheduleRule) Contains(t time.Time) bool {
+	start, err := parseClock(r.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(r.End)
+	if err != nil {
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+
+	if start < end {
+		return r.hasDay(t.Weekday()) && now >= start && now < end
+	}
+	// 跨越午夜
+	if now >= start {
+		return r.hasDay(t.Weekday())
+	}
+	if now < end {
+		return r.hasDay(t.AddDate(0, 0, -1).Weekday())
+	}
+	return false
+}
+
+// InDownloadWindow 检查当前是否允许下载
+// 未启用时间窗口或没有规则时始终允许
+func (s *Settings) InDownloadWindow(t time.Time) bool {
+	if !s.ScheduleEnabled || len(s.ScheduleRules) == 0 {
+		return true
+	}
+	for _, rule := range s.ScheduleRules {
+		if rule.Contains(t) {
+			return true
+		}
+	}
+	return false
 }
 
 // DefaultSettings 返回默认设置
 func DefaultSettings() *Settings {
 	return &Settings{
-		DownloadDir:        "downloads",
-		ChunkSize:          10 * 1024 * 1024, // 10MB
-		ConcurrentLimit:    3,
-		AutoCleanupEnabled: false,
-		AutoCleanupDays:    30,
-		MaxRetries:         3,
-		Theme:              "light",
+		DownloadDir:         "downloads",
+		ChunkSize:           10 * 1024 * 1024, // 10MB
+		ConcurrentLimit:     3,
+		AutoCleanupEnabled:  false,
+		AutoCleanupDays:     30,
+		MaxRetries:          3,
+		Theme:               "light",
+		BandwidthLimit:      0,
+		TaskBandwidthLimit:  0,
+		ScheduleEnabled:     false,
+		ScheduleRules:       []ScheduleRule{},
+		FilenameTemplate:    "",
+		FilenameMaxLength:   50,
+		EmbedMetadata:       true,
+		DedupePolicy:        DedupePolicyKeepBoth,
+		MinFreeSpace:        1024 * 1024 * 1024, // 1GB
+		MaxLibrarySize:      0,
+		RetentionEvictBy:    RetentionEvictLeastPlayed,
+		TrashRetentionDays:  30,
+		BackupIntervalHours: 24,
+		BackupKeep:          7,
 	}
 }
 
@@ -128,10 +460,13 @@ type PaginationParams struct {
 // FilterParams 表示下载记录的过滤参数
 type FilterParams struct {
 	PaginationParams
-	StartDate *time.Time `json:"startDate"`
-	EndDate   *time.Time `json:"endDate"`
-	Status    string     `json:"status"`
-	Query     string     `json:"query"`
+	StartDate    *time.Time `json:"startDate"`
+	EndDate      *time.Time `json:"endDate"`
+	Status       string     `json:"status"`
+	Query        string     `json:"query"`
+	Tag          string     `json:"tag"`          // 标签名称，不区分大小写
+	CollectionID string     `json:"collectionId"` // 合集 ID
+	AuthorID     string     `json:"authorId"`     // 作者 ID，同时匹配未关联作者 ID 但昵称属于该作者的记录
 }
 
 // PagedResult 表示分页结果
@@ -2,6 +2,7 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -17,26 +18,151 @@ func NewQueueRepository() *QueueRepository {
 	return &QueueRepository{db: GetDB()}
 }
 
+// queueItemColumns 查询队列项目时使用的列
+const queueItemColumns = `id, video_id, COALESCE(nonce_id, '') as nonce_id, title, author, COALESCE(author_id, '') as author_id, COALESCE(cover_url, '') as cover_url, video_url, decrypt_key,
+			COALESCE(duration, 0) as duration, COALESCE(resolution, '') as resolution, total_size, downloaded_size,
+			status, priority, added_time, start_time, speed, chunk_size,
+			chunks_total, chunks_completed, COALESCE(chunks_bitmap, '') as chunks_bitmap, COALESCE(speed_limit, 0) as speed_limit,
+			COALESCE(pause_reason, '') as pause_reason, COALESCE(embed_metadata, 1) as embed_metadata,
+			COALESCE(file_path, '') as file_path, retry_count,
+			COALESCE(retry_history, '') as retry_history, error_message,
+			COALESCE(batch_id, '') as batch_id, COALESCE(page_source, '') as page_source, COALESCE(decryptor_prefix, '') as decryptor_prefix,
+			COALESCE(prefix_len, 0) as prefix_len, COALESCE(batch_stats, '') as batch_stats, created_at, updated_at`
+
+// rowScanner 是 *sql.Row 和 *sql.Rows 的公共接口
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanQueueItem 将一行查询结果扫描为队列项目
+func scanQueueItem(row rowScanner) (*QueueItem, error) {
+	item := &QueueItem{}
+	var startTime sql.NullTime
+	var errorMessage sql.NullString
+	var decryptKey sql.NullString
+	var coverURL sql.NullString
+	var resolution sql.NullString
+	var chunksBitmap sql.NullString
+	var batchStats string
+	var retryHistory string
+	err := row.Scan(
+		&item.ID, &item.VideoID, &item.NonceID, &item.Title, &item.Author, &item.AuthorID, &coverURL, &item.VideoURL, &decryptKey,
+		&item.Duration, &resolution, &item.TotalSize, &item.DownloadedSize, &item.Status, &item.Priority,
+		&item.AddedTime, &startTime, &item.Speed, &item.ChunkSize,
+		&item.ChunksTotal, &item.ChunksCompleted, &chunksBitmap, &item.SpeedLimit,
+		&item.PauseReason, &item.EmbedMetadata, &item.FilePath, &item.RetryCount,
+		&retryHistory, &errorMessage, &item.BatchID, &item.PageSource, &item.DecryptorPrefix,
+		&item.PrefixLen, &batchStats, &item.CreatedAt, &item.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if batchStats != "" {
+		if err := json.Unmarshal([]byte(batchStats), &item.BatchStats); err != nil {
+			return nil, fmt.Errorf("failed to decode batch stats: %w", err)
+		}
+	}
+	item.RetryHistory = decodeRetryHistory(retryHistory)
+	if startTime.Valid {
+		item.StartTime = startTime.Time
+	}
+	item.CoverURL = coverURL.String
+	item.Resolution = resolution.String
+	item.ChunksBitmap = chunksBitmap.String
+	item.ErrorMessage = errorMessage.String
+	item.DecryptKey = decryptKey.String
+	return item, nil
+}
+
+// queryQueueItems 执行查询并返回队列项目列表
+func (r *QueueRepository) queryQueueItems(query string, args ...interface{}) ([]QueueItem, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []QueueItem
+	for rows.Next() {
+		item, err := scanQueueItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan queue item: %w", err)
+		}
+		items = append(items, *item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if items == nil {
+		items = []QueueItem{}
+	}
+
+	return items, nil
+}
+
+// encodeBatchStats 将批量下载统计字段编码为 JSON，普通队列项目保存为空字符串
+func encodeBatchStats(stats BatchStats) (string, error) {
+	if stats == (BatchStats{}) {
+		return "", nil
+	}
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode batch stats: %w", err)
+	}
+	return string(data), nil
+}
+
+// encodeRetryHistory 将失败尝试记录编码为 JSON，没有记录时保存为空字符串
+func encodeRetryHistory(history []RetryAttempt) (string, error) {
+	if len(history) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(history)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode retry history: %w", err)
+	}
+	return string(data), nil
+}
+
+// decodeRetryHistory 解析失败尝试记录，无法解析时返回空列表
+func decodeRetryHistory(data string) []RetryAttempt {
+	history := []RetryAttempt{}
+	if data != "" {
+		_ = json.Unmarshal([]byte(data), &history)
+	}
+	return history
+}
+
 // Add 插入新的队列项目
 func (r *QueueRepository) Add(item *QueueItem) error {
 	now := time.Now()
 	item.CreatedAt = now
 	item.UpdatedAt = now
 
+	batchStats, err := encodeBatchStats(item.BatchStats)
+	if err != nil {
+		return err
+	}
+	retryHistory, err := encodeRetryHistory(item.RetryHistory)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		INSERT INTO download_queue (
-			id, video_id, title, author, cover_url, video_url, decrypt_key, duration, resolution, total_size, downloaded_size,
+			id, video_id, nonce_id, title, author, author_id, cover_url, video_url, decrypt_key, duration, resolution, total_size, downloaded_size,
 			status, priority, added_time, start_time, speed, chunk_size,
-			chunks_total, chunks_completed, retry_count, error_message,
-			created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			chunks_total, chunks_completed, chunks_bitmap, speed_limit, pause_reason, embed_metadata, file_path, retry_count, retry_history, error_message,
+			batch_id, page_source, decryptor_prefix, prefix_len, batch_stats, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
-	_, err := r.db.Exec(query,
-		item.ID, item.VideoID, item.Title, item.Author, item.CoverURL, item.VideoURL, item.DecryptKey,
+	_, err = r.db.Exec(query,
+		item.ID, item.VideoID, item.NonceID, item.Title, item.Author, item.AuthorID, item.CoverURL, item.VideoURL, item.DecryptKey,
 		item.Duration, item.Resolution, item.TotalSize, item.DownloadedSize, item.Status, item.Priority,
 		item.AddedTime, item.StartTime, item.Speed, item.ChunkSize,
-		item.ChunksTotal, item.ChunksCompleted, item.RetryCount,
-		item.ErrorMessage, item.CreatedAt, item.UpdatedAt,
+		item.ChunksTotal, item.ChunksCompleted, item.ChunksBitmap, item.SpeedLimit, item.PauseReason, item.EmbedMetadata, item.FilePath, item.RetryCount,
+		retryHistory, item.ErrorMessage, item.BatchID, item.PageSource, item.DecryptorPrefix, item.PrefixLen, batchStats, item.CreatedAt, item.UpdatedAt,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to add queue item: %w", err)
@@ -46,40 +172,14 @@ func (r *QueueRepository) Add(item *QueueItem) error {
 
 // GetByID 根据 ID 获取队列项目
 func (r *QueueRepository) GetByID(id string) (*QueueItem, error) {
-	query := `
-		SELECT id, video_id, title, author, COALESCE(cover_url, '') as cover_url, video_url, decrypt_key, 
-			COALESCE(duration, 0) as duration, COALESCE(resolution, '') as resolution, total_size, downloaded_size,
-			status, priority, added_time, start_time, speed, chunk_size,
-			chunks_total, chunks_completed, retry_count, error_message,
-			created_at, updated_at
-		FROM download_queue WHERE id = ?
-	`
-	item := &QueueItem{}
-	var startTime sql.NullTime
-	var errorMessage sql.NullString
-	var decryptKey sql.NullString
-	var coverURL sql.NullString
-	var resolution sql.NullString
-	err := r.db.QueryRow(query, id).Scan(
-		&item.ID, &item.VideoID, &item.Title, &item.Author, &coverURL, &item.VideoURL, &decryptKey,
-		&item.Duration, &resolution, &item.TotalSize, &item.DownloadedSize, &item.Status, &item.Priority,
-		&item.AddedTime, &startTime, &item.Speed, &item.ChunkSize,
-		&item.ChunksTotal, &item.ChunksCompleted, &item.RetryCount,
-		&errorMessage, &item.CreatedAt, &item.UpdatedAt,
-	)
+	query := `SELECT ` + queueItemColumns + ` FROM download_queue WHERE id = ?`
+	item, err := scanQueueItem(r.db.QueryRow(query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get queue item: %w", err)
 	}
-	if startTime.Valid {
-		item.StartTime = startTime.Time
-	}
-	item.CoverURL = coverURL.String
-	item.Resolution = resolution.String
-	item.ErrorMessage = errorMessage.String
-	item.DecryptKey = decryptKey.String
 	return item, nil
 }
 
@@ -87,20 +187,30 @@ func (r *QueueRepository) GetByID(id string) (*QueueItem, error) {
 func (r *QueueRepository) Update(item *QueueItem) error {
 	item.UpdatedAt = time.Now()
 
+	batchStats, err := encodeBatchStats(item.BatchStats)
+	if err != nil {
+		return err
+	}
+	retryHistory, err := encodeRetryHistory(item.RetryHistory)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		UPDATE download_queue SET
-			video_id = ?, title = ?, author = ?, cover_url = ?, video_url = ?, decrypt_key = ?, duration = ?, total_size = ?,
+			video_id = ?, nonce_id = ?, title = ?, author = ?, author_id = ?, cover_url = ?, video_url = ?, decrypt_key = ?, duration = ?, resolution = ?, total_size = ?,
 			downloaded_size = ?, status = ?, priority = ?, added_time = ?,
 			start_time = ?, speed = ?, chunk_size = ?, chunks_total = ?,
-			chunks_completed = ?, retry_count = ?, error_message = ?, updated_at = ?
+			chunks_completed = ?, chunks_bitmap = ?, speed_limit = ?, pause_reason = ?, embed_metadata = ?, file_path = ?, retry_count = ?, retry_history = ?, error_message = ?,
+			batch_id = ?, page_source = ?, decryptor_prefix = ?, prefix_len = ?, batch_stats = ?, updated_at = ?
 		WHERE id = ?
 	`
 	result, err := r.db.Exec(query,
-		item.VideoID, item.Title, item.Author, item.CoverURL, item.VideoURL, item.DecryptKey, item.Duration, item.TotalSize,
+		item.VideoID, item.NonceID, item.Title, item.Author, item.AuthorID, item.CoverURL, item.VideoURL, item.DecryptKey, item.Duration, item.Resolution, item.TotalSize,
 		item.DownloadedSize, item.Status, item.Priority, item.AddedTime,
 		item.StartTime, item.Speed, item.ChunkSize, item.ChunksTotal,
-		item.ChunksCompleted, item.RetryCount, item.ErrorMessage,
-		item.UpdatedAt, item.ID,
+		item.ChunksCompleted, item.ChunksBitmap, item.SpeedLimit, item.PauseReason, item.EmbedMetadata, item.FilePath, item.RetryCount, retryHistory, item.ErrorMessage,
+		item.BatchID, item.PageSource, item.DecryptorPrefix, item.PrefixLen, batchStats, item.UpdatedAt, item.ID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to update queue item: %w", err)
@@ -159,106 +269,59 @@ func (r *QueueRepository) Clear() error {
 // List 获取按优先级和添加时间排序的所有队列项目
 func (r *QueueRepository) List() ([]QueueItem, error) {
 	query := `
-		SELECT id, video_id, title, author, COALESCE(cover_url, '') as cover_url, video_url, decrypt_key, 
-			COALESCE(duration, 0) as duration, total_size, downloaded_size,
-			status, priority, added_time, start_time, speed, chunk_size,
-			chunks_total, chunks_completed, retry_count, error_message,
-			created_at, updated_at
+		SELECT ` + queueItemColumns + `
 		FROM download_queue
 		ORDER BY priority DESC, added_time ASC
 	`
 
-	rows, err := r.db.Query(query)
+	items, err := r.queryQueueItems(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list queue items: %w", err)
 	}
-	defer rows.Close()
-
-	var items []QueueItem
-	for rows.Next() {
-		var item QueueItem
-		var startTime sql.NullTime
-		var errorMessage sql.NullString
-		var decryptKey sql.NullString
-		var coverURL sql.NullString
-		err := rows.Scan(
-			&item.ID, &item.VideoID, &item.Title, &item.Author, &coverURL, &item.VideoURL, &decryptKey,
-			&item.Duration, &item.TotalSize, &item.DownloadedSize, &item.Status, &item.Priority,
-			&item.AddedTime, &startTime, &item.Speed, &item.ChunkSize,
-			&item.ChunksTotal, &item.ChunksCompleted, &item.RetryCount,
-			&errorMessage, &item.CreatedAt, &item.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan queue item: %w", err)
-		}
-		if startTime.Valid {
-			item.StartTime = startTime.Time
-		}
-		item.CoverURL = coverURL.String
-		item.ErrorMessage = errorMessage.String
-		item.DecryptKey = decryptKey.String
-		items = append(items, item)
-	}
-
-	if items == nil {
-		items = []QueueItem{}
-	}
-
 	return items, nil
 }
 
 // ListByStatus 获取指定状态的队列项目
 func (r *QueueRepository) ListByStatus(status string) ([]QueueItem, error) {
 	query := `
-		SELECT id, video_id, title, author, COALESCE(cover_url, '') as cover_url, video_url, decrypt_key, 
-			COALESCE(duration, 0) as duration, total_size, downloaded_size,
-			status, priority, added_time, start_time, speed, chunk_size,
-			chunks_total, chunks_completed, retry_count, error_message,
-			created_at, updated_at
+		SELECT ` + queueItemColumns + `
 		FROM download_queue
 		WHERE status = ?
 		ORDER BY priority DESC, added_time ASC
 	`
 
-	rows, err := r.db.Query(query, status)
+	items, err := r.queryQueueItems(query, status)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list queue items by status: %w", err)
 	}
-	defer rows.Close()
+	return items, nil
+}
 
-	var items []QueueItem
-	for rows.Next() {
-		var item QueueItem
-		var startTime sql.NullTime
-		var errorMessage sql.NullString
-		var decryptKey sql.NullString
-		var coverURL sql.NullString
-		err := rows.Scan(
-			&item.ID, &item.VideoID, &item.Title, &item.Author, &coverURL, &item.VideoURL, &decryptKey,
-			&item.Duration, &item.TotalSize, &item.DownloadedSize, &item.Status, &item.Priority,
-			&item.AddedTime, &startTime, &item.Speed, &item.ChunkSize,
-			&item.ChunksTotal, &item.ChunksCompleted, &item.RetryCount,
-			&errorMessage, &item.CreatedAt, &item.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan queue item: %w", err)
-		}
-		if startTime.Valid {
-			item.StartTime = startTime.Time
-		}
-		item.CoverURL = coverURL.String
-		item.ErrorMessage = errorMessage.String
-		item.DecryptKey = decryptKey.String
-		items = append(items, item)
-	}
+// ListBatch 获取批量下载提交的队列项目，按提交顺序排列
+func (r *QueueRepository) ListBatch() ([]QueueItem, error) {
+	query := `
+		SELECT ` + queueItemColumns + `
+		FROM download_queue
+		WHERE batch_id != ''
+		ORDER BY added_time ASC, priority DESC
+	`
 
-	if items == nil {
-		items = []QueueItem{}
+	items, err := r.queryQueueItems(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list batch queue items: %w", err)
 	}
-
 	return items, nil
 }
 
+// RemoveBatch 删除所有批量下载提交的队列项目
+func (r *QueueRepository) RemoveBatch() (int64, error) {
+	result, err := r.db.Exec("DELETE FROM download_queue WHERE batch_id != ''")
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove batch queue items: %w", err)
+	}
+	return result.RowsAffected()
+}
+
 // UpdateStatus 更新队列项目的状态
 func (r *QueueRepository) UpdateStatus(id string, status string) error {
 	query := "UPDATE download_queue SET status = ?, updated_at = ? WHERE id = ?"
@@ -273,6 +336,32 @@ func (r *QueueRepository) UpdateStatus(id string, status string) error {
 	return nil
 }
 
+// TransitionStatus 仅当项目仍处于 from 状态时更新为 to，返回是否更新
+// 用于后台协程的状态变化，避免覆盖期间由用户或调度器写入的状态
+func (r *QueueRepository) TransitionStatus(id string, from, to string) (bool, error) {
+	query := "UPDATE download_queue SET status = ?, updated_at = ? WHERE id = ? AND status = ?"
+	result, err := r.db.Exec(query, to, time.Now(), id, from)
+	if err != nil {
+		return false, fmt.Errorf("failed to update queue item status: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	return rows > 0, nil
+}
+
+// UpdateStatusWithReason 更新队列项目的状态和暂停原因
+func (r *QueueRepository) UpdateStatusWithReason(id string, status string, pauseReason string) error {
+	query := "UPDATE download_queue SET status = ?, pause_reason = ?, updated_at = ? WHERE id = ?"
+	result, err := r.db.Exec(query, status, pauseReason, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update queue item status: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("queue item not found: %s", id)
+	}
+	return nil
+}
+
 // UpdateProgress 更新队列项目的下载进度
 func (r *QueueRepository) UpdateProgress(id string, downloadedSize int64, chunksCompleted int, speed int64) error {
 	query := `
@@ -291,6 +380,24 @@ func (r *QueueRepository) UpdateProgress(id string, downloadedSize int64, chunks
 	return nil
 }
 
+// UpdateChunkProgress 更新队列项目的分片完成位图和下载进度
+func (r *QueueRepository) UpdateChunkProgress(id string, chunksBitmap string, downloadedSize int64, chunksCompleted int, speed int64) error {
+	query := `
+		UPDATE download_queue SET
+			chunks_bitmap = ?, downloaded_size = ?, chunks_completed = ?, speed = ?, updated_at = ?
+		WHERE id = ?
+	`
+	result, err := r.db.Exec(query, chunksBitmap, downloadedSize, chunksCompleted, speed, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update queue item chunk progress: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("queue item not found: %s", id)
+	}
+	return nil
+}
+
 // Reorder 根据新顺序更新队列项目的优先级
 func (r *QueueRepository) Reorder(ids []string) error {
 	if len(ids) == 0 {
@@ -345,40 +452,19 @@ func (r *QueueRepository) CountByStatus(status string) (int64, error) {
 // GetNextPending 获取下一个待处理的队列项目
 func (r *QueueRepository) GetNextPending() (*QueueItem, error) {
 	query := `
-		SELECT id, video_id, title, author, COALESCE(cover_url, '') as cover_url, video_url, decrypt_key, 
-			COALESCE(duration, 0) as duration, total_size, downloaded_size,
-			status, priority, added_time, start_time, speed, chunk_size,
-			chunks_total, chunks_completed, retry_count, error_message,
-			created_at, updated_at
+		SELECT ` + queueItemColumns + `
 		FROM download_queue
 		WHERE status = ?
 		ORDER BY priority DESC, added_time ASC
 		LIMIT 1
 	`
-	item := &QueueItem{}
-	var startTime sql.NullTime
-	var errorMessage sql.NullString
-	var decryptKey sql.NullString
-	var coverURL sql.NullString
-	err := r.db.QueryRow(query, QueueStatusPending).Scan(
-		&item.ID, &item.VideoID, &item.Title, &item.Author, &coverURL, &item.VideoURL, &decryptKey,
-		&item.Duration, &item.TotalSize, &item.DownloadedSize, &item.Status, &item.Priority,
-		&item.AddedTime, &startTime, &item.Speed, &item.ChunkSize,
-		&item.ChunksTotal, &item.ChunksCompleted, &item.RetryCount,
-		&errorMessage, &item.CreatedAt, &item.UpdatedAt,
-	)
+	item, err := scanQueueItem(r.db.QueryRow(query, QueueStatusPending))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get next pending queue item: %w", err)
 	}
-	if startTime.Valid {
-		item.StartTime = startTime.Time
-	}
-	item.CoverURL = coverURL.String
-	item.ErrorMessage = errorMessage.String
-	item.DecryptKey = decryptKey.String
 	return item, nil
 }
 
@@ -396,6 +482,41 @@ func (r *QueueRepository) IncrementRetryCount(id string) error {
 	return nil
 }
 
+// AppendRetryAttempt 追加一次失败尝试记录，只保留最近的 MaxRetryHistory 条
+func (r *QueueRepository) AppendRetryAttempt(id string, attempt RetryAttempt) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var data string
+	err = tx.QueryRow("SELECT COALESCE(retry_history, '') FROM download_queue WHERE id = ?", id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("queue item not found: %s", id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get retry history: %w", err)
+	}
+
+	history := append(decodeRetryHistory(data), attempt)
+	if len(history) > MaxRetryHistory {
+		history = history[len(history)-MaxRetryHistory:]
+	}
+	encoded, err := encodeRetryHistory(history)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("UPDATE download_queue SET retry_history = ?, updated_at = ? WHERE id = ?", encoded, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to update retry history: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
 // SetStartTime 设置队列项目的开始时间
 func (r *QueueRepository) SetStartTime(id string, startTime time.Time) error {
 	query := "UPDATE download_queue SET start_time = ?, updated_at = ? WHERE id = ?"
@@ -410,6 +531,48 @@ func (r *QueueRepository) SetStartTime(id string, startTime time.Time) error {
 	return nil
 }
 
+// SetSpeedLimit 设置队列项目的限速（字节/秒）
+func (r *QueueRepository) SetSpeedLimit(id string, bytesPerSecond int64) error {
+	query := "UPDATE download_queue SET speed_limit = ?, updated_at = ? WHERE id = ?"
+	result, err := r.db.Exec(query, bytesPerSecond, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to set speed limit: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("queue item not found: %s", id)
+	}
+	return nil
+}
+
+// SetEmbedMetadata 设置队列项目下载完成后是否写入 MP4 元数据
+func (r *QueueRepository) SetEmbedMetadata(id string, enabled bool) error {
+	query := "UPDATE download_queue SET embed_metadata = ?, updated_at = ? WHERE id = ?"
+	result, err := r.db.Exec(query, enabled, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to set embed metadata: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("queue item not found: %s", id)
+	}
+	return nil
+}
+
+// UpdateVideoSource 更新队列项目的下载地址和解密密钥
+func (r *QueueRepository) UpdateVideoSource(id string, videoURL string, decryptKey string) error {
+	query := "UPDATE download_queue SET video_url = ?, decrypt_key = ?, updated_at = ? WHERE id = ?"
+	result, err := r.db.Exec(query, videoURL, decryptKey, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to update video source: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("queue item not found: %s", id)
+	}
+	return nil
+}
+
 // SetError 设置队列项目的错误信息
 func (r *QueueRepository) SetError(id string, errorMessage string) error {
 	query := "UPDATE download_queue SET error_message = ?, status = ?, updated_at = ? WHERE id = ?"
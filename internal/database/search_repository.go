@@ -0,0 +1,343 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+// 高亮结果是转义后的 HTML，只包含 <mark></mark> 标记
+const (
+	highlightOpen  = "<mark>"
+	highlightClose = "</mark>"
+)
+
+// 标记匹配位置的私有控制字符，转义原文后再替换为 HTML 标记，原文中的同名字符会被丢弃
+const (
+	sentinelOpen  = "\x01"
+	sentinelClose = "\x02"
+)
+
+// ftsMinTermLength trigram 分词器能匹配的最短关键词（字符数）
+const ftsMinTermLength = 3
+
+// searchSource 描述一个可全文搜索的表
+type searchSource struct {
+	table    string   // 源表
+	ftsTable string   // FTS5 索引表（迁移 20 创建）
+	fields   []string // 索引的列，顺序与 FTS5 表一致
+	keys     []string // 高亮结果中对应的字段名
+	columns  string   // 查询源表时选择的列
+	orderBy  string   // 不按相关度排序时的排序方式
+}
+
+var (
+	browseSearchSource = searchSource{
+		table:    "browse_history",
+		ftsTable: "browse_history_fts",
+		fields:   []string{"title", "author"},
+		keys:     []string{"title", "author"},
+		columns:  browseRecordColumns,
+		orderBy:  "browse_time DESC",
+	}
+	downloadSearchSource = searchSource{
+		table:    "download_records",
+		ftsTable: "download_records_fts",
+		fields:   []string{"title", "author"},
+		keys:     []string{"title", "author"},
+		columns:  downloadRecordColumns,
+		orderBy:  "download_time DESC",
+	}
+	commentSearchSource = searchSource{
+		table:    "comments",
+		ftsTable: "comments_fts",
+		fields:   []string{"content", "nickname", "video_title"},
+		keys:     []string{"content", "nickname", "videoTitle"},
+		columns:  commentColumns,
+		orderBy:  "saved_at DESC",
+	}
+)
+
+// SearchRepository 使用 FTS5 全文索引搜索浏览记录、下载记录和评论
+// 当前 SQLite 未启用 FTS5 或关键词少于 3 个字符时回退到 LIKE 搜索
+type SearchRepository struct {
+	db *sql.DB
+}
+
+// NewSearchRepository 创建一个新的 SearchRepository
+func NewSearchRepository() *SearchRepository {
+	return &SearchRepository{db: GetDB()}
+}
+
+// FTSEnabled 是否已创建全文索引
+func (r *SearchRepository) FTSEnabled() bool {
+	return ftsIndexExists(r.db, browseSearchSource.ftsTable)
+}
+
+// SearchBrowse 搜索浏览记录的标题和作者
+func (r *SearchRepository) SearchBrowse(query string, params *PaginationParams) (*PagedResult[SearchHit[BrowseRecord]], error) {
+	return searchTable(r.db, browseSearchSource, query, params, scanBrowseRecord)
+}
+
+// SearchDownloads 搜索下载记录的标题和作者
+func (r *SearchRepository) SearchDownloads(query string, params *PaginationParams) (*PagedResult[SearchHit[DownloadRecord]], error) {
+	return searchTable(r.db, downloadSearchSource, query, params, scanDownloadRecord)
+}
+
+// SearchComments 搜索评论内容、评论者昵称和视频标题
+func (r *SearchRepository) SearchComments(query string, params *PaginationParams) (*PagedResult[SearchHit[Comment]], error) {
+	return searchTable(r.db, commentSearchSource, query, params, scanComment)
+}
+
+// searchTable 在 src 中搜索 query 并按页返回结果：使用全文索引时按 bm25 相关度排序，否则按时间倒序
+func searchTable[T any](db *sql.DB, src searchSource, query string, params *PaginationParams, scan func(rowScanner) (*T, error)) (*PagedResult[SearchHit[T]], error) {
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.PageSize < 1 {
+		params.PageSize = 20
+	}
+	if params.PageSize > 100 {
+		params.PageSize = 100
+	}
+
+	hits := []SearchHit[T]{}
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return NewPagedResult(hits, 0, params.Page, params.PageSize), nil
+	}
+
+	condition, args := searchCondition(db, src, query)
+	var total int64
+	if err := db.QueryRow("SELECT COUNT(*) FROM "+src.table+" WHERE "+condition, args...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count %s search results: %w", src.table, err)
+	}
+
+	offset := (params.Page - 1) * params.PageSize
+	match, useFTS := ftsMatchExpr(terms)
+	useFTS = useFTS && ftsIndexExists(db, src.ftsTable)
+
+	var sqlQuery string
+	if useFTS {
+		highlights := make([]string, len(src.fields))
+		for i := range src.fields {
+			highlights[i] = fmt.Sprintf("highlight(%s, %d, char(1), char(2)) AS hl%d", src.ftsTable, i, i)
+		}
+		sqlQuery = fmt.Sprintf(`
+			SELECT %s, h.score, %s
+			FROM (
+				SELECT rowid AS fts_rowid, bm25(%s) AS score, %s
+				FROM %s WHERE %s MATCH ?
+			) h JOIN %s ON %s.rowid = h.fts_rowid
+			ORDER BY h.score
+			LIMIT ? OFFSET ?
+		`, src.columns, prefixedHighlightColumns(len(src.fields)),
+			src.ftsTable, strings.Join(highlights, ", "), src.ftsTable, src.ftsTable,
+			src.table, src.table)
+		args = []interface{}{match, params.PageSize, offset}
+	} else {
+		// 回退时直接取出原文，在 Go 中标记匹配的关键词
+		sqlQuery = fmt.Sprintf(`
+			SELECT %s, 0, %s
+			FROM %s
+			WHERE %s
+			ORDER BY %s
+			LIMIT ? OFFSET ?
+		`, src.columns, strings.Join(src.fields, ", "), src.table, condition, src.orderBy)
+		args = append(args, params.PageSize, offset)
+	}
+
+	rows, err := db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search %s: %w", src.table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var score float64
+		texts := make([]sql.NullString, len(src.fields))
+		extra := []interface{}{&score}
+		for i := range texts {
+			extra = append(extra, &texts[i])
+		}
+
+		item, err := scan(extendedScanner{row: rows, extra: extra})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s search result: %w", src.table, err)
+		}
+
+		hit := SearchHit[T]{Item: *item, Score: score, Highlights: make(map[string]string, len(src.keys))}
+		for i, key := range src.keys {
+			if useFTS {
+				hit.Highlights[key] = renderHighlight(texts[i].String)
+			} else {
+				hit.Highlights[key] = renderHighlight(highlightTerms(stripSentinels(texts[i].String), terms))
+			}
+		}
+		hits = append(hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to search %s: %w", src.table, err)
+	}
+
+	return NewPagedResult(hits, total, params.Page, params.PageSize), nil
+}
+
+// searchCondition 返回在 src 源表中匹配 query 的 WHERE 条件（不含 WHERE）及参数
+// 所有关键词都需要匹配；可以使用全文索引时按 rowid 过滤，否则对每个关键词做 LIKE 匹配
+func searchCondition(db *sql.DB, src searchSource, query string) (string, []interface{}) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return "1 = 1", nil
+	}
+
+	if match, ok := ftsMatchExpr(terms); ok && ftsIndexExists(db, src.ftsTable) {
+		return fmt.Sprintf("rowid IN (SELECT rowid FROM %s WHERE %s MATCH ?)", src.ftsTable, src.ftsTable), []interface{}{match}
+	}
+
+	var conditions []string
+	var args []interface{}
+	for _, term := range terms {
+		pattern := "%" + escapeLike(term) + "%"
+		fieldConditions := make([]string, len(src.fields))
+		for i, field := range src.fields {
+			fieldConditions[i] = field + ` LIKE ? ESCAPE '\'`
+			args = append(args, pattern)
+		}
+		conditions = append(conditions, "("+strings.Join(fieldConditions, " OR ")+")")
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// searchTerms 将搜索词按空白拆分为关键词
+func searchTerms(query string) []string {
+	return strings.Fields(query)
+}
+
+// ftsMatchExpr 构造 FTS5 MATCH 表达式：每个关键词作为一个短语，关键词之间为 AND
+// trigram 分词器无法匹配少于 3 个字符的关键词，此时返回 false
+func ftsMatchExpr(terms []string) (string, bool) {
+	if len(terms) == 0 {
+		return "", false
+	}
+	phrases := make([]string, len(terms))
+	for i, term := range terms {
+		if utf8.RuneCountInString(term) < ftsMinTermLength {
+			return "", false
+		}
+		phrases[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	}
+	return strings.Join(phrases, " AND "), true
+}
+
+// ftsIndexExists 检查全文索引表是否存在（未启用 FTS5 时迁移 20 会被跳过）
+func ftsIndexExists(db *sql.DB, ftsTable string) bool {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", ftsTable).Scan(&count)
+	return err == nil && count > 0
+}
+
+// prefixedHighlightColumns 返回子查询 h 中的高亮列
+func prefixedHighlightColumns(n int) string {
+	columns := make([]string, n)
+	for i := range columns {
+		columns[i] = fmt.Sprintf("h.hl%d", i)
+	}
+	return strings.Join(columns, ", ")
+}
+
+// escapeLike 转义 LIKE 模式中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// stripSentinels 删除原文中与高亮标记冲突的控制字符
+func stripSentinels(text string) string {
+	return strings.NewReplacer(sentinelOpen, "", sentinelClose, "").Replace(text)
+}
+
+// renderHighlight 将带有私有标记的文本转义为 HTML，再把标记替换为 <mark></mark>
+// 原文中残留的标记字符只在配对时生效，保证输出的标签始终成对
+func renderHighlight(text string) string {
+	var b strings.Builder
+	open := false
+	for len(text) > 0 {
+		i := strings.IndexAny(text, sentinelOpen+sentinelClose)
+		if i < 0 {
+			b.WriteString(html.EscapeString(text))
+			break
+		}
+		b.WriteString(html.EscapeString(text[:i]))
+		switch {
+		case text[i] == sentinelOpen[0] && !open:
+			b.WriteString(highlightOpen)
+			open = true
+		case text[i] == sentinelClose[0] && open:
+			b.WriteString(highlightClose)
+			open = false
+		}
+		text = text[i+1:]
+	}
+	if open {
+		b.WriteString(highlightClose)
+	}
+	return b.String()
+}
+
+// highlightTerms 用私有标记包围文本中出现的关键词（ASCII 字母不区分大小写），由 renderHighlight 转换为 HTML
+func highlightTerms(text string, terms []string) string {
+	if text == "" {
+		return text
+	}
+	// 转换大小写后字节长度改变时无法对应回原文，只做区分大小写的匹配
+	lower := strings.ToLower(text)
+	foldCase := len(lower) == len(text)
+	if !foldCase {
+		lower = text
+	}
+
+	marked := make([]bool, len(text))
+	for _, term := range terms {
+		needle := term
+		if folded := strings.ToLower(term); foldCase && len(folded) == len(term) {
+			needle = folded
+		}
+		if needle == "" {
+			continue
+		}
+		for start := 0; start < len(lower); {
+			idx := strings.Index(lower[start:], needle)
+			if idx < 0 {
+				break
+			}
+			for i := start + idx; i < start+idx+len(needle); i++ {
+				marked[i] = true
+			}
+			start += idx + len(needle)
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			b.WriteString(sentinelOpen)
+		}
+		b.WriteByte(text[i])
+		if marked[i] && (i == len(text)-1 || !marked[i+1]) {
+			b.WriteString(sentinelClose)
+		}
+	}
+	return b.String()
+}
+
+// extendedScanner 在记录的列之后继续扫描额外的列（相关度和高亮文本）
+type extendedScanner struct {
+	row   rowScanner
+	extra []interface{}
+}
+
+func (s extendedScanner) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, s.extra...)...)
+}
@@ -2,9 +2,12 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"time"
+
+	"wx_channel/internal/utils"
 )
 
 // SettingsRepository 处理设置数据库操作
@@ -26,6 +29,22 @@ const (
 	SettingKeyAutoCleanupDays    = "auto_cleanup_days"
 	SettingKeyMaxRetries         = "max_retries"
 	SettingKeyTheme              = "theme"
+	SettingKeyBandwidthLimit     = "bandwidth_limit"
+	SettingKeyTaskBandwidthLimit = "task_bandwidth_limit"
+	SettingKeyScheduleEnabled    = "schedule_enabled"
+	SettingKeyScheduleRules      = "schedule_rules"
+	SettingKeyFilenameTemplate   = "filename_template"
+	SettingKeyFilenameMaxLength  = "filename_max_length"
+	SettingKeyEmbedMetadata      = "embed_metadata"
+	SettingKeyDedupePolicy       = "dedupe_policy"
+	SettingKeyMinFreeSpace       = "min_free_space"
+	SettingKeyMaxLibrarySize     = "max_library_size"
+	SettingKeyRetentionMaxSize   = "retention_max_size"
+	SettingKeyRetentionEvictBy   = "retention_evict_by"
+	SettingKeyRetentionPerAuthor = "retention_keep_per_author"
+	SettingKeyTrashRetentionDays = "trash_retention_days"
+	SettingKeyBackupInterval     = "backup_interval_hours"
+	SettingKeyBackupKeep         = "backup_keep"
 )
 
 // Get 根据键获取设置值
@@ -123,6 +142,77 @@ func (r *SettingsRepository) Load() (*Settings, error) {
 	if v, ok := settingsMap[SettingKeyTheme]; ok && v != "" {
 		settings.Theme = v
 	}
+	if v, ok := settingsMap[SettingKeyBandwidthLimit]; ok && v != "" {
+		if limit, err := strconv.ParseInt(v, 10, 64); err == nil {
+			settings.BandwidthLimit = limit
+		}
+	}
+	if v, ok := settingsMap[SettingKeyTaskBandwidthLimit]; ok && v != "" {
+		if limit, err := strconv.ParseInt(v, 10, 64); err == nil {
+			settings.TaskBandwidthLimit = limit
+		}
+	}
+	if v, ok := settingsMap[SettingKeyScheduleEnabled]; ok {
+		settings.ScheduleEnabled = v == "true"
+	}
+	if v, ok := settingsMap[SettingKeyScheduleRules]; ok && v != "" {
+		var rules []ScheduleRule
+		if err := json.Unmarshal([]byte(v), &rules); err == nil {
+			settings.ScheduleRules = rules
+		}
+	}
+	if v, ok := settingsMap[SettingKeyFilenameTemplate]; ok {
+		settings.FilenameTemplate = v
+	}
+	if v, ok := settingsMap[SettingKeyFilenameMaxLength]; ok && v != "" {
+		if length, err := strconv.Atoi(v); err == nil {
+			settings.FilenameMaxLength = length
+		}
+	}
+	if v, ok := settingsMap[SettingKeyEmbedMetadata]; ok {
+		settings.EmbedMetadata = v == "true"
+	}
+	if v, ok := settingsMap[SettingKeyDedupePolicy]; ok && v != "" {
+		settings.DedupePolicy = v
+	}
+	if v, ok := settingsMap[SettingKeyMinFreeSpace]; ok && v != "" {
+		if size, err := strconv.ParseInt(v, 10, 64); err == nil {
+			settings.MinFreeSpace = size
+		}
+	}
+	if v, ok := settingsMap[SettingKeyMaxLibrarySize]; ok && v != "" {
+		if size, err := strconv.ParseInt(v, 10, 64); err == nil {
+			settings.MaxLibrarySize = size
+		}
+	}
+	if v, ok := settingsMap[SettingKeyRetentionMaxSize]; ok && v != "" {
+		if size, err := strconv.ParseInt(v, 10, 64); err == nil {
+			settings.RetentionMaxSize = size
+		}
+	}
+	if v, ok := settingsMap[SettingKeyRetentionEvictBy]; ok && v != "" {
+		settings.RetentionEvictBy = v
+	}
+	if v, ok := settingsMap[SettingKeyRetentionPerAuthor]; ok && v != "" {
+		if keep, err := strconv.Atoi(v); err == nil {
+			settings.RetentionKeepPerAuthor = keep
+		}
+	}
+	if v, ok := settingsMap[SettingKeyTrashRetentionDays]; ok && v != "" {
+		if days, err := strconv.Atoi(v); err == nil {
+			settings.TrashRetentionDays = days
+		}
+	}
+	if v, ok := settingsMap[SettingKeyBackupInterval]; ok && v != "" {
+		if hours, err := strconv.Atoi(v); err == nil {
+			settings.BackupIntervalHours = hours
+		}
+	}
+	if v, ok := settingsMap[SettingKeyBackupKeep]; ok && v != "" {
+		if keep, err := strconv.Atoi(v); err == nil {
+			settings.BackupKeep = keep
+		}
+	}
 
 	return settings, nil
 }
@@ -142,6 +232,15 @@ func (r *SettingsRepository) Save(settings *Settings) error {
 		ON CONFLICT(key) DO UPDATE SET value = ?, updated_at = ?
 	`
 
+	rules := settings.ScheduleRules
+	if rules == nil {
+		rules = []ScheduleRule{}
+	}
+	rulesJSON, err := json.Marshal(rules)
+	if err != nil {
+		return fmt.Errorf("failed to encode schedule rules: %w", err)
+	}
+
 	// Save each setting
 	settingsMap := map[string]string{
 		SettingKeyDownloadDir:        settings.DownloadDir,
@@ -151,6 +250,22 @@ func (r *SettingsRepository) Save(settings *Settings) error {
 		SettingKeyAutoCleanupDays:    strconv.Itoa(settings.AutoCleanupDays),
 		SettingKeyMaxRetries:         strconv.Itoa(settings.MaxRetries),
 		SettingKeyTheme:              settings.Theme,
+		SettingKeyBandwidthLimit:     strconv.FormatInt(settings.BandwidthLimit, 10),
+		SettingKeyTaskBandwidthLimit: strconv.FormatInt(settings.TaskBandwidthLimit, 10),
+		SettingKeyScheduleEnabled:    strconv.FormatBool(settings.ScheduleEnabled),
+		SettingKeyScheduleRules:      string(rulesJSON),
+		SettingKeyFilenameTemplate:   settings.FilenameTemplate,
+		SettingKeyFilenameMaxLength:  strconv.Itoa(settings.FilenameMaxLength),
+		SettingKeyEmbedMetadata:      strconv.FormatBool(settings.EmbedMetadata),
+		SettingKeyDedupePolicy:       settings.DedupePolicy,
+		SettingKeyMinFreeSpace:       strconv.FormatInt(settings.MinFreeSpace, 10),
+		SettingKeyMaxLibrarySize:     strconv.FormatInt(settings.MaxLibrarySize, 10),
+		SettingKeyRetentionMaxSize:   strconv.FormatInt(settings.RetentionMaxSize, 10),
+		SettingKeyRetentionEvictBy:   settings.RetentionEvictBy,
+		SettingKeyRetentionPerAuthor: strconv.Itoa(settings.RetentionKeepPerAuthor),
+		SettingKeyTrashRetentionDays: strconv.Itoa(settings.TrashRetentionDays),
+		SettingKeyBackupInterval:     strconv.Itoa(settings.BackupIntervalHours),
+		SettingKeyBackupKeep:         strconv.Itoa(settings.BackupKeep),
 	}
 
 	for key, value := range settingsMap {
@@ -197,6 +312,60 @@ func (r *SettingsRepository) Validate(settings *Settings) error {
 		return fmt.Errorf("theme must be 'light' or 'dark'")
 	}
 
+	// Validate bandwidth limits (0 = unlimited)
+	if settings.BandwidthLimit < 0 || settings.TaskBandwidthLimit < 0 {
+		return fmt.Errorf("bandwidth limits must not be negative")
+	}
+
+	// Validate schedule rules
+	for _, rule := range settings.ScheduleRules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate filename template (empty = default layout)
+	if settings.FilenameTemplate != "" {
+		if err := utils.ValidateFilenameTemplate(settings.FilenameTemplate); err != nil {
+			return err
+		}
+	}
+	if settings.FilenameMaxLength < 10 || settings.FilenameMaxLength > 200 {
+		return fmt.Errorf("filename max length must be between 10 and 200")
+	}
+
+	// Validate dedupe policy
+	validPolicies := map[string]bool{DedupePolicyKeepBoth: true, DedupePolicySkip: true, DedupePolicyHardlink: true}
+	if !validPolicies[settings.DedupePolicy] {
+		return fmt.Errorf("dedupe policy must be 'keep_both', 'skip' or 'hardlink'")
+	}
+
+	// Validate disk space limits (0 = disabled)
+	if settings.MinFreeSpace < 0 || settings.MaxLibrarySize < 0 {
+		return fmt.Errorf("disk space limits must not be negative")
+	}
+
+	// Validate retention policy (0 = disabled)
+	if settings.RetentionMaxSize < 0 || settings.RetentionKeepPerAuthor < 0 {
+		return fmt.Errorf("retention limits must not be negative")
+	}
+	if settings.RetentionEvictBy != RetentionEvictLeastPlayed && settings.RetentionEvictBy != RetentionEvictOldest {
+		return fmt.Errorf("retention evict order must be 'least_played' or 'oldest'")
+	}
+
+	// Validate trash retention (0 = keep until purged manually)
+	if settings.TrashRetentionDays < 0 {
+		return fmt.Errorf("trash retention days must not be negative")
+	}
+
+	// Validate database backups (0 = no scheduled backups)
+	if settings.BackupIntervalHours < 0 {
+		return fmt.Errorf("backup interval must not be negative")
+	}
+	if settings.BackupKeep < 1 {
+		return fmt.Errorf("backup keep count must be at least 1")
+	}
+
 	return nil
 }
 
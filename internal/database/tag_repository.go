@@ -0,0 +1,190 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// recordIDsByTagQuery 按标签名称（不区分大小写）查询下载记录 ID 的子查询
+const recordIDsByTagQuery = `SELECT rt.record_id FROM download_record_tags rt JOIN tags t ON t.id = rt.tag_id WHERE t.name = ?`
+
+// TagRepository 处理标签数据库操作
+type TagRepository struct {
+	db *sql.DB
+}
+
+// NewTagRepository 创建一个新的 TagRepository
+func NewTagRepository() *TagRepository {
+	return &TagRepository{db: GetDB()}
+}
+
+// tagColumns 查询标签时使用的列（包含使用该标签的下载记录数）
+const tagColumns = `t.id, t.name, COALESCE(t.color, '') as color,
+			(SELECT COUNT(*) FROM download_record_tags rt WHERE rt.tag_id = t.id) as record_count, t.created_at`
+
+// scanTag 将一行查询结果扫描为标签
+func scanTag(row rowScanner) (*Tag, error) {
+	tag := &Tag{}
+	if err := row.Scan(&tag.ID, &tag.Name, &tag.Color, &tag.RecordCount, &tag.CreatedAt); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// Create 插入新标签
+func (r *TagRepository) Create(tag *Tag) error {
+	if tag.CreatedAt.IsZero() {
+		tag.CreatedAt = time.Now()
+	}
+	_, err := r.db.Exec("INSERT INTO tags (id, name, color, created_at) VALUES (?, ?, ?, ?)",
+		tag.ID, tag.Name, tag.Color, tag.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create tag: %w", err)
+	}
+	return nil
+}
+
+// GetByID 根据 ID 获取标签，不存在时返回 nil
+func (r *TagRepository) GetByID(id string) (*Tag, error) {
+	return r.getOne("t.id = ?", id)
+}
+
+// GetByName 根据名称（不区分大小写）获取标签，不存在时返回 nil
+func (r *TagRepository) GetByName(name string) (*Tag, error) {
+	return r.getOne("t.name = ?", name)
+}
+
+// getOne 按条件获取单个标签
+func (r *TagRepository) getOne(condition string, arg interface{}) (*Tag, error) {
+	tag, err := scanTag(r.db.QueryRow("SELECT "+tagColumns+" FROM tags t WHERE "+condition, arg))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag: %w", err)
+	}
+	return tag, nil
+}
+
+// List 获取所有标签，按名称排序
+func (r *TagRepository) List() ([]Tag, error) {
+	rows, err := r.db.Query("SELECT " + tagColumns + " FROM tags t ORDER BY t.name COLLATE NOCASE")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, *tag)
+	}
+	return tags, rows.Err()
+}
+
+// Update 更新标签的名称和颜色
+func (r *TagRepository) Update(tag *Tag) error {
+	result, err := r.db.Exec("UPDATE tags SET name = ?, color = ? WHERE id = ?", tag.Name, tag.Color, tag.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update tag: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("tag not found: %s", tag.ID)
+	}
+	return nil
+}
+
+// Delete 删除标签，下载记录上的该标签一并移除
+func (r *TagRepository) Delete(id string) error {
+	result, err := r.db.Exec("DELETE FROM tags WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete tag: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("tag not found: %s", id)
+	}
+	return nil
+}
+
+// SetRecordTags 在一个事务中将下载记录的标签替换为 tagIDs
+func (r *TagRepository) SetRecordTags(recordID string, tagIDs []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM download_record_tags WHERE record_id = ?", recordID); err != nil {
+		return fmt.Errorf("failed to clear record tags: %w", err)
+	}
+	now := time.Now()
+	for _, tagID := range tagIDs {
+		_, err := tx.Exec("INSERT OR IGNORE INTO download_record_tags (record_id, tag_id, created_at) VALUES (?, ?, ?)",
+			recordID, tagID, now)
+		if err != nil {
+			return fmt.Errorf("failed to add record tag: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit record tags: %w", err)
+	}
+	return nil
+}
+
+// tagLookupBatchSize 每次查询标签的下载记录数，避免超出 SQLite 参数数量限制
+const tagLookupBatchSize = 500
+
+// GetTagNamesForRecords 获取下载记录的标签名称，返回记录 ID -> 按名称排序的标签名称
+func (r *TagRepository) GetTagNamesForRecords(recordIDs []string) (map[string][]string, error) {
+	result := make(map[string][]string)
+	for start := 0; start < len(recordIDs); start += tagLookupBatchSize {
+		end := start + tagLookupBatchSize
+		if end > len(recordIDs) {
+			end = len(recordIDs)
+		}
+		if err := r.loadTagNames(recordIDs[start:end], result); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// loadTagNames 查询一批下载记录的标签名称并写入 result
+func (r *TagRepository) loadTagNames(recordIDs []string, result map[string][]string) error {
+	placeholders := make([]string, len(recordIDs))
+	args := make([]interface{}, len(recordIDs))
+	for i, id := range recordIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+
+	query := fmt.Sprintf(`
+		SELECT rt.record_id, t.name
+		FROM download_record_tags rt JOIN tags t ON t.id = rt.tag_id
+		WHERE rt.record_id IN (%s)
+		ORDER BY t.name COLLATE NOCASE
+	`, strings.Join(placeholders, ","))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to get record tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var recordID, name string
+		if err := rows.Scan(&recordID, &name); err != nil {
+			return fmt.Errorf("failed to scan record tag: %w", err)
+		}
+		result[recordID] = append(result[recordID], name)
+	}
+	return rows.Err()
+}
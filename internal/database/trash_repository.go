@@ -0,0 +1,134 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// TrashRepository 处理回收站数据库操作
+type TrashRepository struct {
+	db *sql.DB
+}
+
+// NewTrashRepository 创建一个新的 TrashRepository
+func NewTrashRepository() *TrashRepository {
+	return &TrashRepository{db: GetDB()}
+}
+
+// trashItemColumns 查询回收站项目时使用的列
+const trashItemColumns = `id, COALESCE(record_id, '') as record_id, COALESCE(title, '') as title, COALESCE(author, '') as author,
+			original_path, trash_path, file_size, COALESCE(files, '') as files, COALESCE(record_data, '') as record_data, deleted_at`
+
+// scanTrashItem 将一行查询结果扫描为回收站项目
+func scanTrashItem(row rowScanner) (*TrashItem, error) {
+	item := &TrashItem{}
+	var files, recordData string
+	err := row.Scan(
+		&item.ID, &item.RecordID, &item.Title, &item.Author,
+		&item.OriginalPath, &item.TrashPath, &item.FileSize, &files, &recordData, &item.DeletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	item.Files = []string{}
+	if files != "" {
+		_ = json.Unmarshal([]byte(files), &item.Files)
+	}
+	if recordData != "" {
+		record := &DownloadRecord{}
+		if json.Unmarshal([]byte(recordData), record) == nil {
+			item.Record = record
+		}
+	}
+	return item, nil
+}
+
+// Create 插入新的回收站项目
+func (r *TrashRepository) Create(item *TrashItem) error {
+	files, err := json.Marshal(item.Files)
+	if err != nil {
+		return fmt.Errorf("failed to encode trash files: %w", err)
+	}
+	recordData := ""
+	if item.Record != nil {
+		data, err := json.Marshal(item.Record)
+		if err != nil {
+			return fmt.Errorf("failed to encode download record: %w", err)
+		}
+		recordData = string(data)
+	}
+
+	query := `
+		INSERT INTO trash_items (
+			id, record_id, title, author, original_path, trash_path, file_size, files, record_data, deleted_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err = r.db.Exec(query,
+		item.ID, item.RecordID, item.Title, item.Author, item.OriginalPath, item.TrashPath,
+		item.FileSize, string(files), recordData, item.DeletedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create trash item: %w", err)
+	}
+	return nil
+}
+
+// GetByID 根据 ID 获取回收站项目，不存在时返回 nil
+func (r *TrashRepository) GetByID(id string) (*TrashItem, error) {
+	query := "SELECT " + trashItemColumns + " FROM trash_items WHERE id = ?"
+	item, err := scanTrashItem(r.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trash item: %w", err)
+	}
+	return item, nil
+}
+
+// List 获取所有回收站项目，最近删除的在前
+func (r *TrashRepository) List() ([]TrashItem, error) {
+	query := "SELECT " + trashItemColumns + " FROM trash_items ORDER BY deleted_at DESC"
+	return r.queryTrashItems(query)
+}
+
+// GetBefore 获取指定时间前删除的回收站项目
+func (r *TrashRepository) GetBefore(date time.Time) ([]TrashItem, error) {
+	query := "SELECT " + trashItemColumns + " FROM trash_items WHERE deleted_at < ? ORDER BY deleted_at ASC"
+	return r.queryTrashItems(query, date)
+}
+
+// queryTrashItems 执行查询并返回回收站项目列表
+func (r *TrashRepository) queryTrashItems(query string, args ...interface{}) ([]TrashItem, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trash items: %w", err)
+	}
+	defer rows.Close()
+
+	items := []TrashItem{}
+	for rows.Next() {
+		item, err := scanTrashItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan trash item: %w", err)
+		}
+		items = append(items, *item)
+	}
+	return items, rows.Err()
+}
+
+// Delete 根据 ID 删除回收站项目
+func (r *TrashRepository) Delete(id string) error {
+	result, err := r.db.Exec("DELETE FROM trash_items WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete trash item: %w", err)
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("trash item not found: %s", id)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// VideoMetricsRepository 处理视频互动数据快照的数据库操作
+type VideoMetricsRepository struct {
+	db *sql.DB
+}
+
+// NewVideoMetricsRepository 创建一个新的 VideoMetricsRepository
+func NewVideoMetricsRepository() *VideoMetricsRepository {
+	return &VideoMetricsRepository{db: GetDB()}
+}
+
+// Add 追加一条视频互动数据快照，视频需要已有浏览记录
+func (r *VideoMetricsRepository) Add(videoID string, snapshot *VideoMetricsSnapshot) error {
+	if snapshot.CapturedAt.IsZero() {
+		snapshot.CapturedAt = time.Now()
+	}
+	_, err := r.db.Exec(`
+		INSERT INTO video_metrics_snapshots (video_id, like_count, comment_count, fav_count, forward_count, captured_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, videoID, snapshot.LikeCount, snapshot.CommentCount, snapshot.FavCount, snapshot.ForwardCount, snapshot.CapturedAt)
+	if err != nil {
+		return fmt.Errorf("failed to add video metrics snapshot: %w", err)
+	}
+	return nil
+}
+
+// List 获取视频在时间范围内的互动数据快照，按时间先后排序，since 和 until 为 nil 时不限制
+func (r *VideoMetricsRepository) List(videoID string, since, until *time.Time) ([]VideoMetricsSnapshot, error) {
+	conditions := []string{"video_id = ?"}
+	args := []interface{}{videoID}
+	if since != nil {
+		conditions = append(conditions, "captured_at >= ?")
+		args = append(args, *since)
+	}
+	if until != nil {
+		conditions = append(conditions, "captured_at <= ?")
+		args = append(args, *until)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT like_count, comment_count, fav_count, forward_count, captured_at
+		FROM video_metrics_snapshots
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY captured_at ASC, id ASC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list video metrics snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	snapshots := []VideoMetricsSnapshot{}
+	for rows.Next() {
+		var s VideoMetricsSnapshot
+		if err := rows.Scan(&s.LikeCount, &s.CommentCount, &s.FavCount, &s.ForwardCount, &s.CapturedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan video metrics snapshot: %w", err)
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, rows.Err()
+}
@@ -1,17 +1,14 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"math/rand"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"wx_channel/internal/config"
@@ -24,13 +21,11 @@ import (
 )
 
 // BatchHandler 批量下载处理器
+// 批量下载的任务保存为下载队列项目，由队列调度器下载；batch_* 接口是队列之上的兼容层，
+// 进度、取消和继续下载在重启后仍然有效
 type BatchHandler struct {
-	downloadService *services.DownloadRecordService
-	gopeedService   *services.GopeedService // Injected Gopeed Service
-	mu              sync.RWMutex
-	tasks           []BatchTask
-	running         bool
-	cancelFunc      context.CancelFunc // 用于取消时立即中断下载
+	queueService *services.QueueService
+	settingsRepo *database.SettingsRepository
 }
 
 // BatchTask 批量下载任务
@@ -40,6 +35,7 @@ type BatchTask struct {
 	Title           string  `json:"title"`
 	AuthorName      string  `json:"authorName,omitempty"`      // 兼容旧格式
 	Author          string  `json:"author,omitempty"`          // 新格式
+	AuthorID        string  `json:"authorId,omitempty"`        // 作者 ID（视频号 username）
 	Key             string  `json:"key,omitempty"`             // 加密密钥（新方式，后端生成解密数组）
 	DecryptorPrefix string  `json:"decryptorPrefix,omitempty"` // 解密前缀（旧方式，前端传递）
 	PrefixLen       int     `json:"prefixLen,omitempty"`
@@ -137,11 +133,10 @@ func (t *BatchTask) GetCover() string {
 }
 
 // NewBatchHandler 创建批量下载处理器
-func NewBatchHandler(cfg *config.Config, gopeedService *services.GopeedService) *BatchHandler {
+func NewBatchHandler(cfg *config.Config) *BatchHandler {
 	return &BatchHandler{
-		downloadService: services.NewDownloadRecordService(),
-		gopeedService:   gopeedService,
-		tasks:           make([]BatchTask, 0),
+		queueService: services.NewQueueService(),
+		settingsRepo: database.NewSettingsRepository(),
 	}
 }
 
@@ -252,427 +247,127 @@ func (h *BatchHandler) HandleBatchStart(Conn *SunnyNet.HttpConn) bool {
 		return true
 	}
 
-	// 初始化任务
-	h.mu.Lock()
-	h.tasks = make([]BatchTask, len(req.Videos))
-	for i, v := range req.Videos {
-		h.tasks[i] = BatchTask{
-			ID:              v.ID,
-			URL:             v.URL,
-			Title:           v.Title,
-			AuthorName:      v.GetAuthor(), // 兼容 author 和 authorName
-			Author:          v.Author,
-			Key:             v.Key,
-			DecryptorPrefix: v.DecryptorPrefix,
-			PrefixLen:       v.PrefixLen,
-			Status:          "pending",
-			// 保留额外字段
-			Duration:     v.Duration,
-			SizeMB:       v.SizeMB,
-			Cover:        v.Cover,
-			Resolution:   v.Resolution,
-			PageSource:   pageSource, // 保存页面来源
-			PlayCount:    v.PlayCount,
-			LikeCount:    v.LikeCount,
-			CommentCount: v.CommentCount,
-			FavCount:     v.FavCount,
-			ForwardCount: v.ForwardCount,
-			CreateTime:   v.CreateTime,
-			IPRegion:     v.IPRegion,
-		}
-	}
-	h.running = true
-	h.mu.Unlock()
-
-	// 获取并发数配置
-	concurrency := 5 // 默认值（与配置默认值一致）
-	if h.getConfig() != nil && h.getConfig().DownloadConcurrency > 0 {
-		concurrency = h.getConfig().DownloadConcurrency
+	// 将任务添加到下载队列（替换上一批次），由队列调度器按并发限制下载
+	videos := make([]services.VideoInfo, len(req.Videos))
+	for i := range req.Videos {
+		videos[i] = req.Videos[i].toVideoInfo(pageSource)
 	}
-
-	utils.Info("🚀 [批量下载] 开始下载 %d 个视频，并发数: %d", len(req.Videos), concurrency)
-
-	// 启动后台下载
-	go h.startBatchDownload(req.ForceRedownload)
-
-	h.sendSuccessResponse(Conn, map[string]interface{}{
-		"total":       len(req.Videos),
-		"concurrency": concurrency,
-	})
-	return true
-}
-
-// startBatchDownload 开始批量下载（并发版本）
-func (h *BatchHandler) startBatchDownload(forceRedownload bool) {
-	// 创建可取消的 context
-	ctx, cancel := context.WithCancel(context.Background())
-	h.mu.Lock()
-	h.cancelFunc = cancel
-	h.mu.Unlock()
-
-	defer func() {
-		h.mu.Lock()
-		h.running = false
-		h.cancelFunc = nil
-		h.mu.Unlock()
-		cancel() // 确保释放资源
-	}()
-
-	// 获取下载目录
-	downloadsDir, err := h.getDownloadsDir()
+	batchID, items, replaced, err := h.queueService.AddBatch(videos, req.ForceRedownload)
 	if err != nil {
-		utils.HandleError(err, "获取下载目录")
-		return
-	}
-
-	// 获取并发数
-	concurrency := 5 // 默认值（与配置默认值一致）
-	if h.getConfig() != nil && h.getConfig().DownloadConcurrency > 0 {
-		concurrency = h.getConfig().DownloadConcurrency
-	}
-	if concurrency < 1 {
-		concurrency = 1
-	}
-
-	// 创建任务通道
-	taskChan := make(chan int, len(h.tasks))
-	var wg sync.WaitGroup
-
-	// 启动 worker
-	for w := 0; w < concurrency; w++ {
-		wg.Add(1)
-		go func(workerID int) {
-			defer wg.Done()
-			for taskIdx := range taskChan {
-				// 检查是否取消
-				select {
-				case <-ctx.Done():
-					return
-				default:
-				}
-
-				h.mu.Lock()
-				task := &h.tasks[taskIdx]
-				task.Status = "downloading"
-				h.mu.Unlock()
-
-				utils.Info("📥 [Worker %d] 开始下载: %s", workerID, task.Title)
-
-				// 下载视频
-				err := h.downloadVideo(ctx, task, downloadsDir, forceRedownload, taskIdx)
-
-				h.mu.Lock()
-				if err != nil {
-					task.Status = "failed"
-					task.Error = err.Error()
-					task.Progress = 0
-					utils.Error("❌ [Worker %d] 失败: %s - %v", workerID, task.Title, err)
-				} else {
-					task.Status = "done"
-					task.Progress = 100
-					utils.Info("✅ [Worker %d] 完成: %s", workerID, task.Title)
-				}
-				h.mu.Unlock()
-			}
-		}(w)
+		utils.HandleError(err, "添加批量下载任务")
+		h.sendErrorResponse(Conn, err)
+		return true
 	}
 
-	// 分发任务（只处理 pending 状态的任务，跳过 done 和 failed）
-	pendingCount := 0
-	for i := range h.tasks {
-		h.mu.RLock()
-		taskStatus := h.tasks[i].Status
-		h.mu.RUnlock()
-
-		// 只处理 pending 状态的任务
-		if taskStatus != "pending" {
-			continue
-		}
-
-		select {
-		case <-ctx.Done():
-			close(taskChan)
-			wg.Wait()
-			utils.Info("⏹️ [批量下载] 已取消")
-			return
-		case taskChan <- i:
-			pendingCount++
+	skipped := 0
+	for _, item := range items {
+		if item.Status == database.QueueStatusCompleted {
+			skipped++
 		}
 	}
-	close(taskChan)
 
-	if pendingCount == 0 {
-		utils.Info("ℹ️ [批量下载] 没有待处理的任务（所有任务已完成或失败）")
-		return
+	// 获取并发数配置（与下载队列一致）
+	concurrency := database.DefaultSettings().ConcurrentLimit
+	if settings, err := h.settingsRepo.Load(); err == nil {
+		concurrency = settings.ConcurrentLimit
 	}
-	utils.Info("📋 [批量下载] 开始处理 %d 个待处理任务", pendingCount)
-
-	// 等待所有 worker 完成
-	wg.Wait()
 
-	// 统计结果
-	h.mu.RLock()
-	done, failed := 0, 0
-	for _, t := range h.tasks {
-		if t.Status == "done" {
-			done++
-		} else if t.Status == "failed" {
-			failed++
-		}
-	}
-	h.mu.RUnlock()
+	utils.Info("🚀 [批量下载] 已添加 %d 个视频到下载队列（跳过已下载 %d 个，替换上一批次 %d 个），并发数: %d", len(items), skipped, replaced, concurrency)
 
-	utils.Info("✅ [批量下载] 全部完成！成功: %d, 失败: %d", done, failed)
+	h.sendSuccessResponse(Conn, map[string]interface{}{
+		"total":       len(items),
+		"skipped":     skipped,
+		"concurrency": concurrency,
+		"batchId":     batchID,
+		"replaced":    replaced,
+	})
+	return true
 }
 
-// downloadVideo 下载单个视频（带重试和断点续传）
-func (h *BatchHandler) downloadVideo(ctx context.Context, task *BatchTask, downloadsDir string, forceRedownload bool, taskIdx int) error {
-	// 创建作者目录
-	authorFolder := utils.CleanFolderName(task.GetAuthor())
-	savePath := filepath.Join(downloadsDir, authorFolder)
-	if err := utils.EnsureDir(savePath); err != nil {
-		return fmt.Errorf("创建作者目录失败: %v", err)
-	}
-
-	// 优先使用视频ID进行去重检查（如果提供了视频ID）
-	if !forceRedownload && task.ID != "" && h.downloadService != nil {
-		if exists, err := h.downloadService.GetByID(task.ID); err == nil && exists != nil {
-			// DB记录中已存在该视频ID，说明已下载过，尝试查找文件
-			// 使用包含ID的文件名查找
-			filenameWithID := utils.GenerateVideoFilename(task.Title, task.ID)
-			filenameWithID = utils.EnsureExtension(filenameWithID, ".mp4")
-			filePathWithID := filepath.Join(savePath, filenameWithID)
-			if _, err := os.Stat(filePathWithID); err == nil {
-				utils.Info("⏭️ [批量下载] 视频ID已存在记录中，文件已存在，跳过: ID=%s, 文件名=%s", task.ID, filenameWithID)
-				// 文件已存在也保存记录（标记为已完成）
-				h.saveDownloadRecord(task, filePathWithID, "completed")
-				return nil
-			}
-		}
-	} else if h.downloadService == nil {
-		utils.Warn("downloadService is nil, skipping DB check")
-	}
-
-	// 生成文件名：优先使用视频ID确保唯一性
-	cleanFilename := utils.GenerateVideoFilename(task.Title, task.ID)
-	cleanFilename = utils.EnsureExtension(cleanFilename, ".mp4")
-	filePath := filepath.Join(savePath, cleanFilename)
-
-	// 检查文件是否已存在（作为备用检查，主要检查已通过ID完成）
-	if !forceRedownload {
-		if _, err := os.Stat(filePath); err == nil {
-			utils.Info("⏭️ [批量下载] 文件已存在，跳过: %s", cleanFilename)
-			// 文件已存在也保存记录（标记为已完成）
-			h.saveDownloadRecord(task, filePath, "completed")
-			return nil
-		}
-	}
-
-	// 使用配置的重试次数
-	maxRetries := 3
-	if h.getConfig() != nil {
-		maxRetries = h.getConfig().DownloadRetryCount
-	}
-	if maxRetries < 1 {
-		maxRetries = 3
-	}
-	var lastErr error
-
-	for retry := 0; retry < maxRetries; retry++ {
-		// 检查是否取消
-		select {
-		case <-ctx.Done():
-			return fmt.Errorf("下载已取消")
-		default:
-		}
-
-		if retry > 0 {
-			// 指数退避 + 随机抖动
-			baseDelay := time.Duration(1<<uint(retry)) * time.Second // 2s, 4s, 8s...
-			jitter := time.Duration(rand.Intn(1000)) * time.Millisecond
-			delay := baseDelay + jitter
-			utils.Info("🔄 [批量下载] 等待 %v 后重试 (%d/%d): %s", delay, retry, maxRetries-1, task.Title)
-
-			select {
-			case <-ctx.Done():
-				return fmt.Errorf("下载已取消")
-			case <-time.After(delay):
-			}
-		}
-
-		// 使用配置的超时时间
-		timeout := 10 * time.Minute
-		if h.getConfig() != nil && h.getConfig().DownloadTimeout > 0 {
-			timeout = h.getConfig().DownloadTimeout
-		}
-		downloadCtx, cancel := context.WithTimeout(ctx, timeout)
-		err := h.downloadVideoOnce(downloadCtx, task, filePath, taskIdx)
-		cancel()
-
-		if err == nil {
-			// 下载成功，保存到下载记录数据库
-			h.saveDownloadRecord(task, filePath, "completed")
-			return nil
-		}
-
-		lastErr = err
-		utils.LogDownloadRetry(task.ID, task.Title, retry+1, maxRetries, err)
-		utils.Warn("⚠️ [批量下载] 下载失败 (尝试 %d/%d): %v", retry+1, maxRetries, err)
-
-		// 如果不支持断点续传或是加密视频，清理临时文件
-		resumeEnabled := h.getConfig() != nil && h.getConfig().DownloadResumeEnabled
-		if task.DecryptorPrefix != "" || !resumeEnabled {
-			os.Remove(filePath + ".tmp")
-		}
+// toVideoInfo 将批量下载任务转换为队列视频信息，兼容新旧字段格式
+func (t *BatchTask) toVideoInfo(pageSource string) services.VideoInfo {
+	duration := parseDurationToMs(t.Duration)
+	if duration == 0 {
+		duration = t.DurationMs
+	}
+	return services.VideoInfo{
+		VideoID:         t.ID,
+		Title:           t.Title,
+		Author:          t.GetAuthor(),
+		AuthorID:        t.AuthorID,
+		CoverURL:        t.GetCover(),
+		VideoURL:        t.GetURL(),
+		DecryptKey:      t.GetKey(),
+		Duration:        duration,
+		Resolution:      t.Resolution,
+		Size:            t.Size,
+		PageSource:      pageSource,
+		DecryptorPrefix: t.DecryptorPrefix,
+		PrefixLen:       t.PrefixLen,
+		BatchStats: database.BatchStats{
+			Duration:     t.Duration,
+			SizeMB:       t.SizeMB,
+			PlayCount:    t.PlayCount,
+			LikeCount:    t.LikeCount,
+			CommentCount: t.CommentCount,
+			FavCount:     t.FavCount,
+			ForwardCount: t.ForwardCount,
+			CreateTime:   t.CreateTime,
+			IPRegion:     t.IPRegion,
+		},
 	}
-
-	// 记录最终失败的详细错误
-	utils.LogDownloadError(task.ID, task.Title, task.GetAuthor(), task.URL, lastErr, maxRetries)
-	return fmt.Errorf("下载失败（已重试 %d 次）: %v", maxRetries, lastErr)
 }
 
-// downloadVideoOnce 执行一次下载尝试（支持断点续传）
-func (h *BatchHandler) downloadVideoOnce(ctx context.Context, task *BatchTask, filePath string, taskIdx int) error {
-	// 使用 Gopeed 下载
-	if h.gopeedService == nil {
-		return fmt.Errorf("Gopeed下载服务未初始化")
-	}
-
-	// 开始下载
-	utils.Info("🚀 [批量下载] 使用 Gopeed 下载: %s", task.Title)
-
-	// 创建临时文件路径（Gopeed 会处理，这里我们只需要传递最终路径，
-	// 但 GopeedService.DownloadSync 还没有实现自动重命名？
-	// 让我们看看 GopeedService.DownloadSync 的实现。
-	// 它是直接调用 CreateDirect，并没有阻塞直到完成？
-	// 之前的 gopeed_service.go 实现是轮询状态直到 DownloadStatusDone。
-	// 所以是阻塞的。
-
-	// 注意：Gopeed 下载的临时文件名处理可能需要注意。
-	// 如果我们传递 filePath，Gopeed 会直接下载到那个路径（或所在目录）。
-
-	onProgress := func(progress float64, downloaded int64, total int64) {
-		h.mu.Lock()
-		defer h.mu.Unlock()
-
-		// 确保任务索引有效
-		if taskIdx >= 0 && taskIdx < len(h.tasks) {
-			task := &h.tasks[taskIdx]
-
-			// 只在下载中状态更新，避免覆盖完成状态
-			if task.Status == "downloading" {
-				task.Progress = progress * 100 // 转换为百分比
-				task.DownloadedMB = float64(downloaded) / (1024 * 1024)
-				task.TotalMB = float64(total) / (1024 * 1024)
-				// 也可以根据需要计算 SizeMB 字符串
-				if total > 0 {
-					task.SizeMB = fmt.Sprintf("%.2fMB", task.TotalMB)
-				}
-				
-				// 每10%输出一次日志
-				if int(task.Progress)%10 == 0 && task.Progress > 0 {
-					utils.Info("📊 [批量下载] %s 进度: %.1f%% (%.2f/%.2f MB)", 
-						task.Title, task.Progress, task.DownloadedMB, task.TotalMB)
-				}
-			}
-		}
-	}
-
-	// 获取单文件连接数配置
-	connections := 8 // 默认值
-	if h.getConfig() != nil && h.getConfig().DownloadConnections > 0 {
-		connections = h.getConfig().DownloadConnections
-	}
-
-	err := h.gopeedService.DownloadSync(ctx, task.URL, filePath, connections, onProgress)
-	if err != nil {
-		return err
-	}
-
-	// 解密逻辑（如果需要）
-	needDecrypt := task.Key != "" || (task.DecryptorPrefix != "" && task.PrefixLen > 0)
-	if needDecrypt {
-		utils.Info("🔐 [批量下载] 开始解密视频...")
-		// 原地解密（不需要额外的临时文件，因为 gopeed 已经下载了完整文件）
-		if err := utils.DecryptFileInPlace(filePath, task.GetKey(), task.DecryptorPrefix, task.PrefixLen); err != nil {
-			return fmt.Errorf("解密失败: %v", err)
-		}
-		utils.Info("✓ [批量下载] 解密完成")
-	}
-
-	return nil
+// batchTaskFromQueueItem 将队列项目转换为批量下载任务（用于进度查询和导出失败清单）
+func batchTaskFromQueueItem(item *database.QueueItem) BatchTask {
+	task := BatchTask{
+		ID:              item.VideoID,
+		URL:             item.VideoURL,
+		Title:           item.Title,
+		AuthorName:      item.Author,
+		Key:             item.DecryptKey,
+		DecryptorPrefix: item.DecryptorPrefix,
+		PrefixLen:       item.PrefixLen,
+		Status:          batchStatus(item.Status),
+		Error:           item.ErrorMessage,
+		DownloadedMB:    float64(item.DownloadedSize) / (1024 * 1024),
+		TotalMB:         float64(item.TotalSize) / (1024 * 1024),
+		Duration:        item.BatchStats.Duration,
+		SizeMB:          item.BatchStats.SizeMB,
+		Cover:           item.CoverURL,
+		Resolution:      item.Resolution,
+		PageSource:      item.PageSource,
+		PlayCount:       item.BatchStats.PlayCount,
+		LikeCount:       item.BatchStats.LikeCount,
+		CommentCount:    item.BatchStats.CommentCount,
+		FavCount:        item.BatchStats.FavCount,
+		ForwardCount:    item.BatchStats.ForwardCount,
+		CreateTime:      item.BatchStats.CreateTime,
+		IPRegion:        item.BatchStats.IPRegion,
+		DurationMs:      item.Duration,
+		Size:            item.TotalSize,
+	}
+	switch {
+	case task.Status == "done":
+		task.Progress = 100
+	case item.TotalSize > 0:
+		task.Progress = float64(item.DownloadedSize) * 100 / float64(item.TotalSize)
+	}
+	return task
 }
 
-// saveDownloadRecord 保存下载记录到数据库
-func (h *BatchHandler) saveDownloadRecord(task *BatchTask, filePath string, status string) {
-	// 检查DB中是否已存在记录
-	if h.downloadService != nil {
-		if existing, err := h.downloadService.GetByID(task.ID); err == nil && existing != nil {
-			utils.Info("📝 [下载记录] 记录已存在(DB)，跳过保存: %s - %s", task.Title, task.GetAuthor())
-			return
-		}
-	}
-
-	// 获取文件大小
-	var fileSize int64 = 0
-	if stat, err := os.Stat(filePath); err == nil {
-		fileSize = stat.Size()
-	}
-
-	// 解析时长字符串为毫秒 (格式: "00:22" 或 "1:23:45")
-	duration := parseDurationToMs(task.Duration)
-
-	// 尝试从浏览记录获取更多信息（分辨率、封面等）
-	resolution := task.Resolution
-	coverURL := task.Cover
-	if resolution == "" || coverURL == "" {
-		browseRepo := database.NewBrowseHistoryRepository()
-		if browseRecord, err := browseRepo.GetByID(task.ID); err == nil && browseRecord != nil {
-			if resolution == "" {
-				resolution = browseRecord.Resolution
-			}
-			if coverURL == "" {
-				coverURL = browseRecord.CoverURL
-			}
-			// 如果时长为0，也从浏览记录获取
-			if duration == 0 {
-				duration = browseRecord.Duration
-			}
-		}
-	}
-
-	// 创建下载记录
-	// 使用格式化后的文件名作为标题，确保与实际文件名一致
-	cleanTitle := utils.CleanFilename(task.Title)
-	record := &database.DownloadRecord{
-		ID:           task.ID,
-		VideoID:      task.ID,
-		Title:        cleanTitle,
-		Author:       task.GetAuthor(),
-		CoverURL:     coverURL,
-		Duration:     duration,
-		FileSize:     fileSize,
-		FilePath:     filePath,
-		Format:       "mp4",
-		Resolution:   resolution,
-		Status:       status,
-		DownloadTime: time.Now(),
-	}
-
-	// 保存到数据库
-	if h.downloadService != nil {
-		if err := h.downloadService.Create(record); err != nil {
-			// 如果是重复记录，尝试更新
-			if strings.Contains(err.Error(), "UNIQUE constraint") {
-				if updateErr := h.downloadService.Update(record); updateErr != nil {
-					utils.Warn("更新下载记录失败: %v", updateErr)
-				}
-			} else {
-				utils.Warn("保存下载记录失败: %v", err)
-			}
-		} else {
-			utils.Info("📝 [下载记录] 已保存(DB): %s - %s", task.Title, task.GetAuthor())
-		}
+// batchStatus 将队列状态映射为批量下载接口使用的状态（pending, downloading, done, failed）
+func batchStatus(status string) string {
+	switch status {
+	case database.QueueStatusCompleted:
+		return "done"
+	case database.QueueStatusFailed:
+		return "failed"
+	case database.QueueStatusDownloading:
+		return "downloading"
+	default:
+		// pending 和 paused（已取消，等待继续下载）
+		return "pending"
 	}
 }
 
@@ -722,14 +417,19 @@ func (h *BatchHandler) HandleBatchProgress(Conn *SunnyNet.HttpConn) bool {
 		}
 	}
 
-	h.mu.RLock()
-	total := len(h.tasks)
+	items, err := h.queueService.ListBatch()
+	if err != nil {
+		h.sendErrorResponse(Conn, err)
+		return true
+	}
+
+	total := len(items)
 	done, failed, running := 0, 0, 0
 	var downloadingTasks []map[string]interface{}
 	var allTasks []map[string]interface{}
-	isRunning := h.running // 检查是否正在运行
 
-	for _, t := range h.tasks {
+	for i := range items {
+		t := batchTaskFromQueueItem(&items[i])
 		taskInfo := map[string]interface{}{
 			"id":           t.ID,
 			"title":        t.Title,
@@ -748,14 +448,10 @@ func (h *BatchHandler) HandleBatchProgress(Conn *SunnyNet.HttpConn) bool {
 		case "failed":
 			failed++
 		case "downloading":
-			// 只有在真正运行中时才统计为 running
-			if isRunning {
-				running++
-				downloadingTasks = append(downloadingTasks, taskInfo)
-			}
+			running++
+			downloadingTasks = append(downloadingTasks, taskInfo)
 		}
 	}
-	h.mu.RUnlock()
 
 	response := map[string]interface{}{
 		"total":   total,
@@ -797,27 +493,19 @@ func (h *BatchHandler) HandleBatchCancel(Conn *SunnyNet.HttpConn) bool {
 		}
 	}
 
-	h.mu.Lock()
-	if h.running && h.cancelFunc != nil {
-		h.cancelFunc() // 立即取消所有正在进行的下载
-		h.running = false
-
-		// 将正在下载的任务状态更新为 pending（表示已取消，但保留在列表中）
-		// 这样前端可以通过 running=0 判断下载已取消
-		// 注意：保留进度以支持断点续传
-		for i := range h.tasks {
-			if h.tasks[i].Status == "downloading" {
-				h.tasks[i].Status = "pending"
-				// 不重置进度，保留已下载的进度以支持断点续传
-			}
-		}
+	// 暂停批次中未完成的队列项目，进度显示为 pending，前端可以通过 running=0 判断下载已取消
+	// 已下载的分片保留在队列中以支持断点续传
+	paused, err := h.queueService.PauseBatch()
+	if err != nil {
+		h.sendErrorResponse(Conn, err)
+		return true
 	}
-	h.mu.Unlock()
 
-	utils.Info("⏹️ [批量下载] 用户取消下载")
+	utils.Info("⏹️ [批量下载] 用户取消下载（暂停 %d 个任务）", paused)
 
 	h.sendSuccessResponse(Conn, map[string]interface{}{
 		"message": "下载已取消",
+		"paused":  paused,
 	})
 	return true
 }
@@ -843,14 +531,17 @@ func (h *BatchHandler) HandleBatchFailed(Conn *SunnyNet.HttpConn) bool {
 		}
 	}
 
-	h.mu.RLock()
+	items, err := h.queueService.ListBatch()
+	if err != nil {
+		h.sendErrorResponse(Conn, err)
+		return true
+	}
 	failedTasks := make([]BatchTask, 0)
-	for _, t := range h.tasks {
-		if t.Status == "failed" {
-			failedTasks = append(failedTasks, t)
+	for i := range items {
+		if items[i].Status == database.QueueStatusFailed {
+			failedTasks = append(failedTasks, batchTaskFromQueueItem(&items[i]))
 		}
 	}
-	h.mu.RUnlock()
 
 	if len(failedTasks) == 0 {
 		h.sendSuccessResponse(Conn, map[string]interface{}{
@@ -910,21 +601,20 @@ func (h *BatchHandler) HandleBatchResume(Conn *SunnyNet.HttpConn) bool {
 		}
 	}
 
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
-	// 检查是否有待处理的任务
-	// 包括 pending 状态的任务，以及 failed 状态但错误为"下载已取消"的任务
+	// 检查是否有待处理的任务（取消后暂停的任务，以及仍在等待的任务）
+	items, err := h.queueService.ListBatch()
+	if err != nil {
+		h.sendErrorResponse(Conn, err)
+		return true
+	}
 	pendingCount := 0
-	for i := range h.tasks {
-		if h.tasks[i].Status == "pending" {
-			pendingCount++
-		} else if h.tasks[i].Status == "failed" && h.tasks[i].Error == "下载已取消" {
-			// 将因取消而失败的任务重置为 pending 状态，以便继续下载
-			// 注意：保留进度以支持断点续传
-			h.tasks[i].Status = "pending"
-			h.tasks[i].Error = ""
-			// 不重置进度，保留已下载的进度以支持断点续传
+	for _, item := range items {
+		switch item.Status {
+		case database.QueueStatusDownloading:
+			// 如果已经在运行，返回错误
+			h.sendErrorResponse(Conn, fmt.Errorf("下载正在进行中，无法继续"))
+			return true
+		case database.QueueStatusPending, database.QueueStatusPaused:
 			pendingCount++
 		}
 	}
@@ -934,31 +624,14 @@ func (h *BatchHandler) HandleBatchResume(Conn *SunnyNet.HttpConn) bool {
 		return true
 	}
 
-	// 如果已经在运行，返回错误
-	if h.running {
-		h.sendErrorResponse(Conn, fmt.Errorf("下载正在进行中，无法继续"))
+	// 恢复暂停的任务，已下载的分片保留，继续下载时只下载缺失的部分
+	if _, err := h.queueService.ResumeBatch(); err != nil {
+		h.sendErrorResponse(Conn, err)
 		return true
 	}
 
-	// 读取请求体获取 forceRedownload 参数
-	var req struct {
-		ForceRedownload bool `json:"forceRedownload"`
-	}
-	if Conn.Request.Body != nil {
-		body, _ := io.ReadAll(Conn.Request.Body)
-		json.Unmarshal(body, &req)
-		Conn.Request.Body.Close()
-	}
-
-	// 启动下载
-	h.running = true
-	forceRedownload := req.ForceRedownload
-
 	utils.Info("▶️ [批量下载] 继续下载 %d 个待处理任务", pendingCount)
 
-	// 启动后台下载
-	go h.startBatchDownload(forceRedownload)
-
 	h.sendSuccessResponse(Conn, map[string]interface{}{
 		"message": "继续下载已启动",
 		"pending": pendingCount,
@@ -987,19 +660,13 @@ func (h *BatchHandler) HandleBatchClear(Conn *SunnyNet.HttpConn) bool {
 		}
 	}
 
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
-	// 如果正在运行，先取消
-	if h.running && h.cancelFunc != nil {
-		h.cancelFunc()
-		h.running = false
+	// 从队列中移除批次的所有任务，正在下载的任务由队列调度器取消
+	cleared, err := h.queueService.ClearBatch()
+	if err != nil {
+		h.sendErrorResponse(Conn, err)
+		return true
 	}
-
-	// 清除所有任务
-	taskCount := len(h.tasks)
-	h.tasks = nil
-	h.cancelFunc = nil
+	taskCount := int(cleared)
 
 	utils.Info("🗑️ [批量下载] 已清除所有任务（%d 个）", taskCount)
 
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"testing"
+
+	"wx_channel/internal/database"
+)
+
+func TestBatchTaskToVideoInfo(t *testing.T) {
+	task := BatchTask{
+		ID:              "video-1",
+		VideoURL:        "https://example.com/video.mp4",
+		Title:           "Title",
+		AuthorName:      "Author",
+		DecryptKey:      "12345",
+		DecryptorPrefix: "AAEC",
+		PrefixLen:       3,
+		Duration:        "01:05",
+		CoverURL:        "https://example.com/cover.jpg",
+		LikeCount:       "10",
+		IPRegion:        "广东",
+	}
+
+	info := task.toVideoInfo("batch_feed")
+	if info.VideoURL != task.VideoURL || info.DecryptKey != "12345" || info.CoverURL != task.CoverURL {
+		t.Errorf("Legacy fields not mapped: %+v", info)
+	}
+	if info.Author != "Author" {
+		t.Errorf("Expected author 'Author', got '%s'", info.Author)
+	}
+	if info.Duration != 65000 {
+		t.Errorf("Expected duration 65000ms, got %d", info.Duration)
+	}
+	if info.PageSource != "batch_feed" || info.DecryptorPrefix != "AAEC" || info.PrefixLen != 3 {
+		t.Errorf("Batch fields not mapped: %+v", info)
+	}
+	if info.BatchStats.LikeCount != "10" || info.BatchStats.Duration != "01:05" || info.BatchStats.IPRegion != "广东" {
+		t.Errorf("Stats strings not preserved: %+v", info.BatchStats)
+	}
+}
+
+func TestBatchTaskFromQueueItem(t *testing.T) {
+	tests := []struct {
+		status       string
+		wantStatus   string
+		wantProgress float64
+	}{
+		{database.QueueStatusPending, "pending", 25},
+		{database.QueueStatusPaused, "pending", 25},
+		{database.QueueStatusDownloading, "downloading", 25},
+		{database.QueueStatusCompleted, "done", 100},
+		{database.QueueStatusFailed, "failed", 25},
+	}
+
+	for _, tt := range tests {
+		item := &database.QueueItem{
+			VideoID:        "video-1",
+			Status:         tt.status,
+			TotalSize:      4 * 1024 * 1024,
+			DownloadedSize: 1024 * 1024,
+			BatchStats:     database.BatchStats{PlayCount: "100"},
+		}
+		task := batchTaskFromQueueItem(item)
+		if task.Status != tt.wantStatus {
+			t.Errorf("%s: expected status '%s', got '%s'", tt.status, tt.wantStatus, task.Status)
+		}
+		if task.Progress != tt.wantProgress {
+			t.Errorf("%s: expected progress %.0f, got %.2f", tt.status, tt.wantProgress, task.Progress)
+		}
+		if task.ID != "video-1" || task.PlayCount != "100" {
+			t.Errorf("%s: fields not mapped: %+v", tt.status, task)
+		}
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"wx_channel/internal/config"
+	"wx_channel/internal/database"
+	"wx_channel/internal/services"
 	"wx_channel/internal/utils"
 
 	"github.com/qtgolang/SunnyNet/SunnyNet"
@@ -216,6 +218,13 @@ func (h *CommentHandler) saveCommentData(comments []map[string]interface{}, vide
 	// 记录详细评论采集日志
 	utils.LogComment(videoID, videoTitle, totalComments, true)
 
+	// 同时保存到数据库以便全文搜索，失败不影响文件保存
+	if database.GetDB() != nil {
+		if _, err := services.NewCommentService().SaveCaptured(comments, videoID, videoTitle, saveTime); err != nil {
+			utils.Warn("评论写入数据库失败: %v", err)
+		}
+	}
+
 	return nil
 }
 
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -32,6 +33,17 @@ type ConsoleAPIHandler struct {
 	statsService    *services.StatisticsService
 	exportService   *services.ExportService
 	searchService   *services.SearchService
+	verifyService   *services.VerifyService
+	fastStart       *services.FastStartService
+	dedupe          *services.DedupeService
+	diskGuard       *services.DiskGuard
+	cleanupService  *services.CleanupService
+	trash           *services.TrashService
+	tagService      *services.TagService
+	collections     *services.CollectionService
+	authors         *services.AuthorService
+	videoMetrics    *services.VideoMetricsService
+	backups         *services.BackupService
 	wsHub           *websocket.Hub
 }
 
@@ -47,6 +59,17 @@ func NewConsoleAPIHandler(cfg *config.Config, wsHub *websocket.Hub) *ConsoleAPIH
 		statsService:    services.NewStatisticsService(),
 		exportService:   services.NewExportService(),
 		searchService:   services.NewSearchService(),
+		verifyService:   services.NewVerifyService(),
+		fastStart:       services.NewFastStartService(),
+		dedupe:          services.NewDedupeService(),
+		diskGuard:       services.NewDiskGuard(),
+		cleanupService:  services.NewCleanupService(),
+		trash:           services.NewTrashService(),
+		tagService:      services.NewTagService(),
+		collections:     services.NewCollectionService(),
+		authors:         services.NewAuthorService(),
+		videoMetrics:    services.NewVideoMetricsService(),
+		backups:         services.NewBackupService(),
 		wsHub:           wsHub,
 	}
 }
@@ -197,6 +220,15 @@ func getFilterParams(r *http.Request) *database.FilterParams {
 	if query := r.URL.Query().Get("query"); query != "" {
 		params.Query = query
 	}
+	if tag := r.URL.Query().Get("tag"); tag != "" {
+		params.Tag = tag
+	}
+	if collection := r.URL.Query().Get("collection"); collection != "" {
+		params.CollectionID = collection
+	}
+	if authorID := r.URL.Query().Get("authorId"); authorID != "" {
+		params.AuthorID = authorID
+	}
 
 	return params
 }
@@ -373,108 +405,930 @@ func (h *ConsoleAPIHandler) HandleDownloadsList(w http.ResponseWriter, r *http.R
 	result, err := h.downloadService.List(params)
 	if err != nil {
 		h.sendError(w, r, http.StatusInternalServerError, err.Error())
-		return
+		return
+	}
+
+	h.sendSuccess(w, r, result)
+}
+
+// HandleDownloadsGet 处理 GET /api/downloads/:id - 单条记录
+func (h *ConsoleAPIHandler) HandleDownloadsGet(w http.ResponseWriter, r *http.Request, id string) {
+	if h.HandleCORS(w, r) {
+		return
+	}
+
+	record, err := h.downloadService.GetByID(id)
+	if err != nil {
+		h.sendError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if record == nil {
+		h.sendError(w, r, http.StatusNotFound, "record not found")
+		return
+	}
+
+	h.sendSuccess(w, r, record)
+}
+
+// HandleDownloadsDelete 处理 DELETE /api/downloads/:id - 删除单条记录
+func (h *ConsoleAPIHandler) HandleDownloadsDelete(w http.ResponseWriter, r *http.Request, id string) {
+	if h.HandleCORS(w, r) {
+		return
+	}
+
+	// 检查是否应删除文件
+	deleteFiles := r.URL.Query().Get("deleteFiles") == "true"
+
+	err := h.downloadService.Delete(id, deleteFiles)
+	if err != nil {
+		h.sendError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.sendSuccessMessage(w, r, "record deleted")
+}
+
+// HandleDownloadsDeleteMany 处理 DELETE /api/downloads - 批量删除
+func (h *ConsoleAPIHandler) HandleDownloadsDeleteMany(w http.ResponseWriter, r *http.Request) {
+	if h.HandleCORS(w, r) {
+		return
+	}
+
+	var req struct {
+		IDs         []string `json:"ids"`
+		DeleteFiles bool     `json:"deleteFiles"`
+	}
+	if err := h.parseJSON(r, &req); err != nil {
+		h.sendError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if len(req.IDs) == 0 {
+		h.sendError(w, r, http.StatusBadRequest, "no IDs provided")
+		return
+	}
+
+	count, err := h.downloadService.DeleteMany(req.IDs, req.DeleteFiles)
+	if err != nil {
+		h.sendError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.sendSuccess(w, r, map[string]interface{}{
+		"deleted": count,
+	})
+}
+
+// HandleDownloadsVerify 处理 GET/POST /api/downloads/:id/verify - 校验下载文件的大小和 MP4 结构
+func (h *ConsoleAPIHandler) HandleDownloadsVerify(w http.ResponseWriter, r *http.Request, id string) {
+	if h.HandleCORS(w, r) {
+		return
+	}
+
+	result, err := h.verifyService.VerifyRecord(id)
+	if err != nil {
+		h.sendError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if result == nil {
+		h.sendError(w, r, http.StatusNotFound, "record not found")
+		return
+	}
+
+	h.sendSuccess(w, r, result)
+}
+
+// HandleDownloadsScan 处理 POST /api/downloads/verify - 校验所有已完成的下载
+func (h *ConsoleAPIHandler) HandleDownloadsScan(w http.ResponseWriter, r *http.Request) {
+	if h.HandleCORS(w, r) {
+		return
+	}
+
+	result, err := h.verifyService.ScanLibrary()
+	if err != nil {
+		h.sendError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.sendSuccess(w, r, result)
+}
+
+// HandleDownloadsDuplicates 处理 GET /api/downloads/duplicates - 列出内容哈希相同的下载分组
+func (h *ConsoleAPIHandler) HandleDownloadsDuplicates(w http.ResponseWriter, r *http.Request) {
+	if h.HandleCORS(w, r) {
+		return
+	}
+
+	groups, err := h.dedupe.GetDuplicateGroups()
+	if err != nil {
+		h.sendError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.sendSuccess(w, r, map[string]interface{}{
+		"policy": h.dedupe.Policy(),
+		"groups": groups,
+		"total":  len(groups),
+	})
+}
+
+// HandleDownloadsBackfillHashes 处理 POST /api/downloads/duplicates/backfill - 为旧的下载记录计算内容哈希
+func (h *ConsoleAPIHandler) HandleDownloadsBackfillHashes(w http.ResponseWriter, r *http.Request) {
+	if h.HandleCORS(w, r) {
+		return
+	}
+
+	hashed, err := h.dedupe.BackfillHashes()
+	if err != nil {
+		h.sendError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.sendSuccess(w, r, map[string]interface{}{
+		"hashed": hashed,
+	})
+}
+
+// HandleDownloadsRetention 处理 /api/downloads/retention - 按保留策略清理下载库
+// GET 或带 dryRun=true 的 POST 只返回将被删除的下载和可释放的空间
+func (h *ConsoleAPIHandler) HandleDownloadsRetention(w http.ResponseWriter, r *http.Request) {
+	if h.HandleCORS(w, r) {
+		return
+	}
+
+	dryRun := r.Method == "GET" || r.URL.Query().Get("dryRun") == "true"
+	result, err := h.cleanupService.RunAutoCleanup(dryRun)
+	if err != nil {
+		h.sendError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.sendSuccess(w, r, result)
+}
+
+// HandleDownloadsStar 处理 PUT /api/downloads/:id/star - 设置下载的加星状态
+func (h *ConsoleAPIHandler) HandleDownloadsStar(w http.ResponseWriter, r *http.Request, id string) {
+	if h.HandleCORS(w, r) {
+		return
+	}
+
+	var req struct {
+		Starred bool `json:"starred"`
+	}
+	if err := h.parseJSON(r, &req); err != nil {
+		h.sendError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.downloadService.SetStarred(id, req.Starred); err != nil {
+		h.sendError(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+
+	h.sendSuccess(w, r, map[string]interface{}{
+		"id":      id,
+		"starred": req.Starred,
+	})
+}
+
+// HandleDownloadsAPI 路由下载记录 API 请求
+func (h *ConsoleAPIHandler) HandleDownloadsAPI(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+
+	// 处理 CORS 预检请求
+	if h.HandleCORS(w, r) {
+		return
+	}
+
+	// 从路径提取 ID 和操作
+	// 路径格式: /api/downloads/:id 或 /api/downloads/:id/verify 或 /api/downloads/verify
+	// 或 /api/downloads/duplicates[/backfill] 或 /api/downloads/retention 或 /api/downloads/:id/star
+	// 或 /api/downloads/:id/tags
+	path = strings.Replace(path, "/api/v1/", "/api/", 1)
+	pathParts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/api/downloads"), "/"), "/")
+	id := pathParts[0]
+	action := ""
+	if len(pathParts) > 1 {
+		action = pathParts[1]
+	}
+
+	if id == "verify" && action == "" {
+		if r.Method != "POST" {
+			h.sendError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h.HandleDownloadsScan(w, r)
+		return
+	}
+	if id == "duplicates" {
+		switch {
+		case action == "" && r.Method == "GET":
+			h.HandleDownloadsDuplicates(w, r)
+		case action == "backfill" && r.Method == "POST":
+			h.HandleDownloadsBackfillHashes(w, r)
+		case action == "" || action == "backfill":
+			h.sendError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		default:
+			h.sendError(w, r, http.StatusBadRequest, "invalid action")
+		}
+		return
+	}
+	if id == "retention" && action == "" {
+		if r.Method != "GET" && r.Method != "POST" {
+			h.sendError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h.HandleDownloadsRetention(w, r)
+		return
+	}
+	if action == "tags" {
+		if r.Method != "GET" && r.Method != "PUT" {
+			h.sendError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h.HandleDownloadsTags(w, r, id)
+		return
+	}
+	if action == "star" {
+		if r.Method != "PUT" {
+			h.sendError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h.HandleDownloadsStar(w, r, id)
+		return
+	}
+	if action == "verify" {
+		if r.Method != "GET" && r.Method != "POST" {
+			h.sendError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h.HandleDownloadsVerify(w, r, id)
+		return
+	}
+	if action != "" {
+		h.sendError(w, r, http.StatusBadRequest, "invalid action")
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		if id != "" {
+			h.HandleDownloadsGet(w, r, id)
+		} else {
+			h.HandleDownloadsList(w, r)
+		}
+	case "DELETE":
+		if id != "" {
+			h.HandleDownloadsDelete(w, r, id)
+		} else {
+			h.HandleDownloadsDeleteMany(w, r)
+		}
+	default:
+		h.sendError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// ============================================================================
+// 回收站 API 处理器
+// ============================================================================
+
+// HandleTrashList 处理 GET /api/trash - 列出回收站中的项目
+func (h *ConsoleAPIHandler) HandleTrashList(w http.ResponseWriter, r *http.Request) {
+	if h.HandleCORS(w, r) {
+		return
+	}
+
+	items, err := h.trash.List()
+	if err != nil {
+		h.sendError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var totalSize int64
+	for _, item := range items {
+		totalSize += item.FileSize
+	}
+	retentionDays := 0
+	if settings, err := h.settingsRepo.Load(); err == nil {
+		retentionDays = settings.TrashRetentionDays
+	}
+
+	h.sendSuccess(w, r, map[string]interface{}{
+		"items":         items,
+		"total":         len(items),
+		"totalSize":     totalSize,
+		"retentionDays": retentionDays,
+		"path":          h.trash.TrashDir(),
+	})
+}
+
+// HandleTrashRestore 处理 POST /api/trash/:id/restore - 恢复回收站项目
+func (h *ConsoleAPIHandler) HandleTrashRestore(w http.ResponseWriter, r *http.Request, id string) {
+	if h.HandleCORS(w, r) {
+		return
+	}
+
+	item, err := h.trash.Restore(id)
+	if err != nil {
+		h.sendTrashError(w, r, err)
+		return
+	}
+
+	h.sendSuccess(w, r, item)
+}
+
+// HandleTrashPurge 处理 DELETE /api/trash[/:id] - 永久删除回收站项目
+// 不指定 ID 时清空回收站，带 expired=true 时只删除过期的项目
+func (h *ConsoleAPIHandler) HandleTrashPurge(w http.ResponseWriter, r *http.Request, id string) {
+	if h.HandleCORS(w, r) {
+		return
+	}
+
+	var result *services.TrashPurgeResult
+	var err error
+	switch {
+	case id != "":
+		result, err = h.trash.Purge(id)
+	case r.URL.Query().Get("expired") == "true":
+		result, err = h.trash.PurgeExpired()
+	default:
+		result, err = h.trash.PurgeAll()
+	}
+	if err != nil {
+		h.sendTrashError(w, r, err)
+		return
+	}
+
+	h.sendSuccess(w, r, result)
+}
+
+// sendTrashError 发送回收站错误，项目不存在时返回 404，原路径已有文件时返回 409
+func (h *ConsoleAPIHandler) sendTrashError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrTrashItemNotFound):
+		h.sendError(w, r, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrRestoreConflict):
+		h.sendError(w, r, http.StatusConflict, err.Error())
+	default:
+		h.sendError(w, r, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// HandleTrashAPI 路由回收站 API 请求
+// 路径格式: /api/trash 或 /api/trash/:id 或 /api/trash/:id/restore
+func (h *ConsoleAPIHandler) HandleTrashAPI(w http.ResponseWriter, r *http.Request) {
+	if h.HandleCORS(w, r) {
+		return
+	}
+
+	path := strings.Replace(r.URL.Path, "/api/v1/", "/api/", 1)
+	pathParts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/api/trash"), "/"), "/")
+	id := pathParts[0]
+	action := ""
+	if len(pathParts) > 1 {
+		action = pathParts[1]
+	}
+
+	switch {
+	case action == "restore" && id != "":
+		if r.Method != "POST" {
+			h.sendError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h.HandleTrashRestore(w, r, id)
+	case action != "":
+		h.sendError(w, r, http.StatusBadRequest, "invalid action")
+	case r.Method == "GET" && id == "":
+		h.HandleTrashList(w, r)
+	case r.Method == "DELETE":
+		h.HandleTrashPurge(w, r, id)
+	default:
+		h.sendError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// ============================================================================
+// 数据库备份 API 处理器
+// ============================================================================
+
+// restoreRequest 恢复数据库的请求体
+type restoreRequest struct {
+	Name string `json:"name"`
+}
+
+// HandleDatabaseBackup 处理 POST /api/db/backup - 立即备份数据库
+func (h *ConsoleAPIHandler) HandleDatabaseBackup(w http.ResponseWriter, r *http.Request) {
+	info, err := h.backups.Create()
+	if err != nil {
+		h.sendError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.sendSuccess(w, r, info)
+}
+
+// HandleDatabaseBackupList 处理 GET /api/db/backups - 列出数据库备份
+func (h *ConsoleAPIHandler) HandleDatabaseBackupList(w http.ResponseWriter, r *http.Request) {
+	backups, err := h.backups.List()
+	if err != nil {
+		h.sendError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	settings, err := h.settingsRepo.Load()
+	if err != nil {
+		settings = database.DefaultSettings()
+	}
+	h.sendSuccess(w, r, map[string]interface{}{
+		"backups":       backups,
+		"total":         len(backups),
+		"intervalHours": settings.BackupIntervalHours,
+		"keep":          settings.BackupKeep,
+		"path":          h.backups.BackupDir(),
+	})
+}
+
+// HandleDatabaseRestore 处理 POST /api/db/restore - 从备份恢复数据库
+func (h *ConsoleAPIHandler) HandleDatabaseRestore(w http.ResponseWriter, r *http.Request) {
+	var req restoreRequest
+	if err := h.parseJSON(r, &req); err != nil {
+		h.sendError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Name == "" {
+		h.sendError(w, r, http.StatusBadRequest, "backup name is required")
+		return
+	}
+
+	result, err := h.backups.Restore(req.Name)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrBackupNotFound):
+			h.sendError(w, r, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrInvalidBackup), errors.Is(err, services.ErrIncompatibleBackup):
+			h.sendError(w, r, http.StatusBadRequest, err.Error())
+		default:
+			h.sendError(w, r, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.sendSuccess(w, r, result)
+}
+
+// HandleDatabaseIntegrity 处理 GET /api/db/integrity - 立即执行完整性检查
+func (h *ConsoleAPIHandler) HandleDatabaseIntegrity(w http.ResponseWriter, r *http.Request) {
+	health, err := h.backups.Health(true)
+	if err != nil {
+		h.sendError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.sendSuccess(w, r, health)
+}
+
+// HandleDatabaseAPI 路由数据库备份 API 请求
+// 路径格式: /api/db/backup、/api/db/backups、/api/db/restore 或 /api/db/integrity
+func (h *ConsoleAPIHandler) HandleDatabaseAPI(w http.ResponseWriter, r *http.Request) {
+	if h.HandleCORS(w, r) {
+		return
+	}
+
+	path := strings.TrimSuffix(strings.Replace(r.URL.Path, "/api/v1/", "/api/", 1), "/")
+	var method string
+	var handler http.HandlerFunc
+	switch path {
+	case "/api/db/backup":
+		method, handler = "POST", h.HandleDatabaseBackup
+	case "/api/db/backups":
+		method, handler = "GET", h.HandleDatabaseBackupList
+	case "/api/db/restore":
+		method, handler = "POST", h.HandleDatabaseRestore
+	case "/api/db/integrity":
+		method, handler = "GET", h.HandleDatabaseIntegrity
+	default:
+		h.sendError(w, r, http.StatusNotFound, "not found")
+		return
+	}
+
+	if r.Method != method {
+		h.sendError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	handler(w, r)
+}
+
+// ============================================================================
+// 标签和合集 API 处理器
+// ============================================================================
+
+// tagRequest 创建或修改标签的请求体
+type tagRequest struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// collectionRequest 创建或修改合集的请求体
+type collectionRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// HandleTagsList 处理 GET /api/tags - 列出所有标签
+func (h *ConsoleAPIHandler) HandleTagsList(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.tagService.List()
+	if err != nil {
+		h.sendError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.sendSuccess(w, r, tags)
+}
+
+// HandleTagsCreate 处理 POST /api/tags - 创建标签
+func (h *ConsoleAPIHandler) HandleTagsCreate(w http.ResponseWriter, r *http.Request) {
+	var req tagRequest
+	if err := h.parseJSON(r, &req); err != nil {
+		h.sendError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	tag, err := h.tagService.Create(req.Name, req.Color)
+	if err != nil {
+		h.sendLibraryError(w, r, err)
+		return
+	}
+
+	h.sendSuccess(w, r, tag)
+}
+
+// HandleTagsUpdate 处理 PUT /api/tags/:id - 修改标签
+func (h *ConsoleAPIHandler) HandleTagsUpdate(w http.ResponseWriter, r *http.Request, id string) {
+	var req tagRequest
+	if err := h.parseJSON(r, &req); err != nil {
+		h.sendError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	tag, err := h.tagService.Update(id, req.Name, req.Color)
+	if err != nil {
+		h.sendLibraryError(w, r, err)
+		return
+	}
+
+	h.sendSuccess(w, r, tag)
+}
+
+// HandleTagsDelete 处理 DELETE /api/tags/:id - 删除标签
+func (h *ConsoleAPIHandler) HandleTagsDelete(w http.ResponseWriter, r *http.Request, id string) {
+	if err := h.tagService.Delete(id); err != nil {
+		h.sendLibraryError(w, r, err)
+		return
+	}
+
+	h.sendSuccessMessage(w, r, "tag deleted")
+}
+
+// HandleTagsAPI 路由标签 API 请求
+// 路径格式: /api/tags 或 /api/tags/:id
+func (h *ConsoleAPIHandler) HandleTagsAPI(w http.ResponseWriter, r *http.Request) {
+	if h.HandleCORS(w, r) {
+		return
+	}
+
+	path := strings.Replace(r.URL.Path, "/api/v1/", "/api/", 1)
+	pathParts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/api/tags"), "/"), "/")
+	id := pathParts[0]
+	if len(pathParts) > 1 {
+		h.sendError(w, r, http.StatusBadRequest, "invalid action")
+		return
+	}
+
+	switch {
+	case r.Method == "GET" && id == "":
+		h.HandleTagsList(w, r)
+	case r.Method == "POST" && id == "":
+		h.HandleTagsCreate(w, r)
+	case r.Method == "PUT" && id != "":
+		h.HandleTagsUpdate(w, r, id)
+	case r.Method == "DELETE" && id != "":
+		h.HandleTagsDelete(w, r, id)
+	default:
+		h.sendError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// HandleDownloadsTags 处理 GET/PUT /api/downloads/:id/tags - 获取或替换下载记录的标签
+// PUT 请求体为 {"tags": ["项目A", "待剪辑"]}，不存在的标签自动创建
+func (h *ConsoleAPIHandler) HandleDownloadsTags(w http.ResponseWriter, r *http.Request, id string) {
+	if h.HandleCORS(w, r) {
+		return
+	}
+
+	var tags []string
+	var err error
+	if r.Method == "PUT" {
+		var req struct {
+			Tags []string `json:"tags"`
+		}
+		if err := h.parseJSON(r, &req); err != nil {
+			h.sendError(w, r, http.StatusBadRequest, "invalid request body")
+			return
+		}
+		tags, err = h.tagService.SetRecordTags(id, req.Tags)
+	} else {
+		tags, err = h.tagService.GetRecordTags(id)
+	}
+	if err != nil {
+		h.sendLibraryError(w, r, err)
+		return
+	}
+
+	h.sendSuccess(w, r, map[string]interface{}{
+		"id":   id,
+		"tags": tags,
+	})
+}
+
+// HandleCollectionsList 处理 GET /api/collections - 列出所有合集
+func (h *ConsoleAPIHandler) HandleCollectionsList(w http.ResponseWriter, r *http.Request) {
+	collections, err := h.collections.List()
+	if err != nil {
+		h.sendError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.sendSuccess(w, r, collections)
+}
+
+// HandleCollectionsGet 处理 GET /api/collections/:id - 获取合集及其中的下载记录（分页，支持下载记录的过滤参数）
+func (h *ConsoleAPIHandler) HandleCollectionsGet(w http.ResponseWriter, r *http.Request, id string) {
+	collection, err := h.collections.Get(id)
+	if err != nil {
+		h.sendLibraryError(w, r, err)
+		return
+	}
+
+	params := getFilterParams(r)
+	params.CollectionID = id
+	records, err := h.downloadService.List(params)
+	if err != nil {
+		h.sendError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.sendSuccess(w, r, map[string]interface{}{
+		"collection": collection,
+		"records":    records,
+	})
+}
+
+// HandleCollectionsCreate 处理 POST /api/collections - 创建合集
+func (h *ConsoleAPIHandler) HandleCollectionsCreate(w http.ResponseWriter, r *http.Request) {
+	var req collectionRequest
+	if err := h.parseJSON(r, &req); err != nil {
+		h.sendError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	collection, err := h.collections.Create(req.Name, req.Description)
+	if err != nil {
+		h.sendLibraryError(w, r, err)
+		return
+	}
+
+	h.sendSuccess(w, r, collection)
+}
+
+// HandleCollectionsUpdate 处理 PUT /api/collections/:id - 修改合集
+func (h *ConsoleAPIHandler) HandleCollectionsUpdate(w http.ResponseWriter, r *http.Request, id string) {
+	var req collectionRequest
+	if err := h.parseJSON(r, &req); err != nil {
+		h.sendError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	collection, err := h.collections.Update(id, req.Name, req.Description)
+	if err != nil {
+		h.sendLibraryError(w, r, err)
+		return
+	}
+
+	h.sendSuccess(w, r, collection)
+}
+
+// HandleCollectionsDelete 处理 DELETE /api/collections/:id - 删除合集（保留其中的下载记录）
+func (h *ConsoleAPIHandler) HandleCollectionsDelete(w http.ResponseWriter, r *http.Request, id string) {
+	if err := h.collections.Delete(id); err != nil {
+		h.sendLibraryError(w, r, err)
+		return
+	}
+
+	h.sendSuccessMessage(w, r, "collection deleted")
+}
+
+// HandleCollectionsRecords 处理 POST/DELETE /api/collections/:id/records - 向合集加入或移除下载记录
+func (h *ConsoleAPIHandler) HandleCollectionsRecords(w http.ResponseWriter, r *http.Request, id string) {
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	if err := h.parseJSON(r, &req); err != nil {
+		h.sendError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(req.IDs) == 0 {
+		h.sendError(w, r, http.StatusBadRequest, "no IDs provided")
+		return
+	}
+
+	if r.Method == "POST" {
+		added, err := h.collections.AddRecords(id, req.IDs)
+		if err != nil {
+			h.sendLibraryError(w, r, err)
+			return
+		}
+		h.sendSuccess(w, r, map[string]interface{}{"added": added})
+		return
+	}
+
+	removed, err := h.collections.RemoveRecords(id, req.IDs)
+	if err != nil {
+		h.sendLibraryError(w, r, err)
+		return
+	}
+	h.sendSuccess(w, r, map[string]interface{}{"removed": removed})
+}
+
+// HandleCollectionsAPI 路由合集 API 请求
+// 路径格式: /api/collections 或 /api/collections/:id 或 /api/collections/:id/records
+func (h *ConsoleAPIHandler) HandleCollectionsAPI(w http.ResponseWriter, r *http.Request) {
+	if h.HandleCORS(w, r) {
+		return
+	}
+
+	path := strings.Replace(r.URL.Path, "/api/v1/", "/api/", 1)
+	pathParts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/api/collections"), "/"), "/")
+	id := pathParts[0]
+	action := ""
+	if len(pathParts) > 1 {
+		action = pathParts[1]
+	}
+
+	switch {
+	case action == "records" && id != "":
+		if r.Method != "POST" && r.Method != "DELETE" {
+			h.sendError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h.HandleCollectionsRecords(w, r, id)
+	case action != "":
+		h.sendError(w, r, http.StatusBadRequest, "invalid action")
+	case id == "" && r.Method == "GET":
+		h.HandleCollectionsList(w, r)
+	case id == "" && r.Method == "POST":
+		h.HandleCollectionsCreate(w, r)
+	case id != "" && r.Method == "GET":
+		h.HandleCollectionsGet(w, r, id)
+	case id != "" && r.Method == "PUT":
+		h.HandleCollectionsUpdate(w, r, id)
+	case id != "" && r.Method == "DELETE":
+		h.HandleCollectionsDelete(w, r, id)
+	default:
+		h.sendError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// sendLibraryError 发送标签和合集错误：不存在时返回 404，名称重复时返回 409，参数无效时返回 400
+func (h *ConsoleAPIHandler) sendLibraryError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, services.ErrTagNotFound), errors.Is(err, services.ErrCollectionNotFound),
+		errors.Is(err, services.ErrRecordNotFound):
+		h.sendError(w, r, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrTagExists), errors.Is(err, services.ErrCollectionExists):
+		h.sendError(w, r, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrInvalidName), errors.Is(err, services.ErrInvalidColor):
+		h.sendError(w, r, http.StatusBadRequest, err.Error())
+	default:
+		h.sendError(w, r, http.StatusInternalServerError, err.Error())
 	}
-
-	h.sendSuccess(w, r, result)
 }
 
-// HandleDownloadsGet 处理 GET /api/downloads/:id - 单条记录
-func (h *ConsoleAPIHandler) HandleDownloadsGet(w http.ResponseWriter, r *http.Request, id string) {
+// ============================================================================
+// 作者 API 处理器
+// ============================================================================
+
+// HandleAuthorsList 处理 GET /api/authors - 分页列出作者及其浏览、下载汇总
+// 支持 query（作者 ID 或昵称）和 sortBy（last_seen_at、nickname、videos_browsed、videos_downloaded、bytes_stored、avg_likes）
+func (h *ConsoleAPIHandler) HandleAuthorsList(w http.ResponseWriter, r *http.Request) {
 	if h.HandleCORS(w, r) {
 		return
 	}
 
-	record, err := h.downloadService.GetByID(id)
+	params := &database.FilterParams{
+		PaginationParams: *getPaginationParams(r),
+		Query:            r.URL.Query().Get("query"),
+	}
+	if r.URL.Query().Get("sortBy") == "" {
+		params.SortBy = "last_seen_at"
+	}
+
+	result, err := h.authors.List(params)
 	if err != nil {
 		h.sendError(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
-	if record == nil {
-		h.sendError(w, r, http.StatusNotFound, "record not found")
-		return
-	}
 
-	h.sendSuccess(w, r, record)
+	h.sendSuccess(w, r, result)
 }
 
-// HandleDownloadsDelete 处理 DELETE /api/downloads/:id - 删除单条记录
-func (h *ConsoleAPIHandler) HandleDownloadsDelete(w http.ResponseWriter, r *http.Request, id string) {
+// HandleAuthorsGet 处理 GET /api/authors/:id - 作者详情、昵称历史和汇总
+func (h *ConsoleAPIHandler) HandleAuthorsGet(w http.ResponseWriter, r *http.Request, id string) {
 	if h.HandleCORS(w, r) {
 		return
 	}
 
-	// 检查是否应删除文件
-	deleteFiles := r.URL.Query().Get("deleteFiles") == "true"
-
-	err := h.downloadService.Delete(id, deleteFiles)
+	author, err := h.authors.Get(id)
 	if err != nil {
+		if errors.Is(err, services.ErrAuthorNotFound) {
+			h.sendError(w, r, http.StatusNotFound, err.Error())
+			return
+		}
 		h.sendError(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	h.sendSuccessMessage(w, r, "record deleted")
+	h.sendSuccess(w, r, author)
 }
 
-// HandleDownloadsDeleteMany 处理 DELETE /api/downloads - 批量删除
-func (h *ConsoleAPIHandler) HandleDownloadsDeleteMany(w http.ResponseWriter, r *http.Request) {
+// HandleAuthorsAPI 路由作者 API 请求
+// 路径格式: /api/authors 或 /api/authors/:id
+func (h *ConsoleAPIHandler) HandleAuthorsAPI(w http.ResponseWriter, r *http.Request) {
 	if h.HandleCORS(w, r) {
 		return
 	}
 
-	var req struct {
-		IDs         []string `json:"ids"`
-		DeleteFiles bool     `json:"deleteFiles"`
-	}
-	if err := h.parseJSON(r, &req); err != nil {
-		h.sendError(w, r, http.StatusBadRequest, "invalid request body")
+	path := strings.Replace(r.URL.Path, "/api/v1/", "/api/", 1)
+	pathParts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/api/authors"), "/"), "/")
+	id := pathParts[0]
+	if len(pathParts) > 1 {
+		h.sendError(w, r, http.StatusBadRequest, "invalid action")
 		return
 	}
 
-	if len(req.IDs) == 0 {
-		h.sendError(w, r, http.StatusBadRequest, "no IDs provided")
+	switch {
+	case r.Method == "GET" && id == "":
+		h.HandleAuthorsList(w, r)
+	case r.Method == "GET":
+		h.HandleAuthorsGet(w, r, id)
+	default:
+		h.sendError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// ============================================================================
+// 视频互动数据 API 处理器
+// ============================================================================
+
+// HandleVideoMetrics 处理 GET /api/videos/:id/metrics - 视频互动数据随时间变化的曲线
+// 支持 interval（raw、hour、day）以及 startDate、endDate（YYYY-MM-DD）
+func (h *ConsoleAPIHandler) HandleVideoMetrics(w http.ResponseWriter, r *http.Request, id string) {
+	if h.HandleCORS(w, r) {
 		return
 	}
 
-	count, err := h.downloadService.DeleteMany(req.IDs, req.DeleteFiles)
+	params := getFilterParams(r)
+	series, err := h.videoMetrics.Series(id, r.URL.Query().Get("interval"), params.StartDate, params.EndDate)
 	if err != nil {
-		h.sendError(w, r, http.StatusInternalServerError, err.Error())
+		switch {
+		case errors.Is(err, services.ErrVideoNotFound):
+			h.sendError(w, r, http.StatusNotFound, err.Error())
+		case errors.Is(err, services.ErrInvalidMetricsInterval):
+			h.sendError(w, r, http.StatusBadRequest, err.Error())
+		default:
+			h.sendError(w, r, http.StatusInternalServerError, err.Error())
+		}
 		return
 	}
 
-	h.sendSuccess(w, r, map[string]interface{}{
-		"deleted": count,
-	})
+	h.sendSuccess(w, r, series)
 }
 
-// HandleDownloadsAPI 路由下载记录 API 请求
-func (h *ConsoleAPIHandler) HandleDownloadsAPI(w http.ResponseWriter, r *http.Request) {
-	path := r.URL.Path
-
-	// 处理 CORS 预检请求
+// HandleVideosAPI 路由视频 API 请求
+// 路径格式: /api/videos/:id/metrics
+func (h *ConsoleAPIHandler) HandleVideosAPI(w http.ResponseWriter, r *http.Request) {
 	if h.HandleCORS(w, r) {
 		return
 	}
 
-	// 从路径提取 ID
-	id := extractIDFromPath(path, "/api/downloads")
-
-	switch r.Method {
-	case "GET":
-		if id != "" {
-			h.HandleDownloadsGet(w, r, id)
-		} else {
-			h.HandleDownloadsList(w, r)
-		}
-	case "DELETE":
-		if id != "" {
-			h.HandleDownloadsDelete(w, r, id)
-		} else {
-			h.HandleDownloadsDeleteMany(w, r)
-		}
-	default:
+	path := strings.Replace(r.URL.Path, "/api/v1/", "/api/", 1)
+	pathParts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/api/videos"), "/"), "/")
+	if len(pathParts) != 2 || pathParts[0] == "" || pathParts[1] != "metrics" {
+		h.sendError(w, r, http.StatusNotFound, "not found")
+		return
+	}
+	if r.Method != "GET" {
 		h.sendError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
 	}
+
+	h.HandleVideoMetrics(w, r, pathParts[0])
 }
 
 // ============================================================================
@@ -679,6 +1533,60 @@ func (h *ConsoleAPIHandler) HandleQueueFail(w http.ResponseWriter, r *http.Reque
 	h.sendSuccessMessage(w, r, "download marked as failed")
 }
 
+// HandleQueueSpeedLimit 处理 PUT /api/queue/:id/speed-limit - 设置单任务限速
+func (h *ConsoleAPIHandler) HandleQueueSpeedLimit(w http.ResponseWriter, r *http.Request, id string) {
+	if h.HandleCORS(w, r) {
+		return
+	}
+
+	var req struct {
+		Limit int64 `json:"limit"` // 字节/秒，0 表示使用默认单任务限速
+	}
+	if err := h.parseJSON(r, &req); err != nil {
+		h.sendError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.queueService.SetSpeedLimit(id, req.Limit); err != nil {
+		h.sendError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	item, _ := h.queueService.GetByID(id)
+	if item != nil {
+		GetWebSocketHub().BroadcastQueueUpdate(item)
+	}
+
+	h.sendSuccessMessage(w, r, "speed limit updated")
+}
+
+// HandleQueueEmbedMetadata 处理 PUT /api/queue/:id/embed-metadata - 设置下载完成后是否写入 MP4 元数据
+func (h *ConsoleAPIHandler) HandleQueueEmbedMetadata(w http.ResponseWriter, r *http.Request, id string) {
+	if h.HandleCORS(w, r) {
+		return
+	}
+
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := h.parseJSON(r, &req); err != nil {
+		h.sendError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.queueService.SetEmbedMetadata(id, req.Enabled); err != nil {
+		h.sendError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	item, _ := h.queueService.GetByID(id)
+	if item != nil {
+		GetWebSocketHub().BroadcastQueueUpdate(item)
+	}
+
+	h.sendSuccessMessage(w, r, "embed metadata updated")
+}
+
 // HandleQueueAPI 路由队列 API 请求
 func (h *ConsoleAPIHandler) HandleQueueAPI(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
@@ -725,6 +1633,10 @@ func (h *ConsoleAPIHandler) HandleQueueAPI(w http.ResponseWriter, r *http.Reques
 			h.HandleQueueComplete(w, r, id)
 		case "fail":
 			h.HandleQueueFail(w, r, id)
+		case "speed-limit":
+			h.HandleQueueSpeedLimit(w, r, id)
+		case "embed-metadata":
+			h.HandleQueueEmbedMetadata(w, r, id)
 		default:
 			h.sendError(w, r, http.StatusBadRequest, "invalid action")
 		}
@@ -765,22 +1677,105 @@ func (h *ConsoleAPIHandler) HandleSettingsUpdate(w http.ResponseWriter, r *http.
 		return
 	}
 
-	var settings database.Settings
-	if err := h.parseJSON(r, &settings); err != nil {
+	// 以当前设置为基础，未提供的字段保持不变
+	settings, err := h.settingsRepo.Load()
+	if err != nil {
+		settings = database.DefaultSettings()
+	}
+	if err := h.parseJSON(r, settings); err != nil {
 		h.sendError(w, r, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
 	// 验证并保存设置
 	// Requirements: 11.3, 11.4 - 验证分片大小 (1-100MB) 和并发限制 (1-5)
-	if err := h.settingsRepo.SaveAndValidate(&settings); err != nil {
+	if err := h.settingsRepo.SaveAndValidate(settings); err != nil {
 		h.sendError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	// 带宽限制立即对进行中的下载生效
+	services.GetBandwidthLimiter().ApplySettings(settings)
+
 	h.sendSuccessMessage(w, r, "settings updated")
 }
 
+// FilenamePreviewRequest 文件名预览请求
+type FilenamePreviewRequest struct {
+	Template string `json:"template"` // 为空时使用当前设置的模板
+	ID       string `json:"id"`       // 浏览记录、下载记录或队列项目 ID，为空时使用示例数据
+}
+
+// HandleFilenamePreview 处理 GET/POST /api/settings/filename-preview - 预览记录按模板保存的路径
+func (h *ConsoleAPIHandler) HandleFilenamePreview(w http.ResponseWriter, r *http.Request) {
+	if h.HandleCORS(w, r) {
+		return
+	}
+
+	var req FilenamePreviewRequest
+	switch r.Method {
+	case "GET":
+		req.Template = r.URL.Query().Get("template")
+		req.ID = r.URL.Query().Get("id")
+	case "POST":
+		if err := h.parseJSON(r, &req); err != nil {
+			h.sendError(w, r, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	default:
+		h.sendError(w, r, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	fields, found := h.filenameFieldsForRecord(req.ID)
+	if req.ID != "" && !found {
+		h.sendError(w, r, http.StatusNotFound, "record not found")
+		return
+	}
+
+	path, err := services.NewNamingService().Preview(req.Template, fields)
+	if err != nil {
+		h.sendError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.sendSuccess(w, r, map[string]interface{}{
+		"path":         path,
+		"fields":       fields,
+		"placeholders": utils.FilenamePlaceholders(),
+	})
+}
+
+// filenameFieldsForRecord 按 ID 依次查找浏览记录、下载记录和队列项目，未提供 ID 时返回示例数据
+func (h *ConsoleAPIHandler) filenameFieldsForRecord(id string) (utils.FilenameFields, bool) {
+	if id == "" {
+		return utils.FilenameFields{
+			Title:        "示例视频标题",
+			Author:       "示例作者",
+			AuthorID:     "v2_example@finder",
+			VideoID:      "14000000000000000000",
+			Resolution:   "1080x1920",
+			Source:       "feed",
+			Time:         time.Now(),
+			LikeCount:    1024,
+			CommentCount: 128,
+			ForwardCount: 64,
+			FavCount:     32,
+		}, false
+	}
+
+	if record, err := h.browseService.GetByID(id); err == nil && record != nil {
+		return services.FilenameFieldsFromBrowseRecord(record), true
+	}
+	if record, err := h.downloadService.GetByID(id); err == nil && record != nil {
+		return services.FilenameFieldsFromDownloadRecord(record), true
+	}
+	if item, err := h.queueService.GetByID(id); err == nil && item != nil {
+		return services.FilenameFieldsFromQueueItem(item), true
+	}
+	return utils.FilenameFields{}, false
+}
+
 // HandleSettingsAPI 路由设置 API 请求
 func (h *ConsoleAPIHandler) HandleSettingsAPI(w http.ResponseWriter, r *http.Request) {
 	// 处理 CORS 预检请求
@@ -861,9 +1856,65 @@ func (h *ConsoleAPIHandler) HandleStatsChart(w http.ResponseWriter, r *http.Requ
 	h.sendSuccess(w, r, chartData)
 }
 
+// getAnalyticsParams 从查询字符串中提取统计报表参数（startDate、endDate、pageSource、limit）
+func getAnalyticsParams(r *http.Request) *database.AnalyticsParams {
+	filter := getFilterParams(r)
+	params := &database.AnalyticsParams{
+		StartDate:  filter.StartDate,
+		EndDate:    filter.EndDate,
+		PageSource: r.URL.Query().Get("pageSource"),
+	}
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit > 0 {
+		params.Limit = limit
+	}
+	return params
+}
+
+// HandleStatsTrending 处理 GET /api/stats/trending - 点赞或评论增长最快的视频
+func (h *ConsoleAPIHandler) HandleStatsTrending(w http.ResponseWriter, r *http.Request) {
+	videos, err := h.statsService.GetTrendingVideos(getAnalyticsParams(r), r.URL.Query().Get("metric"))
+	if err != nil {
+		h.sendStatsError(w, r, err)
+		return
+	}
+
+	h.sendSuccess(w, r, videos)
+}
+
+// HandleStatsTopAuthors 处理 GET /api/stats/top-authors - 互动总数最高的作者
+func (h *ConsoleAPIHandler) HandleStatsTopAuthors(w http.ResponseWriter, r *http.Request) {
+	authors, err := h.statsService.GetTopAuthors(getAnalyticsParams(r))
+	if err != nil {
+		h.sendStatsError(w, r, err)
+		return
+	}
+
+	h.sendSuccess(w, r, authors)
+}
+
+// HandleStatsDistribution 处理 GET /api/stats/distribution - 分辨率和时长分布
+func (h *ConsoleAPIHandler) HandleStatsDistribution(w http.ResponseWriter, r *http.Request) {
+	distribution, err := h.statsService.GetDistribution(getAnalyticsParams(r))
+	if err != nil {
+		h.sendStatsError(w, r, err)
+		return
+	}
+
+	h.sendSuccess(w, r, distribution)
+}
+
+// sendStatsError 将统计报表错误映射为 HTTP 状态码
+func (h *ConsoleAPIHandler) sendStatsError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, services.ErrInvalidTrendingMetric) || errors.Is(err, services.ErrInvalidPageSource) {
+		h.sendError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.sendError(w, r, http.StatusInternalServerError, err.Error())
+}
+
 // HandleStatsAPI 路由统计 API 请求
 func (h *ConsoleAPIHandler) HandleStatsAPI(w http.ResponseWriter, r *http.Request) {
-	path := r.URL.Path
+	path := strings.Replace(r.URL.Path, "/api/v1/", "/api/", 1)
 
 	// Handle CORS preflight
 	if h.HandleCORS(w, r) {
@@ -875,9 +1926,16 @@ func (h *ConsoleAPIHandler) HandleStatsAPI(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if path == "/api/stats/chart" {
+	switch path {
+	case "/api/stats/chart":
 		h.HandleStatsChart(w, r)
-	} else {
+	case "/api/stats/trending":
+		h.HandleStatsTrending(w, r)
+	case "/api/stats/top-authors":
+		h.HandleStatsTopAuthors(w, r)
+	case "/api/stats/distribution":
+		h.HandleStatsDistribution(w, r)
+	default:
 		h.HandleStatsGet(w, r)
 	}
 }
@@ -937,7 +1995,7 @@ func (h *ConsoleAPIHandler) HandleExportDownloads(w http.ResponseWriter, r *http
 		ids = strings.Split(idsParam, ",")
 	}
 
-	result, err := h.exportService.ExportDownloadRecords(format, ids)
+	result, err := h.exportService.ExportDownloadRecords(format, ids, r.URL.Query().Get("tag"))
 	if err != nil {
 		h.sendError(w, r, http.StatusInternalServerError, err.Error())
 		return
@@ -980,7 +2038,7 @@ func (h *ConsoleAPIHandler) HandleExportAPI(w http.ResponseWriter, r *http.Reque
 // Requirements: 12.1, 12.2 - 跨浏览和下载记录的全局搜索
 // ============================================================================
 
-// HandleSearch 处理 GET /api/search - 全局搜索
+// HandleSearch 处理 GET /api/search - 全局搜索（浏览记录、下载记录和评论）
 func (h *ConsoleAPIHandler) HandleSearch(w http.ResponseWriter, r *http.Request) {
 	if h.HandleCORS(w, r) {
 		return
@@ -1004,15 +2062,30 @@ func (h *ConsoleAPIHandler) HandleSearch(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// 获取限制 (默认: 20)
-	limit := 20
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
-			limit = parsed
+	scope := r.URL.Query().Get("type")
+	if scope != "" && !services.IsValidSearchScope(scope) {
+		h.sendError(w, r, http.StatusBadRequest, "type must be one of: all, browse, download, comment")
+		return
+	}
+
+	// 分页参数 (默认: 第 1 页，每页 20 条)，limit 为 pageSize 的旧名称
+	params := database.PaginationParams{Page: 1, PageSize: 20}
+	if p := r.URL.Query().Get("page"); p != "" {
+		if parsed, err := strconv.Atoi(p); err == nil && parsed > 0 {
+			params.Page = parsed
+		}
+	}
+	pageSize := r.URL.Query().Get("pageSize")
+	if pageSize == "" {
+		pageSize = r.URL.Query().Get("limit")
+	}
+	if pageSize != "" {
+		if parsed, err := strconv.Atoi(pageSize); err == nil && parsed > 0 && parsed <= 100 {
+			params.PageSize = parsed
 		}
 	}
 
-	result, err := h.searchService.Search(query, limit)
+	result, err := h.searchService.Search(query, scope, params)
 	if err != nil {
 		h.sendError(w, r, http.StatusInternalServerError, err.Error())
 		return
@@ -1028,10 +2101,12 @@ func (h *ConsoleAPIHandler) HandleSearch(w http.ResponseWriter, r *http.Request)
 
 // HealthStatus 表示健康检查响应
 type HealthStatus struct {
-	Status        string `json:"status"`
-	Version       string `json:"version"`
-	Timestamp     string `json:"timestamp"`
-	WebSocketPort int    `json:"webSocketPort,omitempty"`
+	Status        string                   `json:"status"` // ok；磁盘空间不足、超出下载库容量或数据库完整性检查失败时为 degraded
+	Version       string                   `json:"version"`
+	Timestamp     string                   `json:"timestamp"`
+	WebSocketPort int                      `json:"webSocketPort,omitempty"`
+	Disk          *services.DiskStatus     `json:"disk,omitempty"`
+	Database      *services.DatabaseHealth `json:"database,omitempty"`
 }
 
 // HandleHealth 处理 GET /api/health - 健康检查
@@ -1059,6 +2134,20 @@ func (h *ConsoleAPIHandler) HandleHealth(w http.ResponseWriter, r *http.Request)
 		Timestamp:     time.Now().Format(time.RFC3339),
 		WebSocketPort: wsPort,
 	}
+	if h.diskGuard != nil {
+		status.Disk = h.diskGuard.Status()
+		if status.Disk.Blocked() {
+			status.Status = "degraded"
+		}
+	}
+	if h.backups != nil && database.GetDB() != nil {
+		if health, err := h.backups.Health(false); err == nil {
+			status.Database = health
+			if !health.OK() {
+				status.Status = "degraded"
+			}
+		}
+	}
 
 	h.sendSuccess(w, r, status)
 }
@@ -1089,6 +2178,8 @@ func (h *ConsoleAPIHandler) HandleAPIRequest(w http.ResponseWriter, r *http.Requ
 		h.HandleSearch(w, r)
 	case path == "/api/settings":
 		h.HandleSettingsAPI(w, r)
+	case path == "/api/settings/filename-preview":
+		h.HandleFilenamePreview(w, r)
 	case strings.HasPrefix(path, "/api/stats"):
 		h.HandleStatsAPI(w, r)
 	case strings.HasPrefix(path, "/api/export"):
@@ -1180,6 +2271,8 @@ func (h *ConsoleAPIHandler) HandleFilesAPI(w http.ResponseWriter, r *http.Reques
 		h.HandleOpenFolder(w, r)
 	case "/api/files/play":
 		h.HandlePlayVideo(w, r)
+	case "/api/files/faststart":
+		h.HandleFastStart(w, r)
 	default:
 		h.sendError(w, r, http.StatusNotFound, "endpoint not found")
 	}
@@ -1263,10 +2356,69 @@ func (h *ConsoleAPIHandler) HandlePlayVideo(w http.ResponseWriter, r *http.Reque
 		h.sendError(w, r, http.StatusInternalServerError, err.Error())
 		return
 	}
+	h.downloadService.MarkPlayed(req.Path, absPath)
 
 	h.sendSuccessMessage(w, r, "video player opened")
 }
 
+// HandleFastStart 处理 POST /api/files/faststart - 将已下载文件的 moov 移到文件开头
+// 指定 path 时处理单个文件，否则处理 ids 指定的下载记录（为空时处理所有已完成的下载）
+func (h *ConsoleAPIHandler) HandleFastStart(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Path string   `json:"path"`
+		IDs  []string `json:"ids"`
+	}
+	if err := h.parseJSON(r, &req); err != nil {
+		h.sendError(w, r, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if req.Path == "" {
+		summary, err := h.fastStart.ProcessRecords(req.IDs)
+		if err != nil {
+			h.sendFastStartError(w, r, err)
+			return
+		}
+		h.sendSuccess(w, r, summary)
+		return
+	}
+
+	downloadsDir, err := h.getConfig().GetResolvedDownloadsDir()
+	if err != nil {
+		h.sendError(w, r, http.StatusInternalServerError, "failed to resolve downloads directory")
+		return
+	}
+	absPath, err := validatePathInBase(downloadsDir, req.Path, false)
+	if err != nil {
+		if pe, ok := err.(*pathValidationError); ok {
+			h.sendError(w, r, pe.status, pe.msg)
+			return
+		}
+		h.sendError(w, r, http.StatusInternalServerError, "failed to validate path")
+		return
+	}
+	if !isAllowedVideoExtension(absPath) {
+		h.sendError(w, r, http.StatusBadRequest, "unsupported video file extension")
+		return
+	}
+
+	result, err := h.fastStart.ProcessFile(absPath)
+	if err != nil {
+		h.sendFastStartError(w, r, err)
+		return
+	}
+	h.sendSuccess(w, r, result)
+}
+
+// sendFastStartError 发送 faststart 错误，任务正在执行时返回 409
+func (h *ConsoleAPIHandler) sendFastStartError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, services.ErrFastStartRunning) {
+		h.sendError(w, r, http.StatusConflict, err.Error())
+		return
+	}
+	h.sendError(w, r, http.StatusInternalServerError, err.Error())
+}
+
 // CORSMiddleware 包装 http.Handler 以支持 CORS
 // Requirements: 14.6 - 在所有响应中包含 CORS 头
 func (h *ConsoleAPIHandler) CORSMiddleware(next http.Handler) http.Handler {
@@ -1421,6 +2573,11 @@ func (h *ConsoleAPIHandler) HandleVideoStream(w http.ResponseWriter, r *http.Req
 	fileSize := fileInfo.Size()
 	rangeHeader := r.Header.Get("Range")
 
+	// 从头开始的请求视为一次播放，跳转产生的范围请求不重复记录
+	if rangeHeader == "" || strings.HasPrefix(rangeHeader, "bytes=0-") {
+		h.downloadService.MarkPlayed(filePath, resolvedPath)
+	}
+
 	if rangeHeader != "" {
 		// 解析范围头
 		var start, end int64
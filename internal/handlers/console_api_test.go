@@ -74,6 +74,370 @@ func TestHandleQueueFail_InvalidJSONReturnsBadRequest(t *testing.T) {
 	}
 }
 
+func TestHandleDownloadsAPI_VerifyRouting(t *testing.T) {
+	handler := &ConsoleAPIHandler{}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+	}{
+		{
+			name:   "library scan requires POST",
+			method: http.MethodGet,
+			path:   "/api/downloads/verify",
+			want:   http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "record verify rejects DELETE",
+			method: http.MethodDelete,
+			path:   "/api/v1/downloads/test-id/verify",
+			want:   http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "unknown action",
+			method: http.MethodGet,
+			path:   "/api/downloads/test-id/unknown",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "duplicates rejects POST",
+			method: http.MethodPost,
+			path:   "/api/downloads/duplicates",
+			want:   http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "hash backfill requires POST",
+			method: http.MethodGet,
+			path:   "/api/v1/downloads/duplicates/backfill",
+			want:   http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "retention rejects DELETE",
+			method: http.MethodDelete,
+			path:   "/api/downloads/retention",
+			want:   http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "star requires PUT",
+			method: http.MethodPost,
+			path:   "/api/v1/downloads/test-id/star",
+			want:   http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "unknown duplicates action",
+			method: http.MethodGet,
+			path:   "/api/downloads/duplicates/unknown",
+			want:   http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rr := httptest.NewRecorder()
+
+			handler.HandleDownloadsAPI(rr, req)
+
+			if rr.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rr.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleTrashAPI_Routing(t *testing.T) {
+	handler := &ConsoleAPIHandler{}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+	}{
+		{
+			name:   "restore requires POST",
+			method: http.MethodGet,
+			path:   "/api/trash/test-id/restore",
+			want:   http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "unknown action",
+			method: http.MethodPost,
+			path:   "/api/v1/trash/test-id/unknown",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "single item cannot be fetched",
+			method: http.MethodGet,
+			path:   "/api/trash/test-id",
+			want:   http.StatusMethodNotAllowed,
+		},
+		{
+			name:   "list rejects POST",
+			method: http.MethodPost,
+			path:   "/api/trash",
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rr := httptest.NewRecorder()
+
+			handler.HandleTrashAPI(rr, req)
+
+			if rr.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rr.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleTagsAndCollectionsAPI_Routing(t *testing.T) {
+	handler := &ConsoleAPIHandler{}
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		handler func(http.ResponseWriter, *http.Request)
+		want    int
+	}{
+		{
+			name:    "tag list rejects PUT",
+			method:  http.MethodPut,
+			path:    "/api/v1/tags",
+			handler: handler.HandleTagsAPI,
+			want:    http.StatusMethodNotAllowed,
+		},
+		{
+			name:    "single tag cannot be created",
+			method:  http.MethodPost,
+			path:    "/api/tags/test-id",
+			handler: handler.HandleTagsAPI,
+			want:    http.StatusMethodNotAllowed,
+		},
+		{
+			name:    "tag has no actions",
+			method:  http.MethodGet,
+			path:    "/api/v1/tags/test-id/records",
+			handler: handler.HandleTagsAPI,
+			want:    http.StatusBadRequest,
+		},
+		{
+			name:    "collection records require POST or DELETE",
+			method:  http.MethodGet,
+			path:    "/api/v1/collections/test-id/records",
+			handler: handler.HandleCollectionsAPI,
+			want:    http.StatusMethodNotAllowed,
+		},
+		{
+			name:    "unknown collection action",
+			method:  http.MethodPost,
+			path:    "/api/collections/test-id/unknown",
+			handler: handler.HandleCollectionsAPI,
+			want:    http.StatusBadRequest,
+		},
+		{
+			name:    "collection list rejects DELETE",
+			method:  http.MethodDelete,
+			path:    "/api/collections",
+			handler: handler.HandleCollectionsAPI,
+			want:    http.StatusMethodNotAllowed,
+		},
+		{
+			name:    "download tags reject POST",
+			method:  http.MethodPost,
+			path:    "/api/v1/downloads/test-id/tags",
+			handler: handler.HandleDownloadsAPI,
+			want:    http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rr := httptest.NewRecorder()
+
+			tt.handler(rr, req)
+
+			if rr.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rr.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleAuthorsAPI_Routing(t *testing.T) {
+	handler := &ConsoleAPIHandler{}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+	}{
+		{name: "author list rejects POST", method: http.MethodPost, path: "/api/v1/authors", want: http.StatusMethodNotAllowed},
+		{name: "author is read-only", method: http.MethodDelete, path: "/api/authors/finder-1", want: http.StatusMethodNotAllowed},
+		{name: "author has no actions", method: http.MethodGet, path: "/api/v1/authors/finder-1/videos", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rr := httptest.NewRecorder()
+
+			handler.HandleAuthorsAPI(rr, req)
+
+			if rr.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rr.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleDatabaseAPI_Routing(t *testing.T) {
+	handler := &ConsoleAPIHandler{}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{name: "backup requires POST", method: http.MethodGet, path: "/api/v1/db/backup", want: http.StatusMethodNotAllowed},
+		{name: "backup list requires GET", method: http.MethodPost, path: "/api/db/backups", want: http.StatusMethodNotAllowed},
+		{name: "integrity requires GET", method: http.MethodDelete, path: "/api/v1/db/integrity", want: http.StatusMethodNotAllowed},
+		{name: "unknown path", method: http.MethodGet, path: "/api/v1/db/vacuum", want: http.StatusNotFound},
+		{name: "restore without name", method: http.MethodPost, path: "/api/v1/db/restore", body: `{}`, want: http.StatusBadRequest},
+		{name: "restore with invalid body", method: http.MethodPost, path: "/api/db/restore", body: `{`, want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			handler.HandleDatabaseAPI(rr, req)
+
+			if rr.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rr.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleVideosAPI_Routing(t *testing.T) {
+	handler := &ConsoleAPIHandler{}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+	}{
+		{name: "metrics reject POST", method: http.MethodPost, path: "/api/v1/videos/123/metrics", want: http.StatusMethodNotAllowed},
+		{name: "video without action", method: http.MethodGet, path: "/api/v1/videos/123", want: http.StatusNotFound},
+		{name: "unknown video action", method: http.MethodGet, path: "/api/videos/123/comments", want: http.StatusNotFound},
+		{name: "missing video id", method: http.MethodGet, path: "/api/videos//metrics", want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rr := httptest.NewRecorder()
+
+			handler.HandleVideosAPI(rr, req)
+
+			if rr.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rr.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleStatsAPI_Validation(t *testing.T) {
+	handler := &ConsoleAPIHandler{}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+	}{
+		{name: "stats reject POST", method: http.MethodPost, path: "/api/v1/stats/trending", want: http.StatusMethodNotAllowed},
+		{name: "invalid trending metric", method: http.MethodGet, path: "/api/v1/stats/trending?metric=views", want: http.StatusBadRequest},
+		{name: "invalid page source", method: http.MethodGet, path: "/api/stats/top-authors?pageSource=unknown", want: http.StatusBadRequest},
+		{name: "invalid distribution page source", method: http.MethodGet, path: "/api/v1/stats/distribution?pageSource=batch_unknown", want: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rr := httptest.NewRecorder()
+
+			handler.HandleStatsAPI(rr, req)
+
+			if rr.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rr.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleSearch_Validation(t *testing.T) {
+	handler := &ConsoleAPIHandler{}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+	}{
+		{
+			name:   "query required",
+			method: http.MethodGet,
+			path:   "/api/search",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "query too short",
+			method: http.MethodGet,
+			path:   "/api/search?q=a",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "unknown type",
+			method: http.MethodGet,
+			path:   "/api/search?q=hiking&type=author",
+			want:   http.StatusBadRequest,
+		},
+		{
+			name:   "search requires GET",
+			method: http.MethodPost,
+			path:   "/api/search?q=hiking",
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rr := httptest.NewRecorder()
+
+			handler.HandleSearch(rr, req)
+
+			if rr.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rr.Code, tt.want)
+			}
+		})
+	}
+}
+
 func TestValidateVideoPlayTargetURL(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -120,6 +120,12 @@ func (h *RecordHandler) HandleRecordDownload(Conn *SunnyNet.HttpConn) bool {
 			}
 		}
 	}
+	// 作者 ID（视频号 username），没有时由数据库从浏览记录中查找
+	if contact, ok := data["contact"].(map[string]interface{}); ok {
+		if id, ok := contact["id"].(string); ok {
+			record.AuthorID = id
+		}
+	}
 
 	// 添加可选字段
 	if size, ok := data["size"].(float64); ok {
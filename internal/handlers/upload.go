@@ -35,6 +35,9 @@ type UploadHandler struct {
 	chunkSem        chan struct{}
 	mergeSem        chan struct{}
 	wsHub           *websocket.Hub
+	sidecar         *services.SidecarService
+	dedupe          *services.DedupeService
+	diskGuard       *services.DiskGuard
 	activeDownloads sync.Map // map[string]context.CancelFunc
 }
 
@@ -54,6 +57,9 @@ func NewUploadHandler(cfg *config.Config, wsHub *websocket.Hub, gopeedService *s
 		chunkSem:        make(chan struct{}, ch),
 		mergeSem:        make(chan struct{}, mg),
 		wsHub:           wsHub,
+		sidecar:         services.NewSidecarService(),
+		dedupe:          services.NewDedupeService(),
+		diskGuard:       services.NewDiskGuard(),
 	}
 }
 
@@ -71,6 +77,31 @@ func (h *UploadHandler) getDownloadsDir() (string, error) {
 	return cfg.GetResolvedDownloadsDir()
 }
 
+// formatResolution 将宽高或分辨率字符串统一为 宽x高 格式
+func formatResolution(width, height int, resolution string) string {
+	if width > 0 && height > 0 {
+		return fmt.Sprintf("%dx%d", width, height)
+	}
+	resolution = strings.ReplaceAll(resolution, " ", "")
+	resolution = strings.ReplaceAll(resolution, "×", "x")
+	return strings.ReplaceAll(resolution, "X", "x")
+}
+
+// templateSavePath 按设置中的文件名模板计算保存路径并创建目录
+// 未配置模板或生成失败时返回 false，调用方沿用原有的 作者/文件名 布局
+func templateSavePath(downloadsDir string, fields utils.FilenameFields) (string, bool) {
+	naming := services.NewNamingService()
+	if !naming.Enabled() {
+		return "", false
+	}
+	path, err := naming.ResolvePath(downloadsDir, fields)
+	if err != nil {
+		utils.Warn("按文件名模板生成保存路径失败，使用默认布局: %v", err)
+		return "", false
+	}
+	return path, true
+}
+
 // Handle implements router.Interceptor
 func (h *UploadHandler) Handle(Conn *SunnyNet.HttpConn) bool {
 	// Critical nil check
@@ -526,19 +557,29 @@ func (h *UploadHandler) HandleCompleteUpload(Conn *SunnyNet.HttpConn) bool {
 	uploadsRoot := filepath.Join(downloadsDir, ".uploads")
 	upDir := filepath.Join(uploadsRoot, req.UploadId)
 
-	// 目标作者目录
-	authorFolder := utils.CleanFolderName(req.AuthorName)
-	savePath := filepath.Join(downloadsDir, authorFolder)
+	var savePath, cleanFilename string
+	if templatePath, ok := templateSavePath(downloadsDir, utils.FilenameFields{
+		Title:  strings.TrimSuffix(req.Filename, ".mp4"),
+		Author: req.AuthorName,
+		Source: "upload",
+	}); ok {
+		savePath = filepath.Dir(templatePath)
+		cleanFilename = filepath.Base(templatePath)
+	} else {
+		// 目标作者目录
+		authorFolder := utils.CleanFolderName(req.AuthorName)
+		savePath = filepath.Join(downloadsDir, authorFolder)
 
-	if err := utils.EnsureDir(savePath); err != nil {
-		utils.HandleError(err, "创建作者目录")
-		h.sendErrorResponse(Conn, err)
-		return true
-	}
+		if err := utils.EnsureDir(savePath); err != nil {
+			utils.HandleError(err, "创建作者目录")
+			h.sendErrorResponse(Conn, err)
+			return true
+		}
 
-	// 清理文件名
-	cleanFilename := utils.CleanFilename(req.Filename)
-	cleanFilename = utils.EnsureExtension(cleanFilename, ".mp4")
+		// 清理文件名
+		cleanFilename = utils.CleanFilename(req.Filename)
+		cleanFilename = utils.EnsureExtension(cleanFilename, ".mp4")
+	}
 
 	// 冲突处理
 	base := filepath.Base(cleanFilename)
@@ -605,6 +646,13 @@ func (h *UploadHandler) HandleCompleteUpload(Conn *SunnyNet.HttpConn) bool {
 	// 记录分片合并成功
 	utils.LogUploadMerge(req.UploadId, req.Filename, req.AuthorName, req.Total, fileSize, true)
 
+	// 写入元数据文件（分片上传只有标题和作者）
+	h.sidecar.WriteAsync(finalPath, &services.SidecarMetadata{
+		Title:    strings.TrimSuffix(req.Filename, ".mp4"),
+		Author:   req.AuthorName,
+		FileSize: totalWritten,
+	})
+
 	responseData := map[string]interface{}{
 		"success": true,
 		"path":    finalPath,
@@ -677,27 +725,38 @@ func (h *UploadHandler) HandleSaveVideo(Conn *SunnyNet.HttpConn) bool {
 	authorName := Conn.Request.FormValue("authorName")
 	isEncrypted := Conn.Request.FormValue("isEncrypted") == "true"
 
-	// 创建作者文件夹路径
-	authorFolder := utils.CleanFolderName(authorName)
-
 	downloadsDir, err := h.getDownloadsDir()
 	if err != nil {
 		utils.HandleError(err, "获取下载目录")
 		h.sendErrorResponse(Conn, err)
 		return true
 	}
-	savePath := filepath.Join(downloadsDir, authorFolder)
 
-	utils.Info("保存目录: %s", savePath)
-	if err := utils.EnsureDir(savePath); err != nil {
-		utils.HandleError(err, "创建文件夹")
-		h.sendErrorResponse(Conn, err)
-		return true
-	}
+	var savePath, cleanFilename string
+	if templatePath, ok := templateSavePath(downloadsDir, utils.FilenameFields{
+		Title:  strings.TrimSuffix(filename, ".mp4"),
+		Author: authorName,
+		Source: "upload",
+	}); ok {
+		savePath = filepath.Dir(templatePath)
+		cleanFilename = filepath.Base(templatePath)
+		utils.Info("保存目录: %s", savePath)
+	} else {
+		// 创建作者文件夹路径
+		authorFolder := utils.CleanFolderName(authorName)
+		savePath = filepath.Join(downloadsDir, authorFolder)
+
+		utils.Info("保存目录: %s", savePath)
+		if err := utils.EnsureDir(savePath); err != nil {
+			utils.HandleError(err, "创建文件夹")
+			h.sendErrorResponse(Conn, err)
+			return true
+		}
 
-	// 清理文件名
-	cleanFilename := utils.CleanFilename(filename)
-	cleanFilename = utils.EnsureExtension(cleanFilename, ".mp4")
+		// 清理文件名
+		cleanFilename = utils.CleanFilename(filename)
+		cleanFilename = utils.EnsureExtension(cleanFilename, ".mp4")
+	}
 
 	// 生成唯一文件名
 	filePath := filepath.Join(savePath, cleanFilename)
@@ -744,6 +803,13 @@ func (h *UploadHandler) HandleSaveVideo(Conn *SunnyNet.HttpConn) bool {
 	// 记录直接上传成功
 	utils.LogDirectUpload(filename, authorName, fileSize, isEncrypted, true)
 
+	// 写入元数据文件（直接上传只有标题和作者）
+	h.sidecar.WriteAsync(filePath, &services.SidecarMetadata{
+		Title:    strings.TrimSuffix(filename, ".mp4"),
+		Author:   authorName,
+		FileSize: written,
+	})
+
 	responseData := map[string]interface{}{
 		"success": true,
 		"path":    filePath,
@@ -1002,6 +1068,14 @@ func (h *UploadHandler) HandleDownloadVideo(Conn *SunnyNet.HttpConn) bool {
 		CommentCount int64  `json:"commentCount"`
 		ForwardCount int64  `json:"forwardCount"`
 		FavCount     int64  `json:"favCount"`
+		PageSource   string `json:"pageSource"` // 页面来源（可选）
+		// 以下字段用于写入元数据 sidecar 文件（可选）
+		CoverURL   string `json:"coverUrl"`
+		Duration   int64  `json:"duration"` // 时长（毫秒）
+		PlayCount  int64  `json:"playCount"`
+		CreateTime string `json:"createTime"`
+		IPRegion   string `json:"ipRegion"`
+		PageURL    string `json:"pageUrl"`
 	}
 
 	if err := json.Unmarshal(body, &req); err != nil {
@@ -1015,25 +1089,12 @@ func (h *UploadHandler) HandleDownloadVideo(Conn *SunnyNet.HttpConn) bool {
 		return true
 	}
 
-	// 创建作者目录
-	authorFolder := utils.CleanFolderName(req.Author)
-	if authorFolder == "" {
-		authorFolder = "未知作者"
-	}
-
 	downloadsDir, err := h.getDownloadsDir()
 	if err != nil {
 		utils.HandleError(err, "获取下载目录")
 		h.sendErrorResponse(Conn, err)
 		return true
 	}
-	savePath := filepath.Join(downloadsDir, authorFolder)
-
-	if err := utils.EnsureDir(savePath); err != nil {
-		utils.HandleError(err, "创建作者目录")
-		h.sendErrorResponse(Conn, err)
-		return true
-	}
 
 	// 优先使用视频ID进行去重检查（如果提供了视频ID）
 	if !req.ForceSave && req.VideoID != "" && h.downloadService != nil {
@@ -1051,56 +1112,86 @@ func (h *UploadHandler) HandleDownloadVideo(Conn *SunnyNet.HttpConn) bool {
 		}
 	}
 
-	// 生成文件名：优先使用视频ID确保唯一性
-	filename := utils.GenerateVideoFilename(req.Title, req.VideoID)
+	// 配置了文件名模板时按模板生成保存路径，否则使用 作者/标题_ID_画质 布局
+	var videoPath string
+	if templatePath, ok := templateSavePath(downloadsDir, utils.FilenameFields{
+		Title:        req.Title,
+		Author:       req.Author,
+		VideoID:      req.VideoID,
+		Resolution:   formatResolution(req.Width, req.Height, req.Resolution),
+		Source:       req.PageSource,
+		LikeCount:    req.LikeCount,
+		CommentCount: req.CommentCount,
+		ForwardCount: req.ForwardCount,
+		FavCount:     req.FavCount,
+	}); ok {
+		videoPath = templatePath
+	} else {
+		// 创建作者目录
+		authorFolder := utils.CleanFolderName(req.Author)
+		if authorFolder == "" {
+			authorFolder = "未知作者"
+		}
 
-	// 检查文件名中是否已经包含分辨率信息（避免重复添加）
-	hasResolutionInFilename := false
-	if req.Width > 0 && req.Height > 0 {
-		resolutionPattern := fmt.Sprintf("_%dx%d", req.Width, req.Height)
-		hasResolutionInFilename = strings.Contains(filename, resolutionPattern)
-	} else if req.Resolution != "" {
-		cleanResolution := strings.ReplaceAll(req.Resolution, " ", "")
-		cleanResolution = strings.ReplaceAll(cleanResolution, "×", "x")
-		cleanResolution = strings.ReplaceAll(cleanResolution, "X", "x")
-		hasResolutionInFilename = strings.Contains(filename, "_"+cleanResolution) || strings.Contains(filename, cleanResolution)
-	}
+		savePath := filepath.Join(downloadsDir, authorFolder)
 
-	// 如果有分辨率信息且文件名中还没有，添加到文件名中（与前端命名方式一致）
-	if !hasResolutionInFilename && (req.FileFormat != "" || req.Width > 0 || req.Height > 0 || req.Resolution != "") {
-		var qualityInfo string
-		if req.FileFormat != "" {
-			qualityInfo = req.FileFormat
-		} else {
-			qualityInfo = "quality"
+		if err := utils.EnsureDir(savePath); err != nil {
+			utils.HandleError(err, "创建作者目录")
+			h.sendErrorResponse(Conn, err)
+			return true
 		}
 
-		// 优先使用 width 和 height，其次使用 resolution 字符串
+		// 生成文件名：优先使用视频ID确保唯一性
+		filename := utils.GenerateVideoFilename(req.Title, req.VideoID)
+
+		// 检查文件名中是否已经包含分辨率信息（避免重复添加）
+		hasResolutionInFilename := false
 		if req.Width > 0 && req.Height > 0 {
-			qualityInfo += fmt.Sprintf("_%dx%d", req.Width, req.Height)
+			resolutionPattern := fmt.Sprintf("_%dx%d", req.Width, req.Height)
+			hasResolutionInFilename = strings.Contains(filename, resolutionPattern)
 		} else if req.Resolution != "" {
-			// 清理分辨率字符串，移除空格和特殊字符
 			cleanResolution := strings.ReplaceAll(req.Resolution, " ", "")
 			cleanResolution = strings.ReplaceAll(cleanResolution, "×", "x")
 			cleanResolution = strings.ReplaceAll(cleanResolution, "X", "x")
-			qualityInfo += "_" + cleanResolution
+			hasResolutionInFilename = strings.Contains(filename, "_"+cleanResolution) || strings.Contains(filename, cleanResolution)
 		}
 
-		// 在添加分辨率信息前，需要先移除扩展名
-		base := strings.TrimSuffix(filename, filepath.Ext(filename))
-		ext := filepath.Ext(filename)
-		if ext == "" {
-			ext = ".mp4"
+		// 如果有分辨率信息且文件名中还没有，添加到文件名中（与前端命名方式一致）
+		if !hasResolutionInFilename && (req.FileFormat != "" || req.Width > 0 || req.Height > 0 || req.Resolution != "") {
+			var qualityInfo string
+			if req.FileFormat != "" {
+				qualityInfo = req.FileFormat
+			} else {
+				qualityInfo = "quality"
+			}
+
+			// 优先使用 width 和 height，其次使用 resolution 字符串
+			if req.Width > 0 && req.Height > 0 {
+				qualityInfo += fmt.Sprintf("_%dx%d", req.Width, req.Height)
+			} else if req.Resolution != "" {
+				// 清理分辨率字符串，移除空格和特殊字符
+				cleanResolution := strings.ReplaceAll(req.Resolution, " ", "")
+				cleanResolution = strings.ReplaceAll(cleanResolution, "×", "x")
+				cleanResolution = strings.ReplaceAll(cleanResolution, "X", "x")
+				qualityInfo += "_" + cleanResolution
+			}
+
+			// 在添加分辨率信息前，需要先移除扩展名
+			base := strings.TrimSuffix(filename, filepath.Ext(filename))
+			ext := filepath.Ext(filename)
+			if ext == "" {
+				ext = ".mp4"
+			}
+			filename = base + "_" + qualityInfo + ext
+			utils.Info("📐 [视频下载] 添加分辨率信息到文件名: %s", qualityInfo)
+		} else if hasResolutionInFilename {
+			utils.Info("📐 [视频下载] 文件名中已包含分辨率信息，跳过添加")
 		}
-		filename = base + "_" + qualityInfo + ext
-		utils.Info("📐 [视频下载] 添加分辨率信息到文件名: %s", qualityInfo)
-	} else if hasResolutionInFilename {
-		utils.Info("📐 [视频下载] 文件名中已包含分辨率信息，跳过添加")
-	}
 
-	// 确保文件扩展名
-	filename = utils.EnsureExtension(filename, ".mp4")
-	videoPath := filepath.Join(savePath, filename)
+		// 确保文件扩展名
+		filename = utils.EnsureExtension(filename, ".mp4")
+		videoPath = filepath.Join(savePath, filename)
+	}
 
 	// 检查文件是否已存在（作为备用检查，主要检查已通过ID完成）
 	if !req.ForceSave {
@@ -1130,6 +1221,13 @@ func (h *UploadHandler) HandleDownloadVideo(Conn *SunnyNet.HttpConn) bool {
 	// 判断是否需要解密
 	needDecrypt := req.Key != ""
 
+	// 磁盘空间不足或超出下载库容量时不开始下载（此时还不知道文件大小）
+	if err := h.diskGuard.CheckDownload(filepath.Dir(videoPath), 0); err != nil {
+		utils.Error("❌ [视频下载] %v", err)
+		h.sendErrorResponse(Conn, fmt.Errorf("磁盘空间不足: %v", err))
+		return true
+	}
+
 	// 临时文件路径
 	tmpPath := videoPath + ".tmp"
 
@@ -1194,7 +1292,28 @@ func (h *UploadHandler) HandleDownloadVideo(Conn *SunnyNet.HttpConn) bool {
 		connections = cfg.DownloadConnections
 	}
 
-	err = h.gopeedService.DownloadSync(downloadCtx, req.VideoURL, tmpPath, connections, onProgress)
+	// 按共享的重试策略下载：超时、5xx 等临时错误退避后重试，404、磁盘已满等永久错误直接失败
+	retryCount := 3
+	if cfg != nil && cfg.DownloadRetryCount >= 0 {
+		retryCount = cfg.DownloadRetryCount
+	}
+	policy := services.NewRetryPolicyWithRetries(retryCount)
+	// 限速下载时数据经过本进程，写入的同时计算内容哈希；Gopeed 引擎直接写文件时为空
+	var contentHash string
+	err = policy.Do(downloadCtx, func(attempt int) error {
+		if attempt > 1 {
+			// 重试前删除上一次的残留文件，避免 Gopeed 改名保存
+			os.Remove(tmpPath)
+		}
+		var err error
+		contentHash, err = h.gopeedService.DownloadSync(downloadCtx, req.VideoURL, tmpPath, connections, onProgress)
+		return err
+	}, func(attempt database.RetryAttempt) {
+		if attempt.DelayMs > 0 {
+			utils.Warn("⚠️ [视频下载] 第 %d 次下载失败，%d 毫秒后重试: %s", attempt.Attempt, attempt.DelayMs, attempt.Error)
+			utils.LogDownloadRetry(req.VideoID, req.Title, attempt.Attempt, policy.MaxRetries(), fmt.Errorf("%s", attempt.Error))
+		}
+	})
 	if err != nil {
 		utils.Error("❌ [视频下载] Gopeed 下载失败: %v", err)
 		h.sendErrorResponse(Conn, fmt.Errorf("下载失败: %v", err))
@@ -1217,6 +1336,8 @@ func (h *UploadHandler) HandleDownloadVideo(Conn *SunnyNet.HttpConn) bool {
 			h.sendErrorResponse(Conn, fmt.Errorf("解密失败: %v", err))
 			return true
 		}
+		// 原地解密改写了文件开头，下载时计算的哈希不再对应文件内容
+		contentHash = ""
 		utils.Info("✓ [视频下载] 解密完成")
 	}
 
@@ -1228,6 +1349,10 @@ func (h *UploadHandler) HandleDownloadVideo(Conn *SunnyNet.HttpConn) bool {
 		return true
 	}
 
+	// 按去重策略处理重复内容，没有写入时计算的哈希时读取文件计算
+	dedup, contentHash := h.dedupe.HashAndApply(videoPath, contentHash)
+	videoPath = dedup.FilePath
+
 	fileSize := float64(stat.Size()) / (1024 * 1024)
 	relativePath, _ := filepath.Rel(downloadsDir, videoPath)
 
@@ -1244,7 +1369,8 @@ func (h *UploadHandler) HandleDownloadVideo(Conn *SunnyNet.HttpConn) bool {
 			VideoID:      req.VideoID,
 			Title:        req.Title,
 			Author:       req.Author,
-			Duration:     0, // 暂时无法获取准确时长，除非前端传递
+			CoverURL:     req.CoverURL,
+			Duration:     req.Duration,
 			FileSize:     int64(stat.Size()),
 			FilePath:     videoPath,
 			Format:       "mp4",
@@ -1255,6 +1381,7 @@ func (h *UploadHandler) HandleDownloadVideo(Conn *SunnyNet.HttpConn) bool {
 			CommentCount: req.CommentCount,
 			ForwardCount: req.ForwardCount,
 			FavCount:     req.FavCount,
+			ContentHash:  contentHash,
 		}
 		if err := h.downloadService.Create(record); err != nil {
 			utils.Error("保存下载记录失败: %v", err)
@@ -1263,12 +1390,37 @@ func (h *UploadHandler) HandleDownloadVideo(Conn *SunnyNet.HttpConn) bool {
 		}
 	}
 
+	// 写入元数据文件
+	meta := &services.SidecarMetadata{
+		VideoID:      req.VideoID,
+		Title:        req.Title,
+		Author:       req.Author,
+		Duration:     req.Duration,
+		Resolution:   req.Resolution,
+		FileSize:     stat.Size(),
+		PlayCount:    req.PlayCount,
+		LikeCount:    req.LikeCount,
+		CommentCount: req.CommentCount,
+		FavCount:     req.FavCount,
+		ForwardCount: req.ForwardCount,
+		CreateTime:   req.CreateTime,
+		IPRegion:     req.IPRegion,
+		PageURL:      req.PageURL,
+		PageSource:   req.PageSource,
+		CoverURL:     req.CoverURL,
+	}
+	meta.MergeBrowseRecord()
+	if dedup.Action != services.DedupeActionSkipped {
+		h.sidecar.WriteAsync(videoPath, meta)
+	}
+
 	responseData := map[string]interface{}{
 		"success":      true,
 		"path":         videoPath,
 		"relativePath": relativePath,
 		"size":         fileSize,
 		"decrypted":    needDecrypt,
+		"dedupe":       dedup.Action,
 	}
 	responseBytes, err := json.Marshal(responseData)
 	if err != nil {
@@ -1310,15 +1462,25 @@ func (h *UploadHandler) downloadVideoWithRetry(ctx context.Context, client *http
 		return fmt.Errorf("请求失败: %v", err)
 	}
 
-	// 确保响应体总是被关闭
+	// 确保响应体总是被关闭（使用原始响应体，避免排空时受限速影响）
+	rawBody := resp.Body
 	defer func() {
-		if resp != nil && resp.Body != nil {
+		if rawBody != nil {
 			// 尝试完全读取并关闭，避免连接泄漏
-			io.Copy(io.Discard, resp.Body)
-			resp.Body.Close()
+			io.Copy(io.Discard, rawBody)
+			rawBody.Close()
 		}
 	}()
 
+	// 应用全局和单任务带宽限制
+	limiter := services.GetBandwidthLimiter()
+	releaseLimit := limiter.Acquire(videoPath, 0)
+	defer releaseLimit()
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{limiter.Reader(ctx, videoPath, rawBody), rawBody}
+
 	// 包装 resp.Body 以显示进度
 	if req.Title != "" { // 只对有标题的请求（真实下载）显示进度
 		resp.Body = &utils.ProgressReader{
@@ -1358,7 +1520,7 @@ func (h *UploadHandler) downloadVideoWithRetry(ctx context.Context, client *http
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
 		// 读取并丢弃响应体，确保连接可以复用
 		io.Copy(io.Discard, resp.Body)
-		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+		return &services.HTTPStatusError{StatusCode: resp.StatusCode}
 	}
 
 	// 如果服务器不支持 Range，重新下载
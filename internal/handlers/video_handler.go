@@ -12,6 +12,7 @@ import (
 
 	"wx_channel/internal/database"
 	"wx_channel/internal/response"
+	"wx_channel/internal/services"
 	"wx_channel/internal/utils"
 
 	"github.com/fatih/color"
@@ -106,6 +107,19 @@ func (h *APIHandler) processVideoData(data map[string]interface{}) {
 	if aid, ok := data["authorId"].(string); ok {
 		authorID = aid
 	}
+	// 前端上报的作者信息在 contact 中（id 为视频号 username）
+	avatarURL := ""
+	if contact, ok := data["contact"].(map[string]interface{}); ok {
+		if id, ok := contact["id"].(string); ok && authorID == "" {
+			authorID = id
+		}
+		if n, ok := contact["nickname"].(string); ok && author == "" {
+			author = n
+		}
+		if a, ok := contact["avatar_url"].(string); ok {
+			avatarURL = a
+		}
+	}
 	sizeMB := 0.0
 	var size int64 = 0
 	if s, ok := data["size"].(float64); ok {
@@ -187,6 +201,7 @@ func (h *APIHandler) processVideoData(data map[string]interface{}) {
 
 	// 保存浏览记录到数据库
 	h.saveBrowseRecord(videoID, title, author, authorID, duration, size, coverUrl, url, decryptKey, resolution, likeCount, commentCount, favCount, forwardCount, pageUrl)
+	h.saveAuthor(authorID, author, avatarURL)
 
 	color.Yellow("\n")
 
@@ -340,6 +355,23 @@ func (h *APIHandler) saveBrowseRecord(videoID, title, author, authorID string, d
 			utils.Info("✓ 浏览记录已保存: %s", title)
 		}
 	}
+
+	// 每次看到视频都追加一条互动数据快照，用于查看增长曲线
+	if err == nil {
+		if err := services.NewVideoMetricsService().Record(record); err != nil {
+			utils.Warn("保存互动数据快照失败: %v", err)
+		}
+	}
+}
+
+// saveAuthor 记录视频作者及其昵称和头像
+func (h *APIHandler) saveAuthor(authorID, nickname, avatarURL string) {
+	if authorID == "" || database.GetDB() == nil {
+		return
+	}
+	if err := services.NewAuthorService().Observe(authorID, nickname, avatarURL, time.Now()); err != nil {
+		utils.Warn("保存作者信息失败: %v", err)
+	}
 }
 
 // HandleTip 处理前端提示请求
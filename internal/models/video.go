@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // VideoProfile 视频信息模型
 type VideoProfile struct {
@@ -94,6 +97,13 @@ func (v *VideoProfile) ToDownloadRecord(pageURL string) *VideoDownloadRecord {
 	}
 }
 
+// VideoDownloadRecordCSVHeader 下载记录CSV表头，与 ToCSVRow 的列顺序一致
+var VideoDownloadRecordCSVHeader = []string{
+	"ID", "标题", "作者", "作者类型", "公众号名称", "视频链接", "页面链接",
+	"文件大小", "时长", "播放量", "点赞数", "评论数", "收藏数", "转发数",
+	"发布时间", "IP属地", "下载时间", "页面来源", "搜索关键词",
+}
+
 // ToCSVRow 转换为CSV行
 func (v *VideoDownloadRecord) ToCSVRow() []string {
 	return []string{
@@ -118,3 +128,44 @@ func (v *VideoDownloadRecord) ToCSVRow() []string {
 		v.SearchKeyword,
 	}
 }
+
+// FromCSVRow 从 ToCSVRow 生成的CSV行解析，第一列不带 ID_ 前缀（如表头）时返回 false
+// 缺少的列保留为空，下载时间按本地时间解析
+func (v *VideoDownloadRecord) FromCSVRow(row []string) bool {
+	cell := func(i int) string {
+		if i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	id := cell(0)
+	if !strings.HasPrefix(id, "ID_") {
+		return false
+	}
+
+	*v = VideoDownloadRecord{
+		ID:            strings.TrimPrefix(id, "ID_"),
+		Title:         cell(1),
+		Author:        cell(2),
+		AuthorType:    cell(3),
+		OfficialName:  cell(4),
+		URL:           cell(5),
+		PageURL:       cell(6),
+		FileSize:      cell(7),
+		Duration:      cell(8),
+		PlayCount:     cell(9),
+		LikeCount:     cell(10),
+		CommentCount:  cell(11),
+		FavCount:      cell(12),
+		ForwardCount:  cell(13),
+		CreateTime:    cell(14),
+		IPRegion:      cell(15),
+		PageSource:    cell(17),
+		SearchKeyword: cell(18),
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", cell(16), time.Local); err == nil {
+		v.DownloadAt = t
+	}
+	return true
+}
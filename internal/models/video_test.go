@@ -161,3 +161,34 @@ func TestVideoProfile_FromMap_EmptyContact(t *testing.T) {
 	}
 }
 
+
+func TestVideoDownloadRecord_FromCSVRow(t *testing.T) {
+	original := &VideoDownloadRecord{
+		ID:         "123",
+		Title:      "测试视频",
+		Author:     "测试作者",
+		FileSize:   "10.5 MB",
+		Duration:   "01:30",
+		LikeCount:  "100",
+		DownloadAt: time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local),
+		PageSource: "feed",
+	}
+
+	var record VideoDownloadRecord
+	if !record.FromCSVRow(original.ToCSVRow()) {
+		t.Fatal("FromCSVRow() 应该能解析 ToCSVRow() 生成的行")
+	}
+	if record != *original {
+		t.Errorf("FromCSVRow() = %+v, 期望 %+v", record, *original)
+	}
+
+	// 表头行不是记录
+	if record.FromCSVRow(VideoDownloadRecordCSVHeader) {
+		t.Error("FromCSVRow() 不应该解析表头行")
+	}
+
+	// 缺少的列保留为空
+	if !record.FromCSVRow([]string{"ID_456", "标题"}) || record.ID != "456" || record.Author != "" {
+		t.Errorf("FromCSVRow() 解析不完整的行失败: %+v", record)
+	}
+}
@@ -21,6 +21,7 @@ type APIRouter struct {
 	systemService      *api.SystemService
 	logsService        *api.LogsService
 	exportService      *api.ExportAPI
+	importService      *api.ImportAPI
 	proxyService       *api.ProxyService
 	certificateService *api.CertificateService
 	versionService     *api.VersionAPI
@@ -61,6 +62,7 @@ func NewAPIRouter(cfg *config.Config, hub *websocket.Hub, sunny *SunnyNet.Sunny)
 		systemService:      api.NewSystemService(),
 		logsService:        api.NewLogsService(cfg),
 		exportService:      api.NewExportAPI(),
+		importService:      api.NewImportAPI(),
 		proxyService:       api.NewProxyService(sunny, cfg.Port),
 		certificateService: api.NewCertificateService(sunny),
 		versionService:     api.NewVersionAPI(),
@@ -94,6 +96,23 @@ func (r *APIRouter) registerRoutes() {
 	r.mux.HandleFunc("/api/downloads", r.consoleHandler.HandleDownloadsAPI)
 	r.mux.HandleFunc("/api/downloads/", r.consoleHandler.HandleDownloadsAPI)
 
+	// 控制台 API - 回收站
+	r.mux.HandleFunc("/api/trash", r.consoleHandler.HandleTrashAPI)
+	r.mux.HandleFunc("/api/trash/", r.consoleHandler.HandleTrashAPI)
+
+	// 控制台 API - 标签和合集
+	r.mux.HandleFunc("/api/tags", r.consoleHandler.HandleTagsAPI)
+	r.mux.HandleFunc("/api/tags/", r.consoleHandler.HandleTagsAPI)
+	r.mux.HandleFunc("/api/collections", r.consoleHandler.HandleCollectionsAPI)
+	r.mux.HandleFunc("/api/collections/", r.consoleHandler.HandleCollectionsAPI)
+
+	// 控制台 API - 作者
+	r.mux.HandleFunc("/api/authors", r.consoleHandler.HandleAuthorsAPI)
+	r.mux.HandleFunc("/api/authors/", r.consoleHandler.HandleAuthorsAPI)
+
+	// 控制台 API - 视频互动数据
+	r.mux.HandleFunc("/api/videos/", r.consoleHandler.HandleVideosAPI)
+
 	// 控制台 API - 队列管理
 	r.mux.HandleFunc("/api/queue", r.consoleHandler.HandleQueueAPI)
 	r.mux.HandleFunc("/api/queue/", r.consoleHandler.HandleQueueAPI)
@@ -101,10 +120,14 @@ func (r *APIRouter) registerRoutes() {
 	// Console API - Settings
 	// 设置管理
 	r.mux.HandleFunc("/api/settings", r.consoleHandler.HandleSettingsAPI)
+	r.mux.HandleFunc("/api/settings/filename-preview", r.consoleHandler.HandleFilenamePreview)
 
 	// 健康检查
 	r.mux.HandleFunc("/api/health", r.consoleHandler.HandleHealth)
 
+	// 控制台 API - 数据库备份和恢复
+	r.mux.HandleFunc("/api/db/", r.consoleHandler.HandleDatabaseAPI)
+
 	// 统计信息
 	r.mux.HandleFunc("/api/stats", r.consoleHandler.HandleStatsAPI)
 	r.mux.HandleFunc("/api/stats/", r.consoleHandler.HandleStatsAPI)
@@ -121,6 +144,10 @@ func (r *APIRouter) registerRoutes() {
 	r.mux.HandleFunc("/api/export/browse", r.exportService.HandleExportBrowseHistory)
 	r.mux.HandleFunc("/api/export/downloads", r.exportService.HandleExportDownloadRecords)
 
+	// 控制台 API - 导入功能
+	r.mux.HandleFunc("/api/import/browse", r.importService.HandleImportBrowseHistory)
+	r.mux.HandleFunc("/api/import/downloads", r.importService.HandleImportDownloadRecords)
+
 	// 控制台 API - 视频相关
 	r.mux.HandleFunc("/api/video/stream", r.consoleHandler.HandleVideoStream)
 	r.mux.HandleFunc("/api/video/play", r.consoleHandler.HandleVideoPlay)
@@ -133,11 +160,23 @@ func (r *APIRouter) registerRoutes() {
 	r.mux.HandleFunc("/api/v1/browse/", r.consoleHandler.HandleBrowseAPI)
 	r.mux.HandleFunc("/api/v1/downloads", r.consoleHandler.HandleDownloadsAPI)
 	r.mux.HandleFunc("/api/v1/downloads/", r.consoleHandler.HandleDownloadsAPI)
+	r.mux.HandleFunc("/api/v1/trash", r.consoleHandler.HandleTrashAPI)
+	r.mux.HandleFunc("/api/v1/trash/", r.consoleHandler.HandleTrashAPI)
+	r.mux.HandleFunc("/api/v1/tags", r.consoleHandler.HandleTagsAPI)
+	r.mux.HandleFunc("/api/v1/tags/", r.consoleHandler.HandleTagsAPI)
+	r.mux.HandleFunc("/api/v1/collections", r.consoleHandler.HandleCollectionsAPI)
+	r.mux.HandleFunc("/api/v1/collections/", r.consoleHandler.HandleCollectionsAPI)
+	r.mux.HandleFunc("/api/v1/authors", r.consoleHandler.HandleAuthorsAPI)
+	r.mux.HandleFunc("/api/v1/authors/", r.consoleHandler.HandleAuthorsAPI)
+	r.mux.HandleFunc("/api/v1/videos/", r.consoleHandler.HandleVideosAPI)
 	r.mux.HandleFunc("/api/v1/queue", r.consoleHandler.HandleQueueAPI)
 	r.mux.HandleFunc("/api/v1/queue/", r.consoleHandler.HandleQueueAPI)
 	r.mux.HandleFunc("/api/v1/settings", r.consoleHandler.HandleSettingsAPI)
 	r.mux.HandleFunc("/api/v1/stats", r.consoleHandler.HandleStatsAPI)
 	r.mux.HandleFunc("/api/v1/stats/", r.consoleHandler.HandleStatsAPI)
+	r.mux.HandleFunc("/api/v1/db/", r.consoleHandler.HandleDatabaseAPI)
+	r.mux.HandleFunc("/api/v1/import/browse", r.importService.HandleImportBrowseHistory)
+	r.mux.HandleFunc("/api/v1/import/downloads", r.importService.HandleImportDownloadRecords)
 }
 
 // Handler 返回带中间件的 HTTP Handler
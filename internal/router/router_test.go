@@ -204,3 +204,27 @@ func TestVideoPlayRoute_UsesPlayHandler(t *testing.T) {
 		t.Fatalf("expected play handler error message, got: %v", msg)
 	}
 }
+
+func TestImportRoutes_Validation(t *testing.T) {
+	router := newTestRouter()
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+		want   int
+	}{
+		{http.MethodGet, "/api/v1/import/browse", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/api/v1/import/downloads?strategy=replace", "[]", http.StatusBadRequest},
+		{http.MethodPost, "/api/import/browse?format=xml", "[]", http.StatusBadRequest},
+		{http.MethodPost, "/api/import/downloads", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.want, w.Code)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"wx_channel/internal/database"
+)
+
+// ErrAuthorNotFound 作者不存在
+var ErrAuthorNotFound = errors.New("author not found")
+
+// AuthorService 记录视频作者及其昵称历史，并提供按作者汇总的浏览和下载数据
+type AuthorService struct {
+	repo *database.AuthorRepository
+}
+
+// NewAuthorService 创建一个新的 AuthorService
+func NewAuthorService() *AuthorService {
+	return &AuthorService{
+		repo: database.NewAuthorRepository(),
+	}
+}
+
+// Observe 记录在浏览或下载时看到的作者，作者 ID 为空时忽略
+func (s *AuthorService) Observe(id, nickname, avatarURL string, seenAt time.Time) error {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return nil
+	}
+	return s.repo.Observe(id, strings.TrimSpace(nickname), strings.TrimSpace(avatarURL), seenAt)
+}
+
+// List 获取分页的作者汇总
+func (s *AuthorService) List(params *database.FilterParams) (*database.PagedResult[database.AuthorStats], error) {
+	return s.repo.List(params)
+}
+
+// Get 获取作者汇总及昵称历史
+func (s *AuthorService) Get(id string) (*database.AuthorStats, error) {
+	stats, err := s.repo.GetStats(id)
+	if err != nil {
+		return nil, err
+	}
+	if stats == nil {
+		return nil, ErrAuthorNotFound
+	}
+
+	nicknames, err := s.repo.GetNicknames(id)
+	if err != nil {
+		return nil, err
+	}
+	stats.Nicknames = nicknames
+	return stats, nil
+}
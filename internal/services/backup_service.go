@@ -0,0 +1,357 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"wx_channel/internal/database"
+	"wx_channel/internal/utils"
+)
+
+// 数据库备份保存在数据库所在目录下的 backups 目录，文件名为 records_<时间>.db
+const (
+	backupDirName    = "backups"
+	backupFilePrefix = "records_"
+	backupFileExt    = ".db"
+)
+
+// backupCheckInterval 检查是否需要自动备份的间隔
+const backupCheckInterval = 10 * time.Minute
+
+// integrityCacheTTL 健康检查中数据库完整性检查结果的缓存时间
+const integrityCacheTTL = 5 * time.Minute
+
+// 数据库备份错误
+var (
+	ErrBackupNotFound     = errors.New("backup not found")
+	ErrInvalidBackup      = errors.New("invalid database backup")
+	ErrIncompatibleBackup = errors.New("backup schema has migrations this version does not support")
+)
+
+// BackupInfo 数据库备份文件信息
+type BackupInfo struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// RestoreResult 恢复数据库的结果
+type RestoreResult struct {
+	Restored      BackupInfo `json:"restored"`
+	SafetyBackup  BackupInfo `json:"safetyBackup"`  // 恢复前自动创建的当前数据库备份
+	SchemaVersion int        `json:"schemaVersion"` // 恢复并迁移后的架构版本
+}
+
+// DatabaseHealth 数据库健康状态
+type DatabaseHealth struct {
+	SchemaVersion int        `json:"schemaVersion"`
+	Integrity     string     `json:"integrity"`          // PRAGMA integrity_check 的结果：ok 或 corrupt
+	Problems      []string   `json:"problems,omitempty"` // integrity_check 发现的问题
+	CheckedAt     time.Time  `json:"checkedAt"`
+	LastBackupAt  *time.Time `json:"lastBackupAt,omitempty"`
+}
+
+// OK 数据库是否通过完整性检查
+func (h *DatabaseHealth) OK() bool {
+	return h.Integrity == "ok"
+}
+
+// BackupService 在应用运行时备份和恢复数据库，按设置定期备份并只保留最近的几个备份
+type BackupService struct {
+	settings *database.SettingsRepository
+
+	opMu sync.Mutex // 串行执行备份和恢复
+
+	healthMu sync.Mutex
+	health   *DatabaseHealth
+
+	mu       sync.Mutex
+	running  bool
+	stopChan chan struct{}
+}
+
+// NewBackupService 创建一个新的 BackupService
+func NewBackupService() *BackupService {
+	return &BackupService{
+		settings: database.NewSettingsRepository(),
+	}
+}
+
+// loadSettings 加载设置，失败时使用默认设置
+func (s *BackupService) loadSettings() *database.Settings {
+	settings, err := s.settings.Load()
+	if err != nil {
+		return database.DefaultSettings()
+	}
+	return settings
+}
+
+// BackupDir 返回备份目录
+func (s *BackupService) BackupDir() string {
+	return filepath.Join(filepath.Dir(database.Path()), backupDirName)
+}
+
+// Create 备份数据库，并删除超出保留数量的最早的备份
+func (s *BackupService) Create() (*BackupInfo, error) {
+	s.opMu.Lock()
+	defer s.opMu.Unlock()
+
+	info, err := s.create()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.rotate(s.loadSettings().BackupKeep); err != nil {
+		utils.Warn("[Backup] Failed to remove old backups: %v", err)
+	}
+	return info, nil
+}
+
+// create 将数据库快照写入新的备份文件，同一秒内多次备份时追加序号
+func (s *BackupService) create() (*BackupInfo, error) {
+	if database.Path() == "" {
+		return nil, fmt.Errorf("database is not initialized")
+	}
+
+	base := backupFilePrefix + time.Now().Format("20060102_150405")
+	name := base + backupFileExt
+	for i := 1; ; i++ {
+		if _, err := os.Stat(filepath.Join(s.BackupDir(), name)); os.IsNotExist(err) {
+			break
+		}
+		name = fmt.Sprintf("%s_%d%s", base, i, backupFileExt)
+	}
+
+	path := filepath.Join(s.BackupDir(), name)
+	if err := database.Backup(path); err != nil {
+		return nil, err
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat backup: %w", err)
+	}
+
+	utils.Info("[Backup] Saved database backup %s", name)
+	return &BackupInfo{Name: name, Size: stat.Size(), CreatedAt: stat.ModTime()}, nil
+}
+
+// List 返回备份目录中的所有备份，最新的在前
+func (s *BackupService) List() ([]BackupInfo, error) {
+	entries, err := os.ReadDir(s.BackupDir())
+	if os.IsNotExist(err) {
+		return []BackupInfo{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	backups := []BackupInfo{}
+	for _, entry := range entries {
+		if entry.IsDir() || !isBackupName(entry.Name()) {
+			continue
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, BackupInfo{Name: entry.Name(), Size: stat.Size(), CreatedAt: stat.ModTime()})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].CreatedAt.Equal(backups[j].CreatedAt) {
+			return backups[i].CreatedAt.After(backups[j].CreatedAt)
+		}
+		return backups[i].Name > backups[j].Name
+	})
+	return backups, nil
+}
+
+// rotate 只保留最新的 keep 个备份
+func (s *BackupService) rotate(keep int) error {
+	if keep < 1 {
+		keep = 1
+	}
+	backups, err := s.List()
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups[min(keep, len(backups)):] {
+		if err := os.Remove(filepath.Join(s.BackupDir(), backup.Name)); err != nil {
+			return fmt.Errorf("failed to remove backup %s: %w", backup.Name, err)
+		}
+		utils.Info("[Backup] Removed old backup %s", backup.Name)
+	}
+	return nil
+}
+
+// isBackupName 检查文件名是否为备份文件，防止访问备份目录以外的文件
+func isBackupName(name string) bool {
+	return name == filepath.Base(name) && strings.HasPrefix(name, backupFilePrefix) && strings.HasSuffix(name, backupFileExt)
+}
+
+// Restore 用备份替换当前数据库的内容
+// 备份需要通过完整性检查，架构版本不能高于当前数据库；恢复前会先备份当前数据库
+func (s *BackupService) Restore(name string) (*RestoreResult, error) {
+	s.opMu.Lock()
+	defer s.opMu.Unlock()
+
+	if !isBackupName(name) {
+		return nil, ErrBackupNotFound
+	}
+	path := filepath.Join(s.BackupDir(), name)
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, ErrBackupNotFound
+	}
+
+	applied, problems, err := database.InspectBackup(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%w: integrity check failed: %s", ErrInvalidBackup, problems[0])
+	}
+	if len(applied) == 0 {
+		return nil, fmt.Errorf("%w: no schema version", ErrInvalidBackup)
+	}
+	current, err := database.AppliedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	// 备份中有当前数据库未执行的迁移（来自更新的版本，或当前构建不支持，例如未启用 FTS5）时无法安全使用；
+	// 备份缺少的迁移在恢复后补上
+	if unknown := unappliedMigrations(applied, current); len(unknown) > 0 {
+		return nil, fmt.Errorf("%w: backup has migrations %v that this build has not applied", ErrIncompatibleBackup, unknown)
+	}
+
+	// 不在此处删除旧备份，避免删除正在恢复的备份
+	safety, err := s.create()
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up current database before restore: %w", err)
+	}
+	if err := database.RestoreFrom(path); err != nil {
+		return nil, err
+	}
+
+	s.healthMu.Lock()
+	s.health = nil
+	s.healthMu.Unlock()
+
+	restored, err := database.GetSchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	utils.Info("[Backup] Restored database from %s (schema version %d)", name, restored)
+	return &RestoreResult{
+		Restored:      BackupInfo{Name: name, Size: stat.Size(), CreatedAt: stat.ModTime()},
+		SafetyBackup:  *safety,
+		SchemaVersion: restored,
+	}, nil
+}
+
+// unappliedMigrations 返回 backup 中有而 current 中没有的迁移版本（按版本排序）
+func unappliedMigrations(backup, current map[int]bool) []int {
+	var versions []int
+	for version := range backup {
+		if !current[version] {
+			versions = append(versions, version)
+		}
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// Health 返回数据库的架构版本和完整性检查结果，refresh 为 false 时使用 5 分钟内的缓存结果
+func (s *BackupService) Health(refresh bool) (*DatabaseHealth, error) {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+
+	if refresh || s.health == nil || time.Since(s.health.CheckedAt) > integrityCacheTTL {
+		version, err := database.GetSchemaVersion()
+		if err != nil {
+			return nil, err
+		}
+		problems, err := database.IntegrityCheck()
+		if err != nil {
+			return nil, err
+		}
+
+		health := &DatabaseHealth{SchemaVersion: version, Integrity: "ok", CheckedAt: time.Now()}
+		if len(problems) > 0 {
+			health.Integrity = "corrupt"
+			health.Problems = problems
+		}
+		s.health = health
+	}
+
+	health := *s.health
+	if backups, err := s.List(); err == nil && len(backups) > 0 {
+		health.LastBackupAt = &backups[0].CreatedAt
+	}
+	return &health, nil
+}
+
+// Start 启动定期备份的后台任务
+func (s *BackupService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopChan = make(chan struct{})
+	s.mu.Unlock()
+
+	go s.loop()
+}
+
+// Stop 停止后台任务
+func (s *BackupService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.running {
+		return
+	}
+	s.running = false
+	close(s.stopChan)
+}
+
+// loop 定期检查距上次备份是否已超过设置的间隔，超过时备份
+func (s *BackupService) loop() {
+	ticker := time.NewTicker(backupCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.backupIfDue(); err != nil {
+			utils.Warn("[Backup] Scheduled backup failed: %v", err)
+		}
+
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// backupIfDue 自动备份间隔为 0 时不备份
+func (s *BackupService) backupIfDue() error {
+	hours := s.loadSettings().BackupIntervalHours
+	if hours <= 0 {
+		return nil
+	}
+
+	backups, err := s.List()
+	if err != nil {
+		return err
+	}
+	if len(backups) > 0 && time.Since(backups[0].CreatedAt) < time.Duration(hours)*time.Hour {
+		return nil
+	}
+	_, err = s.Create()
+	return err
+}
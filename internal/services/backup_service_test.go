@@ -0,0 +1,60 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"wx_channel/internal/database"
+)
+
+func TestBackupService_RestoreChecksMigrations(t *testing.T) {
+	dir := t.TempDir()
+	if err := database.Initialize(&database.Config{DBPath: filepath.Join(dir, "records.db")}); err != nil {
+		t.Fatalf("failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	service := NewBackupService()
+	backup, err := service.Create()
+	if err != nil {
+		t.Fatalf("Create error: %v", err)
+	}
+	result, err := service.Restore(backup.Name)
+	if err != nil {
+		t.Fatalf("Restore error: %v", err)
+	}
+	if current, _ := database.GetSchemaVersion(); result.SchemaVersion != current {
+		t.Fatalf("expected schema version %d, got %d", current, result.SchemaVersion)
+	}
+
+	// 备份中有当前构建未执行的迁移时拒绝恢复，即使最大版本不高于当前数据库
+	incompatible, err := service.Create()
+	if err != nil {
+		t.Fatalf("Create error: %v", err)
+	}
+	conn, err := sql.Open("sqlite3", filepath.Join(service.BackupDir(), incompatible.Name))
+	if err != nil {
+		t.Fatalf("failed to open backup: %v", err)
+	}
+	_, err = conn.Exec("DELETE FROM schema_migrations WHERE version = (SELECT MAX(version) FROM schema_migrations); INSERT INTO schema_migrations (version) VALUES (-1)")
+	conn.Close()
+	if err != nil {
+		t.Fatalf("failed to modify backup: %v", err)
+	}
+	if _, err := service.Restore(incompatible.Name); !errors.Is(err, ErrIncompatibleBackup) {
+		t.Fatalf("expected ErrIncompatibleBackup, got %v", err)
+	}
+}
+
+func TestUnappliedMigrations(t *testing.T) {
+	current := map[int]bool{1: true, 2: true, 3: true, 5: true}
+	if got := unappliedMigrations(map[int]bool{1: true, 2: true}, current); len(got) != 0 {
+		t.Fatalf("expected older backup to be compatible, got %v", got)
+	}
+	got := unappliedMigrations(map[int]bool{1: true, 4: true, 6: true}, current)
+	if len(got) != 2 || got[0] != 4 || got[1] != 6 {
+		t.Fatalf("expected [4 6], got %v", got)
+	}
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"wx_channel/internal/database"
+
+	"golang.org/x/time/rate"
+)
+
+// bandwidthBurst 令牌桶容量，同时也是单次读取的最大字节数
+const bandwidthBurst = 64 * 1024
+
+// BandwidthLimiter 为所有下载路径提供全局和单任务带宽限制
+// 限速值单位为字节/秒，0 表示不限速；修改后对进行中的下载立即生效
+type BandwidthLimiter struct {
+	mu               sync.RWMutex
+	global           *rate.Limiter
+	globalLimit      int64
+	defaultTaskLimit int64
+	tasks            map[string]*taskLimiter
+}
+
+// taskLimiter 单个任务的限速器
+type taskLimiter struct {
+	limiter  *rate.Limiter
+	override int64 // 任务自身的限速，0 表示使用默认单任务限速
+	refs     int
+}
+
+var (
+	bandwidthLimiter     *BandwidthLimiter
+	bandwidthLimiterOnce sync.Once
+)
+
+// GetBandwidthLimiter 返回全局带宽限制器
+func GetBandwidthLimiter() *BandwidthLimiter {
+	bandwidthLimiterOnce.Do(func() {
+		bandwidthLimiter = NewBandwidthLimiter()
+	})
+	return bandwidthLimiter
+}
+
+// NewBandwidthLimiter 创建一个不限速的 BandwidthLimiter
+func NewBandwidthLimiter() *BandwidthLimiter {
+	return &BandwidthLimiter{
+		global: rate.NewLimiter(rate.Inf, bandwidthBurst),
+		tasks:  make(map[string]*taskLimiter),
+	}
+}
+
+// toLimit 将字节/秒转换为 rate.Limit
+func toLimit(bytesPerSecond int64) rate.Limit {
+	if bytesPerSecond <= 0 {
+		return rate.Inf
+	}
+	return rate.Limit(bytesPerSecond)
+}
+
+// ApplySettings 根据设置更新全局和默认单任务限速
+func (l *BandwidthLimiter) ApplySettings(settings *database.Settings) {
+	if settings == nil {
+		return
+	}
+	l.SetGlobalLimit(settings.BandwidthLimit)
+	l.SetDefaultTaskLimit(settings.TaskBandwidthLimit)
+}
+
+// SetGlobalLimit 设置全局限速
+func (l *BandwidthLimiter) SetGlobalLimit(bytesPerSecond int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.globalLimit = bytesPerSecond
+	l.global.SetLimit(toLimit(bytesPerSecond))
+}
+
+// SetDefaultTaskLimit 设置默认单任务限速，对未单独设置限速的任务生效
+func (l *BandwidthLimiter) SetDefaultTaskLimit(bytesPerSecond int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.defaultTaskLimit = bytesPerSecond
+	for _, t := range l.tasks {
+		if t.override <= 0 {
+			t.limiter.SetLimit(toLimit(bytesPerSecond))
+		}
+	}
+}
+
+// SetTaskLimit 设置单个任务的限速，0 表示使用默认单任务限速
+func (l *BandwidthLimiter) SetTaskLimit(taskID string, bytesPerSecond int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	t, ok := l.tasks[taskID]
+	if !ok {
+		// 任务尚未开始下载，限速值由 Acquire 时传入
+		return
+	}
+	t.override = bytesPerSecond
+	t.limiter.SetLimit(l.effectiveTaskLimit(t))
+}
+
+// Limits 返回当前的全局和默认单任务限速
+func (l *BandwidthLimiter) Limits() (global int64, perTask int64) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.globalLimit, l.defaultTaskLimit
+}
+
+// Enabled 检查是否配置了任何限速
+func (l *BandwidthLimiter) Enabled() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.globalLimit > 0 || l.defaultTaskLimit > 0 {
+		return true
+	}
+	for _, t := range l.tasks {
+		if t.override > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Acquire 注册任务的限速器，返回的释放函数必须在下载结束后调用
+// 同一任务的多个连接共享同一个任务限速器
+func (l *BandwidthLimiter) Acquire(taskID string, bytesPerSecond int64) func() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	t, ok := l.tasks[taskID]
+	if !ok {
+		t = &taskLimiter{limiter: rate.NewLimiter(rate.Inf, bandwidthBurst)}
+		l.tasks[taskID] = t
+	}
+	t.refs++
+	if bytesPerSecond > 0 {
+		t.override = bytesPerSecond
+	}
+	t.limiter.SetLimit(l.effectiveTaskLimit(t))
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			t.refs--
+			if t.refs <= 0 {
+				delete(l.tasks, taskID)
+			}
+		})
+	}
+}
+
+// effectiveTaskLimit 计算任务实际使用的限速（调用方需持有锁）
+func (l *BandwidthLimiter) effectiveTaskLimit(t *taskLimiter) rate.Limit {
+	if t.override > 0 {
+		return toLimit(t.override)
+	}
+	return toLimit(l.defaultTaskLimit)
+}
+
+// Reader 返回受全局和任务限速约束的 Reader
+// 任务需先通过 Acquire 注册，未注册时只受全局限速约束
+func (l *BandwidthLimiter) Reader(ctx context.Context, taskID string, r io.Reader) io.Reader {
+	l.mu.RLock()
+	var task *rate.Limiter
+	if t, ok := l.tasks[taskID]; ok {
+		task = t.limiter
+	}
+	l.mu.RUnlock()
+
+	return &limitedReader{ctx: ctx, reader: r, global: l.global, task: task}
+}
+
+// limitedReader 按令牌桶限速读取数据
+type limitedReader struct {
+	ctx    context.Context
+	reader io.Reader
+	global *rate.Limiter
+	task   *rate.Limiter
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > bandwidthBurst {
+		p = p[:bandwidthBurst]
+	}
+
+	n, err := r.reader.Read(p)
+	if n <= 0 {
+		return n, err
+	}
+
+	if r.task != nil {
+		if waitErr := r.task.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	if waitErr := r.global.WaitN(r.ctx, n); waitErr != nil {
+		return n, waitErr
+	}
+
+	return n, err
+}
@@ -2,16 +2,22 @@ package services
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"wx_channel/internal/config"
 	"wx_channel/internal/database"
 	"wx_channel/internal/utils"
+	"wx_channel/pkg/mp4"
 )
 
 // ChunkedDownloader 处理支持分片的大文件下载
@@ -28,6 +34,24 @@ type ChunkedDownloader struct {
 	cancel        context.CancelFunc
 	maxConcurrent int
 	maxRetries    int
+
+	// onFinish 在下载结束（完成或失败）后调用，用于通知调度器释放槽位
+	onFinish func(itemID string)
+
+	// resolver 在下载地址过期时重新获取地址和密钥
+	resolver *URLResolver
+
+	// sidecar 在下载完成后写入元数据文件和封面
+	sidecar *SidecarService
+
+	// embedder 在下载完成后将元数据写入 MP4 文件
+	embedder *MetadataEmbedder
+
+	// dedupe 按内容哈希处理重复下载
+	dedupe *DedupeService
+
+	// diskGuard 在开始下载前检查磁盘空间和下载库容量
+	diskGuard *DiskGuard
 }
 
 // DownloadState 跟踪活动下载的状态
@@ -75,6 +99,10 @@ func NewChunkedDownloader(queueService *QueueService) *ChunkedDownloader {
 		cancel:        cancel,
 		maxConcurrent: settings.ConcurrentLimit,
 		maxRetries:    settings.MaxRetries,
+		sidecar:       NewSidecarService(),
+		embedder:      NewMetadataEmbedder(),
+		dedupe:        NewDedupeService(),
+		diskGuard:     NewDiskGuard(),
 	}
 }
 
@@ -115,11 +143,27 @@ func (d *ChunkedDownloader) StartDownload(item *database.QueueItem) error {
 func (d *ChunkedDownloader) downloadItem(ctx context.Context, state *DownloadState) {
 	item := state.QueueItem
 
-	// 标记为正在下载
-	if err := d.queueService.StartDownload(item.ID); err != nil {
+	// 标记为正在下载；调度后到协程启动前项目可能已被暂停或移除，此时放弃下载，不覆盖已写入的状态
+	started, err := d.queueService.StartDownload(item.ID)
+	if err != nil {
 		d.handleError(item.ID, fmt.Errorf("failed to start download: %w", err))
 		return
 	}
+	if !started || ctx.Err() != nil {
+		d.abandon(state)
+		return
+	}
+
+	// 未知大小的项目需要先探测文件大小才能分片
+	if item.TotalSize <= 0 || item.ChunksTotal <= 0 {
+		if err := d.resolveTotalSize(ctx, item); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			d.handleError(item.ID, fmt.Errorf("failed to resolve file size: %w", err))
+			return
+		}
+	}
 
 	// 准备下载目录
 	downloadPath, err := d.prepareDownloadPath(item)
@@ -128,8 +172,14 @@ func (d *ChunkedDownloader) downloadItem(ctx context.Context, state *DownloadSta
 		return
 	}
 
+	// 空间不足时暂停而不是写出截断的文件
+	if err := d.diskGuard.CheckDownload(filepath.Dir(downloadPath), item.TotalSize-item.DownloadedSize); err != nil {
+		d.pauseForDiskSpace(item.ID, err)
+		return
+	}
+
 	// 下载分片
-	err = d.downloadChunks(ctx, state, downloadPath)
+	contentHash, err := d.downloadChunks(ctx, state, downloadPath)
 	if err != nil {
 		// 检查是否被取消/暂停
 		if ctx.Err() != nil {
@@ -141,16 +191,57 @@ func (d *ChunkedDownloader) downloadItem(ctx context.Context, state *DownloadSta
 
 	// 验证文件完整性
 	if err := d.verifyFileIntegrity(downloadPath, item.TotalSize); err != nil {
+		d.discardDownload(item, downloadPath)
 		d.handleError(item.ID, fmt.Errorf("file integrity check failed: %w", err))
 		return
 	}
 
+	// 后处理：写入 MP4 元数据、faststart，失败不影响下载结果
+	// 在去重之前处理新文件，内容哈希和去重比较的都是最终保存的内容，也不会改写去重复用的已有文件
+	// 改写文件时在写入临时文件的同时计算新的内容哈希，不需要再读一遍文件
+	if item.EmbedMetadata {
+		if hash, ok := d.embedMetadata(ctx, item, downloadPath); ok {
+			contentHash = hash
+		}
+	}
+	if cfg := config.Get(); cfg != nil && cfg.FastStart {
+		if hash, ok := d.fastStart(item, downloadPath); ok {
+			contentHash = hash
+		}
+	}
+
+	// 按去重策略处理与已有下载内容相同的文件
+	dedup := d.dedupe.Apply(downloadPath, contentHash)
+
 	// 标记为完成
-	if err := d.queueService.CompleteDownload(item.ID); err != nil {
+	if err := d.queueService.CompleteDownloadWithFile(item.ID, dedup.FilePath, contentHash); err != nil {
 		d.handleError(item.ID, fmt.Errorf("failed to mark download as completed: %w", err))
 		return
 	}
 
+	// 写入元数据文件（跳过重复下载时新文件已删除）
+	if dedup.Action != DedupeActionSkipped {
+		meta := &SidecarMetadata{
+			VideoID:      item.VideoID,
+			Title:        item.Title,
+			Author:       item.Author,
+			Duration:     item.Duration,
+			Resolution:   item.Resolution,
+			FileSize:     item.TotalSize,
+			CoverURL:     item.CoverURL,
+			PlayCount:    ParseCount(item.BatchStats.PlayCount),
+			LikeCount:    ParseCount(item.BatchStats.LikeCount),
+			CommentCount: ParseCount(item.BatchStats.CommentCount),
+			FavCount:     ParseCount(item.BatchStats.FavCount),
+			ForwardCount: ParseCount(item.BatchStats.ForwardCount),
+			CreateTime:   item.BatchStats.CreateTime,
+			IPRegion:     item.BatchStats.IPRegion,
+			PageSource:   item.PageSource,
+		}
+		meta.MergeBrowseRecord()
+		d.sidecar.WriteAsync(downloadPath, meta)
+	}
+
 	// 发送完成更新
 	d.sendProgress(ProgressUpdate{
 		QueueID:         item.ID,
@@ -166,131 +257,544 @@ func (d *ChunkedDownloader) downloadItem(ctx context.Context, state *DownloadSta
 	d.mu.Lock()
 	delete(d.activeItems, item.ID)
 	d.mu.Unlock()
+
+	d.notifyFinish(item.ID)
+}
+
+// abandon 放弃尚未开始的下载，只移除仍属于该协程的活动项目
+func (d *ChunkedDownloader) abandon(state *DownloadState) {
+	d.mu.Lock()
+	if d.activeItems[state.QueueItem.ID] == state {
+		delete(d.activeItems, state.QueueItem.ID)
+	}
+	d.mu.Unlock()
+	state.CancelFunc()
+
+	d.notifyFinish(state.QueueItem.ID)
+}
+
+// SetOnFinish 设置下载结束回调
+func (d *ChunkedDownloader) SetOnFinish(fn func(itemID string)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.onFinish = fn
+}
+
+// SetURLResolver 设置下载地址过期时使用的地址解析器
+func (d *ChunkedDownloader) SetURLResolver(resolver *URLResolver) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.resolver = resolver
+}
+
+// notifyFinish 调用下载结束回调
+func (d *ChunkedDownloader) notifyFinish(itemID string) {
+	d.mu.RLock()
+	fn := d.onFinish
+	d.mu.RUnlock()
+	if fn != nil {
+		fn(itemID)
+	}
+}
+
+// resolveTotalSize 通过 Range 请求探测文件大小并更新队列项目的分片信息
+// 地址过期时重新获取地址后再探测一次
+func (d *ChunkedDownloader) resolveTotalSize(ctx context.Context, item *database.QueueItem) error {
+	totalSize, err := d.probeSize(ctx, item.VideoURL)
+	if errors.Is(err, errURLExpired) {
+		source, refreshErr := d.refreshVideoSource(item)
+		if refreshErr != nil {
+			return fmt.Errorf("%w; failed to refresh url: %v", err, refreshErr)
+		}
+		totalSize, err = d.probeSize(ctx, source.VideoURL)
+	}
+	if err != nil {
+		return err
+	}
+
+	current, err := d.queueService.GetByID(item.ID)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		return fmt.Errorf("queue item not found: %s", item.ID)
+	}
+
+	if current.ChunkSize <= 0 {
+		current.ChunkSize = database.DefaultSettings().ChunkSize
+	}
+	current.TotalSize = totalSize
+	current.ChunksTotal = CalculateChunkCount(totalSize, current.ChunkSize)
+	current.ChunksCompleted = 0
+	current.ChunksBitmap = ""
+	if err := d.queueService.UpdateItem(current); err != nil {
+		return err
+	}
+
+	*item = *current
+	return nil
 }
 
-// downloadChunks 下载项目的所有分片
-func (d *ChunkedDownloader) downloadChunks(ctx context.Context, state *DownloadState, downloadPath string) error {
+// probeSize 使用 bytes=0-0 请求获取文件总大小
+func (d *ChunkedDownloader) probeSize(ctx context.Context, url string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Range", "bytes=0-0")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	resp.Body.Close()
+
+	var totalSize int64
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		// Content-Range: bytes 0-0/12345
+		contentRange := resp.Header.Get("Content-Range")
+		if idx := strings.LastIndex(contentRange, "/"); idx >= 0 {
+			totalSize, _ = strconv.ParseInt(contentRange[idx+1:], 10, 64)
+		}
+	case resp.StatusCode == http.StatusOK:
+		totalSize = resp.ContentLength
+	case isExpiredStatus(resp.StatusCode):
+		return 0, fmt.Errorf("%w: status code %d", errURLExpired, resp.StatusCode)
+	default:
+		return 0, &HTTPStatusError{StatusCode: resp.StatusCode}
+	}
+	if totalSize <= 0 {
+		return 0, fmt.Errorf("server did not report content length")
+	}
+	return totalSize, nil
+}
+
+// isExpiredStatus 检查状态码是否表示签名地址过期或无权访问
+func isExpiredStatus(statusCode int) bool {
+	return statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden || statusCode == http.StatusGone
+}
+
+// refreshVideoSource 通过前端重新获取过期的下载地址，并写回队列项目
+func (d *ChunkedDownloader) refreshVideoSource(item *database.QueueItem) (*ResolvedSource, error) {
+	d.mu.RLock()
+	resolver := d.resolver
+	d.mu.RUnlock()
+	if resolver == nil {
+		return nil, fmt.Errorf("url resolver not configured")
+	}
+
+	source, err := resolver.Resolve(item)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.queueService.UpdateVideoSource(item.ID, source.VideoURL, source.DecryptKey); err != nil {
+		return nil, err
+	}
+
+	utils.Info("[ChunkedDownloader] Refreshed expired video url for %s", item.Title)
+	return source, nil
+}
+
+// downloadChunks 使用多个并发连接下载项目中尚未完成的分片
+// 每个工作协程通过定位写入器写入各自的范围，分片完成状态持久化到队列中，
+// 恢复时只重新下载缺失的分片；返回下载内容的 SHA-256
+func (d *ChunkedDownloader) downloadChunks(ctx context.Context, state *DownloadState, downloadPath string) (string, error) {
 	item := state.QueueItem
 	chunkSize := item.ChunkSize
 	totalChunks := item.ChunksTotal
 
-	// 打开或创建文件
-	file, err := os.OpenFile(downloadPath, os.O_CREATE|os.O_WRONLY, 0644)
+	// 打开或创建文件（不截断，保留已完成的分片；哈希计算需要读回乱序完成的分片）
+	file, err := os.OpenFile(downloadPath, os.O_CREATE|os.O_RDWR, 0644)
 	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+		return "", fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
-	// 从上一个完成的分片恢复
-	startChunk := state.CurrentChunk
-	startOffset := int64(startChunk) * chunkSize
+	// 加密视频在写入前流式解密
+	src, err := newChunkSource(item)
+	if err != nil {
+		return "", err
+	}
 
-	// 跳转到正确位置
-	if _, err := file.Seek(startOffset, 0); err != nil {
-		return fmt.Errorf("failed to seek to position: %w", err)
+	completed := decodeChunkBitmap(item.ChunksBitmap, item.ChunksCompleted, totalChunks)
+	hasher := newChunkHasher(file)
+
+	// 收集缺失的分片
+	pending := make(chan int, totalChunks)
+	var downloadedSize int64
+	chunksDone := 0
+	for chunkIndex := 0; chunkIndex < totalChunks; chunkIndex++ {
+		if completed[chunkIndex] {
+			start, end := chunkRange(chunkIndex, chunkSize, item.TotalSize)
+			downloadedSize += end - start + 1
+			chunksDone++
+			// 上次运行已完成的分片从文件读回计入哈希
+			hasher.complete(start, end+1)
+			continue
+		}
+		pending <- chunkIndex
 	}
+	close(pending)
+	state.CurrentChunk = chunksDone
 
-	downloadedSize := startOffset
-	lastSpeedCalcTime := time.Now()
-	lastDownloadedSize := downloadedSize
+	// 同一项目的所有连接共享任务限速器
+	release := GetBandwidthLimiter().Acquire(item.ID, item.SpeedLimit)
+	defer release()
 
-	for chunkIndex := startChunk; chunkIndex < totalChunks; chunkIndex++ {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
+	workers := d.connectionCount()
+	if workers > len(pending) {
+		workers = len(pending)
+	}
 
-		// 检查是否暂停
-		d.mu.RLock()
-		isPaused := state.IsPaused
-		d.mu.RUnlock()
-		if isPaused {
-			return nil
-		}
+	workerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-		// 计算分片范围
-		chunkStart := int64(chunkIndex) * chunkSize
-		chunkEnd := chunkStart + chunkSize - 1
-		if chunkEnd >= item.TotalSize {
-			chunkEnd = item.TotalSize - 1
+	var (
+		wg                 sync.WaitGroup
+		progressMu         sync.Mutex
+		errOnce            sync.Once
+		firstErr           error
+		lastSpeedCalcTime  = time.Now()
+		lastDownloadedSize = downloadedSize
+	)
+
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for chunkIndex := range pending {
+				if workerCtx.Err() != nil {
+					return
+				}
+
+				// 检查是否暂停
+				d.mu.RLock()
+				isPaused := state.IsPaused
+				d.mu.RUnlock()
+				if isPaused {
+					fail(context.Canceled)
+					return
+				}
+
+				chunkStart, chunkEnd := chunkRange(chunkIndex, chunkSize, item.TotalSize)
+
+				// 带重试下载分片并直接写入文件对应位置
+				written, err := d.downloadChunkWithRetry(workerCtx, src, chunkStart, chunkEnd, file, hasher)
+				if err != nil {
+					fail(fmt.Errorf("failed to download chunk %d: %w", chunkIndex, err))
+					return
+				}
+
+				progressMu.Lock()
+				completed[chunkIndex] = true
+				downloadedSize += written
+				chunksDone++
+				state.CurrentChunk = chunksDone
+
+				// 计算速度
+				now := time.Now()
+				elapsed := now.Sub(lastSpeedCalcTime).Seconds()
+				if elapsed >= 1.0 {
+					state.BytesPerSecond = int64(float64(downloadedSize-lastDownloadedSize) / elapsed)
+					lastSpeedCalcTime = now
+					lastDownloadedSize = downloadedSize
+				}
+
+				// 持久化分片完成状态
+				if err := d.queueService.UpdateChunkProgress(item.ID, encodeChunkBitmap(completed), downloadedSize, chunksDone, state.BytesPerSecond); err != nil {
+					utils.Warn("[ChunkedDownloader] Failed to update progress: %v", err)
+				}
+
+				update := ProgressUpdate{
+					QueueID:         item.ID,
+					DownloadedSize:  downloadedSize,
+					TotalSize:       item.TotalSize,
+					ChunksCompleted: chunksDone,
+					ChunksTotal:     totalChunks,
+					Speed:           state.BytesPerSecond,
+					Status:          database.QueueStatusDownloading,
+				}
+				progressMu.Unlock()
+
+				// 发送进度更新
+				d.sendProgress(update)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return "", firstErr
+	}
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+
+	contentHash, err := hasher.Sum(item.TotalSize)
+	if err != nil {
+		// 读回乱序完成的分片失败时回退为完整读取文件
+		utils.Warn("[ChunkedDownloader] Rehashing %s: %v", downloadPath, err)
+		if contentHash, err = HashFile(downloadPath); err != nil {
+			utils.Warn("[ChunkedDownloader] Failed to hash %s: %v", downloadPath, err)
+			return "", nil
 		}
+	}
+	return contentHash, nil
+}
+
+// encryptedPrefixLen 加密视频的加密区域大小（128KB）
+const encryptedPrefixLen = 131072
+
+// maxURLRefreshes 单次下载中重新获取过期地址的最大次数
+const maxURLRefreshes = 2
+
+// chunkDecryptor 分片的解密方式：优先使用 ISAAC64 密钥，没有密钥时使用旧版批量下载脚本提供的解密前缀
+type chunkDecryptor struct {
+	hasKey bool
+	key    uint64
+	prefix []byte
+}
+
+// wrap 为从文件偏移 start 开始的分片数据包装解密读取器，分片不在加密区域时原样返回
+func (c chunkDecryptor) wrap(body io.Reader, start int64) io.Reader {
+	switch {
+	case c.hasKey && start < encryptedPrefixLen:
+		return utils.NewDecryptReader(body, c.key, uint64(start), encryptedPrefixLen)
+	case !c.hasKey && start < int64(len(c.prefix)):
+		return utils.NewPrefixDecryptReader(body, c.prefix, uint64(start))
+	}
+	return body
+}
+
+// chunkSource 描述分片的下载来源，地址过期后由工作协程共享刷新
+type chunkSource struct {
+	item *database.QueueItem
+
+	mu         sync.Mutex
+	url        string
+	decryptor  chunkDecryptor
+	generation int // 每次刷新地址后递增
+	refreshes  int // 已刷新次数
+}
 
-		// 带重试下载分片
-		chunkBytes, err := d.downloadChunkWithRetry(ctx, item.VideoURL, chunkStart, chunkEnd)
+// newChunkSource 根据队列项目创建分片下载来源，解析解密密钥或旧版解密前缀
+func newChunkSource(item *database.QueueItem) (*chunkSource, error) {
+	src := &chunkSource{item: item, url: item.VideoURL}
+	if item.DecryptorPrefix != "" && item.PrefixLen > 0 {
+		prefix, err := base64.StdEncoding.DecodeString(item.DecryptorPrefix)
 		if err != nil {
-			return fmt.Errorf("failed to download chunk %d: %w", chunkIndex, err)
+			return nil, fmt.Errorf("failed to decode decryptor prefix: %w", err)
 		}
-
-		// 将分片写入文件
-		if _, err := file.Write(chunkBytes); err != nil {
-			return fmt.Errorf("failed to write chunk %d: %w", chunkIndex, err)
+		if len(prefix) > item.PrefixLen {
+			prefix = prefix[:item.PrefixLen]
 		}
+		src.decryptor.prefix = prefix
+	}
+	if err := src.setDecryptKey(item.DecryptKey); err != nil {
+		return nil, err
+	}
+	return src, nil
+}
 
-		downloadedSize += int64(len(chunkBytes))
-		state.CurrentChunk = chunkIndex + 1
-
-		// 计算速度
-		now := time.Now()
-		elapsed := now.Sub(lastSpeedCalcTime).Seconds()
-		if elapsed >= 1.0 {
-			bytesDownloaded := downloadedSize - lastDownloadedSize
-			state.BytesPerSecond = int64(float64(bytesDownloaded) / elapsed)
-			lastSpeedCalcTime = now
-			lastDownloadedSize = downloadedSize
-		}
+// setDecryptKey 解析并设置解密密钥（调用方需持有锁或独占访问），密钥为空时回退到解密前缀
+func (s *chunkSource) setDecryptKey(decryptKey string) error {
+	if decryptKey == "" {
+		s.decryptor.hasKey = false
+		s.decryptor.key = 0
+		return nil
+	}
+	key, err := utils.ParseKey(decryptKey)
+	if err != nil {
+		return fmt.Errorf("failed to parse decrypt key: %w", err)
+	}
+	s.decryptor.hasKey = true
+	s.decryptor.key = key
+	return nil
+}
 
-		// 更新数据库中的进度
-		if err := d.queueService.UpdateProgress(item.ID, downloadedSize, state.CurrentChunk, state.BytesPerSecond); err != nil {
-			utils.Warn("[ChunkedDownloader] Failed to update progress: %v", err)
-		}
+// snapshot 返回当前的下载地址、解密方式和地址版本
+func (s *chunkSource) snapshot() (string, chunkDecryptor, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.url, s.decryptor, s.generation
+}
 
-		// 发送进度更新
-		d.sendProgress(ProgressUpdate{
-			QueueID:         item.ID,
-			DownloadedSize:  downloadedSize,
-			TotalSize:       item.TotalSize,
-			ChunksCompleted: state.CurrentChunk,
-			ChunksTotal:     totalChunks,
-			Speed:           state.BytesPerSecond,
-			Status:          database.QueueStatusDownloading,
-		})
+// refreshSource 刷新过期的分片下载地址
+// 多个工作协程同时遇到过期时只刷新一次，其余协程直接使用新地址重试
+func (d *ChunkedDownloader) refreshSource(src *chunkSource, generation int) error {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	if src.generation != generation {
+		return nil
 	}
+	if src.refreshes >= maxURLRefreshes {
+		return fmt.Errorf("url still rejected after %d refreshes", src.refreshes)
+	}
+	src.refreshes++
 
+	source, err := d.refreshVideoSource(src.item)
+	if err != nil {
+		return err
+	}
+
+	// 新地址的加密前缀使用新密钥，已写入的分片是解密后的明文，与密钥无关，无需重新下载
+	if err := src.setDecryptKey(source.DecryptKey); err != nil {
+		return err
+	}
+	src.url = source.VideoURL
+	src.generation++
 	return nil
 }
 
-// downloadChunkWithRetry 带重试逻辑下载单个分片
-func (d *ChunkedDownloader) downloadChunkWithRetry(ctx context.Context, url string, start, end int64) ([]byte, error) {
-	var lastErr error
+// connectionCount 返回单个文件的并发连接数
+func (d *ChunkedDownloader) connectionCount() int {
+	if cfg := config.Get(); cfg != nil && cfg.DownloadConnections > 0 {
+		return cfg.DownloadConnections
+	}
+	return 1
+}
+
+// chunkRange 计算分片的字节范围（包含两端）
+func chunkRange(chunkIndex int, chunkSize, totalSize int64) (int64, int64) {
+	start := int64(chunkIndex) * chunkSize
+	end := start + chunkSize - 1
+	if end >= totalSize {
+		end = totalSize - 1
+	}
+	return start, end
+}
+
+// decodeChunkBitmap 解析分片完成位图
+// 位图为空时兼容旧的顺序下载进度：前 chunksCompleted 个分片视为已完成
+func decodeChunkBitmap(bitmap string, chunksCompleted, totalChunks int) []bool {
+	completed := make([]bool, totalChunks)
+	if bitmap == "" {
+		for i := 0; i < chunksCompleted && i < totalChunks; i++ {
+			completed[i] = true
+		}
+		return completed
+	}
+	for i := 0; i < len(bitmap) && i < totalChunks; i++ {
+		completed[i] = bitmap[i] == '1'
+	}
+	return completed
+}
 
-	for attempt := 0; attempt <= d.maxRetries; attempt++ {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
+// encodeChunkBitmap 将分片完成状态编码为位图字符串
+func encodeChunkBitmap(completed []bool) string {
+	buf := make([]byte, len(completed))
+	for i, done := range completed {
+		if done {
+			buf[i] = '1'
+		} else {
+			buf[i] = '0'
 		}
+	}
+	return string(buf)
+}
 
-		if attempt > 0 {
-			// 重试前等待（指数退避）
-			waitTime := time.Duration(attempt) * time.Second
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(waitTime):
+// downloadChunkWithRetry 按共享的重试策略下载单个分片，并写入文件的对应位置
+// hasher 不为 nil 时同时计算内容哈希；每次失败都记录到队列项目的重试历史
+func (d *ChunkedDownloader) downloadChunkWithRetry(ctx context.Context, src *chunkSource, start, end int64, file io.WriterAt, hasher *chunkHasher) (int64, error) {
+	var written int64
+	policy := NewRetryPolicyWithRetries(d.maxRetries)
+
+	err := policy.Do(ctx, func(int) error {
+		for {
+			// 每次尝试都从分片起始位置重新写入，单次尝试超时后按可重试错误处理
+			url, decryptor, generation := src.snapshot()
+			w, finish := hasher.begin(start, io.NewOffsetWriter(file, start))
+			attemptCtx, cancel := context.WithTimeout(ctx, d.attemptTimeout())
+			n, err := d.downloadChunkTo(attemptCtx, src.item.ID, url, decryptor, start, end, w)
+			cancel()
+			finish(err == nil, end+1)
+			if err == nil {
+				written = n
+				return nil
+			}
+			if ctx.Err() != nil || !errors.Is(err, errURLExpired) {
+				return err
 			}
-		}
 
-		data, err := d.downloadChunk(ctx, url, start, end)
-		if err == nil {
-			return data, nil
+			// 签名地址过期时重新获取地址后立即重试，不计入重试次数；刷新后仍被拒绝则不再重试
+			if refreshErr := d.refreshSource(src, generation); refreshErr != nil {
+				return Permanent(fmt.Errorf("%w; failed to refresh url: %v", err, refreshErr))
+			}
 		}
+	}, func(attempt database.RetryAttempt) {
+		attempt.Error = fmt.Sprintf("bytes %d-%d: %s", start, end, attempt.Error)
+		utils.Warn("[ChunkedDownloader] Chunk download failed (attempt %d/%d, %s): %s",
+			attempt.Attempt, policy.MaxRetries()+1, attempt.Class, attempt.Error)
+		d.recordRetryAttempt(src.item.ID, attempt)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return written, nil
+}
 
-		lastErr = err
-		utils.Warn("[ChunkedDownloader] Chunk download failed (attempt %d/%d): %v", attempt+1, d.maxRetries+1, err)
+// recordRetryAttempt 将失败的尝试写入队列项目的重试历史，供控制台查看
+func (d *ChunkedDownloader) recordRetryAttempt(itemID string, attempt database.RetryAttempt) {
+	if err := d.queueService.RecordRetryAttempt(itemID, attempt); err != nil {
+		utils.Warn("[ChunkedDownloader] Failed to record retry attempt: %v", err)
 	}
+}
 
-	return nil, fmt.Errorf("chunk download failed after %d retries: %w", d.maxRetries+1, lastErr)
+// attemptTimeout 返回单次分片下载尝试的超时时间（配置的下载超时，未配置时为 10 分钟）
+func (d *ChunkedDownloader) attemptTimeout() time.Duration {
+	if cfg := config.Get(); cfg != nil && cfg.DownloadTimeout > 0 {
+		return cfg.DownloadTimeout
+	}
+	return 10 * time.Minute
+}
+
+// downloadChunkTo 使用 HTTP Range 请求下载单个分片并流式写入 w（受带宽限制约束）
+// 分片与加密区域重叠时按分片起始偏移解密
+func (d *ChunkedDownloader) downloadChunkTo(ctx context.Context, taskID, url string, decryptor chunkDecryptor, start, end int64, w io.Writer) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// 并发写入要求服务器遵守 Range，只有从 0 开始的分片可以接受 200
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+	case resp.StatusCode == http.StatusOK && start == 0:
+	case resp.StatusCode == http.StatusOK:
+		return 0, fmt.Errorf("server ignored range request for bytes %d-%d", start, end)
+	case isExpiredStatus(resp.StatusCode):
+		return 0, fmt.Errorf("%w: status code %d", errURLExpired, resp.StatusCode)
+	default:
+		return 0, &HTTPStatusError{StatusCode: resp.StatusCode}
+	}
+
+	expected := end - start + 1
+	body := decryptor.wrap(GetBandwidthLimiter().Reader(ctx, taskID, resp.Body), start)
+	written, err := io.CopyN(w, body, expected)
+	if err != nil {
+		return written, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return written, nil
 }
 
 // downloadChunk 使用 HTTP Range 请求下载单个分片
@@ -311,7 +815,7 @@ func (d *ChunkedDownloader) downloadChunk(ctx context.Context, url string, start
 
 	// 接受 200 (完整内容) 和 206 (部分内容)
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode}
 	}
 
 	data, err := io.ReadAll(resp.Body)
@@ -322,6 +826,15 @@ func (d *ChunkedDownloader) downloadChunk(ctx context.Context, url string, start
 	return data, nil
 }
 
+// DownloadRoot 返回队列下载的根目录
+func (d *ChunkedDownloader) DownloadRoot() string {
+	baseDir, err := utils.GetBaseDir()
+	if err != nil {
+		return d.downloadDir
+	}
+	return filepath.Join(baseDir, d.downloadDir)
+}
+
 // prepareDownloadPath 准备项目的下载路径
 func (d *ChunkedDownloader) prepareDownloadPath(item *database.QueueItem) (string, error) {
 	baseDir, err := utils.GetBaseDir()
@@ -329,6 +842,12 @@ func (d *ChunkedDownloader) prepareDownloadPath(item *database.QueueItem) (strin
 		return "", err
 	}
 
+	// 配置了文件名模板时按模板生成路径
+	naming := NewNamingService()
+	if naming.Enabled() {
+		return naming.ResolvePath(filepath.Join(baseDir, d.downloadDir), FilenameFieldsFromQueueItem(item))
+	}
+
 	// 创建作者文件夹
 	authorFolder := utils.CleanFolderName(item.Author)
 	downloadDir := filepath.Join(baseDir, d.downloadDir, authorFolder)
@@ -337,14 +856,65 @@ func (d *ChunkedDownloader) prepareDownloadPath(item *database.QueueItem) (strin
 		return "", fmt.Errorf("failed to create download directory: %w", err)
 	}
 
-	// 清理文件名
+	// 清理文件名，批量下载沿用 标题_视频ID 命名，避免同名视频互相覆盖
 	filename := utils.CleanFilename(item.Title)
+	if item.BatchID != "" {
+		filename = utils.GenerateVideoFilename(item.Title, item.VideoID)
+	}
 	filename = utils.EnsureExtension(filename, ".mp4")
 
 	return filepath.Join(downloadDir, filename), nil
 }
 
-// verifyFileIntegrity 验证下载的文件大小是否与预期大小匹配
+// embedMetadata 将标题、作者和封面写入 MP4 文件，并同步写入后的文件大小
+// 返回修改后文件的内容哈希和文件是否被修改
+func (d *ChunkedDownloader) embedMetadata(ctx context.Context, item *database.QueueItem, downloadPath string) (string, bool) {
+	contentHash, err := d.embedder.Embed(ctx, downloadPath, item)
+	if err != nil {
+		utils.Warn("[ChunkedDownloader] Failed to embed metadata for %s: %v", item.Title, err)
+		return "", false
+	}
+	d.syncFileSize(item, downloadPath)
+	return contentHash, true
+}
+
+// fastStart 将 moov 移到文件开头，便于控制台边下边播（只调整位置，文件大小不变）
+// 返回修改后文件的内容哈希和文件是否被修改
+func (d *ChunkedDownloader) fastStart(item *database.QueueItem, downloadPath string) (string, bool) {
+	changed, contentHash, err := mp4.FastStartHash(downloadPath)
+	if err != nil {
+		utils.Warn("[ChunkedDownloader] Failed to apply faststart for %s: %v", item.Title, err)
+		return "", false
+	}
+	if changed {
+		utils.Info("[ChunkedDownloader] Moved moov to the front of %s", downloadPath)
+	}
+	return contentHash, changed
+}
+
+// syncFileSize 后处理修改文件后同步队列中的文件大小
+func (d *ChunkedDownloader) syncFileSize(item *database.QueueItem, downloadPath string) {
+	fileInfo, err := os.Stat(downloadPath)
+	if err != nil || fileInfo.Size() == item.TotalSize {
+		return
+	}
+
+	// 写入元数据后文件大小会变化，更新记录以免后续完整性校验误报
+	current, err := d.queueService.GetByID(item.ID)
+	if err != nil || current == nil {
+		return
+	}
+	current.TotalSize = fileInfo.Size()
+	current.DownloadedSize = fileInfo.Size()
+	if err := d.queueService.UpdateItem(current); err != nil {
+		utils.Warn("[ChunkedDownloader] Failed to update file size for %s: %v", item.Title, err)
+		return
+	}
+	item.TotalSize = fileInfo.Size()
+	item.DownloadedSize = fileInfo.Size()
+}
+
+// verifyFileIntegrity 验证下载的文件大小是否与预期大小匹配，并校验 MP4 结构
 func (d *ChunkedDownloader) verifyFileIntegrity(filePath string, expectedSize int64) error {
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
@@ -356,11 +926,55 @@ func (d *ChunkedDownloader) verifyFileIntegrity(filePath string, expectedSize in
 		return fmt.Errorf("file size mismatch: expected %d bytes, got %d bytes", expectedSize, actualSize)
 	}
 
-	return nil
+	// 大小一致时仍可能被截断（预期大小来自不完整的响应）或前 128KB 未解密
+	return checkMP4Structure(filePath)
 }
 
-// handleError 处理下载错误
+// discardDownload 删除校验失败的文件并清空分片进度
+// 所有分片都已标记完成，保留进度会让重试不下载任何内容、每次都以同样的错误失败
+func (d *ChunkedDownloader) discardDownload(item *database.QueueItem, downloadPath string) {
+	if err := os.Remove(downloadPath); err != nil && !os.IsNotExist(err) {
+		utils.Warn("[ChunkedDownloader] Failed to remove corrupted file %s: %v", downloadPath, err)
+	}
+	if err := d.queueService.UpdateChunkProgress(item.ID, "", 0, 0, 0); err != nil {
+		utils.Warn("[ChunkedDownloader] Failed to reset progress for %s: %v", item.Title, err)
+	}
+}
+
+// handleError 处理下载错误，磁盘空间不足时暂停项目而不是标记为失败
 func (d *ChunkedDownloader) handleError(itemID string, err error) {
+	if IsDiskSpaceError(err) {
+		d.pauseForDiskSpace(itemID, err)
+		return
+	}
+	d.failDownload(itemID, err)
+}
+
+// pauseForDiskSpace 因磁盘空间不足暂停项目，空间恢复后由调度器自动恢复
+func (d *ChunkedDownloader) pauseForDiskSpace(itemID string, err error) {
+	utils.Warn("[ChunkedDownloader] Pausing %s: %v", itemID, err)
+
+	if pauseErr := d.queueService.PauseForDiskSpace(itemID); pauseErr != nil {
+		utils.Error("[ChunkedDownloader] Failed to pause for disk space: %v", pauseErr)
+		d.failDownload(itemID, err)
+		return
+	}
+
+	d.sendProgress(ProgressUpdate{
+		QueueID:      itemID,
+		Status:       database.QueueStatusPaused,
+		ErrorMessage: err.Error(),
+	})
+
+	d.mu.Lock()
+	delete(d.activeItems, itemID)
+	d.mu.Unlock()
+
+	d.notifyFinish(itemID)
+}
+
+// failDownload 将项目标记为失败
+func (d *ChunkedDownloader) failDownload(itemID string, err error) {
 	utils.Error("[ChunkedDownloader] Download error for %s: %v", itemID, err)
 
 	// 获取项目详细信息以进行日志记录
@@ -384,6 +998,8 @@ func (d *ChunkedDownloader) handleError(itemID string, err error) {
 	d.mu.Lock()
 	delete(d.activeItems, itemID)
 	d.mu.Unlock()
+
+	d.notifyFinish(itemID)
 }
 
 // sendProgress 发送进度更新到通道
@@ -465,6 +1081,15 @@ func (d *ChunkedDownloader) GetActiveDownloads() []string {
 	return ids
 }
 
+// IsActive 检查项目是否正在下载
+func (d *ChunkedDownloader) IsActive(itemID string) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	_, exists := d.activeItems[itemID]
+	return exists
+}
+
 // GetDownloadState 返回活动下载的状态
 func (d *ChunkedDownloader) GetDownloadState(itemID string) (*DownloadState, bool) {
 	d.mu.RLock()
@@ -600,15 +1225,17 @@ func (d *ChunkedDownloader) ResumeAllPaused() error {
 
 // FileIntegrityResult 包含文件完整性检查的结果
 type FileIntegrityResult struct {
-	IsValid      bool   `json:"isValid"`
-	ExpectedSize int64  `json:"expectedSize"`
-	ActualSize   int64  `json:"actualSize"`
-	FilePath     string `json:"filePath"`
-	ErrorMessage string `json:"errorMessage,omitempty"`
+	IsValid      bool        `json:"isValid"`
+	ExpectedSize int64       `json:"expectedSize"`
+	ActualSize   int64       `json:"actualSize"`
+	FilePath     string      `json:"filePath"`
+	ErrorMessage string      `json:"errorMessage,omitempty"`
+	Issues       []mp4.Issue `json:"issues,omitempty"`
+	RepairHint   string      `json:"repairHint,omitempty"`
 }
 
 // VerifyDownloadedFile 验证下载文件的完整性
-// 这检查实际文件大小是否与预期大小匹配
+// 这检查实际文件大小是否与预期大小匹配，并校验 MP4 结构
 func (d *ChunkedDownloader) VerifyDownloadedFile(itemID string) (*FileIntegrityResult, error) {
 	item, err := d.queueService.GetByID(itemID)
 	if err != nil {
@@ -618,52 +1245,35 @@ func (d *ChunkedDownloader) VerifyDownloadedFile(itemID string) (*FileIntegrityR
 		return nil, fmt.Errorf("queue item not found: %s", itemID)
 	}
 
-	// Get the file path
-	filePath, err := d.prepareDownloadPath(item)
-	if err != nil {
-		return &FileIntegrityResult{
-			IsValid:      false,
-			ExpectedSize: item.TotalSize,
-			ErrorMessage: fmt.Sprintf("failed to get file path: %v", err),
-		}, nil
-	}
-
-	// 检查文件是否存在
-	fileInfo, err := os.Stat(filePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return &FileIntegrityResult{
-				IsValid:      false,
-				ExpectedSize: item.TotalSize,
-				FilePath:     filePath,
-				ErrorMessage: "file does not exist",
-			}, nil
-		}
-		return &FileIntegrityResult{
-			IsValid:      false,
-			ExpectedSize: item.TotalSize,
-			FilePath:     filePath,
-			ErrorMessage: fmt.Sprintf("failed to stat file: %v", err),
-		}, nil
-	}
-
-	actualSize := fileInfo.Size()
-	isValid := actualSize == item.TotalSize
-
+	filePath := downloadedFilePath(item)
+	verified := VerifyFile(filePath, item.TotalSize)
 	result := &FileIntegrityResult{
-		IsValid:      isValid,
+		IsValid:      verified.Valid,
 		ExpectedSize: item.TotalSize,
-		ActualSize:   actualSize,
+		ActualSize:   verified.ActualSize,
 		FilePath:     filePath,
+		Issues:       verified.Issues,
+		RepairHint:   verified.RepairHint,
 	}
-
-	if !isValid {
-		result.ErrorMessage = fmt.Sprintf("file size mismatch: expected %d bytes, got %d bytes", item.TotalSize, actualSize)
+	if !verified.Valid {
+		result.ErrorMessage = verified.Issues[0].Message
 	}
 
 	return result, nil
 }
 
+// downloadedFilePath 返回已完成项目的文件路径：优先使用完成时保存的路径（文件名模板修改或去重后仍然准确），
+// 较早完成的项目按下载记录查找，都没有时按命名规则计算；只读取，不创建目录
+func downloadedFilePath(item *database.QueueItem) string {
+	if item.FilePath != "" {
+		return item.FilePath
+	}
+	if filePath, _ := existingDownloadPath(item); filePath != "" {
+		return filePath
+	}
+	return calculateDownloadFilePath(item)
+}
+
 // VerifyFileSize 是一个独立函数，用于验证文件大小是否匹配预期
 func VerifyFileSize(filePath string, expectedSize int64) error {
 	fileInfo, err := os.Stat(filePath)
@@ -703,89 +1313,40 @@ func (d *ChunkedDownloader) VerifyAllCompletedDownloads() ([]FileIntegrityResult
 	return results, nil
 }
 
-// RetryConfig 包含重试配置
-type RetryConfig struct {
-	MaxRetries    int           `json:"maxRetries"`
-	InitialDelay  time.Duration `json:"initialDelay"`
-	MaxDelay      time.Duration `json:"maxDelay"`
-	BackoffFactor float64       `json:"backoffFactor"`
-}
-
-// DefaultRetryConfig 返回默认重试配置
-func DefaultRetryConfig() *RetryConfig {
-	return &RetryConfig{
-		MaxRetries:    3,
-		InitialDelay:  time.Second,
-		MaxDelay:      30 * time.Second,
-		BackoffFactor: 2.0,
-	}
-}
-
 // RetryResult 包含重试操作的结果
 type RetryResult struct {
-	Success   bool   `json:"success"`
-	Attempts  int    `json:"attempts"`
-	LastError string `json:"lastError,omitempty"`
-	TotalTime int64  `json:"totalTimeMs"`
+	Success   bool                    `json:"success"`
+	Attempts  int                     `json:"attempts"`
+	LastError string                  `json:"lastError,omitempty"`
+	TotalTime int64                   `json:"totalTimeMs"`
+	History   []database.RetryAttempt `json:"history"` // 每次失败的尝试
 }
 
-// downloadChunkWithRetryTracked 带重试下载分片并返回详细结果
+// downloadChunkWithRetryTracked 按共享的重试策略下载分片并返回详细结果
 func (d *ChunkedDownloader) downloadChunkWithRetryTracked(ctx context.Context, url string, start, end int64, config *RetryConfig) ([]byte, *RetryResult) {
-	if config == nil {
-		config = DefaultRetryConfig()
-	}
-
-	result := &RetryResult{
-		Success:  false,
-		Attempts: 0,
-	}
-
+	policy := NewRetryPolicy(config)
+	result := &RetryResult{History: []database.RetryAttempt{}}
 	startTime := time.Now()
-	var lastErr error
-	delay := config.InitialDelay
-
-	for attempt := 0; attempt <= config.MaxRetries; attempt++ {
-		select {
-		case <-ctx.Done():
-			result.LastError = ctx.Err().Error()
-			result.TotalTime = time.Since(startTime).Milliseconds()
-			return nil, result
-		default:
-		}
-
-		result.Attempts = attempt + 1
 
-		if attempt > 0 {
-			// Wait before retry with exponential backoff
-			select {
-			case <-ctx.Done():
-				result.LastError = ctx.Err().Error()
-				result.TotalTime = time.Since(startTime).Milliseconds()
-				return nil, result
-			case <-time.After(delay):
-			}
-
-			// 计算下一次退避延迟
-			delay = time.Duration(float64(delay) * config.BackoffFactor)
-			if delay > config.MaxDelay {
-				delay = config.MaxDelay
-			}
-		}
-
-		data, err := d.downloadChunk(ctx, url, start, end)
-		if err == nil {
-			result.Success = true
-			result.TotalTime = time.Since(startTime).Milliseconds()
-			return data, result
-		}
-
-		lastErr = err
-		utils.Warn("[ChunkedDownloader] Chunk download failed (attempt %d/%d): %v", attempt+1, config.MaxRetries+1, err)
-	}
+	var data []byte
+	err := policy.Do(ctx, func(attempt int) error {
+		result.Attempts = attempt
+		var err error
+		data, err = d.downloadChunk(ctx, url, start, end)
+		return err
+	}, func(attempt database.RetryAttempt) {
+		utils.Warn("[ChunkedDownloader] Chunk download failed (attempt %d/%d, %s): %s",
+			attempt.Attempt, policy.MaxRetries()+1, attempt.Class, attempt.Error)
+		result.History = append(result.History, attempt)
+	})
 
-	result.LastError = lastErr.Error()
 	result.TotalTime = time.Since(startTime).Milliseconds()
-	return nil, result
+	if err != nil {
+		result.LastError = err.Error()
+		return nil, result
+	}
+	result.Success = true
+	return data, result
 }
 
 // RetryFailedDownload 从头开始或上一个检查点重试失败的下载
@@ -2,7 +2,8 @@ package services
 
 import (
 	"fmt"
-	"os"
+	"path/filepath"
+	"sort"
 	"time"
 
 	"wx_channel/internal/database"
@@ -16,6 +17,27 @@ type CleanupResult struct {
 	SpaceFreed             int64     `json:"spaceFreed"`
 	CleanupTime            time.Time `json:"cleanupTime"`
 	Errors                 []string  `json:"errors,omitempty"`
+
+	DryRun  bool                 `json:"dryRun"`            // 试运行时只统计将被删除的内容，不做任何修改
+	Removed []RetentionCandidate `json:"removed,omitempty"` // 保留策略删除（试运行时为将要删除）的下载
+}
+
+// 保留策略的删除原因
+const (
+	RetentionReasonPerAuthor = "per_author" // 超出每个作者保留的视频数
+	RetentionReasonQuota     = "quota"      // 下载库超出大小上限
+)
+
+// RetentionCandidate 被保留策略选中删除的下载
+type RetentionCandidate struct {
+	RecordID     string    `json:"recordId"`
+	Title        string    `json:"title"`
+	Author       string    `json:"author"`
+	FilePath     string    `json:"filePath"`
+	FileSize     int64     `json:"fileSize"`
+	DownloadTime time.Time `json:"downloadTime"`
+	LastPlayedAt time.Time `json:"lastPlayedAt"`
+	Reason       string    `json:"reason"`
 }
 
 // CleanupService 处理数据清理操作
@@ -24,6 +46,7 @@ type CleanupService struct {
 	browseRepo   *database.BrowseHistoryRepository
 	downloadRepo *database.DownloadRecordRepository
 	settingsRepo *database.SettingsRepository
+	trash        *TrashService
 }
 
 // NewCleanupService 创建一个新的 CleanupService
@@ -32,6 +55,7 @@ func NewCleanupService() *CleanupService {
 		browseRepo:   database.NewBrowseHistoryRepository(),
 		downloadRepo: database.NewDownloadRecordRepository(),
 		settingsRepo: database.NewSettingsRepository(),
+		trash:        NewTrashService(),
 	}
 }
 
@@ -55,7 +79,7 @@ func (s *CleanupService) ClearBrowseHistory() (*CleanupResult, error) {
 	}, nil
 }
 
-// ClearDownloadRecords 清空所有下载记录（可选删除文件）
+// ClearDownloadRecords 清空所有下载记录（可选将文件移入回收站）
 // Requirements: 5.3 - 清空下载记录（可选择删除文件）
 func (s *CleanupService) ClearDownloadRecords(deleteFiles bool) (*CleanupResult, error) {
 	result := &CleanupResult{
@@ -69,20 +93,10 @@ func (s *CleanupService) ClearDownloadRecords(deleteFiles bool) (*CleanupResult,
 		return nil, fmt.Errorf("failed to get download records: %w", err)
 	}
 
-	// 如果请求则删除文件
+	// 如果请求则将文件移入回收站
 	if deleteFiles {
 		for _, record := range records {
-			if record.FilePath != "" {
-				fileInfo, err := os.Stat(record.FilePath)
-				if err == nil {
-					result.SpaceFreed += fileInfo.Size()
-					if err := os.Remove(record.FilePath); err != nil {
-						result.Errors = append(result.Errors, fmt.Sprintf("failed to delete file %s: %v", record.FilePath, err))
-					} else {
-						result.FilesDeleted++
-					}
-				}
-			}
+			s.moveToTrash(&record, result)
 		}
 	}
 
@@ -117,7 +131,7 @@ func (s *CleanupService) DeleteDownloadRecordsBefore(date time.Time, deleteFiles
 		Errors:      []string{},
 	}
 
-	// 如果需要删除文件，我们需要先获取记录
+	// 如果需要删除文件，我们需要先获取记录，文件移入回收站
 	if deleteFiles {
 		// 获取所有记录并按日期过滤
 		records, err := s.downloadRepo.GetAll()
@@ -126,16 +140,8 @@ func (s *CleanupService) DeleteDownloadRecordsBefore(date time.Time, deleteFiles
 		}
 
 		for _, record := range records {
-			if record.DownloadTime.Before(date) && record.FilePath != "" {
-				fileInfo, err := os.Stat(record.FilePath)
-				if err == nil {
-					result.SpaceFreed += fileInfo.Size()
-					if err := os.Remove(record.FilePath); err != nil {
-						result.Errors = append(result.Errors, fmt.Sprintf("failed to delete file %s: %v", record.FilePath, err))
-					} else {
-						result.FilesDeleted++
-					}
-				}
+			if record.DownloadTime.Before(date) {
+				s.moveToTrash(&record, result)
 			}
 		}
 	}
@@ -150,35 +156,179 @@ func (s *CleanupService) DeleteDownloadRecordsBefore(date time.Time, deleteFiles
 	return result, nil
 }
 
-// RunAutoCleanup 根据设置运行自动清理
+// RunAutoCleanup 根据设置运行自动清理：按天数清理浏览记录，再按保留策略删除下载
+// dryRun 为 true 时只返回将被删除的内容和可释放的空间
 // Requirements: 11.5 - 基于设置的自动清理
-func (s *CleanupService) RunAutoCleanup() (*CleanupResult, error) {
+func (s *CleanupService) RunAutoCleanup(dryRun bool) (*CleanupResult, error) {
 	// 加载设置
 	settings, err := s.settingsRepo.Load()
 	if err != nil {
 		return nil, fmt.Errorf("failed to load settings: %w", err)
 	}
 
-	// 检查自动清理是否启用
-	if !settings.AutoCleanupEnabled {
-		return &CleanupResult{
-			CleanupTime: time.Now(),
-		}, nil
+	result := &CleanupResult{
+		CleanupTime: time.Now(),
+		Errors:      []string{},
+		DryRun:      dryRun,
+		Removed:     []RetentionCandidate{},
 	}
 
-	// 计算截止日期
-	cutoffDate := time.Now().AddDate(0, 0, -settings.AutoCleanupDays)
-
 	// 删除旧的浏览记录
-	browseResult, err := s.DeleteBrowseRecordsBefore(cutoffDate)
+	if settings.AutoCleanupEnabled {
+		cutoffDate := time.Now().AddDate(0, 0, -settings.AutoCleanupDays)
+		if dryRun {
+			count, err := s.browseRepo.CountBefore(cutoffDate)
+			if err != nil {
+				return nil, fmt.Errorf("failed to count browse records: %w", err)
+			}
+			result.BrowseRecordsDeleted = count
+		} else {
+			browseResult, err := s.DeleteBrowseRecordsBefore(cutoffDate)
+			if err != nil {
+				return nil, fmt.Errorf("failed to cleanup browse records: %w", err)
+			}
+			result.BrowseRecordsDeleted = browseResult.BrowseRecordsDeleted
+		}
+	}
+
+	if settings.RetentionMaxSize <= 0 && settings.RetentionKeepPerAuthor <= 0 {
+		return result, nil
+	}
+
+	records, err := s.downloadRepo.GetAll()
 	if err != nil {
-		return nil, fmt.Errorf("failed to cleanup browse records: %w", err)
+		return nil, fmt.Errorf("failed to get download records: %w", err)
 	}
+	result.Removed = planRetention(records, settings)
 
-	return &CleanupResult{
-		BrowseRecordsDeleted: browseResult.BrowseRecordsDeleted,
-		CleanupTime:          time.Now(),
-	}, nil
+	if dryRun {
+		for _, candidate := range result.Removed {
+			result.DownloadRecordsDeleted++
+			result.FilesDeleted++
+			result.SpaceFreed += candidate.FileSize
+		}
+		return result, nil
+	}
+
+	s.applyRetention(records, result)
+	return result, nil
+}
+
+// planRetention 按保留策略选出要删除的已完成下载，加星的下载既不会被删除，也不计入每个作者的保留数
+func planRetention(records []database.DownloadRecord, settings *database.Settings) []RetentionCandidate {
+	var librarySize int64
+	var candidates []*database.DownloadRecord
+	for i := range records {
+		record := &records[i]
+		if record.Status != database.DownloadStatusCompleted {
+			continue
+		}
+		librarySize += record.FileSize
+		if !record.Starred && record.FilePath != "" {
+			candidates = append(candidates, record)
+		}
+	}
+
+	removed := []RetentionCandidate{}
+	selected := make(map[string]bool)
+	remove := func(record *database.DownloadRecord, reason string) {
+		selected[record.ID] = true
+		librarySize -= record.FileSize
+		removed = append(removed, RetentionCandidate{
+			RecordID:     record.ID,
+			Title:        record.Title,
+			Author:       record.Author,
+			FilePath:     record.FilePath,
+			FileSize:     record.FileSize,
+			DownloadTime: record.DownloadTime,
+			LastPlayedAt: record.LastPlayedAt,
+			Reason:       reason,
+		})
+	}
+
+	// 每个作者只保留最新的 N 个视频
+	if settings.RetentionKeepPerAuthor > 0 {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].DownloadTime.After(candidates[j].DownloadTime)
+		})
+		kept := make(map[string]int)
+		for _, record := range candidates {
+			if kept[record.Author] < settings.RetentionKeepPerAuthor {
+				kept[record.Author]++
+				continue
+			}
+			remove(record, RetentionReasonPerAuthor)
+		}
+	}
+
+	// 下载库超出大小上限时按淘汰顺序删除，直到不超过上限
+	if settings.RetentionMaxSize > 0 && librarySize > settings.RetentionMaxSize {
+		sort.SliceStable(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			if settings.RetentionEvictBy != database.RetentionEvictOldest && !a.LastPlayedAt.Equal(b.LastPlayedAt) {
+				return a.LastPlayedAt.Before(b.LastPlayedAt)
+			}
+			return a.DownloadTime.Before(b.DownloadTime)
+		})
+		for _, record := range candidates {
+			if librarySize <= settings.RetentionMaxSize {
+				break
+			}
+			if !selected[record.ID] {
+				remove(record, RetentionReasonQuota)
+			}
+		}
+	}
+
+	return removed
+}
+
+// applyRetention 将保留策略选中的下载移入回收站并删除记录，仍被其他记录引用的文件（去重后共用的文件）会保留
+func (s *CleanupService) applyRetention(records []database.DownloadRecord, result *CleanupResult) {
+	selected := make(map[string]bool, len(result.Removed))
+	for _, candidate := range result.Removed {
+		selected[candidate.RecordID] = true
+	}
+	byID := make(map[string]*database.DownloadRecord, len(records))
+	referenced := make(map[string]bool)
+	for i := range records {
+		byID[records[i].ID] = &records[i]
+		if !selected[records[i].ID] && records[i].FilePath != "" {
+			referenced[filepath.Clean(records[i].FilePath)] = true
+		}
+	}
+
+	for _, candidate := range result.Removed {
+		if err := s.downloadRepo.Delete(candidate.RecordID); err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			continue
+		}
+		result.DownloadRecordsDeleted++
+
+		path := filepath.Clean(candidate.FilePath)
+		if referenced[path] {
+			continue
+		}
+		s.moveToTrash(byID[candidate.RecordID], result)
+		// 同一文件的多条记录只处理一次
+		referenced[path] = true
+	}
+}
+
+// moveToTrash 将下载记录的文件（及元数据和封面）移入回收站，并计入清理结果
+func (s *CleanupService) moveToTrash(record *database.DownloadRecord, result *CleanupResult) {
+	if record.FilePath == "" {
+		return
+	}
+	moved, err := s.trash.MoveToTrash(record.FilePath, record)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("failed to move file %s to trash: %v", record.FilePath, err))
+		return
+	}
+	if moved > 0 {
+		result.FilesDeleted++
+		result.SpaceFreed += moved
+	}
 }
 
 // DeleteSelectedBrowseRecords 按 ID 删除特定的浏览记录
@@ -211,17 +361,7 @@ func (s *CleanupService) DeleteSelectedDownloadRecords(ids []string, deleteFiles
 		}
 
 		for _, record := range records {
-			if record.FilePath != "" {
-				fileInfo, err := os.Stat(record.FilePath)
-				if err == nil {
-					result.SpaceFreed += fileInfo.Size()
-					if err := os.Remove(record.FilePath); err != nil {
-						result.Errors = append(result.Errors, fmt.Sprintf("failed to delete file %s: %v", record.FilePath, err))
-					} else {
-						result.FilesDeleted++
-					}
-				}
-			}
+			s.moveToTrash(&record, result)
 		}
 	}
 
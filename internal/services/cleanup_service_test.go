@@ -0,0 +1,128 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"wx_channel/internal/database"
+)
+
+func TestPlanRetention(t *testing.T) {
+	t0 := time.Date(2024, 3, 5, 10, 0, 0, 0, time.Local)
+	at := func(hours int) time.Time { return t0.Add(time.Duration(hours) * time.Hour) }
+	record := func(id, author string, downloaded int, lastPlayed time.Time) database.DownloadRecord {
+		return database.DownloadRecord{
+			ID:           id,
+			Author:       author,
+			FilePath:     "/downloads/" + id + ".mp4",
+			FileSize:     10,
+			Status:       database.DownloadStatusCompleted,
+			DownloadTime: at(downloaded),
+			LastPlayedAt: lastPlayed,
+		}
+	}
+
+	starred := record("a3", "A", 3, time.Time{})
+	starred.Starred = true
+	noFile := record("c1", "C", -1, time.Time{})
+	noFile.FilePath = ""
+	pending := record("p1", "A", 6, time.Time{})
+	pending.Status = database.DownloadStatusPending
+	pending.FileSize = 100
+
+	// 已完成的下载共 70：a1 a2 a3(加星) a4 b1 b2 c1(没有文件)，p1 未完成不计入
+	records := []database.DownloadRecord{
+		record("a1", "A", 1, at(10)),
+		record("a2", "A", 2, time.Time{}),
+		starred,
+		record("a4", "A", 4, at(5)),
+		record("b1", "B", 0, at(20)),
+		record("b2", "B", 5, time.Time{}),
+		noFile,
+		pending,
+	}
+
+	type removal struct{ id, reason string }
+	tests := []struct {
+		name     string
+		settings database.Settings
+		want     []removal
+	}{
+		{
+			name:     "disabled",
+			settings: database.Settings{},
+		},
+		{
+			// 加星的 a3 不计入保留数，否则 a2 也会被删除
+			name:     "keep per author",
+			settings: database.Settings{RetentionKeepPerAuthor: 2},
+			want:     []removal{{"a1", RetentionReasonPerAuthor}},
+		},
+		{
+			name:     "under quota",
+			settings: database.Settings{RetentionMaxSize: 70},
+		},
+		{
+			// 从未播放的优先，同样未播放时先删除较早下载的
+			name:     "quota least played",
+			settings: database.Settings{RetentionMaxSize: 40, RetentionEvictBy: database.RetentionEvictLeastPlayed},
+			want:     []removal{{"a2", RetentionReasonQuota}, {"b2", RetentionReasonQuota}, {"a4", RetentionReasonQuota}},
+		},
+		{
+			name:     "quota oldest",
+			settings: database.Settings{RetentionMaxSize: 40, RetentionEvictBy: database.RetentionEvictOldest},
+			want:     []removal{{"b1", RetentionReasonQuota}, {"a1", RetentionReasonQuota}, {"a2", RetentionReasonQuota}},
+		},
+		{
+			// 按作者删除的 a1 已经计入释放的空间，不会被大小上限再次选中
+			name:     "per author then quota",
+			settings: database.Settings{RetentionKeepPerAuthor: 2, RetentionMaxSize: 40, RetentionEvictBy: database.RetentionEvictLeastPlayed},
+			want:     []removal{{"a1", RetentionReasonPerAuthor}, {"a2", RetentionReasonQuota}, {"b2", RetentionReasonQuota}},
+		},
+		{
+			// 上限无法满足时删除所有可删除的下载，加星和没有文件的记录保留
+			name:     "quota below protected size",
+			settings: database.Settings{RetentionMaxSize: 5, RetentionEvictBy: database.RetentionEvictOldest},
+			want: []removal{
+				{"b1", RetentionReasonQuota}, {"a1", RetentionReasonQuota}, {"a2", RetentionReasonQuota},
+				{"a4", RetentionReasonQuota}, {"b2", RetentionReasonQuota},
+			},
+		},
+		{
+			name:     "keep per author with quota below protected size",
+			settings: database.Settings{RetentionKeepPerAuthor: 1, RetentionMaxSize: 5, RetentionEvictBy: database.RetentionEvictOldest},
+			want: []removal{
+				{"a2", RetentionReasonPerAuthor}, {"a1", RetentionReasonPerAuthor}, {"b1", RetentionReasonPerAuthor},
+				{"a4", RetentionReasonQuota}, {"b2", RetentionReasonQuota},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := planRetention(records, &tt.settings)
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d removals %v, got %+v", len(tt.want), tt.want, got)
+			}
+			seen := make(map[string]bool)
+			for i, candidate := range got {
+				if seen[candidate.RecordID] {
+					t.Fatalf("record %s selected twice", candidate.RecordID)
+				}
+				seen[candidate.RecordID] = true
+				if candidate.RecordID != tt.want[i].id || candidate.Reason != tt.want[i].reason {
+					t.Fatalf("expected removals %v, got %+v", tt.want, got)
+				}
+				if candidate.FilePath == "" || candidate.FileSize != 10 {
+					t.Fatalf("unexpected candidate %+v", candidate)
+				}
+			}
+			for _, id := range []string{"a3", "c1", "p1"} {
+				if seen[id] {
+					t.Fatalf("expected %s to be kept, got %+v", id, got)
+				}
+			}
+		})
+	}
+}
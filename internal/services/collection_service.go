@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"wx_channel/internal/database"
+
+	"github.com/google/uuid"
+)
+
+// 合集错误
+var (
+	ErrCollectionNotFound = errors.New("collection not found")
+	ErrCollectionExists   = errors.New("a collection with this name already exists")
+)
+
+// CollectionService 管理命名的下载记录合集
+type CollectionService struct {
+	repo         *database.CollectionRepository
+	downloadRepo *database.DownloadRecordRepository
+}
+
+// NewCollectionService 创建一个新的 CollectionService
+func NewCollectionService() *CollectionService {
+	return &CollectionService{
+		repo:         database.NewCollectionRepository(),
+		downloadRepo: database.NewDownloadRecordRepository(),
+	}
+}
+
+// List 获取所有合集
+func (s *CollectionService) List() ([]database.Collection, error) {
+	return s.repo.List()
+}
+
+// Get 根据 ID 获取合集
+func (s *CollectionService) Get(id string) (*database.Collection, error) {
+	c, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, ErrCollectionNotFound
+	}
+	return c, nil
+}
+
+// Create 创建合集
+func (s *CollectionService) Create(name, description string) (*database.Collection, error) {
+	name, err := normalizeName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.GetByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrCollectionExists
+	}
+
+	c := &database.Collection{ID: uuid.New().String(), Name: name, Description: strings.TrimSpace(description)}
+	if err := s.repo.Create(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Update 修改合集的名称和描述
+func (s *CollectionService) Update(id, name, description string) (*database.Collection, error) {
+	c, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err = normalizeName(name)
+	if err != nil {
+		return nil, err
+	}
+	if existing, err := s.repo.GetByName(name); err != nil {
+		return nil, err
+	} else if existing != nil && existing.ID != id {
+		return nil, ErrCollectionExists
+	}
+
+	c.Name = name
+	c.Description = strings.TrimSpace(description)
+	if err := s.repo.Update(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Delete 删除合集，合集中的下载记录保留
+func (s *CollectionService) Delete(id string) error {
+	if _, err := s.Get(id); err != nil {
+		return err
+	}
+	return s.repo.Delete(id)
+}
+
+// AddRecords 将下载记录加入合集，不存在的记录被忽略，返回新加入的记录数
+func (s *CollectionService) AddRecords(id string, recordIDs []string) (int64, error) {
+	if _, err := s.Get(id); err != nil {
+		return 0, err
+	}
+
+	records, err := s.downloadRepo.GetByIDs(recordIDs)
+	if err != nil {
+		return 0, err
+	}
+	existing := make([]string, len(records))
+	for i := range records {
+		existing[i] = records[i].ID
+	}
+	return s.repo.AddRecords(id, existing)
+}
+
+// RemoveRecords 从合集中移除下载记录，返回移除的记录数
+func (s *CollectionService) RemoveRecords(id string, recordIDs []string) (int64, error) {
+	if _, err := s.Get(id); err != nil {
+		return 0, err
+	}
+	return s.repo.RemoveRecords(id, recordIDs)
+}
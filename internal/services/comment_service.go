@@ -0,0 +1,118 @@
+package services
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"wx_channel/internal/database"
+)
+
+// CommentService 将采集到的评论保存到数据库，用于全文搜索
+type CommentService struct {
+	repo *database.CommentRepository
+}
+
+// NewCommentService 创建一个新的 CommentService
+func NewCommentService() *CommentService {
+	return &CommentService{
+		repo: database.NewCommentRepository(),
+	}
+}
+
+// SaveCaptured 保存前端采集的评论（含二级回复），返回保存的评论数
+// 同一条评论再次采集时更新内容和点赞数
+func (s *CommentService) SaveCaptured(comments []map[string]interface{}, videoID, videoTitle string, savedAt time.Time) (int, error) {
+	if videoID == "" {
+		return 0, fmt.Errorf("video id is required")
+	}
+
+	var records []database.Comment
+	var collect func(items []interface{}, parentID string)
+	add := func(item map[string]interface{}, parentID string) {
+		comment := parseCapturedComment(item, videoID, videoTitle, parentID, savedAt)
+		if comment.Content == "" {
+			return
+		}
+		records = append(records, comment)
+		if replies, ok := item["levelTwoComment"].([]interface{}); ok {
+			collect(replies, comment.ID)
+		}
+	}
+	collect = func(items []interface{}, parentID string) {
+		for _, raw := range items {
+			if item, ok := raw.(map[string]interface{}); ok {
+				add(item, parentID)
+			}
+		}
+	}
+	for _, item := range comments {
+		add(item, "")
+	}
+
+	if err := s.repo.SaveMany(records); err != nil {
+		return 0, err
+	}
+	return len(records), nil
+}
+
+// parseCapturedComment 将一条采集的评论转换为数据库记录
+func parseCapturedComment(item map[string]interface{}, videoID, videoTitle, parentID string, savedAt time.Time) database.Comment {
+	comment := database.Comment{
+		ID:         stringValue(item["id"]),
+		VideoID:    videoID,
+		VideoTitle: videoTitle,
+		ParentID:   parentID,
+		Nickname:   stringValue(item["nickname"]),
+		Content:    stringValue(item["content"]),
+		LikeCount:  int64Value(item["likeCount"]),
+		IPLocation: stringValue(item["ipLocation"]),
+		SavedAt:    savedAt,
+	}
+	if comment.ID == "" {
+		comment.ID = stringValue(item["commentId"])
+	}
+
+	createTime := int64Value(item["createTime"])
+	if createTime > 1e12 {
+		// 毫秒时间戳
+		comment.CreateTime = time.UnixMilli(createTime)
+	} else if createTime > 0 {
+		comment.CreateTime = time.Unix(createTime, 0)
+	}
+
+	// 没有评论 ID 时根据视频、作者、内容和时间生成稳定的 ID，避免重复保存
+	if comment.ID == "" {
+		sum := sha1.Sum([]byte(fmt.Sprintf("%s|%s|%s|%s|%d", videoID, parentID, comment.Nickname, comment.Content, createTime)))
+		comment.ID = "local-" + hex.EncodeToString(sum[:8])
+	}
+	return comment
+}
+
+// stringValue 将 JSON 值转换为字符串（数字按整数格式输出）
+func stringValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// int64Value 将 JSON 数字或数字字符串转换为 int64，无法转换时返回 0
+func int64Value(v interface{}) int64 {
+	switch val := v.(type) {
+	case float64:
+		return int64(val)
+	case string:
+		n, _ := strconv.ParseInt(val, 10, 64)
+		return n
+	}
+	return 0
+}
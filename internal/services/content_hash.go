@@ -0,0 +1,132 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+)
+
+// HashFile 计算文件内容的 SHA-256（十六进制）
+// 下载和改写文件时都在写入的同时计算哈希，只有无法经过写入流程的文件（Gopeed 引擎直接写入、
+// 原地解密、读回分片失败、校验已有文件）才回退为完整读取文件
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash file: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// chunkHasher 为并发分片下载按文件顺序计算 SHA-256
+// 位于前沿（下一个待计入位置）的分片边写边计算；先于前沿完成的分片在前沿到达时
+// 从刚写入的文件读回（通常仍在页缓存中），下载结束时不需要再完整读一遍文件
+type chunkHasher struct {
+	mu        sync.Mutex
+	file      io.ReaderAt
+	h         hash.Hash
+	next      int64           // 已计入哈希的字节数
+	pending   map[int64]int64 // 已写完但尚未计入的分片：起始偏移 -> 结束偏移（不含）
+	streaming bool            // 前沿分片正在边写边计算
+	err       error
+}
+
+// newChunkHasher 创建分片哈希计算器，file 用于读回乱序完成的分片
+func newChunkHasher(file io.ReaderAt) *chunkHasher {
+	return &chunkHasher{
+		file:    file,
+		h:       sha256.New(),
+		pending: make(map[int64]int64),
+	}
+}
+
+// begin 开始写入 [start, ...) 的分片；分片位于前沿时返回同时计算哈希的 writer
+// 写入结束后必须调用 finish，ok 表示分片完整写入，end 为分片结束偏移（不含）
+func (c *chunkHasher) begin(start int64, w io.Writer) (io.Writer, func(ok bool, end int64)) {
+	if c == nil {
+		return w, func(bool, int64) {}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil || c.streaming || start != c.next {
+		return w, func(ok bool, end int64) {
+			if ok {
+				c.complete(start, end)
+			}
+		}
+	}
+
+	// 失败重试时需要回滚到分片开始前的状态
+	snapshot, err := c.h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		c.err = err
+		return w, func(bool, int64) {}
+	}
+	c.streaming = true
+
+	return io.MultiWriter(w, c.h), func(ok bool, end int64) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.streaming = false
+		if !ok {
+			if err := c.h.(encoding.BinaryUnmarshaler).UnmarshalBinary(snapshot); err != nil {
+				c.err = err
+			}
+			return
+		}
+		c.next = end
+		c.advance()
+	}
+}
+
+// complete 记录乱序完成的分片，并尝试推进前沿
+func (c *chunkHasher) complete(start, end int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pending[start] = end
+	c.advance()
+}
+
+// advance 将前沿处已完成的分片从文件读回并计入哈希（调用方需持有锁）
+func (c *chunkHasher) advance() {
+	for c.err == nil && !c.streaming {
+		end, ok := c.pending[c.next]
+		if !ok {
+			return
+		}
+		delete(c.pending, c.next)
+		if _, err := io.Copy(c.h, io.NewSectionReader(c.file, c.next, end-c.next)); err != nil {
+			c.err = fmt.Errorf("failed to read back chunk: %w", err)
+			return
+		}
+		c.next = end
+	}
+}
+
+// Sum 返回完整文件的 SHA-256（十六进制），尚未覆盖 total 字节时返回错误
+func (c *chunkHasher) Sum(total int64) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return "", c.err
+	}
+	if c.next != total {
+		return "", fmt.Errorf("content hash covers %d of %d bytes", c.next, total)
+	}
+	return hex.EncodeToString(c.h.Sum(nil)), nil
+}
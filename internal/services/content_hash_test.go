@@ -0,0 +1,126 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// chunkWrite 一次分片写入：写入 [start, end)，ok 为 false 时只写入一半后失败
+type chunkWrite struct {
+	start, end int64
+	ok         bool
+}
+
+func TestChunkHasher(t *testing.T) {
+	content := make([]byte, 1000)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+	sum := sha256.Sum256(content)
+	want := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name   string
+		writes []chunkWrite
+	}{
+		{name: "in order", writes: []chunkWrite{{0, 300, true}, {300, 600, true}, {600, 1000, true}}},
+		{name: "out of order", writes: []chunkWrite{{600, 1000, true}, {300, 600, true}, {0, 300, true}}},
+		{name: "failed attempt retried", writes: []chunkWrite{{0, 300, false}, {0, 300, true}, {300, 1000, true}}},
+		{name: "failed out of order chunk", writes: []chunkWrite{{300, 600, false}, {0, 300, true}, {300, 600, true}, {600, 1000, true}}},
+		{name: "single chunk", writes: []chunkWrite{{0, 1000, true}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := os.Create(filepath.Join(t.TempDir(), "video.mp4"))
+			if err != nil {
+				t.Fatalf("failed to create file: %v", err)
+			}
+			defer file.Close()
+
+			hasher := newChunkHasher(file)
+			for _, cw := range tt.writes {
+				w, finish := hasher.begin(cw.start, io.NewOffsetWriter(file, cw.start))
+				end := cw.end
+				if !cw.ok {
+					end = cw.start + (cw.end-cw.start)/2
+				}
+				if _, err := w.Write(content[cw.start:end]); err != nil {
+					t.Fatalf("write error: %v", err)
+				}
+				finish(cw.ok, cw.end)
+			}
+
+			got, err := hasher.Sum(int64(len(content)))
+			if err != nil {
+				t.Fatalf("Sum error: %v", err)
+			}
+			if got != want {
+				t.Fatalf("expected hash %s, got %s", want, got)
+			}
+		})
+	}
+}
+
+func TestChunkHasher_ConcurrentChunks(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 30)
+	sum := sha256.Sum256(content)
+
+	file, err := os.Create(filepath.Join(t.TempDir(), "video.mp4"))
+	if err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	defer file.Close()
+
+	hasher := newChunkHasher(file)
+
+	// 前沿分片写入期间，后一个分片完成后需要等前沿分片结束再从文件读回
+	w0, finish0 := hasher.begin(0, io.NewOffsetWriter(file, 0))
+	w1, finish1 := hasher.begin(100, io.NewOffsetWriter(file, 100))
+	w1.Write(content[100:300])
+	finish1(true, 300)
+	w0.Write(content[:100])
+	finish0(true, 100)
+
+	got, err := hasher.Sum(int64(len(content)))
+	if err != nil {
+		t.Fatalf("Sum error: %v", err)
+	}
+	if got != hex.EncodeToString(sum[:]) {
+		t.Fatalf("unexpected hash %s", got)
+	}
+}
+
+func TestChunkHasher_Incomplete(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "video.mp4"))
+	if err != nil {
+		t.Fatalf("failed to create file: %v", err)
+	}
+	defer file.Close()
+
+	hasher := newChunkHasher(file)
+	w, finish := hasher.begin(0, file)
+	w.Write([]byte("abc"))
+	finish(true, 3)
+
+	// 缺少中间的分片时不能返回哈希
+	_, finish = hasher.begin(5, io.NewOffsetWriter(file, 5))
+	finish(true, 10)
+	if _, err := hasher.Sum(10); err == nil {
+		t.Fatal("expected error when chunks are missing")
+	}
+
+	// nil 计算器直接返回原 writer
+	var none *chunkHasher
+	var buf bytes.Buffer
+	if w, finish := none.begin(0, &buf); w != &buf {
+		t.Fatal("expected nil hasher to return the original writer")
+	} else {
+		finish(true, 0)
+	}
+}
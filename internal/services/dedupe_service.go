@@ -0,0 +1,170 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"wx_channel/internal/database"
+	"wx_channel/internal/utils"
+)
+
+// 去重处理结果
+const (
+	DedupeActionNone       = "none"       // 没有重复内容
+	DedupeActionKept       = "kept"       // 有重复内容，按策略保留两个文件
+	DedupeActionSkipped    = "skipped"    // 删除了新文件，记录指向已有文件
+	DedupeActionHardlinked = "hardlinked" // 新文件替换为指向已有文件的硬链接
+)
+
+// DedupeResult 保存时去重的处理结果
+type DedupeResult struct {
+	FilePath    string                   `json:"filePath"` // 处理后记录应指向的文件
+	Action      string                   `json:"action"`
+	DuplicateOf *database.DownloadRecord `json:"duplicateOf,omitempty"`
+}
+
+// Reused 是否复用了已有文件（复用时不应再修改文件内容）
+func (r *DedupeResult) Reused() bool {
+	return r.Action == DedupeActionSkipped || r.Action == DedupeActionHardlinked
+}
+
+// DedupeService 根据内容哈希处理重复下载
+type DedupeService struct {
+	repo     *database.DownloadRecordRepository
+	settings *database.SettingsRepository
+}
+
+// NewDedupeService 创建一个新的 DedupeService
+func NewDedupeService() *DedupeService {
+	return &DedupeService{
+		repo:     database.NewDownloadRecordRepository(),
+		settings: database.NewSettingsRepository(),
+	}
+}
+
+// Policy 返回当前的去重策略
+func (s *DedupeService) Policy() string {
+	settings, err := s.settings.Load()
+	if err != nil || settings.DedupePolicy == "" {
+		return database.DedupePolicyKeepBoth
+	}
+	return settings.DedupePolicy
+}
+
+// Apply 在保存新下载的文件时按去重策略处理，出错时保留新文件
+func (s *DedupeService) Apply(filePath, contentHash string) *DedupeResult {
+	result := &DedupeResult{FilePath: filePath, Action: DedupeActionNone}
+	if contentHash == "" {
+		return result
+	}
+
+	existing := s.findExisting(filePath, contentHash)
+	if existing == nil {
+		return result
+	}
+	result.DuplicateOf = existing
+
+	switch s.Policy() {
+	case database.DedupePolicySkip:
+		if err := os.Remove(filePath); err != nil {
+			utils.Warn("[Dedupe] Failed to remove duplicate %s: %v", filePath, err)
+			result.Action = DedupeActionKept
+			return result
+		}
+		utils.Info("[Dedupe] %s duplicates %s, removed the new copy", filePath, existing.FilePath)
+		result.FilePath = existing.FilePath
+		result.Action = DedupeActionSkipped
+	case database.DedupePolicyHardlink:
+		if err := replaceWithHardlink(existing.FilePath, filePath); err != nil {
+			utils.Warn("[Dedupe] Failed to hardlink %s to %s, keeping both: %v", filePath, existing.FilePath, err)
+			result.Action = DedupeActionKept
+			return result
+		}
+		utils.Info("[Dedupe] %s duplicates %s, replaced with a hardlink", filePath, existing.FilePath)
+		result.Action = DedupeActionHardlinked
+	default:
+		result.Action = DedupeActionKept
+	}
+	return result
+}
+
+// HashAndApply 按去重策略处理文件，contentHash 为写入时计算的内容哈希
+// contentHash 为空时（例如 Gopeed 引擎自行写入文件、下载后原地解密）回退为读取文件计算哈希
+// 哈希计算失败时返回空哈希并保留文件
+func (s *DedupeService) HashAndApply(filePath, contentHash string) (*DedupeResult, string) {
+	if contentHash == "" {
+		var err error
+		if contentHash, err = HashFile(filePath); err != nil {
+			utils.Warn("[Dedupe] Failed to hash %s: %v", filePath, err)
+			return &DedupeResult{FilePath: filePath, Action: DedupeActionNone}, ""
+		}
+	}
+	return s.Apply(filePath, contentHash), contentHash
+}
+
+// findExisting 查找内容相同且文件仍然存在的已有下载
+func (s *DedupeService) findExisting(filePath, contentHash string) *database.DownloadRecord {
+	records, err := s.repo.FindByContentHash(contentHash)
+	if err != nil {
+		utils.Warn("[Dedupe] Failed to look up content hash: %v", err)
+		return nil
+	}
+
+	newInfo, err := os.Stat(filePath)
+	if err != nil {
+		return nil
+	}
+	for i := range records {
+		info, err := os.Stat(records[i].FilePath)
+		if err != nil || os.SameFile(info, newInfo) {
+			continue
+		}
+		return &records[i]
+	}
+	return nil
+}
+
+// replaceWithHardlink 将 filePath 原子替换为指向 target 的硬链接
+func replaceWithHardlink(target, filePath string) error {
+	tmpPath := filePath + ".link"
+	os.Remove(tmpPath)
+	if err := os.Link(target, tmpPath); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// GetDuplicateGroups 获取整个下载库中内容重复的记录分组
+func (s *DedupeService) GetDuplicateGroups() ([]database.DuplicateGroup, error) {
+	return s.repo.GetDuplicateGroups()
+}
+
+// BackfillHashes 为尚未计算内容哈希的已完成下载计算哈希，返回成功计算的数量
+// 用于升级前下载的文件；文件经过元数据写入等后处理时，哈希对应的是处理后的内容
+func (s *DedupeService) BackfillHashes() (int, error) {
+	records, err := s.repo.GetWithoutContentHash()
+	if err != nil {
+		return 0, err
+	}
+
+	hashed := 0
+	for _, record := range records {
+		if record.FilePath == "" {
+			continue
+		}
+		hash, err := HashFile(filepath.Clean(record.FilePath))
+		if err != nil {
+			continue
+		}
+		if err := s.repo.SetContentHash(record.ID, hash); err != nil {
+			return hashed, fmt.Errorf("failed to save content hash: %w", err)
+		}
+		hashed++
+	}
+	return hashed, nil
+}
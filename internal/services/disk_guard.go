@@ -0,0 +1,149 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"wx_channel/internal/config"
+	"wx_channel/internal/database"
+	"wx_channel/internal/utils"
+)
+
+// 磁盘空间检查错误
+var (
+	ErrInsufficientDiskSpace = errors.New("insufficient disk space")
+	ErrLibraryQuotaExceeded  = errors.New("library size quota exceeded")
+)
+
+// DiskStatus 下载目录的磁盘空间和下载库容量状态
+type DiskStatus struct {
+	Path           string `json:"path"`
+	Total          int64  `json:"total"`          // 磁盘总空间（字节）
+	Available      int64  `json:"available"`      // 磁盘可用空间（字节）
+	MinFreeSpace   int64  `json:"minFreeSpace"`   // 可用空间阈值，0 表示不检查
+	LibrarySize    int64  `json:"librarySize"`    // 已完成下载的文件总大小
+	MaxLibrarySize int64  `json:"maxLibrarySize"` // 下载库容量上限，0 表示不限制
+	LowSpace       bool   `json:"lowSpace"`       // 可用空间低于阈值，队列已暂停
+	QuotaExceeded  bool   `json:"quotaExceeded"`  // 下载库已达到容量上限，队列已暂停
+	Error          string `json:"error,omitempty"`
+}
+
+// Blocked 是否因磁盘空间或容量上限而暂停下载
+func (s *DiskStatus) Blocked() bool {
+	return s.LowSpace || s.QuotaExceeded
+}
+
+// DiskGuard 检查下载目录的可用空间和下载库容量
+type DiskGuard struct {
+	settings     *database.SettingsRepository
+	downloadRepo *database.DownloadRecordRepository
+}
+
+// NewDiskGuard 创建一个新的 DiskGuard
+func NewDiskGuard() *DiskGuard {
+	return &DiskGuard{
+		settings:     database.NewSettingsRepository(),
+		downloadRepo: database.NewDownloadRecordRepository(),
+	}
+}
+
+// loadSettings 加载设置，失败时使用默认设置
+func (g *DiskGuard) loadSettings() *database.Settings {
+	settings, err := g.settings.Load()
+	if err != nil {
+		return database.DefaultSettings()
+	}
+	return settings
+}
+
+// downloadsDir 返回下载目录（优先使用配置中的 DownloadsDir）
+func downloadsDir(settings *database.Settings) string {
+	if cfg := config.Get(); cfg != nil && cfg.DownloadsDir != "" {
+		if dir, err := cfg.GetResolvedDownloadsDir(); err == nil {
+			return dir
+		}
+	}
+	if dir, err := utils.ResolveDownloadDir(settings.DownloadDir); err == nil {
+		return dir
+	}
+	return settings.DownloadDir
+}
+
+// Status 返回下载目录当前的磁盘空间和容量状态
+func (g *DiskGuard) Status() *DiskStatus {
+	settings := g.loadSettings()
+	status := &DiskStatus{
+		Path:           downloadsDir(settings),
+		MinFreeSpace:   settings.MinFreeSpace,
+		MaxLibrarySize: settings.MaxLibrarySize,
+	}
+
+	if space, err := utils.GetDiskSpace(status.Path); err != nil {
+		status.Error = err.Error()
+	} else {
+		status.Total = int64(space.Total)
+		status.Available = int64(space.Available)
+		status.LowSpace = settings.MinFreeSpace > 0 && status.Available < settings.MinFreeSpace
+	}
+
+	if librarySize, err := g.downloadRepo.GetTotalFileSize(); err == nil {
+		status.LibrarySize = librarySize
+		status.QuotaExceeded = settings.MaxLibrarySize > 0 && librarySize >= settings.MaxLibrarySize
+	} else if status.Error == "" {
+		status.Error = err.Error()
+	}
+
+	return status
+}
+
+// CheckDownload 检查 dir 所在磁盘和下载库是否还能容纳 size 字节的下载
+// 下载完成后可用空间仍需不低于阈值；无法获取磁盘空间时不阻止下载
+func (g *DiskGuard) CheckDownload(dir string, size int64) error {
+	settings := g.loadSettings()
+	if dir == "" {
+		dir = downloadsDir(settings)
+	}
+	if size < 0 {
+		size = 0
+	}
+
+	if space, err := utils.GetDiskSpace(dir); err == nil {
+		available := int64(space.Available)
+		if available-size < settings.MinFreeSpace || available < size {
+			return fmt.Errorf("%w: %s available, %s required (keeping %s free)", ErrInsufficientDiskSpace,
+				formatBytes(available), formatBytes(size), formatBytes(settings.MinFreeSpace))
+		}
+	}
+
+	if settings.MaxLibrarySize > 0 {
+		librarySize, err := g.downloadRepo.GetTotalFileSize()
+		if err != nil {
+			return fmt.Errorf("failed to get library size: %w", err)
+		}
+		if librarySize+size > settings.MaxLibrarySize {
+			return fmt.Errorf("%w: library uses %s of %s, download needs %s", ErrLibraryQuotaExceeded,
+				formatBytes(librarySize), formatBytes(settings.MaxLibrarySize), formatBytes(size))
+		}
+	}
+
+	return nil
+}
+
+// IsDiskSpaceError 检查错误是否由磁盘空间不足或超出下载库容量引起
+func IsDiskSpaceError(err error) bool {
+	return errors.Is(err, ErrInsufficientDiskSpace) || errors.Is(err, ErrLibraryQuotaExceeded) || isDiskFull(err)
+}
+
+// formatBytes 将字节数格式化为易读的字符串
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -1,25 +1,29 @@
 package services
 
 import (
-	"os"
 	"time"
 
 	"wx_channel/internal/database"
+	"wx_channel/internal/utils"
 )
 
 // DownloadRecordService 处理下载记录业务逻辑
 type DownloadRecordService struct {
-	repo *database.DownloadRecordRepository
+	repo  *database.DownloadRecordRepository
+	tags  *database.TagRepository
+	trash *TrashService
 }
 
 // NewDownloadRecordService 创建一个新的 DownloadRecordService
 func NewDownloadRecordService() *DownloadRecordService {
 	return &DownloadRecordService{
-		repo: database.NewDownloadRecordRepository(),
+		repo:  database.NewDownloadRecordRepository(),
+		tags:  database.NewTagRepository(),
+		trash: NewTrashService(),
 	}
 }
 
-// List 获取下载记录（带过滤和分页）
+// List 获取下载记录（带过滤和分页，包含标签）
 // Requirements: 2.3, 2.4 - 按日期范围和状态过滤
 func (s *DownloadRecordService) List(params *database.FilterParams) (*database.PagedResult[database.DownloadRecord], error) {
 	if params == nil {
@@ -32,15 +36,40 @@ func (s *DownloadRecordService) List(params *database.FilterParams) (*database.P
 			},
 		}
 	}
-	return s.repo.List(params)
+	result, err := s.repo.List(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := populateRecordTags(s.tags, result.Items); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
-// GetByID 按 ID 获取单条下载记录
+// GetByID 按 ID 获取单条下载记录（包含标签）
 func (s *DownloadRecordService) GetByID(id string) (*database.DownloadRecord, error) {
-	return s.repo.GetByID(id)
+	record, err := s.repo.GetByID(id)
+	if err != nil || record == nil {
+		return record, err
+	}
+	records := []database.DownloadRecord{*record}
+	if err := populateRecordTags(s.tags, records); err != nil {
+		return nil, err
+	}
+	return &records[0], nil
 }
 
-// Delete 按 ID 删除下载记录（可选删除文件）
+// moveToTrash 将记录的文件移入回收站，文件不存在时忽略
+func (s *DownloadRecordService) moveToTrash(record *database.DownloadRecord) {
+	if record.FilePath == "" {
+		return
+	}
+	if _, err := s.trash.MoveToTrash(record.FilePath, record); err != nil {
+		utils.Warn("[Download] Failed to move %s to trash: %v", record.FilePath, err)
+	}
+}
+
+// Delete 按 ID 删除下载记录（可选将文件移入回收站）
 // Requirements: 5.3 - 删除记录（可选择保留或删除文件）
 func (s *DownloadRecordService) Delete(id string, deleteFile bool) error {
 	if deleteFile {
@@ -48,15 +77,14 @@ func (s *DownloadRecordService) Delete(id string, deleteFile bool) error {
 		if err != nil {
 			return err
 		}
-		if record != nil && record.FilePath != "" {
-			// 尝试删除文件，如果文件不存在则忽略错误
-			_ = os.Remove(record.FilePath)
+		if record != nil {
+			s.moveToTrash(record)
 		}
 	}
 	return s.repo.Delete(id)
 }
 
-// DeleteMany 按 ID 批量删除下载记录（可选删除文件）
+// DeleteMany 按 ID 批量删除下载记录（可选将文件移入回收站）
 // Requirements: 5.3 - 批量删除（可选择保留或删除文件）
 func (s *DownloadRecordService) DeleteMany(ids []string, deleteFiles bool) (int64, error) {
 	if deleteFiles {
@@ -64,17 +92,14 @@ func (s *DownloadRecordService) DeleteMany(ids []string, deleteFiles bool) (int6
 		if err != nil {
 			return 0, err
 		}
-		for _, record := range records {
-			if record.FilePath != "" {
-				// 尝试删除文件，如果文件不存在则忽略错误
-				_ = os.Remove(record.FilePath)
-			}
+		for i := range records {
+			s.moveToTrash(&records[i])
 		}
 	}
 	return s.repo.DeleteMany(ids)
 }
 
-// Clear 清空所有下载记录（可选删除文件）
+// Clear 清空所有下载记录（可选将文件移入回收站）
 // Requirements: 5.3 - 清空记录（可选择保留或删除文件）
 func (s *DownloadRecordService) Clear(deleteFiles bool) error {
 	if deleteFiles {
@@ -82,17 +107,14 @@ func (s *DownloadRecordService) Clear(deleteFiles bool) error {
 		if err != nil {
 			return err
 		}
-		for _, record := range records {
-			if record.FilePath != "" {
-				// 尝试删除文件，如果文件不存在则忽略错误
-				_ = os.Remove(record.FilePath)
-			}
+		for i := range records {
+			s.moveToTrash(&records[i])
 		}
 	}
 	return s.repo.Clear()
 }
 
-// DeleteBefore 删除指定日期前的所有记录（可选删除文件）
+// DeleteBefore 删除指定日期前的所有记录（可选将文件移入回收站）
 func (s *DownloadRecordService) DeleteBefore(date time.Time, deleteFiles bool) (int64, error) {
 	if deleteFiles {
 		// 分页获取日期前的所有记录以删除文件
@@ -110,10 +132,8 @@ func (s *DownloadRecordService) DeleteBefore(date time.Time, deleteFiles bool) (
 			if err != nil {
 				return 0, err
 			}
-			for _, record := range result.Items {
-				if record.FilePath != "" {
-					_ = os.Remove(record.FilePath)
-				}
+			for i := range result.Items {
+				s.moveToTrash(&result.Items[i])
 			}
 			// 如果这一页数据不足一批，说明没有更多了
 			if len(result.Items) < batchSize {
@@ -125,6 +145,26 @@ func (s *DownloadRecordService) DeleteBefore(date time.Time, deleteFiles bool) (
 	return s.repo.DeleteBefore(date)
 }
 
+// SetStarred 设置下载记录的加星状态，加星的下载不会被保留策略删除
+func (s *DownloadRecordService) SetStarred(id string, starred bool) error {
+	return s.repo.SetStarred(id, starred)
+}
+
+// MarkPlayed 记录文件在控制台被播放，paths 为同一文件的不同写法（请求路径、解析后的路径）
+func (s *DownloadRecordService) MarkPlayed(paths ...string) {
+	now := time.Now()
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		if _, err := s.repo.MarkPlayedByPath(path, now); err != nil {
+			utils.Warn("[Download] Failed to record playback of %s: %v", path, err)
+		}
+	}
+}
+
 // Count 返回下载记录总数
 func (s *DownloadRecordService) Count() (int64, error) {
 	return s.repo.Count()
@@ -5,6 +5,7 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"wx_channel/internal/database"
@@ -16,6 +17,8 @@ type ExportFormat string
 const (
 	ExportFormatJSON ExportFormat = "json"
 	ExportFormatCSV  ExportFormat = "csv"
+	// ExportFormatLegacyCSV 旧版 download_records.csv 的列格式，仅用于下载记录
+	ExportFormatLegacyCSV ExportFormat = "legacy"
 )
 
 // ExportResult 包含导出的数据和元数据
@@ -32,6 +35,7 @@ type ExportResult struct {
 type ExportService struct {
 	browseRepo   *database.BrowseHistoryRepository
 	downloadRepo *database.DownloadRecordRepository
+	tagRepo      *database.TagRepository
 }
 
 // NewExportService 创建一个新的 ExportService
@@ -39,6 +43,7 @@ func NewExportService() *ExportService {
 	return &ExportService{
 		browseRepo:   database.NewBrowseHistoryRepository(),
 		downloadRepo: database.NewDownloadRecordRepository(),
+		tagRepo:      database.NewTagRepository(),
 	}
 }
 
@@ -93,22 +98,32 @@ func (s *ExportService) ExportBrowseHistory(format ExportFormat, ids []string) (
 	}, nil
 }
 
-// ExportDownloadRecords 导出下载记录
+// ExportDownloadRecords 导出下载记录（包含标签）
+// tag 不为空时只导出带有该标签的记录
 // Requirements: 4.2 - 以 JSON 或 CSV 格式导出下载记录
-func (s *ExportService) ExportDownloadRecords(format ExportFormat, ids []string) (*ExportResult, error) {
+func (s *ExportService) ExportDownloadRecords(format ExportFormat, ids []string, tag string) (*ExportResult, error) {
 	var records []database.DownloadRecord
 	var err error
 
-	// 获取记录 - 所有或按特定 ID
+	// 获取记录 - 所有、按特定 ID 或按标签
 	// Requirements: 9.4 - 按 ID 选择性导出
-	if len(ids) > 0 {
+	switch {
+	case len(ids) > 0:
 		records, err = s.downloadRepo.GetByIDs(ids)
-	} else {
+	case tag != "":
+		records, err = s.downloadRepo.GetByTag(tag)
+	default:
 		records, err = s.downloadRepo.GetAll()
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get download records: %w", err)
 	}
+	if err := populateRecordTags(s.tagRepo, records); err != nil {
+		return nil, fmt.Errorf("failed to get download record tags: %w", err)
+	}
+	if len(ids) > 0 && tag != "" {
+		records = filterRecordsByTag(records, tag)
+	}
 
 	var data []byte
 	var contentType string
@@ -120,6 +135,9 @@ func (s *ExportService) ExportDownloadRecords(format ExportFormat, ids []string)
 	case ExportFormatCSV:
 		data, err = s.exportDownloadRecordsToCSV(records)
 		contentType = "text/csv"
+	case ExportFormatLegacyCSV:
+		data, err = exportDownloadRecordsToLegacyCSV(records)
+		contentType = "text/csv"
 	default:
 		return nil, fmt.Errorf("unsupported export format: %s", format)
 	}
@@ -128,9 +146,14 @@ func (s *ExportService) ExportDownloadRecords(format ExportFormat, ids []string)
 		return nil, err
 	}
 
+	extension := format
+	if format == ExportFormatLegacyCSV {
+		extension = ExportFormatCSV
+	}
+
 	return &ExportResult{
 		Data:        data,
-		Filename:    GenerateTimestampFilename("download_records", format),
+		Filename:    GenerateTimestampFilename("download_records", extension),
 		ContentType: contentType,
 		RecordCount: len(records),
 		ExportTime:  time.Now(),
@@ -221,7 +244,7 @@ func (s *ExportService) exportDownloadRecordsToCSV(records []database.DownloadRe
 		"ID", "VideoID", "Title", "Author", "Duration", "FileSize",
 		"FilePath", "Format", "Resolution", "Status", "DownloadTime",
 		"LikeCount", "CommentCount", "ForwardCount", "FavCount",
-		"ErrorMessage", "CreatedAt", "UpdatedAt",
+		"ErrorMessage", "CreatedAt", "UpdatedAt", "Tags",
 	}
 	if err := writer.Write(header); err != nil {
 		return nil, fmt.Errorf("failed to write CSV header: %w", err)
@@ -248,6 +271,7 @@ func (s *ExportService) exportDownloadRecordsToCSV(records []database.DownloadRe
 			record.ErrorMessage,
 			record.CreatedAt.Format(time.RFC3339),
 			record.UpdatedAt.Format(time.RFC3339),
+			strings.Join(record.Tags, ","),
 		}
 		if err := writer.Write(row); err != nil {
 			return nil, fmt.Errorf("failed to write CSV row: %w", err)
@@ -262,6 +286,20 @@ func (s *ExportService) exportDownloadRecordsToCSV(records []database.DownloadRe
 	return buf.Bytes(), nil
 }
 
+// filterRecordsByTag 返回带有指定标签（不区分大小写）的记录
+func filterRecordsByTag(records []database.DownloadRecord, tag string) []database.DownloadRecord {
+	filtered := []database.DownloadRecord{}
+	for _, record := range records {
+		for _, name := range record.Tags {
+			if strings.EqualFold(name, tag) {
+				filtered = append(filtered, record)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
 // formatDuration 将毫秒持续时间格式化为 MM:SS 字符串
 func formatDuration(ms int64) string {
 	seconds := ms / 1000
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"wx_channel/internal/database"
+	"wx_channel/internal/utils"
+	"wx_channel/pkg/mp4"
+)
+
+// ErrFastStartRunning 表示已有 faststart 任务正在执行
+var ErrFastStartRunning = errors.New("faststart is already running")
+
+// fastStartMu 保证同一时间只有一个 faststart 任务，避免重复改写同一文件
+var fastStartMu sync.Mutex
+
+// FastStartResult 单个文件的 faststart 处理结果
+type FastStartResult struct {
+	RecordID string `json:"recordId,omitempty"`
+	FilePath string `json:"filePath"`
+	Changed  bool   `json:"changed"` // moov 已移到文件开头；false 表示原本就是 faststart 或处理失败
+	Error    string `json:"error,omitempty"`
+}
+
+// FastStartSummary 批量 faststart 的汇总结果
+type FastStartSummary struct {
+	Total    int               `json:"total"`
+	Changed  int               `json:"changed"`
+	Failed   int               `json:"failed"`
+	Results  []FastStartResult `json:"results"`
+	Duration int64             `json:"duration"` // 毫秒
+}
+
+// FastStartService 对已下载的文件执行 faststart（将 moov 移到文件开头）
+type FastStartService struct {
+	repo *database.DownloadRecordRepository
+}
+
+// NewFastStartService 创建一个新的 FastStartService
+func NewFastStartService() *FastStartService {
+	return &FastStartService{
+		repo: database.NewDownloadRecordRepository(),
+	}
+}
+
+// ProcessFile 对单个文件执行 faststart
+func (s *FastStartService) ProcessFile(filePath string) (*FastStartResult, error) {
+	if !fastStartMu.TryLock() {
+		return nil, ErrFastStartRunning
+	}
+	defer fastStartMu.Unlock()
+
+	result := s.fastStartFile(filePath)
+	return &result, nil
+}
+
+// ProcessRecords 对指定下载记录的文件执行 faststart，ids 为空时处理所有已完成的下载
+func (s *FastStartService) ProcessRecords(ids []string) (*FastStartSummary, error) {
+	if !fastStartMu.TryLock() {
+		return nil, ErrFastStartRunning
+	}
+	defer fastStartMu.Unlock()
+
+	var records []database.DownloadRecord
+	var err error
+	if len(ids) > 0 {
+		records, err = s.repo.GetByIDs(ids)
+	} else {
+		records, err = s.repo.GetAll()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load download records: %w", err)
+	}
+
+	start := time.Now()
+	summary := &FastStartSummary{Results: []FastStartResult{}}
+	for _, record := range records {
+		if record.Status != database.DownloadStatusCompleted || !isMP4Path(record.FilePath) {
+			continue
+		}
+		summary.Total++
+		result := s.fastStartFile(record.FilePath)
+		result.RecordID = record.ID
+		switch {
+		case result.Error != "":
+			summary.Failed++
+		case result.Changed:
+			summary.Changed++
+		default:
+			// 原本就是 faststart 的文件不放入结果列表
+			continue
+		}
+		summary.Results = append(summary.Results, result)
+	}
+	summary.Duration = time.Since(start).Milliseconds()
+
+	utils.Info("[FastStart] Processed %d files: %d changed, %d failed", summary.Total, summary.Changed, summary.Failed)
+	return summary, nil
+}
+
+// fastStartFile 校验文件结构后执行 faststart，结构有问题的文件不做修改
+// 改写会生成新文件，去重产生的硬链接会被断开，因此跳过有多个硬链接的文件
+func (s *FastStartService) fastStartFile(filePath string) FastStartResult {
+	result := FastStartResult{FilePath: filePath}
+	if !isMP4Path(filePath) {
+		result.Error = "not an mp4 file"
+		return result
+	}
+
+	links, err := utils.LinkCount(filePath)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if links > 1 {
+		result.Error = fmt.Sprintf("file has %d hardlinks, skipped to keep them shared", links)
+		return result
+	}
+
+	report, err := mp4.Validate(filePath)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if !report.Valid {
+		result.Error = fmt.Sprintf("invalid mp4 structure: %s", report.Issues[0].Message)
+		return result
+	}
+	if report.FastStart {
+		return result
+	}
+
+	changed, hash, err := mp4.FastStartHash(filePath)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Changed = changed
+	if changed {
+		s.updateContentHash(filePath, hash)
+	}
+	return result
+}
+
+// updateContentHash 文件内容改变后更新指向该文件的下载记录的内容哈希（改写文件时计算）
+func (s *FastStartService) updateContentHash(filePath, hash string) {
+	if _, err := s.repo.SetContentHashByPath(filePath, hash); err != nil {
+		utils.Warn("[FastStart] Failed to update content hash for %s: %v", filePath, err)
+	}
+}
@@ -2,7 +2,12 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
 	"path/filepath"
 	"reflect"
 	"sync"
@@ -77,9 +82,15 @@ func (s *GopeedService) DeleteTask(taskID string) error {
 
 // DownloadSync downloads a file synchronously (blocking until done)
 // Used by BatchHandler to replace existing downloadVideoOnce logic
-func (s *GopeedService) DownloadSync(ctx context.Context, url string, path string, connections int, onProgress func(progress float64, downloaded int64, total int64)) error {
+// 返回写入时计算的内容 SHA-256；Gopeed 引擎自行写入文件，数据不经过这里，此时返回空字符串
+func (s *GopeedService) DownloadSync(ctx context.Context, url string, path string, connections int, onProgress func(progress float64, downloaded int64, total int64)) (string, error) {
+	// Gopeed 引擎无法限速，启用带宽限制时改用受限速约束的流式下载
+	if GetBandwidthLimiter().Enabled() {
+		return s.downloadThrottled(ctx, url, path, onProgress)
+	}
+
 	if s.Downloader == nil {
-		return fmt.Errorf("downloader not initialized")
+		return "", Permanent(fmt.Errorf("downloader not initialized"))
 	}
 
 	// Configure options
@@ -103,7 +114,7 @@ func (s *GopeedService) DownloadSync(ctx context.Context, url string, path strin
 	req := &base.Request{URL: url}
 	id, err := s.Downloader.CreateDirect(req, opts)
 	if err != nil {
-		return fmt.Errorf("failed to create task: %v", err)
+		return "", fmt.Errorf("failed to create task: %v", err)
 	}
 
 	// Poll status
@@ -115,11 +126,11 @@ func (s *GopeedService) DownloadSync(ctx context.Context, url string, path strin
 		case <-ctx.Done():
 			// Cancel task
 			s.Downloader.Delete(&download.TaskFilter{IDs: []string{id}}, true)
-			return ctx.Err()
+			return "", ctx.Err()
 		case <-ticker.C:
 			task := s.Downloader.GetTask(id)
 			if task == nil {
-				return fmt.Errorf("task not found: %s", id)
+				return "", fmt.Errorf("task not found: %s", id)
 			}
 
 			// Report progress
@@ -174,9 +185,9 @@ func (s *GopeedService) DownloadSync(ctx context.Context, url string, path strin
 			// Check status
 			switch task.Status {
 			case base.DownloadStatusDone:
-				return nil
+				return "", nil
 			case base.DownloadStatusError:
-				return fmt.Errorf("download task failed")
+				return "", fmt.Errorf("download task failed")
 			case base.DownloadStatusRunning, base.DownloadStatusReady:
 				// Continue waiting
 				continue
@@ -187,3 +198,66 @@ func (s *GopeedService) DownloadSync(ctx context.Context, url string, path strin
 		}
 	}
 }
+
+// downloadThrottled 使用单连接流式下载文件，并受全局和单任务带宽限制约束
+// 数据经过限速读取器写入文件的同时计算内容 SHA-256
+func (s *GopeedService) downloadThrottled(ctx context.Context, url string, path string, onProgress func(progress float64, downloaded int64, total int64)) (string, error) {
+	limiter := GetBandwidthLimiter()
+	release := limiter.Acquire(path, 0)
+	defer release()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", &HTTPStatusError{StatusCode: resp.StatusCode}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %v", err)
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %w", err)
+	}
+	defer out.Close()
+
+	total := resp.ContentLength
+	reader := &utils.ProgressReader{
+		Ctx:    ctx,
+		Reader: limiter.Reader(ctx, path, resp.Body),
+		Total:  total,
+		OnProgress: func(current, total int64) {
+			if onProgress == nil {
+				return
+			}
+			var progress float64
+			if total > 0 {
+				progress = float64(current) / float64(total)
+			}
+			onProgress(progress, current, total)
+		},
+	}
+
+	h := sha256.New()
+	written, err := io.Copy(io.MultiWriter(out, h), reader)
+	if err != nil {
+		return "", fmt.Errorf("download failed: %w", err)
+	}
+	if total > 0 && written != total {
+		return "", fmt.Errorf("incomplete download: expected %d bytes, got %d bytes", total, written)
+	}
+
+	if onProgress != nil {
+		onProgress(1, written, written)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
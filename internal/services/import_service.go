@@ -0,0 +1,551 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"wx_channel/internal/database"
+	"wx_channel/internal/utils"
+)
+
+// ImportStrategy 表示导入时遇到已存在记录的处理方式
+type ImportStrategy string
+
+const (
+	ImportStrategySkip      ImportStrategy = "skip"      // 保留已有记录
+	ImportStrategyOverwrite ImportStrategy = "overwrite" // 用导入的记录覆盖
+	ImportStrategyMerge     ImportStrategy = "merge"     // 互动数取较大值，空字段用导入的值补齐
+)
+
+// 导入的记录类型
+const (
+	ImportKindBrowse    = "browse"
+	ImportKindDownloads = "downloads"
+)
+
+// maxImportErrors 导入报告中最多保留的错误信息条数
+const maxImportErrors = 50
+
+// 导入错误
+var (
+	ErrInvalidImportKind     = errors.New("invalid import kind")
+	ErrInvalidImportStrategy = errors.New("invalid import strategy")
+	ErrInvalidImportFormat   = errors.New("invalid import format")
+	ErrInvalidImportData     = errors.New("invalid import data")
+)
+
+// ImportReport 导入结果汇总
+type ImportReport struct {
+	Kind     string         `json:"kind"`
+	Format   ExportFormat   `json:"format"`
+	Legacy   bool           `json:"legacy,omitempty"` // 是否为旧版 download_records.csv
+	Strategy ImportStrategy `json:"strategy"`
+	Total    int            `json:"total"`
+	Created  int            `json:"created"`
+	Updated  int            `json:"updated"`
+	Skipped  int            `json:"skipped"`
+	Failed   int            `json:"failed"`
+	Errors   []string       `json:"errors,omitempty"` // 最多保留前 50 条
+}
+
+// fail 记录一条导入失败的记录
+func (r *ImportReport) fail(format string, args ...interface{}) {
+	r.Failed++
+	if len(r.Errors) < maxImportErrors {
+		r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+	}
+}
+
+// ImportService 从 JSON、CSV 导出文件或旧版 download_records.csv 导入浏览和下载记录
+type ImportService struct {
+	browseRepo   *database.BrowseHistoryRepository
+	downloadRepo *database.DownloadRecordRepository
+	tags         *TagService
+	authors      *AuthorService
+	metrics      *VideoMetricsService
+}
+
+// NewImportService 创建一个新的 ImportService
+func NewImportService() *ImportService {
+	return &ImportService{
+		browseRepo:   database.NewBrowseHistoryRepository(),
+		downloadRepo: database.NewDownloadRecordRepository(),
+		tags:         NewTagService(),
+		authors:      NewAuthorService(),
+		metrics:      NewVideoMetricsService(),
+	}
+}
+
+// ParseImportStrategy 解析冲突处理方式，空字符串表示 skip
+func ParseImportStrategy(s string) (ImportStrategy, error) {
+	switch strategy := ImportStrategy(strings.ToLower(strings.TrimSpace(s))); strategy {
+	case "":
+		return ImportStrategySkip, nil
+	case ImportStrategySkip, ImportStrategyOverwrite, ImportStrategyMerge:
+		return strategy, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrInvalidImportStrategy, s)
+	}
+}
+
+// ParseImportFormat 解析导入格式，空字符串表示根据内容自动识别
+func ParseImportFormat(s string) (ExportFormat, error) {
+	switch format := ExportFormat(strings.ToLower(strings.TrimSpace(s))); format {
+	case "", ExportFormatJSON, ExportFormatCSV:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrInvalidImportFormat, s)
+	}
+}
+
+// detectImportFormat 以 [ 或 { 开头的内容视为 JSON，其余视为 CSV
+func detectImportFormat(data []byte) ExportFormat {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF}))
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		return ExportFormatJSON
+	}
+	return ExportFormatCSV
+}
+
+// Import 按记录类型导入数据，format 为空时自动识别
+func (s *ImportService) Import(kind string, data []byte, format ExportFormat, strategy ImportStrategy) (*ImportReport, error) {
+	switch kind {
+	case ImportKindBrowse:
+		return s.ImportBrowseRecords(data, format, strategy)
+	case ImportKindDownloads:
+		return s.ImportDownloadRecords(data, format, strategy)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrInvalidImportKind, kind)
+	}
+}
+
+// ImportBrowseRecords 导入浏览记录，并为导入的记录追加互动数据快照、记录作者
+func (s *ImportService) ImportBrowseRecords(data []byte, format ExportFormat, strategy ImportStrategy) (*ImportReport, error) {
+	if format == "" {
+		format = detectImportFormat(data)
+	}
+
+	var records []database.BrowseRecord
+	var err error
+	if format == ExportFormatJSON {
+		records, err = ParseBrowseRecordsFromJSON(data)
+	} else {
+		records, err = ParseBrowseRecordsFromCSV(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImportData, err)
+	}
+
+	report := &ImportReport{Kind: ImportKindBrowse, Format: format, Strategy: strategy, Total: len(records)}
+	for i := range records {
+		s.importBrowseRecord(&records[i], i+1, strategy, report)
+	}
+	return report, nil
+}
+
+// importBrowseRecord 导入一条浏览记录，n 为记录序号（从 1 开始）
+func (s *ImportService) importBrowseRecord(record *database.BrowseRecord, n int, strategy ImportStrategy, report *ImportReport) {
+	record.ID = strings.TrimSpace(record.ID)
+	if record.ID == "" {
+		report.fail("record %d: missing id", n)
+		return
+	}
+	if record.BrowseTime.IsZero() {
+		record.BrowseTime = time.Now()
+	}
+
+	existing, err := s.browseRepo.GetByID(record.ID)
+	if err != nil {
+		report.fail("record %d (%s): %v", n, record.ID, err)
+		return
+	}
+
+	switch {
+	case existing == nil:
+		if err := s.browseRepo.Create(record); err != nil {
+			report.fail("record %d (%s): %v", n, record.ID, err)
+			return
+		}
+		report.Created++
+	case strategy == ImportStrategySkip:
+		report.Skipped++
+		return
+	default:
+		if strategy == ImportStrategyMerge {
+			record = mergeBrowseRecords(existing, record)
+		}
+		if err := s.browseRepo.Update(record); err != nil {
+			report.fail("record %d (%s): %v", n, record.ID, err)
+			return
+		}
+		report.Updated++
+	}
+
+	if err := s.metrics.Record(record); err != nil {
+		utils.Warn("导入浏览记录时保存互动数据快照失败: %v", err)
+	}
+	if err := s.authors.Observe(record.AuthorID, record.Author, "", record.BrowseTime); err != nil {
+		utils.Warn("导入浏览记录时保存作者失败: %v", err)
+	}
+}
+
+// mergeBrowseRecords 合并已有和导入的浏览记录：互动数取较大值，空字段用导入的值补齐，浏览时间取较晚的
+func mergeBrowseRecords(existing, imported *database.BrowseRecord) *database.BrowseRecord {
+	merged := *existing
+	mergeString(&merged.Title, imported.Title)
+	mergeString(&merged.Author, imported.Author)
+	mergeString(&merged.AuthorID, imported.AuthorID)
+	mergeString(&merged.Resolution, imported.Resolution)
+	mergeString(&merged.CoverURL, imported.CoverURL)
+	mergeString(&merged.VideoURL, imported.VideoURL)
+	mergeString(&merged.DecryptKey, imported.DecryptKey)
+	mergeString(&merged.PageURL, imported.PageURL)
+	mergeInt(&merged.Duration, imported.Duration)
+	mergeInt(&merged.Size, imported.Size)
+	merged.LikeCount = max(merged.LikeCount, imported.LikeCount)
+	merged.CommentCount = max(merged.CommentCount, imported.CommentCount)
+	merged.FavCount = max(merged.FavCount, imported.FavCount)
+	merged.ForwardCount = max(merged.ForwardCount, imported.ForwardCount)
+	if imported.BrowseTime.After(merged.BrowseTime) {
+		merged.BrowseTime = imported.BrowseTime
+	}
+	return &merged
+}
+
+// ImportDownloadRecords 导入下载记录，CSV 支持下载记录导出文件和旧版 download_records.csv
+func (s *ImportService) ImportDownloadRecords(data []byte, format ExportFormat, strategy ImportStrategy) (*ImportReport, error) {
+	if format == "" {
+		format = detectImportFormat(data)
+	}
+
+	var records []database.DownloadRecord
+	var legacy bool
+	var err error
+	if format == ExportFormatJSON {
+		records, err = ParseDownloadRecordsFromJSON(data)
+	} else {
+		records, legacy, err = ParseDownloadRecordsFromCSV(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImportData, err)
+	}
+
+	report := &ImportReport{Kind: ImportKindDownloads, Format: format, Legacy: legacy, Strategy: strategy, Total: len(records)}
+	s.importDownloadRecords(records, strategy, report)
+	return report, nil
+}
+
+// importDownloadRecords 逐条导入下载记录，结果计入 report
+func (s *ImportService) importDownloadRecords(records []database.DownloadRecord, strategy ImportStrategy, report *ImportReport) {
+	for i := range records {
+		s.importDownloadRecord(&records[i], i+1, strategy, report)
+	}
+}
+
+// importDownloadRecord 导入一条下载记录，n 为记录序号（从 1 开始）
+func (s *ImportService) importDownloadRecord(record *database.DownloadRecord, n int, strategy ImportStrategy, report *ImportReport) {
+	record.ID = strings.TrimSpace(record.ID)
+	if record.ID == "" {
+		report.fail("record %d: missing id", n)
+		return
+	}
+	if record.Status == "" {
+		record.Status = database.DownloadStatusCompleted
+	}
+	if record.DownloadTime.IsZero() {
+		record.DownloadTime = time.Now()
+	}
+
+	existing, err := s.downloadRepo.GetByID(record.ID)
+	if err != nil {
+		report.fail("record %d (%s): %v", n, record.ID, err)
+		return
+	}
+	if existing != nil && strategy == ImportStrategySkip {
+		report.Skipped++
+		return
+	}
+
+	tags := record.Tags
+	if existing != nil && strategy == ImportStrategyMerge {
+		existingTags, err := s.tags.GetRecordTags(record.ID)
+		if err != nil {
+			report.fail("record %d (%s): %v", n, record.ID, err)
+			return
+		}
+		tags = append(existingTags, tags...)
+		record = mergeDownloadRecords(existing, record)
+	}
+
+	// Create 覆盖已有记录时原地更新，保留原有的标签和合集关联
+	if err := s.downloadRepo.Create(record); err != nil {
+		report.fail("record %d (%s): %v", n, record.ID, err)
+		return
+	}
+	if existing == nil {
+		report.Created++
+	} else {
+		report.Updated++
+	}
+
+	if len(tags) > 0 {
+		if _, err := s.tags.SetRecordTags(record.ID, tags); err != nil {
+			utils.Warn("导入下载记录时设置标签失败: %v", err)
+		}
+	}
+}
+
+// mergeDownloadRecords 合并已有和导入的下载记录：互动数取较大值，空字段用导入的值补齐
+// 已有记录未完成而导入的记录已完成时使用导入记录的状态
+func mergeDownloadRecords(existing, imported *database.DownloadRecord) *database.DownloadRecord {
+	merged := *existing
+	mergeString(&merged.VideoID, imported.VideoID)
+	mergeString(&merged.Title, imported.Title)
+	mergeString(&merged.Author, imported.Author)
+	mergeString(&merged.AuthorID, imported.AuthorID)
+	mergeString(&merged.CoverURL, imported.CoverURL)
+	mergeString(&merged.FilePath, imported.FilePath)
+	mergeString(&merged.Format, imported.Format)
+	mergeString(&merged.Resolution, imported.Resolution)
+	mergeString(&merged.ContentHash, imported.ContentHash)
+	mergeInt(&merged.Duration, imported.Duration)
+	mergeInt(&merged.FileSize, imported.FileSize)
+	merged.LikeCount = max(merged.LikeCount, imported.LikeCount)
+	merged.CommentCount = max(merged.CommentCount, imported.CommentCount)
+	merged.ForwardCount = max(merged.ForwardCount, imported.ForwardCount)
+	merged.FavCount = max(merged.FavCount, imported.FavCount)
+	merged.Starred = merged.Starred || imported.Starred
+	if imported.LastPlayedAt.After(merged.LastPlayedAt) {
+		merged.LastPlayedAt = imported.LastPlayedAt
+	}
+	if merged.Status != database.DownloadStatusCompleted && imported.Status == database.DownloadStatusCompleted {
+		merged.Status = imported.Status
+		merged.ErrorMessage = imported.ErrorMessage
+		merged.DownloadTime = imported.DownloadTime
+	}
+	return &merged
+}
+
+// mergeString 当前值为空时使用导入的值
+func mergeString(dst *string, value string) {
+	if *dst == "" {
+		*dst = value
+	}
+}
+
+// mergeInt 当前值为 0 时使用导入的值
+func mergeInt(dst *int64, value int64) {
+	if *dst == 0 {
+		*dst = value
+	}
+}
+
+// csvTable 带表头的 CSV 数据
+type csvTable struct {
+	columns map[string]int
+	rows    [][]string
+}
+
+// get 按列名获取单元格，列不存在时返回空字符串
+func (t *csvTable) get(row []string, column string) string {
+	i, ok := t.columns[column]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+// readCSV 读取 CSV 数据（可带 UTF-8 BOM），跳过空行
+func readCSV(data []byte) ([][]string, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV: %w", err)
+	}
+
+	nonEmpty := rows[:0]
+	for _, row := range rows {
+		if len(row) > 1 || (len(row) == 1 && strings.TrimSpace(row[0]) != "") {
+			nonEmpty = append(nonEmpty, row)
+		}
+	}
+	return nonEmpty, nil
+}
+
+// newCSVTable 将第一行作为表头
+func newCSVTable(rows [][]string) *csvTable {
+	table := &csvTable{columns: make(map[string]int)}
+	if len(rows) == 0 {
+		return table
+	}
+	for i, name := range rows[0] {
+		table.columns[strings.TrimSpace(name)] = i
+	}
+	table.rows = rows[1:]
+	return table
+}
+
+// ParseBrowseRecordsFromCSV 从浏览记录导出的 CSV 数据解析浏览记录
+func ParseBrowseRecordsFromCSV(data []byte) ([]database.BrowseRecord, error) {
+	rows, err := readCSV(data)
+	if err != nil {
+		return nil, err
+	}
+	table := newCSVTable(rows)
+	if _, ok := table.columns["ID"]; !ok && len(rows) > 0 {
+		return nil, fmt.Errorf("missing ID column in browse records CSV")
+	}
+
+	records := make([]database.BrowseRecord, 0, len(table.rows))
+	for _, row := range table.rows {
+		records = append(records, database.BrowseRecord{
+			ID:           table.get(row, "ID"),
+			Title:        table.get(row, "Title"),
+			Author:       table.get(row, "Author"),
+			AuthorID:     table.get(row, "AuthorID"),
+			Duration:     parseImportDuration(table.get(row, "Duration")),
+			Size:         parseImportSize(table.get(row, "Size")),
+			Resolution:   table.get(row, "Resolution"),
+			CoverURL:     table.get(row, "CoverURL"),
+			VideoURL:     table.get(row, "VideoURL"),
+			DecryptKey:   table.get(row, "DecryptKey"),
+			BrowseTime:   parseImportTime(table.get(row, "BrowseTime")),
+			LikeCount:    parseImportCount(table.get(row, "LikeCount")),
+			CommentCount: parseImportCount(table.get(row, "CommentCount")),
+			FavCount:     parseImportCount(table.get(row, "FavCount")),
+			ForwardCount: parseImportCount(table.get(row, "ForwardCount")),
+			PageURL:      table.get(row, "PageURL"),
+		})
+	}
+	return records, nil
+}
+
+// ParseDownloadRecordsFromCSV 从 CSV 数据解析下载记录
+// 表头包含 VideoID 列时按下载记录导出文件解析，否则按旧版 download_records.csv 解析，legacy 表示后者
+// 导出文件中的时长和文件大小是格式化后的值，解析结果可能与原值略有差异
+func ParseDownloadRecordsFromCSV(data []byte) (records []database.DownloadRecord, legacy bool, err error) {
+	rows, err := readCSV(data)
+	if err != nil {
+		return nil, false, err
+	}
+	table := newCSVTable(rows)
+	if _, ok := table.columns["VideoID"]; !ok {
+		return parseLegacyDownloadRows(rows), true, nil
+	}
+
+	records = make([]database.DownloadRecord, 0, len(table.rows))
+	for _, row := range table.rows {
+		record := database.DownloadRecord{
+			ID:           table.get(row, "ID"),
+			VideoID:      table.get(row, "VideoID"),
+			Title:        table.get(row, "Title"),
+			Author:       table.get(row, "Author"),
+			Duration:     parseImportDuration(table.get(row, "Duration")),
+			FileSize:     parseImportSize(table.get(row, "FileSize")),
+			FilePath:     table.get(row, "FilePath"),
+			Format:       table.get(row, "Format"),
+			Resolution:   table.get(row, "Resolution"),
+			Status:       table.get(row, "Status"),
+			DownloadTime: parseImportTime(table.get(row, "DownloadTime")),
+			LikeCount:    parseImportCount(table.get(row, "LikeCount")),
+			CommentCount: parseImportCount(table.get(row, "CommentCount")),
+			ForwardCount: parseImportCount(table.get(row, "ForwardCount")),
+			FavCount:     parseImportCount(table.get(row, "FavCount")),
+			ErrorMessage: table.get(row, "ErrorMessage"),
+		}
+		for _, tag := range strings.Split(table.get(row, "Tags"), ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				record.Tags = append(record.Tags, tag)
+			}
+		}
+		records = append(records, record)
+	}
+	return records, false, nil
+}
+
+// parseImportTime 解析 RFC3339 或本地时间 "2006-01-02 15:04:05" 格式的时间，无法解析时返回零值
+func parseImportTime(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseImportDuration 解析 "MM:SS"、"HH:MM:SS" 格式或毫秒数的时长，返回毫秒
+func parseImportDuration(s string) int64 {
+	if !strings.Contains(s, ":") {
+		return ParseCount(s)
+	}
+
+	var seconds int64
+	for _, part := range strings.Split(s, ":") {
+		n, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds * 1000
+}
+
+// parseImportSize 解析 "10.5 MB" 格式或字节数的文件大小，返回字节数
+func parseImportSize(s string) int64 {
+	s = strings.ToUpper(strings.ReplaceAll(s, " ", ""))
+	if s == "" {
+		return 0
+	}
+
+	multiplier := 1.0
+	for i, unit := range []string{"KB", "MB", "GB", "TB"} {
+		if strings.HasSuffix(s, unit) {
+			multiplier = float64(int64(1) << (10 * (i + 1)))
+			s = strings.TrimSuffix(s, unit)
+			break
+		}
+	}
+	s = strings.TrimSuffix(s, "B")
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return int64(n * multiplier)
+}
+
+// parseImportCount 解析互动数，支持 "1.2万"、"1.2w" 格式
+func parseImportCount(s string) int64 {
+	s = strings.ReplaceAll(s, ",", "")
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(s, "万"):
+		multiplier, s = 1e4, strings.TrimSuffix(s, "万")
+	case strings.HasSuffix(s, "w"), strings.HasSuffix(s, "W"):
+		multiplier, s = 1e4, s[:len(s)-1]
+	case strings.HasSuffix(s, "亿"):
+		multiplier, s = 1e8, strings.TrimSuffix(s, "亿")
+	}
+	if multiplier == 1 {
+		return ParseCount(s)
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return int64(n * multiplier)
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"wx_channel/internal/database"
+	"wx_channel/internal/models"
+)
+
+// legacyRecordsMigratedSuffix 旧版 download_records.csv 迁移完成后追加的文件名后缀
+const legacyRecordsMigratedSuffix = ".migrated"
+
+// legacyDuplicateWindow 旧版同一次下载会同时写入 CSV 和数据库（记录 ID 不同，两边的时间可能分别取自下载开始和完成），
+// 数据库中同一视频的记录下载时间与 CSV 相差在此范围内时视为同一次下载
+const legacyDuplicateWindow = 24 * time.Hour
+
+// ParseLegacyDownloadRecordsFromCSV 从旧版 download_records.csv 数据解析下载记录
+func ParseLegacyDownloadRecordsFromCSV(data []byte) ([]database.DownloadRecord, error) {
+	rows, err := readCSV(data)
+	if err != nil {
+		return nil, err
+	}
+	return parseLegacyDownloadRows(rows), nil
+}
+
+// parseLegacyDownloadRows 按 models.VideoDownloadRecord 的CSV行格式解析旧版记录
+// 跳过表头等不是记录的行；同一 ID 出现多次时保留第一条，与旧版 CSVManager 的去重方式一致
+func parseLegacyDownloadRows(rows [][]string) []database.DownloadRecord {
+	records := make([]database.DownloadRecord, 0, len(rows))
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		var legacy models.VideoDownloadRecord
+		if !legacy.FromCSVRow(row) || legacy.ID == "" || seen[legacy.ID] {
+			continue
+		}
+		seen[legacy.ID] = true
+		records = append(records, downloadRecordFromLegacy(&legacy))
+	}
+	return records
+}
+
+// downloadRecordFromLegacy 将旧版下载记录转换为数据库记录，旧版记录均为已完成的下载
+func downloadRecordFromLegacy(legacy *models.VideoDownloadRecord) database.DownloadRecord {
+	return database.DownloadRecord{
+		ID:           legacy.ID,
+		VideoID:      legacy.ID,
+		Title:        legacy.Title,
+		Author:       legacy.Author,
+		Duration:     parseImportDuration(legacy.Duration),
+		FileSize:     parseImportSize(legacy.FileSize),
+		Status:       database.DownloadStatusCompleted,
+		DownloadTime: legacy.DownloadAt,
+		LikeCount:    parseImportCount(legacy.LikeCount),
+		CommentCount: parseImportCount(legacy.CommentCount),
+		ForwardCount: parseImportCount(legacy.ForwardCount),
+		FavCount:     parseImportCount(legacy.FavCount),
+	}
+}
+
+// legacyRecordFromDownload 将数据库记录转换为旧版下载记录，用于生成旧版格式的 CSV
+func legacyRecordFromDownload(record *database.DownloadRecord) *models.VideoDownloadRecord {
+	legacy := &models.VideoDownloadRecord{
+		ID:           record.ID,
+		Title:        record.Title,
+		Author:       record.Author,
+		LikeCount:    strconv.FormatInt(record.LikeCount, 10),
+		CommentCount: strconv.FormatInt(record.CommentCount, 10),
+		FavCount:     strconv.FormatInt(record.FavCount, 10),
+		ForwardCount: strconv.FormatInt(record.ForwardCount, 10),
+		DownloadAt:   record.DownloadTime.Local(),
+	}
+	if record.FileSize > 0 {
+		legacy.FileSize = formatFileSize(record.FileSize)
+	}
+	if record.Duration > 0 {
+		legacy.Duration = formatDuration(record.Duration)
+	}
+	return legacy
+}
+
+// exportDownloadRecordsToLegacyCSV 将下载记录导出为旧版 download_records.csv 格式
+func exportDownloadRecordsToLegacyCSV(records []database.DownloadRecord) ([]byte, error) {
+	var buf bytes.Buffer
+	// 写入 UTF-8 BOM 以兼容 Excel
+	buf.Write([]byte{0xEF, 0xBB, 0xBF})
+
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(models.VideoDownloadRecordCSVHeader); err != nil {
+		return nil, fmt.Errorf("failed to write CSV header: %w", err)
+	}
+	for i := range records {
+		if err := writer.Write(legacyRecordFromDownload(&records[i]).ToCSVRow()); err != nil {
+			return nil, fmt.Errorf("failed to write CSV row: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, fmt.Errorf("failed to flush CSV writer: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// MigrateLegacyDownloadRecords 将旧版 download_records.csv 一次性导入数据库
+// 数据库中已存在的记录（相同 ID，或同一视频在相近时间的下载）保持不变；全部导入成功后文件重命名为 *.migrated，之后不再读取
+// 文件不存在（未使用过旧版或已迁移）时返回 nil
+func (s *ImportService) MigrateLegacyDownloadRecords(csvPath string) (*ImportReport, error) {
+	data, err := os.ReadFile(csvPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read legacy download records: %w", err)
+	}
+
+	records, err := ParseLegacyDownloadRecordsFromCSV(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse legacy download records: %w", err)
+	}
+
+	report := &ImportReport{
+		Kind:     ImportKindDownloads,
+		Format:   ExportFormatCSV,
+		Legacy:   true,
+		Strategy: ImportStrategySkip,
+		Total:    len(records),
+	}
+	for i := range records {
+		recorded, err := s.isRecordedLegacyDownload(&records[i])
+		if err != nil {
+			report.fail("record %d (%s): %v", i+1, records[i].ID, err)
+			continue
+		}
+		if recorded {
+			report.Skipped++
+			continue
+		}
+		s.importDownloadRecord(&records[i], i+1, ImportStrategySkip, report)
+	}
+	if report.Failed > 0 {
+		// 保留文件，下次启动时重试（已导入的记录会被跳过）
+		return report, fmt.Errorf("failed to migrate %d legacy download records", report.Failed)
+	}
+
+	migratedPath := csvPath + legacyRecordsMigratedSuffix
+	if _, err := os.Stat(migratedPath); err == nil {
+		migratedPath = fmt.Sprintf("%s.%s%s", csvPath, time.Now().Format("20060102_150405"), legacyRecordsMigratedSuffix)
+	}
+	if err := os.Rename(csvPath, migratedPath); err != nil {
+		return report, fmt.Errorf("failed to rename legacy download records: %w", err)
+	}
+	return report, nil
+}
+
+// isRecordedLegacyDownload 数据库中是否已有与旧版记录对应的同一次下载
+// 旧版记录的 ID 就是视频 ID，而数据库中的下载记录使用独立的 ID，因此按视频 ID 和下载时间比较
+func (s *ImportService) isRecordedLegacyDownload(record *database.DownloadRecord) (bool, error) {
+	existing, err := s.downloadRepo.FindByVideoID(record.VideoID)
+	if err != nil {
+		return false, err
+	}
+	for i := range existing {
+		// 旧版记录的时间无法解析时，同一视频已有记录即视为已存在
+		if record.DownloadTime.IsZero() {
+			return true, nil
+		}
+		diff := existing[i].DownloadTime.Sub(record.DownloadTime)
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff <= legacyDuplicateWindow {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"wx_channel/internal/database"
+	"wx_channel/internal/models"
+)
+
+// writeLegacyCSV 按旧版 download_records.csv 格式写入记录
+func writeLegacyCSV(t *testing.T, path string, records []models.VideoDownloadRecord) {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(models.VideoDownloadRecordCSVHeader)
+	for i := range records {
+		writer.Write(records[i].ToCSVRow())
+	}
+	writer.Flush()
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("failed to write legacy csv: %v", err)
+	}
+}
+
+func TestMigrateLegacyDownloadRecords_SkipsRecordedDownloads(t *testing.T) {
+	dir := t.TempDir()
+	if err := database.Initialize(&database.Config{DBPath: filepath.Join(dir, "records.db")}); err != nil {
+		t.Fatalf("failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	downloadTime := time.Date(2024, 3, 5, 10, 0, 0, 0, time.Local)
+	repo := database.NewDownloadRecordRepository()
+	existing := []database.DownloadRecord{
+		// 旧版同一次下载同时写入的数据库记录，ID 与 CSV 不同
+		{ID: "db-1", VideoID: "video-1", Title: "已记录", Status: database.DownloadStatusCompleted, DownloadTime: downloadTime.Add(3 * time.Minute)},
+		// 同一视频很早之前的另一次下载
+		{ID: "db-3", VideoID: "video-3", Title: "旧下载", Status: database.DownloadStatusCompleted, DownloadTime: downloadTime.AddDate(0, -1, 0)},
+	}
+	for i := range existing {
+		if err := repo.Create(&existing[i]); err != nil {
+			t.Fatalf("failed to create record: %v", err)
+		}
+	}
+
+	csvPath := filepath.Join(dir, "download_records.csv")
+	legacy := []models.VideoDownloadRecord{
+		{ID: "video-1", Title: "已记录", DownloadAt: downloadTime},
+		{ID: "video-2", Title: "只在 CSV 中", DownloadAt: downloadTime},
+		{ID: "video-3", Title: "再次下载", DownloadAt: downloadTime},
+	}
+	writeLegacyCSV(t, csvPath, legacy)
+
+	service := NewImportService()
+	report, err := service.MigrateLegacyDownloadRecords(csvPath)
+	if err != nil {
+		t.Fatalf("MigrateLegacyDownloadRecords error: %v", err)
+	}
+	if report.Total != 3 || report.Created != 2 || report.Skipped != 1 || report.Failed != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	for videoID, want := range map[string]int{"video-1": 1, "video-2": 1, "video-3": 2} {
+		records, err := repo.FindByVideoID(videoID)
+		if err != nil {
+			t.Fatalf("FindByVideoID error: %v", err)
+		}
+		if len(records) != want {
+			t.Fatalf("expected %d records for %s, got %d", want, videoID, len(records))
+		}
+	}
+	if _, err := os.Stat(csvPath + legacyRecordsMigratedSuffix); err != nil {
+		t.Fatalf("expected legacy csv to be renamed: %v", err)
+	}
+
+	// 再次迁移同样的记录时全部跳过
+	writeLegacyCSV(t, csvPath, legacy)
+	report, err = service.MigrateLegacyDownloadRecords(csvPath)
+	if err != nil {
+		t.Fatalf("second MigrateLegacyDownloadRecords error: %v", err)
+	}
+	if report.Created != 0 || report.Skipped != 3 {
+		t.Fatalf("expected all records to be skipped, got %+v", report)
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"wx_channel/internal/database"
+	"wx_channel/internal/utils"
+	"wx_channel/pkg/mp4"
+)
+
+const (
+	// coverFetchTimeout 下载封面图片的超时时间
+	coverFetchTimeout = 30 * time.Second
+	// coverMaxSize 写入 MP4 的封面图片大小上限
+	coverMaxSize = 5 * 1024 * 1024
+)
+
+// MetadataEmbedder 将标题、作者、简介、日期和封面写入下载完成的 MP4 文件
+type MetadataEmbedder struct {
+	client *http.Client
+}
+
+// NewMetadataEmbedder 创建一个新的 MetadataEmbedder
+func NewMetadataEmbedder() *MetadataEmbedder {
+	return &MetadataEmbedder{
+		client: &http.Client{Timeout: coverFetchTimeout},
+	}
+}
+
+// Embed 将队列项目的元数据写入 MP4 文件，返回写入后文件内容的 SHA-256（写入时计算）
+// 封面下载失败时仍写入文字元数据
+func (e *MetadataEmbedder) Embed(ctx context.Context, path string, item *database.QueueItem) (string, error) {
+	meta := MP4MetadataFromQueueItem(item)
+
+	if item.CoverURL != "" {
+		cover, err := e.fetchCover(ctx, item.CoverURL)
+		if err != nil {
+			utils.Warn("[MetadataEmbedder] Failed to fetch cover for %s: %v", item.Title, err)
+		} else {
+			meta.Cover = cover
+		}
+	}
+
+	contentHash, err := mp4.WriteMetadataHash(path, meta)
+	if err != nil {
+		return "", fmt.Errorf("failed to write mp4 metadata: %w", err)
+	}
+	return contentHash, nil
+}
+
+// fetchCover 下载封面图片
+func (e *MetadataEmbedder) fetchCover(ctx context.Context, coverURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, coverURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, coverMaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > coverMaxSize {
+		return nil, fmt.Errorf("cover exceeds %d bytes", coverMaxSize)
+	}
+	return data, nil
+}
+
+// MP4MetadataFromQueueItem 从队列项目构建 MP4 元数据
+// 视频号的标题即视频描述：标题取第一行，完整描述写入注释
+// 日期取视频的发布时间，未知时不写入（加入队列的时间不是视频的日期）
+func MP4MetadataFromQueueItem(item *database.QueueItem) *mp4.Metadata {
+	description := strings.TrimSpace(item.Title)
+	title := description
+	if i := strings.IndexAny(title, "\r\n"); i >= 0 {
+		title = strings.TrimSpace(title[:i])
+	}
+
+	meta := &mp4.Metadata{
+		Title:   title,
+		Artist:  item.Author,
+		Comment: description,
+	}
+	createTime := formatCreateTime(item.BatchStats.CreateTime)
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", createTime, time.Local); err == nil {
+		meta.Date = t.Format("2006-01-02")
+	}
+	return meta
+}
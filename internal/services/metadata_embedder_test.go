@@ -0,0 +1,42 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"wx_channel/internal/database"
+)
+
+func TestMP4MetadataFromQueueItem_Date(t *testing.T) {
+	published := time.Unix(1709641800, 0).Format("2006-01-02")
+
+	tests := []struct {
+		name       string
+		createTime string
+		want       string
+	}{
+		{name: "unix seconds", createTime: "1709641800", want: published},
+		{name: "unix milliseconds", createTime: "1709641800000", want: published},
+		{name: "formatted time", createTime: "2024-03-05 20:30:00", want: "2024-03-05"},
+		{name: "unknown", createTime: "", want: ""},
+		{name: "unparseable", createTime: "昨天", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 加入队列的时间不作为视频日期
+			item := &database.QueueItem{
+				Title:      "标题\n完整描述",
+				AddedTime:  time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local),
+				BatchStats: database.BatchStats{CreateTime: tt.createTime},
+			}
+			meta := MP4MetadataFromQueueItem(item)
+			if meta.Date != tt.want {
+				t.Fatalf("expected date %q, got %q", tt.want, meta.Date)
+			}
+			if meta.Title != "标题" {
+				t.Fatalf("expected first line as title, got %q", meta.Title)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"wx_channel/internal/database"
+	"wx_channel/internal/utils"
+)
+
+// NamingService 按用户设置的文件名模板生成视频保存路径
+type NamingService struct{}
+
+// NewNamingService 创建一个新的 NamingService
+func NewNamingService() *NamingService {
+	return &NamingService{}
+}
+
+// settings 读取当前设置，数据库不可用时使用默认设置
+func (s *NamingService) settings() *database.Settings {
+	if database.GetDB() == nil {
+		return database.DefaultSettings()
+	}
+	settings, err := database.NewSettingsRepository().Load()
+	if err != nil {
+		return database.DefaultSettings()
+	}
+	return settings
+}
+
+// Enabled 检查是否配置了文件名模板，未配置时各下载路径沿用原有的 作者/标题 布局
+func (s *NamingService) Enabled() bool {
+	return s.settings().FilenameTemplate != ""
+}
+
+// Render 按当前模板生成相对保存路径（使用 / 分隔）
+func (s *NamingService) Render(fields utils.FilenameFields) (string, error) {
+	return s.Preview("", fields)
+}
+
+// Preview 使用指定模板生成相对保存路径，模板为空时使用当前设置的模板
+func (s *NamingService) Preview(tmpl string, fields utils.FilenameFields) (string, error) {
+	settings := s.settings()
+	if tmpl == "" {
+		tmpl = settings.FilenameTemplate
+	}
+	if tmpl == "" {
+		// 默认布局：作者/标题.mp4
+		tmpl = "{author}/{title}.{ext}"
+	}
+	return utils.RenderFilenameTemplate(tmpl, fields, settings.FilenameMaxLength)
+}
+
+// ResolvePath 按当前模板生成 downloadsDir 下的保存路径，并创建所需的目录
+func (s *NamingService) ResolvePath(downloadsDir string, fields utils.FilenameFields) (string, error) {
+	relPath, err := s.Render(fields)
+	if err != nil {
+		return "", err
+	}
+
+	fullPath := filepath.Join(downloadsDir, filepath.FromSlash(relPath))
+	if err := utils.EnsureDir(filepath.Dir(fullPath)); err != nil {
+		return "", fmt.Errorf("failed to create download directory: %w", err)
+	}
+	return fullPath, nil
+}
+
+// PageSource 从页面地址中提取页面来源，例如 .../web/pages/feed → feed
+func PageSource(pageURL string) string {
+	if pageURL == "" {
+		return ""
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+	source := path.Base(strings.TrimSuffix(u.Path, "/"))
+	if source == "." || source == "/" {
+		return ""
+	}
+	return source
+}
+
+// FilenameFieldsFromQueueItem 从队列项目构建文件名模板字段
+func FilenameFieldsFromQueueItem(item *database.QueueItem) utils.FilenameFields {
+	source := "queue"
+	if item.PageSource != "" {
+		source = item.PageSource
+	}
+	return utils.FilenameFields{
+		Title:        item.Title,
+		Author:       item.Author,
+		VideoID:      item.VideoID,
+		Resolution:   item.Resolution,
+		Source:       source,
+		Time:         item.AddedTime,
+		LikeCount:    ParseCount(item.BatchStats.LikeCount),
+		CommentCount: ParseCount(item.BatchStats.CommentCount),
+		ForwardCount: ParseCount(item.BatchStats.ForwardCount),
+		FavCount:     ParseCount(item.BatchStats.FavCount),
+	}
+}
+
+// FilenameFieldsFromBrowseRecord 从浏览记录构建文件名模板字段
+func FilenameFieldsFromBrowseRecord(record *database.BrowseRecord) utils.FilenameFields {
+	return utils.FilenameFields{
+		Title:        record.Title,
+		Author:       record.Author,
+		AuthorID:     record.AuthorID,
+		VideoID:      record.ID,
+		Resolution:   record.Resolution,
+		Source:       PageSource(record.PageURL),
+		Time:         record.BrowseTime,
+		LikeCount:    record.LikeCount,
+		CommentCount: record.CommentCount,
+		ForwardCount: record.ForwardCount,
+		FavCount:     record.FavCount,
+	}
+}
+
+// FilenameFieldsFromDownloadRecord 从下载记录构建文件名模板字段
+func FilenameFieldsFromDownloadRecord(record *database.DownloadRecord) utils.FilenameFields {
+	ext := record.Format
+	if ext == "" {
+		ext = "mp4"
+	}
+	return utils.FilenameFields{
+		Title:        record.Title,
+		Author:       record.Author,
+		VideoID:      record.VideoID,
+		Resolution:   record.Resolution,
+		Ext:          ext,
+		Time:         record.DownloadTime,
+		LikeCount:    record.LikeCount,
+		CommentCount: record.CommentCount,
+		ForwardCount: record.ForwardCount,
+		FavCount:     record.FavCount,
+	}
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"wx_channel/internal/database"
+	"wx_channel/internal/utils"
+)
+
+// defaultScheduleInterval 调度器轮询队列的默认间隔
+const defaultScheduleInterval = 2 * time.Second
+
+// QueueScheduler 持续调度下载队列，自动驱动 ChunkedDownloader
+// 按优先级提升待处理项目、遵守并发限制，并在启动时恢复中断的下载
+type QueueScheduler struct {
+	queueService *QueueService
+	downloader   *ChunkedDownloader
+	settings     *database.SettingsRepository
+	diskGuard    *DiskGuard
+	interval     time.Duration
+
+	mu       sync.Mutex
+	onUpdate func(item *database.QueueItem)
+	running  bool
+	wakeup   chan struct{}
+	stopChan chan struct{}
+}
+
+// NewQueueScheduler 创建一个新的 QueueScheduler
+func NewQueueScheduler(queueService *QueueService, downloader *ChunkedDownloader) *QueueScheduler {
+	s := &QueueScheduler{
+		queueService: queueService,
+		downloader:   downloader,
+		settings:     database.NewSettingsRepository(),
+		diskGuard:    NewDiskGuard(),
+		interval:     defaultScheduleInterval,
+		wakeup:       make(chan struct{}, 1),
+		stopChan:     make(chan struct{}),
+	}
+
+	// 下载结束后广播最新状态并立即调度下一个项目
+	downloader.SetOnFinish(func(itemID string) {
+		s.broadcast(itemID)
+		s.Notify()
+	})
+
+	return s
+}
+
+// SetUpdateCallback 设置队列项目状态变化时的回调（用于 WebSocket 广播）
+func (s *QueueScheduler) SetUpdateCallback(fn func(item *database.QueueItem)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onUpdate = fn
+}
+
+// Start 恢复中断的下载并启动调度循环
+func (s *QueueScheduler) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	s.recoverInterrupted()
+	go s.loop()
+}
+
+// Stop 停止调度循环并取消所有活动下载
+// 活动项目在数据库中保持 downloading 状态，下次启动时自动恢复
+func (s *QueueScheduler) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	s.mu.Unlock()
+
+	close(s.stopChan)
+	for _, id := range s.downloader.GetActiveDownloads() {
+		_ = s.downloader.CancelDownload(id)
+	}
+}
+
+// Notify 唤醒调度器立即执行一次调度
+func (s *QueueScheduler) Notify() {
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// loop 调度主循环
+func (s *QueueScheduler) loop() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.schedule()
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+		case <-s.wakeup:
+		}
+		s.schedule()
+	}
+}
+
+// recoverInterrupted 将上次运行中断的 downloading 项目重置为 pending
+// 分片位图保留在队列中，重新调度时只下载缺失的分片
+func (s *QueueScheduler) recoverInterrupted() {
+	items, err := s.queueService.GetByStatus(database.QueueStatusDownloading)
+	if err != nil {
+		utils.Warn("[QueueScheduler] Failed to load interrupted downloads: %v", err)
+		return
+	}
+
+	for _, item := range items {
+		if s.downloader.IsActive(item.ID) {
+			continue
+		}
+		if err := s.queueService.UpdateStatus(item.ID, database.QueueStatusPending); err != nil {
+			utils.Warn("[QueueScheduler] Failed to recover %s: %v", item.ID, err)
+			continue
+		}
+		utils.Info("[QueueScheduler] Recovered interrupted download: %s", item.Title)
+		s.broadcast(item.ID)
+	}
+}
+
+// schedule 同步活动下载与队列状态，并按优先级填充空闲槽位
+func (s *QueueScheduler) schedule() {
+	s.reconcile()
+
+	settings, err := s.settings.Load()
+	if err != nil {
+		settings = database.DefaultSettings()
+	}
+
+	// 时间窗口外暂停所有活动下载，窗口内恢复被窗口暂停的项目
+	if !settings.InDownloadWindow(time.Now()) {
+		s.pauseForSchedule()
+		return
+	}
+	s.resumeFromSchedule()
+
+	// 磁盘空间低于阈值或下载库超出容量时暂停队列，空间恢复后自动继续
+	if status := s.diskGuard.Status(); status.Blocked() {
+		s.pauseForDiskSpace(status)
+		return
+	}
+	s.resumeFromDiskSpace()
+
+	slots := settings.ConcurrentLimit - len(s.downloader.GetActiveDownloads())
+	if slots <= 0 {
+		return
+	}
+
+	// ListByStatus 已按 priority DESC, added_time ASC 排序
+	pending, err := s.queueService.GetByStatus(database.QueueStatusPending)
+	if err != nil {
+		utils.Warn("[QueueScheduler] Failed to load pending items: %v", err)
+		return
+	}
+
+	for i := range pending {
+		if slots <= 0 {
+			break
+		}
+		item := pending[i]
+		if s.downloader.IsActive(item.ID) {
+			continue
+		}
+		if err := s.downloader.StartDownload(&item); err != nil {
+			utils.Warn("[QueueScheduler] Failed to start %s: %v", item.ID, err)
+			continue
+		}
+		slots--
+
+		// 数据库状态由下载协程异步更新，这里直接广播 downloading
+		started := pending[i]
+		started.Status = database.QueueStatusDownloading
+		s.emit(&started)
+	}
+}
+
+// pauseForSchedule 暂停所有活动下载并标记为时间窗口暂停
+func (s *QueueScheduler) pauseForSchedule() {
+	for _, id := range s.downloader.GetActiveDownloads() {
+		if err := s.downloader.CancelDownload(id); err != nil {
+			continue
+		}
+		if err := s.queueService.PauseForSchedule(id); err != nil {
+			utils.Warn("[QueueScheduler] Failed to pause %s for schedule: %v", id, err)
+			continue
+		}
+		utils.Info("[QueueScheduler] Paused download %s outside schedule window", id)
+		s.broadcast(id)
+	}
+}
+
+// resumeFromSchedule 恢复因时间窗口暂停的项目
+func (s *QueueScheduler) resumeFromSchedule() {
+	resumed, err := s.queueService.ResumeScheduled()
+	if err != nil {
+		utils.Warn("[QueueScheduler] Failed to resume scheduled items: %v", err)
+	}
+	for i := range resumed {
+		utils.Info("[QueueScheduler] Resumed download %s inside schedule window", resumed[i].ID)
+		s.emit(&resumed[i])
+	}
+}
+
+// pauseForDiskSpace 暂停所有活动下载并标记为磁盘空间暂停
+func (s *QueueScheduler) pauseForDiskSpace(status *DiskStatus) {
+	paused := 0
+	defer func() {
+		if paused > 0 && status.Total > 0 {
+			utils.LogDiskSpace(status.Path, float64(status.Available)/(1<<30), float64(status.Total)/(1<<30))
+		}
+	}()
+
+	for _, id := range s.downloader.GetActiveDownloads() {
+		if err := s.downloader.CancelDownload(id); err != nil {
+			continue
+		}
+		if err := s.queueService.PauseForDiskSpace(id); err != nil {
+			utils.Warn("[QueueScheduler] Failed to pause %s for disk space: %v", id, err)
+			continue
+		}
+		utils.Warn("[QueueScheduler] Paused download %s: %s available (min %s), library %s (max %s)", id,
+			formatBytes(status.Available), formatBytes(status.MinFreeSpace),
+			formatBytes(status.LibrarySize), formatBytes(status.MaxLibrarySize))
+		paused++
+		s.broadcast(id)
+	}
+}
+
+// resumeFromDiskSpace 恢复因磁盘空间暂停、且现在空间足够的项目
+func (s *QueueScheduler) resumeFromDiskSpace() {
+	root := s.downloader.DownloadRoot()
+	resumed, err := s.queueService.ResumeDiskPaused(func(item *database.QueueItem) bool {
+		return s.diskGuard.CheckDownload(root, item.TotalSize-item.DownloadedSize) == nil
+	})
+	if err != nil {
+		utils.Warn("[QueueScheduler] Failed to resume disk paused items: %v", err)
+	}
+	for i := range resumed {
+		utils.Info("[QueueScheduler] Resumed download %s after disk space recovered", resumed[i].ID)
+		s.emit(&resumed[i])
+	}
+}
+
+// reconcile 同步活动下载与队列状态：取消队列中已不再处于 downloading 状态的活动下载（例如被用户暂停或移除），
+// 并将没有活动下载的 downloading 项目重置为 pending，重新调度时只下载缺失的分片
+func (s *QueueScheduler) reconcile() {
+	downloading, err := s.queueService.GetByStatus(database.QueueStatusDownloading)
+	if err != nil {
+		utils.Warn("[QueueScheduler] Failed to load downloading items: %v", err)
+	}
+	for _, item := range downloading {
+		if s.downloader.IsActive(item.ID) {
+			continue
+		}
+		if reset, err := s.queueService.ResetStalled(item.ID); err != nil {
+			utils.Warn("[QueueScheduler] Failed to reset stalled download %s: %v", item.ID, err)
+		} else if reset {
+			utils.Info("[QueueScheduler] Reset stalled download: %s", item.Title)
+			s.broadcast(item.ID)
+		}
+	}
+
+	for _, id := range s.downloader.GetActiveDownloads() {
+		item, err := s.queueService.GetByID(id)
+		if err != nil {
+			continue
+		}
+		if item != nil && (item.Status == database.QueueStatusDownloading || item.Status == database.QueueStatusPending) {
+			continue
+		}
+		if err := s.downloader.CancelDownload(id); err == nil {
+			utils.Info("[QueueScheduler] Stopped download %s", id)
+		}
+	}
+}
+
+// broadcast 读取项目最新状态并广播
+func (s *QueueScheduler) broadcast(itemID string) {
+	item, err := s.queueService.GetByID(itemID)
+	if err != nil || item == nil {
+		return
+	}
+	s.emit(item)
+}
+
+// emit 调用更新回调
+func (s *QueueScheduler) emit(item *database.QueueItem) {
+	s.mu.Lock()
+	fn := s.onUpdate
+	s.mu.Unlock()
+	if fn != nil {
+		fn(item)
+	}
+}
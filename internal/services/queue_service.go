@@ -2,7 +2,9 @@ package services
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -32,14 +34,23 @@ func NewQueueService() *QueueService {
 // VideoInfo 表示要添加到队列的视频信息
 type VideoInfo struct {
 	VideoID    string `json:"videoId"`
+	NonceID    string `json:"nonceId"`
 	Title      string `json:"title"`
 	Author     string `json:"author"`
+	AuthorID   string `json:"authorId,omitempty"` // 作者 ID（视频号 username）
 	CoverURL   string `json:"coverUrl"`
 	VideoURL   string `json:"videoUrl"`
 	DecryptKey string `json:"decryptKey"`
 	Duration   int64  `json:"duration"`
 	Resolution string `json:"resolution"`
 	Size       int64  `json:"size"`
+	// EmbedMetadata 下载完成后是否将元数据写入 MP4 文件，未指定时使用设置中的默认值
+	EmbedMetadata *bool `json:"embedMetadata,omitempty"`
+	// 以下字段用于批量下载提交的视频
+	PageSource      string              `json:"pageSource,omitempty"`
+	DecryptorPrefix string              `json:"decryptorPrefix,omitempty"`
+	PrefixLen       int                 `json:"prefixLen,omitempty"`
+	BatchStats      database.BatchStats `json:"batchStats"`
 }
 
 // AddToQueue 将视频添加到下载队列
@@ -47,6 +58,31 @@ func (s *QueueService) AddToQueue(videos []VideoInfo) ([]database.QueueItem, err
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	return s.addVideos(videos, "", true)
+}
+
+// AddBatch 将批量下载提交的视频作为一个新批次添加到队列，并移除上一批次的项目
+// forceRedownload 为 false 时，已下载过且文件仍存在的视频直接标记为完成；replaced 为移除的上一批次项目数
+func (s *QueueService) AddBatch(videos []VideoInfo, forceRedownload bool) (batchID string, items []database.QueueItem, replaced int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 上一批次中仍在下载的项目由调度器在同步队列状态时取消
+	replaced, err = s.repo.RemoveBatch()
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	batchID = uuid.New().String()
+	items, err = s.addVideos(videos, batchID, forceRedownload)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	return batchID, items, replaced, nil
+}
+
+// addVideos 创建队列项目（调用方需持有锁），batchID 为空表示普通队列项目
+func (s *QueueService) addVideos(videos []VideoInfo, batchID string, forceRedownload bool) ([]database.QueueItem, error) {
 	// 加载设置以获取分片大小
 	settings, err := s.settings.Load()
 	if err != nil {
@@ -73,11 +109,18 @@ func (s *QueueService) AddToQueue(videos []VideoInfo) ([]database.QueueItem, err
 		chunkSize := settings.ChunkSize
 		chunksTotal := CalculateChunkCount(video.Size, chunkSize)
 
+		embedMetadata := settings.EmbedMetadata
+		if video.EmbedMetadata != nil {
+			embedMetadata = *video.EmbedMetadata
+		}
+
 		item := &database.QueueItem{
 			ID:              uuid.New().String(),
 			VideoID:         video.VideoID,
+			NonceID:         video.NonceID,
 			Title:           video.Title,
 			Author:          video.Author,
+			AuthorID:        video.AuthorID,
 			CoverURL:        video.CoverURL,
 			VideoURL:        video.VideoURL,
 			DecryptKey:      video.DecryptKey,
@@ -92,18 +135,128 @@ func (s *QueueService) AddToQueue(videos []VideoInfo) ([]database.QueueItem, err
 			ChunkSize:       chunkSize,
 			ChunksTotal:     chunksTotal,
 			ChunksCompleted: 0,
+			EmbedMetadata:   embedMetadata,
 			RetryCount:      0,
+			BatchID:         batchID,
+			PageSource:      video.PageSource,
+			DecryptorPrefix: video.DecryptorPrefix,
+			PrefixLen:       video.PrefixLen,
+			BatchStats:      video.BatchStats,
+		}
+
+		var existingPath string
+		var recorded bool
+		if !forceRedownload {
+			existingPath, recorded = existingDownloadPath(item)
+		}
+		if existingPath != "" {
+			markCompleted(item, existingPath)
 		}
 
 		if err := s.repo.Add(item); err != nil {
 			return nil, fmt.Errorf("failed to add item to queue: %w", err)
 		}
+		if existingPath != "" {
+			utils.Info("[Queue] %s has already been downloaded, skipping: %s", item.Title, existingPath)
+			// 文件已存在但没有下载记录时补建记录
+			if !recorded {
+				createDownloadRecord(item, existingPath, "")
+			}
+		}
 		addedItems = append(addedItems, *item)
 	}
 
 	return addedItems, nil
 }
 
+// existingDownloadPath 返回视频已下载的文件路径：下载记录中的文件仍存在，或预期保存路径已有文件
+// recorded 表示该视频已有下载记录
+func existingDownloadPath(item *database.QueueItem) (filePath string, recorded bool) {
+	if item.VideoID != "" {
+		records, err := database.NewDownloadRecordRepository().FindByVideoID(item.VideoID)
+		if err == nil {
+			recorded = len(records) > 0
+			for _, record := range records {
+				if record.Status != database.DownloadStatusCompleted || record.FilePath == "" {
+					continue
+				}
+				if _, err := os.Stat(record.FilePath); err == nil {
+					return record.FilePath, true
+				}
+			}
+		}
+	}
+
+	filePath = calculateDownloadFilePath(item)
+	if _, err := os.Stat(filePath); err == nil {
+		return filePath, recorded
+	}
+	return "", recorded
+}
+
+// ListBatch 返回当前批次的队列项目（按提交顺序）
+func (s *QueueService) ListBatch() ([]database.QueueItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.repo.ListBatch()
+}
+
+// ClearBatch 从队列中移除当前批次的所有项目，返回移除的数量
+func (s *QueueService) ClearBatch() (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.repo.RemoveBatch()
+}
+
+// PauseBatch 暂停当前批次中等待和正在下载的项目，返回暂停的数量
+// 正在下载的项目由调度器在同步队列状态时取消，已下载的分片保留用于断点续传
+func (s *QueueService) PauseBatch() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items, err := s.repo.ListBatch()
+	if err != nil {
+		return 0, err
+	}
+
+	paused := 0
+	for _, item := range items {
+		if item.Status != database.QueueStatusPending && item.Status != database.QueueStatusDownloading {
+			continue
+		}
+		if err := s.repo.UpdateStatusWithReason(item.ID, database.QueueStatusPaused, database.PauseReasonUser); err != nil {
+			return paused, err
+		}
+		paused++
+	}
+	return paused, nil
+}
+
+// ResumeBatch 恢复当前批次中暂停的项目，返回恢复的数量
+func (s *QueueService) ResumeBatch() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items, err := s.repo.ListBatch()
+	if err != nil {
+		return 0, err
+	}
+
+	resumed := 0
+	for _, item := range items {
+		if item.Status != database.QueueStatusPaused {
+			continue
+		}
+		if err := s.repo.UpdateStatusWithReason(item.ID, database.QueueStatusPending, ""); err != nil {
+			return resumed, err
+		}
+		resumed++
+	}
+	return resumed, nil
+}
+
 // RemoveFromQueue 从队列中移除项目
 // 注意：根据需求 10.5，这不会删除任何部分下载数据
 func (s *QueueService) RemoveFromQueue(id string) error {
@@ -139,7 +292,79 @@ func (s *QueueService) Pause(id string) error {
 		return fmt.Errorf("can only pause downloading items, current status: %s", item.Status)
 	}
 
-	return s.repo.UpdateStatus(id, database.QueueStatusPaused)
+	return s.repo.UpdateStatusWithReason(id, database.QueueStatusPaused, database.PauseReasonUser)
+}
+
+// PauseForSchedule 在下载时间窗口外自动暂停项目
+// 与用户手动暂停区分，窗口重新打开时由 ResumeScheduled 自动恢复
+func (s *QueueService) PauseForSchedule(id string) error {
+	return s.pauseAutomatically(id, database.PauseReasonSchedule)
+}
+
+// PauseForDiskSpace 在磁盘空间不足或超出下载库容量时自动暂停项目
+// 空间恢复后由 ResumeDiskPaused 自动恢复
+func (s *QueueService) PauseForDiskSpace(id string) error {
+	return s.pauseAutomatically(id, database.PauseReasonDisk)
+}
+
+// pauseAutomatically 以指定原因暂停下载中或待处理的项目
+func (s *QueueService) pauseAutomatically(id, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		return fmt.Errorf("queue item not found: %s", id)
+	}
+
+	if item.Status != database.QueueStatusDownloading && item.Status != database.QueueStatusPending {
+		return fmt.Errorf("can only pause downloading or pending items, current status: %s", item.Status)
+	}
+
+	return s.repo.UpdateStatusWithReason(id, database.QueueStatusPaused, reason)
+}
+
+// ResumeScheduled 恢复所有因时间窗口而暂停的项目，返回被恢复的项目
+// 用户手动暂停的项目不受影响
+func (s *QueueService) ResumeScheduled() ([]database.QueueItem, error) {
+	return s.resumePaused(database.PauseReasonSchedule, nil)
+}
+
+// ResumeDiskPaused 恢复因磁盘空间不足而暂停、且 fits 判断现在可以容纳的项目，返回被恢复的项目
+func (s *QueueService) ResumeDiskPaused(fits func(item *database.QueueItem) bool) ([]database.QueueItem, error) {
+	return s.resumePaused(database.PauseReasonDisk, fits)
+}
+
+// resumePaused 恢复以指定原因暂停的项目，accept 不为 nil 时只恢复通过判断的项目
+func (s *QueueService) resumePaused(reason string, accept func(item *database.QueueItem) bool) ([]database.QueueItem, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paused, err := s.repo.ListByStatus(database.QueueStatusPaused)
+	if err != nil {
+		return nil, err
+	}
+
+	resumed := make([]database.QueueItem, 0)
+	for _, item := range paused {
+		if item.PauseReason != reason {
+			continue
+		}
+		if accept != nil && !accept(&item) {
+			continue
+		}
+		if err := s.repo.UpdateStatusWithReason(item.ID, database.QueueStatusPending, ""); err != nil {
+			return resumed, err
+		}
+		item.Status = database.QueueStatusPending
+		item.PauseReason = ""
+		resumed = append(resumed, item)
+	}
+
+	return resumed, nil
 }
 
 // Resume 恢复暂停的项目
@@ -160,7 +385,7 @@ func (s *QueueService) Resume(id string) error {
 		return fmt.Errorf("can only resume paused items, current status: %s", item.Status)
 	}
 
-	return s.repo.UpdateStatus(id, database.QueueStatusPending)
+	return s.repo.UpdateStatusWithReason(id, database.QueueStatusPending, "")
 }
 
 // Reorder 根据提供的 ID 顺序重新排序队列
@@ -188,6 +413,31 @@ func (s *QueueService) SetPriority(id string, priority int) error {
 	return s.repo.Update(item)
 }
 
+// SetSpeedLimit 设置项目的限速（字节/秒），0 表示使用默认单任务限速
+// 对正在下载的项目立即生效
+func (s *QueueService) SetSpeedLimit(id string, bytesPerSecond int64) error {
+	if bytesPerSecond < 0 {
+		return fmt.Errorf("speed limit must not be negative")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.repo.SetSpeedLimit(id, bytesPerSecond); err != nil {
+		return err
+	}
+	GetBandwidthLimiter().SetTaskLimit(id, bytesPerSecond)
+	return nil
+}
+
+// SetEmbedMetadata 设置项目下载完成后是否将元数据写入 MP4 文件
+func (s *QueueService) SetEmbedMetadata(id string, enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.repo.SetEmbedMetadata(id, enabled)
+}
+
 // GetQueue 返回按优先级排序的所有队列项目
 func (s *QueueService) GetQueue() ([]database.QueueItem, error) {
 	s.mu.RLock()
@@ -228,6 +478,22 @@ func (s *QueueService) UpdateProgress(id string, downloadedSize int64, chunksCom
 	return s.repo.UpdateProgress(id, downloadedSize, chunksCompleted, speed)
 }
 
+// UpdateChunkProgress 更新分片完成位图和下载进度
+func (s *QueueService) UpdateChunkProgress(id string, chunksBitmap string, downloadedSize int64, chunksCompleted int, speed int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.repo.UpdateChunkProgress(id, chunksBitmap, downloadedSize, chunksCompleted, speed)
+}
+
+// UpdateVideoSource 更新队列项目的下载地址和解密密钥（地址过期后重新获取时使用）
+func (s *QueueService) UpdateVideoSource(id string, videoURL string, decryptKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.repo.UpdateVideoSource(id, videoURL, decryptKey)
+}
+
 // UpdateStatus 更新队列项目的状态
 func (s *QueueService) UpdateStatus(id string, status string) error {
 	s.mu.Lock()
@@ -236,19 +502,35 @@ func (s *QueueService) UpdateStatus(id string, status string) error {
 	return s.repo.UpdateStatus(id, status)
 }
 
-// StartDownload 标记项目为正在下载并设置开始时间
-func (s *QueueService) StartDownload(id string) error {
+// StartDownload 将仍处于 pending 的项目标记为正在下载并设置开始时间
+// 项目在此之前已被暂停、移除或改为其他状态时不做修改并返回 false
+func (s *QueueService) StartDownload(id string) (bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if err := s.repo.UpdateStatus(id, database.QueueStatusDownloading); err != nil {
-		return err
+	started, err := s.repo.TransitionStatus(id, database.QueueStatusPending, database.QueueStatusDownloading)
+	if err != nil || !started {
+		return false, err
 	}
-	return s.repo.SetStartTime(id, time.Now())
+	return true, s.repo.SetStartTime(id, time.Now())
+}
+
+// ResetStalled 将没有活动下载协程的 downloading 项目重置为 pending，返回是否重置
+func (s *QueueService) ResetStalled(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.repo.TransitionStatus(id, database.QueueStatusDownloading, database.QueueStatusPending)
 }
 
 // CompleteDownload 标记项目为完成并创建下载记录
 func (s *QueueService) CompleteDownload(id string) error {
+	return s.CompleteDownloadWithFile(id, "", "")
+}
+
+// CompleteDownloadWithFile 标记项目为完成并创建下载记录
+// filePath 为实际保存路径（为空时按命名规则计算），contentHash 为下载内容的 SHA-256
+func (s *QueueService) CompleteDownloadWithFile(id, filePath, contentHash string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -266,18 +548,38 @@ func (s *QueueService) CompleteDownload(id string) error {
 		return nil
 	}
 
+	if filePath == "" {
+		filePath = calculateDownloadFilePath(item)
+	}
+	markCompleted(item, filePath)
+	if err := s.repo.Update(item); err != nil {
+		return err
+	}
+
+	createDownloadRecord(item, filePath, contentHash)
+	return nil
+}
+
+// markCompleted 将队列项目的状态和进度设置为已完成，filePath 为文件的实际保存路径
+func markCompleted(item *database.QueueItem, filePath string) {
 	item.Status = database.QueueStatusCompleted
+	item.FilePath = filePath
 	item.DownloadedSize = item.TotalSize
 	item.ChunksCompleted = item.ChunksTotal
 	item.Speed = 0
+}
 
-	if err := s.repo.Update(item); err != nil {
-		return err
-	}
-
+// createDownloadRecord 为已完成的队列项目创建下载记录
+func createDownloadRecord(item *database.QueueItem, filePath, contentHash string) {
 	// 根据批量下载约定计算文件路径
-	// 路径格式: {baseDir}/downloads/{authorFolder}/{cleanFilename}.mp4
-	filePath := calculateDownloadFilePath(item.Author, item.Title)
+	// 路径格式: {baseDir}/downloads/{authorFolder}/{cleanFilename}.mp4，配置了文件名模板时按模板生成
+	fileSize := item.TotalSize
+	if filePath == "" {
+		filePath = calculateDownloadFilePath(item)
+	} else if info, err := os.Stat(filePath); err == nil {
+		// 去重时记录可能指向已有文件，以实际文件大小为准
+		fileSize = info.Size()
+	}
 
 	// 创建下载记录
 	downloadRecord := &database.DownloadRecord{
@@ -285,14 +587,20 @@ func (s *QueueService) CompleteDownload(id string) error {
 		VideoID:      item.VideoID,
 		Title:        item.Title,
 		Author:       item.Author,
+		AuthorID:     item.AuthorID,
 		CoverURL:     item.CoverURL,
 		Duration:     item.Duration,
-		FileSize:     item.TotalSize,
+		FileSize:     fileSize,
 		FilePath:     filePath,
 		Format:       "mp4",
 		Resolution:   item.Resolution, // 使用队列项目中的分辨率
 		Status:       database.DownloadStatusCompleted,
 		DownloadTime: time.Now(),
+		LikeCount:    ParseCount(item.BatchStats.LikeCount),
+		CommentCount: ParseCount(item.BatchStats.CommentCount),
+		ForwardCount: ParseCount(item.BatchStats.ForwardCount),
+		FavCount:     ParseCount(item.BatchStats.FavCount),
+		ContentHash:  contentHash,
 	}
 
 	downloadRepo := database.NewDownloadRecordRepository()
@@ -300,12 +608,19 @@ func (s *QueueService) CompleteDownload(id string) error {
 		// 记录错误但不失败完成
 		fmt.Printf("Warning: failed to create download record: %v\n", err)
 	}
+}
 
-	return nil
+// ParseCount 解析字符串格式的统计数字，无法解析时返回 0
+func ParseCount(s string) int64 {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n
 }
 
 // calculateDownloadFilePath 计算下载视频的预期文件路径
-func calculateDownloadFilePath(author, title string) string {
+func calculateDownloadFilePath(item *database.QueueItem) string {
 	// 从当前配置获取下载目录
 	cfg := config.Get()
 	var downloadsDir string
@@ -324,17 +639,29 @@ func calculateDownloadFilePath(author, title string) string {
 		downloadsDir = filepath.Join(baseDir, "downloads")
 	}
 
+	// 配置了文件名模板时与下载器使用相同的路径
+	naming := NewNamingService()
+	if naming.Enabled() {
+		if relPath, err := naming.Render(FilenameFieldsFromQueueItem(item)); err == nil {
+			return filepath.Join(downloadsDir, filepath.FromSlash(relPath))
+		}
+	}
+
 	// 清理作者名作为文件夹名
-	authorFolder := cleanFolderName(author)
+	authorFolder := cleanFolderName(item.Author)
 	if authorFolder == "" {
 		authorFolder = "未知作者"
 	}
 
 	// 清理标题作为文件名
-	cleanTitle := cleanFilename(title)
+	cleanTitle := cleanFilename(item.Title)
 	if cleanTitle == "" {
 		cleanTitle = "未命名视频"
 	}
+	// 批量下载沿用 标题_视频ID 命名，避免同名视频互相覆盖
+	if item.BatchID != "" {
+		cleanTitle = utils.GenerateVideoFilename(item.Title, item.VideoID)
+	}
 
 	// 确保 .mp4 扩展名
 	if !strings.HasSuffix(strings.ToLower(cleanTitle), ".mp4") {
@@ -402,6 +729,14 @@ func (s *QueueService) IncrementRetryCount(id string) error {
 	return s.repo.IncrementRetryCount(id)
 }
 
+// RecordRetryAttempt 记录一次失败的下载尝试
+func (s *QueueService) RecordRetryAttempt(id string, attempt database.RetryAttempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.repo.AppendRetryAttempt(id, attempt)
+}
+
 // ClearQueue 从队列中移除所有项目
 func (s *QueueService) ClearQueue() error {
 	s.mu.Lock()
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+
+	"wx_channel/internal/database"
+)
+
+// 下载错误分类
+const (
+	RetryClassTransient = "transient" // 临时错误（超时、5xx、连接重置），可以重试
+	RetryClassPermanent = "permanent" // 永久错误（404、刷新地址后仍 403、磁盘已满），重试不会成功
+)
+
+// Windows 上磁盘已满的错误码（ERROR_HANDLE_DISK_FULL、ERROR_DISK_FULL）
+const (
+	windowsErrorHandleDiskFull syscall.Errno = 39
+	windowsErrorDiskFull       syscall.Errno = 112
+)
+
+// HTTPStatusError 表示服务器返回了非预期的状态码
+type HTTPStatusError struct {
+	StatusCode int
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+// permanentError 标记不应重试的错误
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 将错误标记为永久错误，重试策略遇到后立即停止
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// ClassifyError 判断下载错误是临时错误还是永久错误，无法识别的错误按临时错误处理
+func ClassifyError(err error) string {
+	var perm *permanentError
+	if errors.As(err, &perm) {
+		return RetryClassPermanent
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		switch code := statusErr.StatusCode; {
+		case code == http.StatusRequestTimeout, code == http.StatusTooManyRequests, code >= 500:
+			return RetryClassTransient
+		case code >= 400:
+			return RetryClassPermanent
+		}
+		return RetryClassTransient
+	}
+
+	if isDiskFull(err) || errors.Is(err, os.ErrPermission) {
+		return RetryClassPermanent
+	}
+
+	// 超时、连接重置、读取中断等网络错误都可以重试
+	return RetryClassTransient
+}
+
+// isDiskFull 检查错误是否由磁盘空间不足引起
+func isDiskFull(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return errno == windowsErrorDiskFull || errno == windowsErrorHandleDiskFull
+	}
+	return errno == syscall.ENOSPC
+}
+
+// RetryConfig 包含重试配置
+type RetryConfig struct {
+	MaxRetries    int           `json:"maxRetries"`
+	InitialDelay  time.Duration `json:"initialDelay"`
+	MaxDelay      time.Duration `json:"maxDelay"`
+	BackoffFactor float64       `json:"backoffFactor"`
+	Jitter        float64       `json:"jitter"` // 随机抖动比例，0.2 表示在 ±20% 范围内浮动
+}
+
+// DefaultRetryConfig 返回默认重试配置
+func DefaultRetryConfig() *RetryConfig {
+	return &RetryConfig{
+		MaxRetries:    3,
+		InitialDelay:  time.Second,
+		MaxDelay:      30 * time.Second,
+		BackoffFactor: 2.0,
+		Jitter:        0.2,
+	}
+}
+
+// RetryPolicy 所有下载方式共用的重试策略：按错误分类决定是否重试，重试前按指数退避加随机抖动等待
+type RetryPolicy struct {
+	config RetryConfig
+}
+
+// NewRetryPolicy 创建重试策略，config 为 nil 时使用默认配置
+func NewRetryPolicy(config *RetryConfig) *RetryPolicy {
+	if config == nil {
+		config = DefaultRetryConfig()
+	}
+	return &RetryPolicy{config: *config}
+}
+
+// NewRetryPolicyWithRetries 使用默认退避参数和指定的最大重试次数创建重试策略
+func NewRetryPolicyWithRetries(maxRetries int) *RetryPolicy {
+	config := DefaultRetryConfig()
+	if maxRetries >= 0 {
+		config.MaxRetries = maxRetries
+	}
+	return NewRetryPolicy(config)
+}
+
+// MaxRetries 返回最大重试次数（不含首次尝试）
+func (p *RetryPolicy) MaxRetries() int {
+	return p.config.MaxRetries
+}
+
+// Backoff 返回第 attempt 次失败（从 1 开始）后的等待时间
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	factor := p.config.BackoffFactor
+	if factor < 1 {
+		factor = 1
+	}
+
+	delay := float64(p.config.InitialDelay) * math.Pow(factor, float64(attempt-1))
+	if p.config.MaxDelay > 0 && delay > float64(p.config.MaxDelay) {
+		delay = float64(p.config.MaxDelay)
+	}
+	if p.config.Jitter > 0 {
+		delay *= 1 + p.config.Jitter*(2*rand.Float64()-1)
+	}
+	if delay < 0 {
+		return 0
+	}
+	return time.Duration(delay)
+}
+
+// Do 按策略执行 fn，直到成功、遇到永久错误、重试次数用尽或 ctx 取消
+// fn 的参数为当前尝试序号（从 1 开始）；onFailure 在每次失败后调用，可为 nil
+func (p *RetryPolicy) Do(ctx context.Context, fn func(attempt int) error, onFailure func(database.RetryAttempt)) error {
+	maxAttempts := p.config.MaxRetries + 1
+
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := fn(attempt)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		class := ClassifyError(err)
+		retry := class == RetryClassTransient && attempt < maxAttempts
+		var delay time.Duration
+		if retry {
+			delay = p.Backoff(attempt)
+		}
+
+		if onFailure != nil {
+			onFailure(database.RetryAttempt{
+				Attempt: attempt,
+				Time:    time.Now(),
+				Error:   err.Error(),
+				Class:   class,
+				DelayMs: delay.Milliseconds(),
+			})
+		}
+
+		if class == RetryClassPermanent {
+			return err
+		}
+		if !retry {
+			return fmt.Errorf("failed after %d attempts: %w", attempt, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"wx_channel/internal/database"
+)
+
+// diskFullErrno 返回当前平台上表示磁盘已满的错误码
+func diskFullErrno() syscall.Errno {
+	if runtime.GOOS == "windows" {
+		return windowsErrorDiskFull
+	}
+	return syscall.ENOSPC
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "404", err: &HTTPStatusError{StatusCode: 404}, want: RetryClassPermanent},
+		{name: "wrapped 404", err: fmt.Errorf("chunk 3: %w", &HTTPStatusError{StatusCode: 404}), want: RetryClassPermanent},
+		{name: "403 after refresh", err: Permanent(fmt.Errorf("still forbidden after refresh: %w", &HTTPStatusError{StatusCode: 403})), want: RetryClassPermanent},
+		{name: "permanent wrapper", err: fmt.Errorf("download: %w", Permanent(errors.New("downloader not initialized"))), want: RetryClassPermanent},
+		{name: "disk full", err: &os.PathError{Op: "write", Path: "video.mp4", Err: diskFullErrno()}, want: RetryClassPermanent},
+		{name: "permission denied", err: fmt.Errorf("failed to create file: %w", os.ErrPermission), want: RetryClassPermanent},
+		{name: "500", err: &HTTPStatusError{StatusCode: 500}, want: RetryClassTransient},
+		{name: "503", err: &HTTPStatusError{StatusCode: 503}, want: RetryClassTransient},
+		{name: "408", err: &HTTPStatusError{StatusCode: 408}, want: RetryClassTransient},
+		{name: "429", err: &HTTPStatusError{StatusCode: 429}, want: RetryClassTransient},
+		{name: "context deadline", err: fmt.Errorf("request failed: %w", context.DeadlineExceeded), want: RetryClassTransient},
+		{name: "read timeout", err: &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}, want: RetryClassTransient},
+		{name: "connection reset", err: &net.OpError{Op: "read", Net: "tcp", Err: &os.SyscallError{Syscall: "read", Err: syscall.ECONNRESET}}, want: RetryClassTransient},
+		{name: "unexpected eof", err: fmt.Errorf("failed to write chunk: %w", io.ErrUnexpectedEOF), want: RetryClassTransient},
+		{name: "unknown", err: errors.New("something went wrong"), want: RetryClassTransient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.err); got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	config := &RetryConfig{
+		MaxRetries:    5,
+		InitialDelay:  time.Second,
+		MaxDelay:      10 * time.Second,
+		BackoffFactor: 2,
+	}
+
+	tests := []struct {
+		attempt int
+		base    time.Duration
+	}{
+		{attempt: 0, base: time.Second}, // 小于 1 时按第 1 次计算
+		{attempt: 1, base: time.Second},
+		{attempt: 2, base: 2 * time.Second},
+		{attempt: 3, base: 4 * time.Second},
+		{attempt: 4, base: 8 * time.Second},
+		{attempt: 5, base: 10 * time.Second}, // 超过 MaxDelay 时取上限
+		{attempt: 10, base: 10 * time.Second},
+	}
+
+	for _, jitter := range []float64{0, 0.2} {
+		config.Jitter = jitter
+		policy := NewRetryPolicy(config)
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("jitter %.1f attempt %d", jitter, tt.attempt), func(t *testing.T) {
+				low := time.Duration(float64(tt.base) * (1 - jitter))
+				high := time.Duration(float64(tt.base) * (1 + jitter))
+				// 抖动是随机的，多次取样检查都落在 ±Jitter 范围内
+				for i := 0; i < 100; i++ {
+					if got := policy.Backoff(tt.attempt); got < low || got > high {
+						t.Fatalf("expected delay in [%v, %v], got %v", low, high, got)
+					}
+				}
+			})
+		}
+	}
+
+	// 增长因子小于 1 时不缩短等待时间
+	flat := NewRetryPolicy(&RetryConfig{InitialDelay: time.Second, BackoffFactor: 0.5})
+	if got := flat.Backoff(3); got != time.Second {
+		t.Fatalf("expected factor below 1 to keep the initial delay, got %v", got)
+	}
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	transient := &HTTPStatusError{StatusCode: 503}
+	permanent := &HTTPStatusError{StatusCode: 404}
+
+	tests := []struct {
+		name         string
+		errs         []error // 每次尝试返回的错误，超出时返回 nil
+		wantAttempts int
+		wantErr      error
+		wantClasses  []string
+	}{
+		{name: "success", wantAttempts: 1},
+		{name: "transient then success", errs: []error{transient, transient}, wantAttempts: 3, wantClasses: []string{RetryClassTransient, RetryClassTransient}},
+		{name: "permanent stops", errs: []error{transient, permanent}, wantAttempts: 2, wantErr: permanent, wantClasses: []string{RetryClassTransient, RetryClassPermanent}},
+		{name: "retries exhausted", errs: []error{transient, transient, transient, transient}, wantAttempts: 3, wantErr: transient, wantClasses: []string{RetryClassTransient, RetryClassTransient, RetryClassTransient}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := NewRetryPolicy(&RetryConfig{MaxRetries: 2, InitialDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond, BackoffFactor: 2})
+
+			attempts := 0
+			var failures []database.RetryAttempt
+			err := policy.Do(context.Background(), func(attempt int) error {
+				attempts++
+				if attempt != attempts {
+					t.Fatalf("expected attempt %d, got %d", attempts, attempt)
+				}
+				if attempt <= len(tt.errs) {
+					return tt.errs[attempt-1]
+				}
+				return nil
+			}, func(a database.RetryAttempt) {
+				failures = append(failures, a)
+			})
+
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("expected success, got %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			if attempts != tt.wantAttempts {
+				t.Fatalf("expected %d attempts, got %d", tt.wantAttempts, attempts)
+			}
+			if len(failures) != len(tt.wantClasses) {
+				t.Fatalf("expected %d failures, got %+v", len(tt.wantClasses), failures)
+			}
+			for i, f := range failures {
+				if f.Attempt != i+1 || f.Class != tt.wantClasses[i] {
+					t.Fatalf("unexpected failure %d: %+v", i, f)
+				}
+				// 不再重试时（永久错误或最后一次尝试）不等待
+				last := i == len(failures)-1 && tt.wantErr != nil
+				if last != (f.DelayMs == 0) {
+					t.Fatalf("unexpected delay for failure %d: %+v", i, f)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_DoCanceled(t *testing.T) {
+	policy := NewRetryPolicy(&RetryConfig{MaxRetries: 5, InitialDelay: time.Hour, BackoffFactor: 2})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	attempts := 0
+	err := policy.Do(ctx, func(attempt int) error {
+		attempts++
+		// 等待退避期间取消
+		time.AfterFunc(10*time.Millisecond, cancel)
+		return &HTTPStatusError{StatusCode: 503}
+	}, nil)
+	if !errors.Is(err, context.Canceled) || attempts != 1 {
+		t.Fatalf("expected cancellation after 1 attempt, got %v after %d", err, attempts)
+	}
+}
@@ -4,20 +4,37 @@ import (
 	"wx_channel/internal/database"
 )
 
+// 搜索范围
+const (
+	SearchScopeAll      = "all"
+	SearchScopeBrowse   = "browse"
+	SearchScopeDownload = "download"
+	SearchScopeComment  = "comment"
+)
+
 // SearchResult 表示全局搜索结果
 // Requirements: 12.2 - 按来源分组并显示计数
 type SearchResult struct {
 	BrowseResults   []database.BrowseRecord   `json:"browseResults"`
 	DownloadResults []database.DownloadRecord `json:"downloadResults"`
+	CommentResults  []database.Comment        `json:"commentResults"`
 	BrowseCount     int64                     `json:"browseCount"`
 	DownloadCount   int64                     `json:"downloadCount"`
+	CommentCount    int64                     `json:"commentCount"`
 	TotalCount      int64                     `json:"totalCount"`
+
+	// 按相关度排序、带高亮的分页结果，未搜索的来源为 nil
+	Browse     *database.PagedResult[database.SearchHit[database.BrowseRecord]]   `json:"browse,omitempty"`
+	Downloads  *database.PagedResult[database.SearchHit[database.DownloadRecord]] `json:"downloads,omitempty"`
+	Comments   *database.PagedResult[database.SearchHit[database.Comment]]        `json:"comments,omitempty"`
+	FTSEnabled bool                                                               `json:"ftsEnabled"`
 }
 
 // SearchService 处理全局搜索业务逻辑
 type SearchService struct {
 	browseRepo   *database.BrowseHistoryRepository
 	downloadRepo *database.DownloadRecordRepository
+	searchRepo   *database.SearchRepository
 }
 
 // NewSearchService 创建一个新的 SearchService
@@ -25,55 +42,79 @@ func NewSearchService() *SearchService {
 	return &SearchService{
 		browseRepo:   database.NewBrowseHistoryRepository(),
 		downloadRepo: database.NewDownloadRecordRepository(),
+		searchRepo:   database.NewSearchRepository(),
+	}
+}
+
+// IsValidSearchScope 检查搜索范围是否有效
+func IsValidSearchScope(scope string) bool {
+	switch scope {
+	case SearchScopeAll, SearchScopeBrowse, SearchScopeDownload, SearchScopeComment:
+		return true
 	}
+	return false
 }
 
-// Search 在浏览和下载记录中执行全局搜索
+// Search 在浏览记录、下载记录和评论中执行全局搜索
+// scope 为空时搜索所有来源；每个来源按 params 分页
 // Requirements: 12.1 - 搜索浏览和下载记录
 // Requirements: 12.2 - 按来源分组并显示计数
-func (s *SearchService) Search(query string, limit int) (*SearchResult, error) {
-	if limit < 1 {
-		limit = 20
+func (s *SearchService) Search(query, scope string, params database.PaginationParams) (*SearchResult, error) {
+	if scope == "" {
+		scope = SearchScopeAll
 	}
 
 	result := &SearchResult{
 		BrowseResults:   []database.BrowseRecord{},
 		DownloadResults: []database.DownloadRecord{},
+		CommentResults:  []database.Comment{},
+		FTSEnabled:      s.searchRepo.FTSEnabled(),
 	}
 
 	// 搜索浏览记录
-	browseParams := &database.PaginationParams{
-		Page:     1,
-		PageSize: limit,
-		SortBy:   "browse_time",
-		SortDesc: true,
-	}
-	browseResult, err := s.browseRepo.Search(query, browseParams)
-	if err != nil {
-		return nil, err
+	if scope == SearchScopeAll || scope == SearchScopeBrowse {
+		browseParams := params
+		hits, err := s.searchRepo.SearchBrowse(query, &browseParams)
+		if err != nil {
+			return nil, err
+		}
+		result.Browse = hits
+		result.BrowseCount = hits.Total
+		for _, hit := range hits.Items {
+			result.BrowseResults = append(result.BrowseResults, hit.Item)
+		}
 	}
-	result.BrowseResults = browseResult.Items
-	result.BrowseCount = browseResult.Total
 
 	// 搜索下载记录
-	downloadParams := &database.FilterParams{
-		PaginationParams: database.PaginationParams{
-			Page:     1,
-			PageSize: limit,
-			SortBy:   "download_time",
-			SortDesc: true,
-		},
-		Query: query,
+	if scope == SearchScopeAll || scope == SearchScopeDownload {
+		downloadParams := params
+		hits, err := s.searchRepo.SearchDownloads(query, &downloadParams)
+		if err != nil {
+			return nil, err
+		}
+		result.Downloads = hits
+		result.DownloadCount = hits.Total
+		for _, hit := range hits.Items {
+			result.DownloadResults = append(result.DownloadResults, hit.Item)
+		}
 	}
-	downloadResult, err := s.downloadRepo.List(downloadParams)
-	if err != nil {
-		return nil, err
+
+	// 搜索评论
+	if scope == SearchScopeAll || scope == SearchScopeComment {
+		commentParams := params
+		hits, err := s.searchRepo.SearchComments(query, &commentParams)
+		if err != nil {
+			return nil, err
+		}
+		result.Comments = hits
+		result.CommentCount = hits.Total
+		for _, hit := range hits.Items {
+			result.CommentResults = append(result.CommentResults, hit.Item)
+		}
 	}
-	result.DownloadResults = downloadResult.Items
-	result.DownloadCount = downloadResult.Total
 
 	// 计算总数
-	result.TotalCount = result.BrowseCount + result.DownloadCount
+	result.TotalCount = result.BrowseCount + result.DownloadCount + result.CommentCount
 
 	return result, nil
 }
@@ -0,0 +1,372 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"wx_channel/internal/config"
+	"wx_channel/internal/database"
+	"wx_channel/internal/utils"
+)
+
+// posterDownloadTimeout 下载封面图片的超时时间
+const posterDownloadTimeout = 30 * time.Second
+
+// SidecarMetadata 写入视频旁 sidecar 文件的元数据
+type SidecarMetadata struct {
+	VideoID      string    `json:"videoId"`
+	Title        string    `json:"title"`
+	Author       string    `json:"author"`
+	AuthorID     string    `json:"authorId,omitempty"`
+	Duration     int64     `json:"duration"`     // 视频时长（毫秒）
+	DurationText string    `json:"durationText"` // 格式化时长，例如 "01:23"
+	Resolution   string    `json:"resolution,omitempty"`
+	FileSize     int64     `json:"fileSize"`
+	PlayCount    int64     `json:"playCount"`
+	LikeCount    int64     `json:"likeCount"`
+	CommentCount int64     `json:"commentCount"`
+	FavCount     int64     `json:"favCount"`
+	ForwardCount int64     `json:"forwardCount"`
+	CreateTime   string    `json:"createTime,omitempty"` // 视频发布时间
+	IPRegion     string    `json:"ipRegion,omitempty"`
+	PageURL      string    `json:"pageUrl,omitempty"`
+	PageSource   string    `json:"pageSource,omitempty"`
+	CoverURL     string    `json:"coverUrl,omitempty"`
+	DownloadTime time.Time `json:"downloadTime"`
+}
+
+// SidecarService 在下载的视频旁写入 .json / .nfo 元数据文件和封面
+type SidecarService struct {
+	client *http.Client
+}
+
+// NewSidecarService 创建一个新的 SidecarService
+func NewSidecarService() *SidecarService {
+	return &SidecarService{
+		client: &http.Client{Timeout: posterDownloadTimeout},
+	}
+}
+
+// Enabled 检查是否开启了任一 sidecar 文件
+func (s *SidecarService) Enabled() bool {
+	cfg := config.Get()
+	return cfg != nil && (cfg.SaveMetadataJSON || cfg.SaveMetadataNFO || cfg.SavePoster)
+}
+
+// WriteAsync 在后台写入 sidecar 文件，失败时仅记录日志，不影响下载结果
+func (s *SidecarService) WriteAsync(videoPath string, meta *SidecarMetadata) {
+	if s == nil || !s.Enabled() || videoPath == "" || meta == nil {
+		return
+	}
+	go func() {
+		if err := s.Write(videoPath, meta); err != nil {
+			utils.Warn("[Sidecar] 写入元数据文件失败 %s: %v", videoPath, err)
+		}
+	}()
+}
+
+// Write 按配置开关写入 sidecar 文件
+func (s *SidecarService) Write(videoPath string, meta *SidecarMetadata) error {
+	cfg := config.Get()
+	if cfg == nil {
+		return nil
+	}
+
+	meta.normalize()
+	if meta.FileSize == 0 {
+		if stat, err := os.Stat(videoPath); err == nil {
+			meta.FileSize = stat.Size()
+		}
+	}
+	base := strings.TrimSuffix(videoPath, filepath.Ext(videoPath))
+	var errs []string
+
+	// 先下载封面，NFO 中需要引用封面文件名
+	posterName := ""
+	if cfg.SavePoster && meta.CoverURL != "" {
+		name, err := s.writePoster(videoPath, meta.CoverURL)
+		if err != nil {
+			errs = append(errs, err.Error())
+		} else {
+			posterName = name
+		}
+	}
+
+	if cfg.SaveMetadataJSON {
+		if err := writeSidecarJSON(base+".json", meta); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if cfg.SaveMetadataNFO {
+		if err := writeSidecarNFO(base+".nfo", meta, posterName); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// normalize 补全派生字段
+func (m *SidecarMetadata) normalize() {
+	if m.DurationText == "" && m.Duration > 0 {
+		m.DurationText = utils.FormatDuration(float64(m.Duration))
+	}
+	if m.PageSource == "" {
+		m.PageSource = PageSource(m.PageURL)
+	}
+	if m.DownloadTime.IsZero() {
+		m.DownloadTime = time.Now()
+	}
+	m.CreateTime = formatCreateTime(m.CreateTime)
+}
+
+// writeSidecarJSON 写入 JSON 元数据文件
+func writeSidecarJSON(path string, meta *SidecarMetadata) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(meta); err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	return writeFileAtomic(path, buf.Bytes())
+}
+
+// nfoMovie Kodi/Jellyfin 的 movie NFO 结构
+type nfoMovie struct {
+	XMLName   xml.Name     `xml:"movie"`
+	Title     string       `xml:"title"`
+	Plot      string       `xml:"plot,omitempty"`
+	Runtime   int64        `xml:"runtime,omitempty"` // 分钟
+	Premiered string       `xml:"premiered,omitempty"`
+	Year      string       `xml:"year,omitempty"`
+	Director  string       `xml:"director,omitempty"`
+	Studio    string       `xml:"studio"`
+	Tags      []string     `xml:"tag,omitempty"`
+	UniqueID  *nfoUniqueID `xml:"uniqueid,omitempty"`
+	Thumb     *nfoThumb    `xml:"thumb,omitempty"`
+	DateAdded string       `xml:"dateadded"`
+}
+
+type nfoUniqueID struct {
+	Type    string `xml:"type,attr"`
+	Default bool   `xml:"default,attr"`
+	Value   string `xml:",chardata"`
+}
+
+type nfoThumb struct {
+	Aspect string `xml:"aspect,attr"`
+	Value  string `xml:",chardata"`
+}
+
+// writeSidecarNFO 写入 NFO 元数据文件
+func writeSidecarNFO(path string, meta *SidecarMetadata, posterName string) error {
+	movie := nfoMovie{
+		Title:     meta.Title,
+		Plot:      nfoPlot(meta),
+		Director:  meta.Author,
+		Studio:    "微信视频号",
+		DateAdded: meta.DownloadTime.Format("2006-01-02 15:04:05"),
+	}
+	if meta.Duration > 0 {
+		// 不足一分钟按一分钟计
+		movie.Runtime = (meta.Duration + 59999) / 60000
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", meta.CreateTime, time.Local); err == nil {
+		movie.Premiered = t.Format("2006-01-02")
+		movie.Year = t.Format("2006")
+	}
+	if meta.PageSource != "" {
+		movie.Tags = append(movie.Tags, meta.PageSource)
+	}
+	if meta.IPRegion != "" {
+		movie.Tags = append(movie.Tags, meta.IPRegion)
+	}
+	if meta.VideoID != "" {
+		movie.UniqueID = &nfoUniqueID{Type: "wx_channels", Default: true, Value: meta.VideoID}
+	}
+	if posterName != "" {
+		movie.Thumb = &nfoThumb{Aspect: "poster", Value: posterName}
+	}
+
+	data, err := xml.MarshalIndent(movie, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal nfo: %w", err)
+	}
+	data = append([]byte(xml.Header), data...)
+	return writeFileAtomic(path, data)
+}
+
+// nfoPlot 生成 NFO 简介：统计数据、发布时间、IP 属地和页面地址
+func nfoPlot(meta *SidecarMetadata) string {
+	lines := []string{fmt.Sprintf("播放 %d · 点赞 %d · 评论 %d · 收藏 %d · 转发 %d",
+		meta.PlayCount, meta.LikeCount, meta.CommentCount, meta.FavCount, meta.ForwardCount)}
+	if meta.CreateTime != "" {
+		lines = append(lines, "发布时间: "+meta.CreateTime)
+	}
+	if meta.IPRegion != "" {
+		lines = append(lines, "IP属地: "+meta.IPRegion)
+	}
+	if meta.PageURL != "" {
+		lines = append(lines, "页面地址: "+meta.PageURL)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sidecarPaths 返回视频可能附带的元数据和封面文件（不含同目录共用的 poster.jpg）
+func sidecarPaths(videoPath string) []string {
+	base := strings.TrimSuffix(videoPath, filepath.Ext(videoPath))
+	return []string{base + ".json", base + ".nfo", base + "-poster.jpg"}
+}
+
+// posterFilename 返回封面文件名：目录中只有当前视频时使用 poster.jpg，
+// 否则使用 Kodi 同样识别的 <视频名>-poster.jpg，避免同一作者目录下的视频互相覆盖
+func posterFilename(videoPath string) string {
+	dir := filepath.Dir(videoPath)
+	videoName := filepath.Base(videoPath)
+
+	entries, err := os.ReadDir(dir)
+	if err == nil {
+		for _, entry := range entries {
+			if entry.IsDir() || entry.Name() == videoName {
+				continue
+			}
+			if strings.EqualFold(filepath.Ext(entry.Name()), ".mp4") {
+				return strings.TrimSuffix(videoName, filepath.Ext(videoName)) + "-poster.jpg"
+			}
+		}
+	}
+	return "poster.jpg"
+}
+
+// writePoster 下载封面并保存到视频所在目录，返回封面文件名
+func (s *SidecarService) writePoster(videoPath, coverURL string) (string, error) {
+	name := posterFilename(videoPath)
+	posterPath := filepath.Join(filepath.Dir(videoPath), name)
+	if _, err := os.Stat(posterPath); err == nil {
+		return name, nil
+	}
+
+	resp, err := s.client.Get(coverURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to download poster: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download poster: HTTP %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read poster: %w", err)
+	}
+	if err := writeFileAtomic(posterPath, data); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// writeFileAtomic 先写临时文件再重命名，避免媒体库读到写了一半的文件
+func writeFileAtomic(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// formatCreateTime 将 Unix 时间戳（秒或毫秒）格式化为本地时间，其他格式原样返回
+func formatCreateTime(createTime string) string {
+	createTime = strings.TrimSpace(createTime)
+	ts, err := strconv.ParseInt(createTime, 10, 64)
+	if err != nil || ts <= 0 {
+		return createTime
+	}
+	if ts > 1e12 {
+		ts /= 1000
+	}
+	return time.Unix(ts, 0).Format("2006-01-02 15:04:05")
+}
+
+// SidecarMetadataFromDownloadRecord 从下载记录构建 sidecar 元数据，并用浏览记录补全作者 ID、页面地址等字段
+func SidecarMetadataFromDownloadRecord(record *database.DownloadRecord) *SidecarMetadata {
+	meta := &SidecarMetadata{
+		VideoID:      record.VideoID,
+		Title:        record.Title,
+		Author:       record.Author,
+		Duration:     record.Duration,
+		Resolution:   record.Resolution,
+		FileSize:     record.FileSize,
+		LikeCount:    record.LikeCount,
+		CommentCount: record.CommentCount,
+		FavCount:     record.FavCount,
+		ForwardCount: record.ForwardCount,
+		CoverURL:     record.CoverURL,
+		DownloadTime: record.DownloadTime,
+	}
+	meta.MergeBrowseRecord()
+	return meta
+}
+
+// MergeBrowseRecord 使用浏览记录补全缺失的字段
+func (m *SidecarMetadata) MergeBrowseRecord() {
+	if m.VideoID == "" || database.GetDB() == nil {
+		return
+	}
+	record, err := database.NewBrowseHistoryRepository().GetByID(m.VideoID)
+	if err != nil || record == nil {
+		return
+	}
+
+	if m.Title == "" {
+		m.Title = record.Title
+	}
+	if m.Author == "" {
+		m.Author = record.Author
+	}
+	if m.AuthorID == "" {
+		m.AuthorID = record.AuthorID
+	}
+	if m.Duration == 0 {
+		m.Duration = record.Duration
+	}
+	if m.Resolution == "" {
+		m.Resolution = record.Resolution
+	}
+	if m.CoverURL == "" {
+		m.CoverURL = record.CoverURL
+	}
+	if m.PageURL == "" {
+		m.PageURL = record.PageURL
+	}
+	if m.LikeCount == 0 {
+		m.LikeCount = record.LikeCount
+	}
+	if m.CommentCount == 0 {
+		m.CommentCount = record.CommentCount
+	}
+	if m.FavCount == 0 {
+		m.FavCount = record.FavCount
+	}
+	if m.ForwardCount == 0 {
+		m.ForwardCount = record.ForwardCount
+	}
+}
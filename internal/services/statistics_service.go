@@ -1,9 +1,25 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+
 	"wx_channel/internal/database"
 )
 
+// 增长排行的排序指标
+const (
+	TrendingMetricLikes    = "likes"
+	TrendingMetricComments = "comments"
+)
+
+// 统计报表参数错误
+var (
+	ErrInvalidTrendingMetric = errors.New("invalid trending metric")
+	ErrInvalidPageSource     = errors.New("invalid page source")
+)
+
 // Statistics 表示仪表盘统计数据
 type Statistics struct {
 	TotalBrowseCount   int64                     `json:"totalBrowseCount"`
@@ -12,6 +28,7 @@ type Statistics struct {
 	StorageUsed        int64                     `json:"storageUsed"`
 	RecentBrowse       []database.BrowseRecord   `json:"recentBrowse"`
 	RecentDownload     []database.DownloadRecord `json:"recentDownload"`
+	Disk               *DiskStatus               `json:"disk"` // 下载目录的磁盘空间和下载库容量
 }
 
 // ChartData 表示仪表盘的图表数据
@@ -20,17 +37,27 @@ type ChartData struct {
 	Values []int64  `json:"values"`
 }
 
+// Distribution 表示浏览过的视频的分辨率和时长分布
+type Distribution struct {
+	Resolutions []database.DistributionBucket `json:"resolutions"`
+	Durations   []database.DistributionBucket `json:"durations"`
+}
+
 // StatisticsService 处理统计业务逻辑
 type StatisticsService struct {
-	browseRepo   *database.BrowseHistoryRepository
-	downloadRepo *database.DownloadRecordRepository
+	browseRepo    *database.BrowseHistoryRepository
+	downloadRepo  *database.DownloadRecordRepository
+	analyticsRepo *database.AnalyticsRepository
+	diskGuard     *DiskGuard
 }
 
 // NewStatisticsService 创建一个新的 StatisticsService
 func NewStatisticsService() *StatisticsService {
 	return &StatisticsService{
-		browseRepo:   database.NewBrowseHistoryRepository(),
-		downloadRepo: database.NewDownloadRecordRepository(),
+		browseRepo:    database.NewBrowseHistoryRepository(),
+		downloadRepo:  database.NewDownloadRecordRepository(),
+		analyticsRepo: database.NewAnalyticsRepository(),
+		diskGuard:     NewDiskGuard(),
 	}
 }
 
@@ -83,6 +110,9 @@ func (s *StatisticsService) GetStatistics() (*Statistics, error) {
 	}
 	stats.RecentDownload = recentDownload
 
+	// 磁盘空间和下载库容量
+	stats.Disk = s.diskGuard.Status()
+
 	return stats, nil
 }
 
@@ -121,3 +151,51 @@ func (s *StatisticsService) GetRecentDownload(limit int) ([]database.DownloadRec
 	}
 	return s.downloadRepo.GetRecent(limit)
 }
+
+// GetTrendingVideos 返回点赞（metric 为 likes 或空）或评论增长最快的视频
+func (s *StatisticsService) GetTrendingVideos(params *database.AnalyticsParams, metric string) ([]database.TrendingVideo, error) {
+	if metric == "" {
+		metric = TrendingMetricLikes
+	}
+	if metric != TrendingMetricLikes && metric != TrendingMetricComments {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTrendingMetric, metric)
+	}
+	if err := normalizePageSource(params); err != nil {
+		return nil, err
+	}
+	return s.analyticsRepo.GetTrendingVideos(params, metric == TrendingMetricComments)
+}
+
+// GetTopAuthors 返回时间范围内互动总数最高的作者
+func (s *StatisticsService) GetTopAuthors(params *database.AnalyticsParams) ([]database.AuthorEngagement, error) {
+	if err := normalizePageSource(params); err != nil {
+		return nil, err
+	}
+	return s.analyticsRepo.GetTopAuthors(params)
+}
+
+// GetDistribution 返回时间范围内浏览过的视频的分辨率和时长分布
+func (s *StatisticsService) GetDistribution(params *database.AnalyticsParams) (*Distribution, error) {
+	if err := normalizePageSource(params); err != nil {
+		return nil, err
+	}
+
+	resolutions, err := s.analyticsRepo.GetResolutionDistribution(params)
+	if err != nil {
+		return nil, err
+	}
+	durations, err := s.analyticsRepo.GetDurationDistribution(params)
+	if err != nil {
+		return nil, err
+	}
+	return &Distribution{Resolutions: resolutions, Durations: durations}, nil
+}
+
+// normalizePageSource 校验页面来源，兼容批量下载使用的 batch_ 前缀（如 batch_feed）
+func normalizePageSource(params *database.AnalyticsParams) error {
+	params.PageSource = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(params.PageSource)), "batch_")
+	if !database.IsValidPageSource(params.PageSource) {
+		return fmt.Errorf("%w: %s", ErrInvalidPageSource, params.PageSource)
+	}
+	return nil
+}
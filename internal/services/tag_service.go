@@ -0,0 +1,227 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"wx_channel/internal/database"
+
+	"github.com/google/uuid"
+)
+
+// maxTagNameLength 标签和合集名称的最大长度（字符数）
+const maxTagNameLength = 50
+
+// tagColorPattern 标签颜色格式，例如 #1e90ff
+var tagColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// 标签错误
+var (
+	ErrTagNotFound    = errors.New("tag not found")
+	ErrTagExists      = errors.New("a tag with this name already exists")
+	ErrRecordNotFound = errors.New("download record not found")
+	ErrInvalidName    = errors.New("invalid name")
+	ErrInvalidColor   = errors.New("invalid color")
+)
+
+// TagService 管理下载记录的标签
+type TagService struct {
+	repo         *database.TagRepository
+	downloadRepo *database.DownloadRecordRepository
+}
+
+// NewTagService 创建一个新的 TagService
+func NewTagService() *TagService {
+	return &TagService{
+		repo:         database.NewTagRepository(),
+		downloadRepo: database.NewDownloadRecordRepository(),
+	}
+}
+
+// normalizeName 去除名称首尾空白并检查长度
+func normalizeName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: name is required", ErrInvalidName)
+	}
+	if utf8.RuneCountInString(name) > maxTagNameLength {
+		return "", fmt.Errorf("%w: name must be at most %d characters", ErrInvalidName, maxTagNameLength)
+	}
+	return name, nil
+}
+
+// normalizeTagName 检查标签名称，标签名称不能包含逗号（导出和过滤时用逗号分隔多个标签）
+func normalizeTagName(name string) (string, error) {
+	name, err := normalizeName(name)
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(name, ",") {
+		return "", fmt.Errorf("%w: tag name must not contain commas", ErrInvalidName)
+	}
+	return name, nil
+}
+
+// validateTagColor 检查标签颜色，可以为空
+func validateTagColor(color string) error {
+	if color != "" && !tagColorPattern.MatchString(color) {
+		return fmt.Errorf("%w: color must be a hex color like #1e90ff", ErrInvalidColor)
+	}
+	return nil
+}
+
+// List 获取所有标签
+func (s *TagService) List() ([]database.Tag, error) {
+	return s.repo.List()
+}
+
+// Create 创建标签
+func (s *TagService) Create(name, color string) (*database.Tag, error) {
+	name, err := normalizeTagName(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateTagColor(color); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.GetByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrTagExists
+	}
+
+	tag := &database.Tag{ID: uuid.New().String(), Name: name, Color: color}
+	if err := s.repo.Create(tag); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// Update 修改标签的名称和颜色
+func (s *TagService) Update(id, name, color string) (*database.Tag, error) {
+	tag, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if tag == nil {
+		return nil, ErrTagNotFound
+	}
+
+	name, err = normalizeTagName(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateTagColor(color); err != nil {
+		return nil, err
+	}
+	if existing, err := s.repo.GetByName(name); err != nil {
+		return nil, err
+	} else if existing != nil && existing.ID != id {
+		return nil, ErrTagExists
+	}
+
+	tag.Name = name
+	tag.Color = color
+	if err := s.repo.Update(tag); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// Delete 删除标签
+func (s *TagService) Delete(id string) error {
+	tag, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if tag == nil {
+		return ErrTagNotFound
+	}
+	return s.repo.Delete(id)
+}
+
+// GetRecordTags 获取下载记录的标签名称
+func (s *TagService) GetRecordTags(recordID string) ([]string, error) {
+	record, err := s.downloadRepo.GetByID(recordID)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, ErrRecordNotFound
+	}
+
+	tags, err := s.repo.GetTagNamesForRecords([]string{recordID})
+	if err != nil {
+		return nil, err
+	}
+	if tags[recordID] == nil {
+		return []string{}, nil
+	}
+	return tags[recordID], nil
+}
+
+// SetRecordTags 将下载记录的标签替换为 names，不存在的标签自动创建
+func (s *TagService) SetRecordTags(recordID string, names []string) ([]string, error) {
+	record, err := s.downloadRepo.GetByID(recordID)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, ErrRecordNotFound
+	}
+
+	var tagIDs []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name, err := normalizeTagName(name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+
+		tag, err := s.repo.GetByName(name)
+		if err != nil {
+			return nil, err
+		}
+		if tag == nil {
+			if tag, err = s.Create(name, ""); err != nil {
+				return nil, err
+			}
+		}
+		tagIDs = append(tagIDs, tag.ID)
+	}
+
+	if err := s.repo.SetRecordTags(recordID, tagIDs); err != nil {
+		return nil, err
+	}
+	return s.GetRecordTags(recordID)
+}
+
+// populateRecordTags 查询并填充下载记录的 Tags 字段
+func populateRecordTags(repo *database.TagRepository, records []database.DownloadRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	ids := make([]string, len(records))
+	for i := range records {
+		ids[i] = records[i].ID
+	}
+
+	tags, err := repo.GetTagNamesForRecords(ids)
+	if err != nil {
+		return err
+	}
+	for i := range records {
+		records[i].Tags = tags[records[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,339 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"wx_channel/internal/database"
+	"wx_channel/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+// trashDirName 回收站目录名，位于下载目录下
+const trashDirName = ".trash"
+
+// trashPurgeInterval 自动永久删除过期回收站项目的检查间隔
+const trashPurgeInterval = time.Hour
+
+// 回收站错误
+var (
+	ErrTrashItemNotFound = errors.New("trash item not found")
+	ErrRestoreConflict   = errors.New("a file already exists at the original path")
+)
+
+// TrashPurgeResult 永久删除回收站项目的结果
+type TrashPurgeResult struct {
+	ItemsPurged int64    `json:"itemsPurged"`
+	SpaceFreed  int64    `json:"spaceFreed"`
+	Errors      []string `json:"errors,omitempty"`
+}
+
+// TrashService 管理下载目录下的回收站：删除的下载文件先移入回收站，可以恢复，到期后自动永久删除
+type TrashService struct {
+	repo         *database.TrashRepository
+	downloadRepo *database.DownloadRecordRepository
+	settings     *database.SettingsRepository
+
+	mu       sync.Mutex
+	running  bool
+	stopChan chan struct{}
+}
+
+// NewTrashService 创建一个新的 TrashService
+func NewTrashService() *TrashService {
+	return &TrashService{
+		repo:         database.NewTrashRepository(),
+		downloadRepo: database.NewDownloadRecordRepository(),
+		settings:     database.NewSettingsRepository(),
+	}
+}
+
+// loadSettings 加载设置，失败时使用默认设置
+func (s *TrashService) loadSettings() *database.Settings {
+	settings, err := s.settings.Load()
+	if err != nil {
+		return database.DefaultSettings()
+	}
+	return settings
+}
+
+// TrashDir 返回回收站目录
+func (s *TrashService) TrashDir() string {
+	return filepath.Join(downloadsDir(s.loadSettings()), trashDirName)
+}
+
+// MoveToTrash 将下载文件及其元数据和封面移入回收站，返回移入的文件大小，文件不存在时返回 0
+// record 为删除前的下载记录（可为 nil），恢复时用于重新创建记录
+func (s *TrashService) MoveToTrash(filePath string, record *database.DownloadRecord) (int64, error) {
+	filePath = filepath.Clean(filePath)
+	if _, err := os.Stat(filePath); err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	id := uuid.New().String()
+	item := &database.TrashItem{
+		ID:           id,
+		Title:        filepath.Base(filePath),
+		OriginalPath: filePath,
+		TrashPath:    filepath.Join(s.TrashDir(), id),
+		Files:        []string{},
+		Record:       record,
+		DeletedAt:    time.Now(),
+	}
+	if record != nil {
+		item.RecordID = record.ID
+		item.Title = record.Title
+		item.Author = record.Author
+	}
+
+	if err := os.MkdirAll(item.TrashPath, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create trash directory: %w", err)
+	}
+
+	for i, path := range append([]string{filePath}, sidecarPaths(filePath)...) {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if err := moveFile(path, trashedPath(item, path)); err != nil {
+			if i == 0 {
+				os.RemoveAll(item.TrashPath)
+				return 0, fmt.Errorf("failed to move file to trash: %w", err)
+			}
+			utils.Warn("[Trash] Failed to move %s to trash: %v", path, err)
+			continue
+		}
+		item.Files = append(item.Files, path)
+		item.FileSize += info.Size()
+	}
+
+	if err := s.repo.Create(item); err != nil {
+		// 无法保存回收站记录时把文件移回原处，避免文件无法恢复
+		for _, path := range item.Files {
+			_ = moveFile(trashedPath(item, path), path)
+		}
+		os.RemoveAll(item.TrashPath)
+		return 0, err
+	}
+
+	utils.Info("[Trash] Moved %s to trash", filePath)
+	return item.FileSize, nil
+}
+
+// List 返回回收站中的所有项目，最近删除的在前
+func (s *TrashService) List() ([]database.TrashItem, error) {
+	items, err := s.repo.List()
+	if err != nil {
+		return nil, err
+	}
+
+	days := s.loadSettings().TrashRetentionDays
+	for i := range items {
+		if days > 0 {
+			expiresAt := items[i].DeletedAt.AddDate(0, 0, days)
+			items[i].ExpiresAt = &expiresAt
+		}
+	}
+	return items, nil
+}
+
+// Restore 将回收站项目的文件移回原处，并重新创建已删除的下载记录
+func (s *TrashService) Restore(id string) (*database.TrashItem, error) {
+	item, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, ErrTrashItemNotFound
+	}
+
+	for _, path := range item.Files {
+		if _, err := os.Stat(path); err == nil {
+			return nil, fmt.Errorf("%w: %s", ErrRestoreConflict, path)
+		}
+	}
+
+	for _, path := range item.Files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := moveFile(trashedPath(item, path), path); err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", path, err)
+		}
+	}
+
+	if item.Record != nil {
+		existing, err := s.downloadRepo.GetByID(item.Record.ID)
+		if err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			if err := s.downloadRepo.Create(item.Record); err != nil {
+				return nil, fmt.Errorf("failed to restore download record: %w", err)
+			}
+		}
+	}
+
+	if err := s.repo.Delete(item.ID); err != nil {
+		return nil, err
+	}
+	os.RemoveAll(item.TrashPath)
+
+	utils.Info("[Trash] Restored %s", item.OriginalPath)
+	return item, nil
+}
+
+// Purge 永久删除指定的回收站项目
+func (s *TrashService) Purge(id string) (*TrashPurgeResult, error) {
+	item, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, ErrTrashItemNotFound
+	}
+
+	result := &TrashPurgeResult{}
+	s.purgeItem(item, result)
+	return result, nil
+}
+
+// PurgeAll 清空回收站
+func (s *TrashService) PurgeAll() (*TrashPurgeResult, error) {
+	items, err := s.repo.List()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &TrashPurgeResult{}
+	for i := range items {
+		s.purgeItem(&items[i], result)
+	}
+	return result, nil
+}
+
+// PurgeExpired 永久删除超过保留天数的回收站项目，保留天数为 0 时不删除
+func (s *TrashService) PurgeExpired() (*TrashPurgeResult, error) {
+	result := &TrashPurgeResult{}
+	days := s.loadSettings().TrashRetentionDays
+	if days <= 0 {
+		return result, nil
+	}
+
+	items, err := s.repo.GetBefore(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return nil, err
+	}
+	for i := range items {
+		s.purgeItem(&items[i], result)
+	}
+	return result, nil
+}
+
+// purgeItem 删除回收站项目的文件和记录
+func (s *TrashService) purgeItem(item *database.TrashItem, result *TrashPurgeResult) {
+	// 只删除回收站目录下的项目目录
+	if filepath.Base(filepath.Dir(item.TrashPath)) != trashDirName {
+		result.Errors = append(result.Errors, fmt.Sprintf("refusing to delete %s: not a trash directory", item.TrashPath))
+		return
+	}
+	if err := os.RemoveAll(item.TrashPath); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("failed to delete %s: %v", item.TrashPath, err))
+		return
+	}
+	if err := s.repo.Delete(item.ID); err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return
+	}
+	result.ItemsPurged++
+	result.SpaceFreed += item.FileSize
+}
+
+// Start 启动后台任务，定期永久删除过期的回收站项目
+func (s *TrashService) Start() {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.stopChan = make(chan struct{})
+	s.mu.Unlock()
+
+	go s.loop()
+}
+
+// Stop 停止后台任务
+func (s *TrashService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.running {
+		return
+	}
+	s.running = false
+	close(s.stopChan)
+}
+
+// loop 定期清理过期项目
+func (s *TrashService) loop() {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		result, err := s.PurgeExpired()
+		if err != nil {
+			utils.Warn("[Trash] Failed to purge expired items: %v", err)
+		} else if result.ItemsPurged > 0 {
+			utils.Info("[Trash] Purged %d expired items, freed %s", result.ItemsPurged, formatBytes(result.SpaceFreed))
+		}
+
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// trashedPath 返回文件在回收站项目目录中的路径
+func trashedPath(item *database.TrashItem, originalPath string) string {
+	return filepath.Join(item.TrashPath, filepath.Base(originalPath))
+}
+
+// moveFile 移动文件，无法直接重命名时（例如跨磁盘）复制后删除源文件
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	in.Close()
+	return os.Remove(src)
+}
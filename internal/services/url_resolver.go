@@ -0,0 +1,150 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"wx_channel/internal/database"
+	"wx_channel/internal/utils"
+	"wx_channel/internal/websocket"
+)
+
+// urlResolveTimeout 通过前端获取视频详情的超时时间
+const urlResolveTimeout = 30 * time.Second
+
+// errURLExpired 表示下载地址已过期或被 CDN 拒绝访问
+var errURLExpired = errors.New("video url expired")
+
+// ResolvedSource 重新获取到的视频下载地址和解密密钥
+type ResolvedSource struct {
+	VideoURL   string `json:"videoUrl"`
+	DecryptKey string `json:"decryptKey"`
+}
+
+// URLResolver 通过本地 WebSocket Hub 调用前端 feed_profile 接口，重新获取过期的视频下载地址
+type URLResolver struct {
+	hub     *websocket.Hub
+	timeout time.Duration
+}
+
+// NewURLResolver 创建一个新的 URLResolver
+func NewURLResolver(hub *websocket.Hub) *URLResolver {
+	return &URLResolver{
+		hub:     hub,
+		timeout: urlResolveTimeout,
+	}
+}
+
+// Resolve 获取队列项目最新的下载地址和解密密钥，并同步更新对应的浏览记录
+func (r *URLResolver) Resolve(item *database.QueueItem) (*ResolvedSource, error) {
+	if r.hub == nil {
+		return nil, fmt.Errorf("websocket hub not available")
+	}
+
+	body := websocket.FeedProfileBody{
+		ObjectID: item.VideoID,
+		NonceID:  item.NonceID,
+	}
+	if body.NonceID == "" {
+		// 没有 nonce ID 的项目尝试使用浏览记录中的页面地址（包含 oid 和 nid 参数）
+		browseRepo := database.NewBrowseHistoryRepository()
+		if record, err := browseRepo.GetByID(item.VideoID); err == nil && record != nil && hasFeedParams(record.PageURL) {
+			body = websocket.FeedProfileBody{URL: record.PageURL}
+		}
+	}
+	if body.URL == "" && (body.ObjectID == "" || body.NonceID == "") {
+		return nil, fmt.Errorf("missing object id or nonce id for item: %s", item.ID)
+	}
+
+	data, err := r.hub.CallAPI("key:channels:feed_profile", body, r.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch feed profile: %w", err)
+	}
+
+	source, err := parseFeedProfileSource(data)
+	if err != nil {
+		return nil, err
+	}
+
+	r.updateBrowseRecord(item.VideoID, source)
+	return source, nil
+}
+
+// updateBrowseRecord 将新的下载地址写回浏览记录
+func (r *URLResolver) updateBrowseRecord(videoID string, source *ResolvedSource) {
+	if videoID == "" {
+		return
+	}
+
+	browseRepo := database.NewBrowseHistoryRepository()
+	record, err := browseRepo.GetByID(videoID)
+	if err != nil || record == nil {
+		return
+	}
+
+	record.VideoURL = source.VideoURL
+	record.DecryptKey = source.DecryptKey
+	if err := browseRepo.Update(record); err != nil {
+		utils.Warn("[URLResolver] Failed to update browse record %s: %v", videoID, err)
+	}
+}
+
+// hasFeedParams 检查页面地址是否包含 oid 和 nid 参数
+func hasFeedParams(pageURL string) bool {
+	if pageURL == "" {
+		return false
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return false
+	}
+	query := u.Query()
+	return query.Get("oid") != "" && query.Get("nid") != ""
+}
+
+// feedProfileObject feed_profile 响应中的视频对象
+type feedProfileObject struct {
+	ObjectDesc struct {
+		Media []struct {
+			URL       string          `json:"url"`
+			URLToken  string          `json:"urlToken"`
+			DecodeKey json.RawMessage `json:"decodeKey"`
+		} `json:"media"`
+	} `json:"objectDesc"`
+}
+
+// parseFeedProfileSource 从 feed_profile 响应中提取下载地址和解密密钥
+func parseFeedProfileSource(data json.RawMessage) (*ResolvedSource, error) {
+	var resp struct {
+		Data struct {
+			Object feedProfileObject `json:"object"`
+		} `json:"data"`
+		Object feedProfileObject `json:"object"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse feed profile: %w", err)
+	}
+
+	media := resp.Data.Object.ObjectDesc.Media
+	if len(media) == 0 {
+		media = resp.Object.ObjectDesc.Media
+	}
+	if len(media) == 0 || media[0].URL == "" {
+		return nil, fmt.Errorf("feed profile contains no video url")
+	}
+
+	// decodeKey 可能是字符串或数字，保留原始文本避免大整数丢失精度
+	decryptKey := strings.Trim(string(media[0].DecodeKey), `"`)
+	if decryptKey == "null" {
+		decryptKey = ""
+	}
+
+	return &ResolvedSource{
+		VideoURL:   media[0].URL + media[0].URLToken,
+		DecryptKey: decryptKey,
+	}, nil
+}
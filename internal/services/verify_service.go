@@ -0,0 +1,186 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"wx_channel/internal/database"
+	"wx_channel/pkg/mp4"
+)
+
+// 文件级校验问题代码（结构问题代码见 mp4 包）
+const (
+	IssueFileMissing  = "file_missing"
+	IssueSizeMismatch = "size_mismatch"
+)
+
+// VerifyResult 单个下载文件的校验结果
+type VerifyResult struct {
+	RecordID     string      `json:"recordId,omitempty"`
+	VideoID      string      `json:"videoId,omitempty"`
+	Title        string      `json:"title,omitempty"`
+	FilePath     string      `json:"filePath"`
+	ExpectedSize int64       `json:"expectedSize"`
+	ActualSize   int64       `json:"actualSize"`
+	Valid        bool        `json:"valid"`
+	Issues       []mp4.Issue `json:"issues,omitempty"`
+	RepairHint   string      `json:"repairHint,omitempty"`
+	Report       *mp4.Report `json:"report,omitempty"`
+}
+
+// addIssue 记录问题，第一个问题的修复建议作为结果的修复建议
+func (r *VerifyResult) addIssue(code, hint, message string) {
+	r.Valid = false
+	r.Issues = append(r.Issues, mp4.Issue{Code: code, Message: message})
+	if r.RepairHint == "" {
+		r.RepairHint = hint
+	}
+}
+
+// LibraryScanResult 批量校验下载库的结果
+type LibraryScanResult struct {
+	Total     int            `json:"total"`
+	Valid     int            `json:"valid"`
+	Invalid   int            `json:"invalid"`
+	Skipped   int            `json:"skipped"` // 未完成的下载记录
+	Results   []VerifyResult `json:"results"` // 仅包含校验失败的记录
+	StartedAt time.Time      `json:"startedAt"`
+	Duration  int64          `json:"duration"` // 毫秒
+}
+
+// VerifyService 校验已下载文件的大小和 MP4 结构
+type VerifyService struct {
+	repo *database.DownloadRecordRepository
+}
+
+// NewVerifyService 创建一个新的 VerifyService
+func NewVerifyService() *VerifyService {
+	return &VerifyService{
+		repo: database.NewDownloadRecordRepository(),
+	}
+}
+
+// VerifyRecord 校验下载记录对应的文件，记录不存在时返回 nil
+func (s *VerifyService) VerifyRecord(id string) (*VerifyResult, error) {
+	record, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, nil
+	}
+	return verifyRecord(record), nil
+}
+
+// ScanLibrary 校验所有已完成的下载记录
+func (s *VerifyService) ScanLibrary() (*LibraryScanResult, error) {
+	records, err := s.repo.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load download records: %w", err)
+	}
+
+	result := &LibraryScanResult{
+		Results:   []VerifyResult{},
+		StartedAt: time.Now(),
+	}
+	for i := range records {
+		if records[i].Status != database.DownloadStatusCompleted {
+			result.Skipped++
+			continue
+		}
+		result.Total++
+		verified := verifyRecord(&records[i])
+		if verified.Valid {
+			result.Valid++
+			continue
+		}
+		result.Invalid++
+		// 批量结果中省略完整报告以减小响应体积
+		verified.Report = nil
+		result.Results = append(result.Results, *verified)
+	}
+	result.Duration = time.Since(result.StartedAt).Milliseconds()
+	return result, nil
+}
+
+// verifyRecord 校验下载记录对应的文件
+func verifyRecord(record *database.DownloadRecord) *VerifyResult {
+	result := VerifyFile(record.FilePath, record.FileSize)
+	result.RecordID = record.ID
+	result.VideoID = record.VideoID
+	result.Title = record.Title
+	return result
+}
+
+// VerifyFile 校验文件是否存在、大小是否一致（expectedSize 为 0 时跳过），MP4 文件还会校验结构
+func VerifyFile(filePath string, expectedSize int64) *VerifyResult {
+	result := &VerifyResult{
+		FilePath:     filePath,
+		ExpectedSize: expectedSize,
+		Valid:        true,
+	}
+
+	if filePath == "" {
+		result.addIssue(IssueFileMissing, "re-download the video", "download record has no file path")
+		return result
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			result.addIssue(IssueFileMissing, "re-download the video", "file does not exist")
+		} else {
+			result.addIssue(IssueFileMissing, "check file permissions", fmt.Sprintf("failed to stat file: %v", err))
+		}
+		return result
+	}
+	result.ActualSize = info.Size()
+
+	if isMP4Path(filePath) {
+		report, err := mp4.Validate(filePath)
+		if err != nil {
+			result.addIssue(IssueFileMissing, "check file permissions", err.Error())
+			return result
+		}
+		result.Report = report
+		for _, issue := range report.Issues {
+			result.addIssue(issue.Code, report.RepairHint, issue.Message)
+		}
+	}
+
+	// 结构问题的修复建议更具体，大小不一致放在最后
+	if expectedSize > 0 && result.ActualSize != expectedSize {
+		hint := "re-download the video"
+		if result.ActualSize < expectedSize {
+			hint = "the download is incomplete: resume or re-download the file"
+		}
+		result.addIssue(IssueSizeMismatch, hint, fmt.Sprintf("file size mismatch: expected %d bytes, got %d bytes", expectedSize, result.ActualSize))
+	}
+	return result
+}
+
+// checkMP4Structure 校验 MP4 文件结构，有问题时返回包含修复建议的错误
+func checkMP4Structure(filePath string) error {
+	if !isMP4Path(filePath) {
+		return nil
+	}
+	report, err := mp4.Validate(filePath)
+	if err != nil {
+		return err
+	}
+	if report.Valid {
+		return nil
+	}
+	return fmt.Errorf("invalid mp4 structure: %s (%s)", report.Issues[0].Message, report.RepairHint)
+}
+
+// isMP4Path 根据扩展名判断是否为 MP4 文件
+func isMP4Path(filePath string) bool {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".mp4", ".m4v", ".mov":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"wx_channel/internal/database"
+)
+
+// 互动数据曲线的采样间隔
+const (
+	MetricsIntervalRaw  = "raw"  // 返回每次看到视频时的快照
+	MetricsIntervalHour = "hour" // 每小时取最后一次快照
+	MetricsIntervalDay  = "day"  // 每天（本地时间）取最后一次快照
+)
+
+// 互动数据错误
+var (
+	ErrVideoNotFound          = errors.New("video not found")
+	ErrInvalidMetricsInterval = errors.New("invalid metrics interval")
+)
+
+// VideoMetricsSeries 视频的互动数据曲线
+type VideoMetricsSeries struct {
+	VideoID  string                          `json:"videoId"`
+	Title    string                          `json:"title"`
+	Author   string                          `json:"author"`
+	Interval string                          `json:"interval"`
+	Points   []database.VideoMetricsSnapshot `json:"points"`
+	// Change 最后一个点相对第一个点的变化，少于两个点时为 nil
+	Change *VideoMetricsChange `json:"change,omitempty"`
+}
+
+// VideoMetricsChange 两个时间点之间互动数据的变化
+type VideoMetricsChange struct {
+	LikeCount    int64     `json:"likeCount"`
+	CommentCount int64     `json:"commentCount"`
+	FavCount     int64     `json:"favCount"`
+	ForwardCount int64     `json:"forwardCount"`
+	Since        time.Time `json:"since"`
+	Until        time.Time `json:"until"`
+}
+
+// VideoMetricsService 记录浏览视频时的互动数据，并提供随时间变化的曲线
+type VideoMetricsService struct {
+	repo       *database.VideoMetricsRepository
+	browseRepo *database.BrowseHistoryRepository
+}
+
+// NewVideoMetricsService 创建一个新的 VideoMetricsService
+func NewVideoMetricsService() *VideoMetricsService {
+	return &VideoMetricsService{
+		repo:       database.NewVideoMetricsRepository(),
+		browseRepo: database.NewBrowseHistoryRepository(),
+	}
+}
+
+// Record 为浏览记录追加一条互动数据快照，浏览记录需要已保存
+func (s *VideoMetricsService) Record(record *database.BrowseRecord) error {
+	return s.repo.Add(record.ID, &database.VideoMetricsSnapshot{
+		LikeCount:    record.LikeCount,
+		CommentCount: record.CommentCount,
+		FavCount:     record.FavCount,
+		ForwardCount: record.ForwardCount,
+		CapturedAt:   record.BrowseTime,
+	})
+}
+
+// Series 获取视频在时间范围内的互动数据曲线，interval 为空时返回全部快照
+func (s *VideoMetricsService) Series(videoID, interval string, since, until *time.Time) (*VideoMetricsSeries, error) {
+	if interval == "" {
+		interval = MetricsIntervalRaw
+	}
+	if interval != MetricsIntervalRaw && interval != MetricsIntervalHour && interval != MetricsIntervalDay {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMetricsInterval, interval)
+	}
+
+	record, err := s.browseRepo.GetByID(videoID)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, ErrVideoNotFound
+	}
+
+	snapshots, err := s.repo.List(videoID, since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	series := &VideoMetricsSeries{
+		VideoID:  record.ID,
+		Title:    record.Title,
+		Author:   record.Author,
+		Interval: interval,
+		Points:   downsampleMetrics(snapshots, interval),
+	}
+	if n := len(series.Points); n > 1 {
+		first, last := series.Points[0], series.Points[n-1]
+		series.Change = &VideoMetricsChange{
+			LikeCount:    last.LikeCount - first.LikeCount,
+			CommentCount: last.CommentCount - first.CommentCount,
+			FavCount:     last.FavCount - first.FavCount,
+			ForwardCount: last.ForwardCount - first.ForwardCount,
+			Since:        first.CapturedAt,
+			Until:        last.CapturedAt,
+		}
+	}
+	return series, nil
+}
+
+// downsampleMetrics 按小时或天分组，每组保留最后一次快照；snapshots 需按时间先后排序
+func downsampleMetrics(snapshots []database.VideoMetricsSnapshot, interval string) []database.VideoMetricsSnapshot {
+	if interval == MetricsIntervalRaw {
+		return snapshots
+	}
+
+	points := []database.VideoMetricsSnapshot{}
+	lastKey := ""
+	for _, snapshot := range snapshots {
+		local := snapshot.CapturedAt.Local()
+		key := local.Format("2006-01-02")
+		if interval == MetricsIntervalHour {
+			key = local.Format("2006-01-02 15")
+		}
+		if key == lastKey {
+			points[len(points)-1] = snapshot
+			continue
+		}
+		points = append(points, snapshot)
+		lastKey = key
+	}
+	return points
+}
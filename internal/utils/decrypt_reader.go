@@ -147,3 +147,27 @@ func (ctx *Isaac64Ctx) isaac64() {
 		ctx.randrsl[j] = ctx.bb
 	}
 }
+
+// PrefixDecryptReader 使用旧版批量下载脚本提供的解密前缀对数据流进行 XOR 解密
+// 只解密前缀覆盖的部分，之后的数据原样返回
+type PrefixDecryptReader struct {
+	reader io.Reader
+	prefix []byte
+	offset uint64 // 下一个字节在文件中的偏移
+}
+
+// NewPrefixDecryptReader 创建一个新的前缀解密读取器
+// offset: 数据流起始位置在文件中的偏移（用于 Range 请求）
+func NewPrefixDecryptReader(reader io.Reader, prefix []byte, offset uint64) *PrefixDecryptReader {
+	return &PrefixDecryptReader{reader: reader, prefix: prefix, offset: offset}
+}
+
+// Read 实现 io.Reader 接口
+func (r *PrefixDecryptReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	for i := 0; i < n && r.offset+uint64(i) < uint64(len(r.prefix)); i++ {
+		p[i] ^= r.prefix[r.offset+uint64(i)]
+	}
+	r.offset += uint64(n)
+	return n, err
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrefixDecryptReader(t *testing.T) {
+	data := bytes.Repeat([]byte("encrypted video data "), 20)
+	prefix := make([]byte, 100)
+	for i := range prefix {
+		prefix[i] = byte(i*31 + 7)
+	}
+
+	// 与下载完成后原地解密的结果一致
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := DecryptFileInPlace(path, "", base64.StdEncoding.EncodeToString(prefix), len(prefix)); err != nil {
+		t.Fatalf("DecryptFileInPlace error: %v", err)
+	}
+	want, _ := os.ReadFile(path)
+
+	// 按不同的分片边界读取，跨越前缀末尾
+	for _, chunkSize := range []int{1, 7, 64, 100, 150, len(data)} {
+		var got []byte
+		for start := 0; start < len(data); start += chunkSize {
+			end := start + chunkSize
+			if end > len(data) {
+				end = len(data)
+			}
+			r := NewPrefixDecryptReader(bytes.NewReader(data[start:end]), prefix, uint64(start))
+			chunk, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("read error: %v", err)
+			}
+			got = append(got, chunk...)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("chunk size %d: decrypted data does not match", chunkSize)
+		}
+	}
+}
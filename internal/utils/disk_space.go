@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DiskSpace 磁盘空间信息（字节）
+type DiskSpace struct {
+	Total     uint64 `json:"total"`
+	Available uint64 `json:"available"` // 当前用户可用的空间
+}
+
+// GetDiskSpace 获取 path 所在卷的磁盘空间，path 不存在时使用最近的已存在上级目录
+func GetDiskSpace(path string) (*DiskSpace, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, fmt.Errorf("no existing directory for %s", path)
+		}
+		dir = parent
+	}
+
+	space, err := diskSpace(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get disk space: %w", err)
+	}
+	return space, nil
+}
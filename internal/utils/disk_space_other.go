@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !windows
+
+package utils
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// diskSpace 当前平台不支持获取磁盘空间
+func diskSpace(dir string) (*DiskSpace, error) {
+	return nil, fmt.Errorf("disk space is not supported on %s", runtime.GOOS)
+}
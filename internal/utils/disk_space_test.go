@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestGetDiskSpace(t *testing.T) {
+	dir := t.TempDir()
+
+	space, err := GetDiskSpace(dir)
+	if err != nil {
+		t.Fatalf("GetDiskSpace(%q) error: %v", dir, err)
+	}
+	if space.Total == 0 || space.Available > space.Total {
+		t.Fatalf("unexpected disk space: %+v", space)
+	}
+
+	// 不存在的目录使用最近的已存在上级目录
+	missing, err := GetDiskSpace(filepath.Join(dir, "missing", "nested"))
+	if err != nil {
+		t.Fatalf("GetDiskSpace for missing dir error: %v", err)
+	}
+	if missing.Total != space.Total {
+		t.Fatalf("expected same volume total %d, got %d", space.Total, missing.Total)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build linux || darwin
+
+package utils
+
+import "syscall"
+
+// diskSpace 使用 statfs 获取磁盘空间
+func diskSpace(dir string) (*DiskSpace, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return nil, err
+	}
+	blockSize := uint64(stat.Bsize)
+	return &DiskSpace{
+		Total:     uint64(stat.Blocks) * blockSize,
+		Available: uint64(stat.Bavail) * blockSize,
+	}, nil
+}
@@ -0,0 +1,30 @@
+//go:build windows
+
+package utils
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceExW = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// diskSpace 使用 GetDiskFreeSpaceExW 获取磁盘空间
+func diskSpace(dir string) (*DiskSpace, error) {
+	p, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var available, total, free uint64
+	ret, _, callErr := procGetDiskFreeSpaceExW.Call(
+		uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&available)),
+		uintptr(unsafe.Pointer(&total)),
+		uintptr(unsafe.Pointer(&free)),
+	)
+	if ret == 0 {
+		return nil, callErr
+	}
+	return &DiskSpace{Total: total, Available: available}, nil
+}
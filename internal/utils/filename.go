@@ -11,6 +11,11 @@ import (
 
 // CleanFilename 清理文件名，移除非法字符
 func CleanFilename(filename string) string {
+	return CleanFilenameWithLimit(filename, DefaultFilenameMaxLength)
+}
+
+// CleanFilenameWithLimit 清理文件名并限制为 maxLength 个字符
+func CleanFilenameWithLimit(filename string, maxLength int) string {
 	// 先移除HTML标签（如 <em class="highlight">纪录片</em>）
 	htmlTagRegex := regexp.MustCompile(`<[^>]*>`)
 	filename = htmlTagRegex.ReplaceAllString(filename, "")
@@ -55,11 +60,10 @@ func CleanFilename(filename string) string {
 	}
 
 	// 限制文件名长度，避免路径过长导致保存失败
-	// Windows 路径限制为 260 字符，考虑到目录路径和扩展名，文件名主体限制为 50 个字符
+	// Windows 路径限制为 260 字符，考虑到目录路径和扩展名，文件名主体默认限制为 50 个字符
 	// 使用 rune 而不是 byte 来正确处理中文等多字节字符
-	maxLength := 50
 	runes := []rune(filename)
-	if len(runes) > maxLength {
+	if maxLength > 0 && len(runes) > maxLength {
 		// 截断，不添加省略号（避免文件名中出现特殊字符）
 		filename = string(runes[:maxLength])
 	}
@@ -0,0 +1,194 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultFilenameMaxLength 文件名主体和目录名的默认最大字符数
+const DefaultFilenameMaxLength = 50
+
+// filenamePlaceholderRegex 匹配模板中的 {name} 占位符
+var filenamePlaceholderRegex = regexp.MustCompile(`\{([a-zA-Z-]+)\}`)
+
+// FilenameFields 文件名模板可用的视频字段
+type FilenameFields struct {
+	Title        string    `json:"title"`
+	Author       string    `json:"author"`
+	AuthorID     string    `json:"authorId"`
+	VideoID      string    `json:"videoId"`
+	Resolution   string    `json:"resolution"`
+	Source       string    `json:"source"` // 页面来源，例如 feed、profile、batch_console
+	Ext          string    `json:"ext"`    // 不带点的扩展名，默认 mp4
+	Time         time.Time `json:"time"`
+	LikeCount    int64     `json:"likeCount"`
+	CommentCount int64     `json:"commentCount"`
+	ForwardCount int64     `json:"forwardCount"`
+	FavCount     int64     `json:"favCount"`
+}
+
+// FilenamePlaceholders 返回模板支持的占位符及其说明
+func FilenamePlaceholders() map[string]string {
+	return map[string]string{
+		"title":      "视频标题",
+		"author":     "作者昵称",
+		"authorId":   "作者 ID",
+		"videoId":    "视频 ID",
+		"resolution": "分辨率",
+		"source":     "页面来源",
+		"ext":        "扩展名",
+		"date":       "日期 (20060102)",
+		"time":       "时间 (150405)",
+		"yyyy":       "年",
+		"mm":         "月",
+		"dd":         "日",
+		"yyyy-mm":    "年-月",
+		"yyyy-mm-dd": "年-月-日",
+		"likes":      "点赞数",
+		"comments":   "评论数",
+		"forwards":   "转发数",
+		"favs":       "收藏数",
+	}
+}
+
+// value 返回占位符对应的值
+func (f FilenameFields) value(name string) (string, bool) {
+	t := f.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	switch name {
+	case "title":
+		return f.Title, true
+	case "author":
+		if strings.TrimSpace(f.Author) == "" {
+			return "未知作者", true
+		}
+		return f.Author, true
+	case "authorId":
+		return f.AuthorID, true
+	case "videoId":
+		return f.VideoID, true
+	case "resolution":
+		return f.Resolution, true
+	case "source":
+		return f.Source, true
+	case "ext":
+		return f.ext(), true
+	case "date":
+		return t.Format("20060102"), true
+	case "time":
+		return t.Format("150405"), true
+	case "yyyy":
+		return t.Format("2006"), true
+	case "mm":
+		return t.Format("01"), true
+	case "dd":
+		return t.Format("02"), true
+	case "yyyy-mm":
+		return t.Format("2006-01"), true
+	case "yyyy-mm-dd":
+		return t.Format("2006-01-02"), true
+	case "likes":
+		return strconv.FormatInt(f.LikeCount, 10), true
+	case "comments":
+		return strconv.FormatInt(f.CommentCount, 10), true
+	case "forwards":
+		return strconv.FormatInt(f.ForwardCount, 10), true
+	case "favs":
+		return strconv.FormatInt(f.FavCount, 10), true
+	}
+	return "", false
+}
+
+// ext 返回不带点的扩展名
+func (f FilenameFields) ext() string {
+	ext := strings.TrimPrefix(f.Ext, ".")
+	if ext == "" {
+		ext = "mp4"
+	}
+	return ext
+}
+
+// splitTemplate 按 / 或 \ 拆分模板中的目录层级
+func splitTemplate(tmpl string) []string {
+	return strings.FieldsFunc(tmpl, func(r rune) bool {
+		return r == '/' || r == '\\'
+	})
+}
+
+// ValidateFilenameTemplate 验证文件名模板
+func ValidateFilenameTemplate(tmpl string) error {
+	if strings.TrimSpace(tmpl) == "" {
+		return fmt.Errorf("filename template is empty")
+	}
+	if strings.HasPrefix(tmpl, "/") || strings.HasPrefix(tmpl, "\\") || strings.Contains(tmpl, ":") {
+		return fmt.Errorf("filename template must be a relative path")
+	}
+
+	var fields FilenameFields
+	for _, match := range filenamePlaceholderRegex.FindAllStringSubmatch(tmpl, -1) {
+		if _, ok := fields.value(match[1]); !ok {
+			return fmt.Errorf("unknown placeholder in filename template: {%s}", match[1])
+		}
+	}
+
+	segments := splitTemplate(tmpl)
+	if len(segments) == 0 || strings.HasSuffix(tmpl, "/") || strings.HasSuffix(tmpl, "\\") {
+		return fmt.Errorf("filename template must end with a file name")
+	}
+	for _, segment := range segments {
+		if segment == "." || segment == ".." {
+			return fmt.Errorf("filename template must not contain relative directory segments")
+		}
+	}
+	return nil
+}
+
+// RenderFilenameTemplate 按模板生成相对保存路径（使用 / 分隔目录）
+// 每一级目录和文件名主体都会清理非法字符并限制在 maxLength 个字符以内，
+// 渲染后为空的目录层级会被跳过
+func RenderFilenameTemplate(tmpl string, fields FilenameFields, maxLength int) (string, error) {
+	if err := ValidateFilenameTemplate(tmpl); err != nil {
+		return "", err
+	}
+	if maxLength <= 0 {
+		maxLength = DefaultFilenameMaxLength
+	}
+
+	render := func(segment string) string {
+		return filenamePlaceholderRegex.ReplaceAllStringFunc(segment, func(match string) string {
+			v, _ := fields.value(match[1 : len(match)-1])
+			return v
+		})
+	}
+
+	segments := splitTemplate(tmpl)
+	parts := make([]string, 0, len(segments))
+
+	// 目录层级
+	for _, segment := range segments[:len(segments)-1] {
+		dir := strings.TrimSpace(render(segment))
+		if dir == "" {
+			continue
+		}
+		dir = strings.TrimRight(CleanFilenameWithLimit(dir, maxLength), ".")
+		if strings.TrimSpace(dir) == "" {
+			continue
+		}
+		parts = append(parts, dir)
+	}
+
+	// 文件名：扩展名不计入长度限制
+	ext := "." + fields.ext()
+	name := render(segments[len(segments)-1])
+	base := strings.TrimSuffix(name, ext)
+	base = CleanFilenameWithLimit(base, maxLength)
+	parts = append(parts, base+ext)
+
+	return strings.Join(parts, "/"), nil
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderFilenameTemplate(t *testing.T) {
+	fields := FilenameFields{
+		Title:      "测试/视频:标题",
+		Author:     "作者",
+		VideoID:    "123",
+		Resolution: "1080x1920",
+		Time:       time.Date(2024, 3, 5, 8, 9, 10, 0, time.Local),
+		LikeCount:  42,
+	}
+
+	testCases := []struct {
+		template string
+		expected string
+		desc     string
+	}{
+		{"{author}/{yyyy-mm}/{date}_{title}_{videoId}.{ext}", "作者/2024-03/20240305_测试_视频_标题_123.mp4", "目录和日期占位符"},
+		{"{title}_{resolution}_{likes}", "测试_视频_标题_1080x1920_42.mp4", "缺少扩展名时自动添加"},
+		{"{source}/{author}/{title}.{ext}", "作者/测试_视频_标题.mp4", "空目录层级被跳过"},
+		{"{yyyy}/{mm}/{dd}/{videoId}.{ext}", "2024/03/05/123.mp4", "年月日目录"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			path, err := RenderFilenameTemplate(tc.template, fields, 50)
+			if err != nil {
+				t.Fatalf("渲染模板失败: %v", err)
+			}
+			if path != tc.expected {
+				t.Errorf("模板: %q, 期望: %q, 实际: %q", tc.template, tc.expected, path)
+			}
+		})
+	}
+}
+
+func TestRenderFilenameTemplate_MaxLength(t *testing.T) {
+	fields := FilenameFields{Title: strings.Repeat("长", 100), Author: "作者"}
+
+	path, err := RenderFilenameTemplate("{author}/{title}.{ext}", fields, 20)
+	if err != nil {
+		t.Fatalf("渲染模板失败: %v", err)
+	}
+
+	name := strings.TrimSuffix(path[strings.LastIndex(path, "/")+1:], ".mp4")
+	if len([]rune(name)) != 20 {
+		t.Errorf("文件名主体长度应为 20, 实际 = %d", len([]rune(name)))
+	}
+	if !strings.HasSuffix(path, ".mp4") {
+		t.Errorf("截断后应保留扩展名: %s", path)
+	}
+}
+
+func TestValidateFilenameTemplate(t *testing.T) {
+	invalid := []string{
+		"",
+		"/abs/{title}",
+		"C:/{title}",
+		"{author}/../{title}",
+		"{unknown}.{ext}",
+		"{author}/",
+	}
+	for _, tmpl := range invalid {
+		if err := ValidateFilenameTemplate(tmpl); err == nil {
+			t.Errorf("模板 %q 应该验证失败", tmpl)
+		}
+	}
+
+	if err := ValidateFilenameTemplate("{author}/{yyyy-mm}/{date}_{title}_{videoId}.{ext}"); err != nil {
+		t.Errorf("有效模板验证失败: %v", err)
+	}
+}
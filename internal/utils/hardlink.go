@@ -0,0 +1,12 @@
+package utils
+
+import "fmt"
+
+// LinkCount 返回文件的硬链接数，不支持的平台返回 1
+func LinkCount(path string) (uint64, error) {
+	n, err := linkCount(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get link count: %w", err)
+	}
+	return n, nil
+}
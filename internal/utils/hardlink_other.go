@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !windows
+
+package utils
+
+import "os"
+
+// linkCount 当前平台无法获取硬链接数，文件存在时按 1 处理
+func linkCount(path string) (uint64, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestLinkCount(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "video.mp4")
+	if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	n, err := LinkCount(path)
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 link, got %d (%v)", n, err)
+	}
+
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "windows" {
+		t.Skipf("link count is not supported on %s", runtime.GOOS)
+	}
+	if err := os.Link(path, filepath.Join(dir, "copy.mp4")); err != nil {
+		t.Skipf("hardlinks not supported: %v", err)
+	}
+	n, err = LinkCount(path)
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 links, got %d (%v)", n, err)
+	}
+
+	if _, err := LinkCount(filepath.Join(dir, "missing.mp4")); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
@@ -0,0 +1,22 @@
+//go:build linux || darwin
+
+package utils
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// linkCount 从 stat 结果读取硬链接数
+func linkCount(path string) (uint64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("unexpected stat type %T", info.Sys())
+	}
+	return uint64(stat.Nlink), nil
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package utils
+
+import (
+	"os"
+	"syscall"
+)
+
+// linkCount 使用 GetFileInformationByHandle 读取硬链接数
+func linkCount(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var info syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(syscall.Handle(f.Fd()), &info); err != nil {
+		return 0, err
+	}
+	return uint64(info.NumberOfLinks), nil
+}
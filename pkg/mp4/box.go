@@ -0,0 +1,168 @@
+// Package mp4 提供纯 Go 的 MP4 (ISO BMFF) box 读写，不依赖 ffmpeg 等外部工具
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ErrInvalidBox 表示 box 头部损坏或大小超出父容器
+var ErrInvalidBox = errors.New("invalid mp4 box")
+
+// Box 表示文件中的一个 box
+type Box struct {
+	Type       string
+	Offset     int64 // box 在文件中的起始偏移
+	Size       int64 // 包含头部的总大小
+	HeaderSize int64
+}
+
+// End 返回 box 结束位置（不含）
+func (b Box) End() int64 {
+	return b.Offset + b.Size
+}
+
+// PayloadOffset 返回 box 内容的起始偏移
+func (b Box) PayloadOffset() int64 {
+	return b.Offset + b.HeaderSize
+}
+
+// ReadBoxes 读取 [start, end) 范围内的同级 box
+func ReadBoxes(r io.ReaderAt, start, end int64) ([]Box, error) {
+	var boxes []Box
+	offset := start
+	for offset < end {
+		box, err := readBoxHeader(r, offset, end)
+		if err != nil {
+			return boxes, err
+		}
+		boxes = append(boxes, box)
+		offset = box.End()
+	}
+	return boxes, nil
+}
+
+// FindBox 返回第一个指定类型的 box
+func FindBox(boxes []Box, typ string) (Box, bool) {
+	for _, box := range boxes {
+		if box.Type == typ {
+			return box, true
+		}
+	}
+	return Box{}, false
+}
+
+// readBoxHeader 读取 offset 处的 box 头部
+func readBoxHeader(r io.ReaderAt, offset, end int64) (Box, error) {
+	if end-offset < 8 {
+		return Box{}, fmt.Errorf("%w: truncated header at offset %d", ErrInvalidBox, offset)
+	}
+
+	var header [16]byte
+	if _, err := r.ReadAt(header[:8], offset); err != nil {
+		return Box{}, fmt.Errorf("failed to read box header at offset %d: %w", offset, err)
+	}
+
+	box := Box{
+		Type:       string(header[4:8]),
+		Offset:     offset,
+		Size:       int64(binary.BigEndian.Uint32(header[0:4])),
+		HeaderSize: 8,
+	}
+
+	switch box.Size {
+	case 0:
+		// 大小为 0 表示延伸到文件末尾
+		box.Size = end - offset
+	case 1:
+		// 64 位大小
+		if end-offset < 16 {
+			return Box{}, fmt.Errorf("%w: truncated largesize header at offset %d", ErrInvalidBox, offset)
+		}
+		if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+			return Box{}, fmt.Errorf("failed to read box header at offset %d: %w", offset, err)
+		}
+		largeSize := binary.BigEndian.Uint64(header[8:16])
+		if largeSize > math.MaxInt64 {
+			return Box{}, fmt.Errorf("%w: box %q size overflows at offset %d", ErrInvalidBox, box.Type, offset)
+		}
+		box.Size = int64(largeSize)
+		box.HeaderSize = 16
+	}
+
+	if box.Size < box.HeaderSize || box.Size > end-offset {
+		return Box{}, fmt.Errorf("%w: box %q at offset %d has size %d beyond parent end %d", ErrInvalidBox, box.Type, offset, box.Size, end)
+	}
+	return box, nil
+}
+
+// rawBox 内存中的 box，Data 包含完整的头部和内容
+type rawBox struct {
+	Type   string
+	Header int
+	Data   []byte
+}
+
+// Payload 返回 box 内容
+func (b rawBox) Payload() []byte {
+	return b.Data[b.Header:]
+}
+
+// parseBoxes 解析内存中的同级 box
+func parseBoxes(data []byte) ([]rawBox, error) {
+	var boxes []rawBox
+	r := byteReaderAt(data)
+	headers, err := ReadBoxes(r, 0, int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range headers {
+		boxes = append(boxes, rawBox{
+			Type:   h.Type,
+			Header: int(h.HeaderSize),
+			Data:   data[h.Offset:h.End()],
+		})
+	}
+	return boxes, nil
+}
+
+// encodeBox 生成指定类型和内容的 box
+func encodeBox(typ string, payloads ...[]byte) []byte {
+	size := 8
+	for _, p := range payloads {
+		size += len(p)
+	}
+
+	var out []byte
+	if uint64(size) > math.MaxUint32 {
+		out = make([]byte, 16, size+8)
+		binary.BigEndian.PutUint32(out[0:4], 1)
+		copy(out[4:8], typ)
+		binary.BigEndian.PutUint64(out[8:16], uint64(size+8))
+	} else {
+		out = make([]byte, 8, size)
+		binary.BigEndian.PutUint32(out[0:4], uint32(size))
+		copy(out[4:8], typ)
+	}
+	for _, p := range payloads {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// byteReaderAt 将字节切片包装为 io.ReaderAt
+type byteReaderAt []byte
+
+func (b byteReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 || off >= int64(len(b)) {
+		return 0, io.EOF
+	}
+	n := copy(p, b[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,217 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testFile 测试用 MP4 的布局选项
+type testFile struct {
+	moovFirst bool     // moov 位于 mdat 之前
+	co64      bool     // 使用 co64 而不是 stco
+	udta      []byte   // moov 中附加的 udta box（完整 box 数据）
+	samples   [][]byte // 每个分片一个样本
+	trailer   []byte   // 文件末尾附加的数据
+}
+
+// build 生成一个只有一个轨道的最小 MP4，分片偏移指向 mdat 中对应的样本
+func (f testFile) build() []byte {
+	ftyp := encodeBox("ftyp", []byte("isom"), []byte{0, 0, 2, 0}, []byte("isommp41"))
+
+	var media []byte
+	for _, s := range f.samples {
+		media = append(media, s...)
+	}
+	mdat := encodeBox("mdat", media)
+
+	// 先用 0 偏移生成 moov 以确定其大小，再按实际布局填入偏移
+	moovSize := len(f.moov(make([]int64, len(f.samples))))
+	mediaStart := int64(len(ftyp)) + 8
+	if f.moovFirst {
+		mediaStart += int64(moovSize)
+	}
+	offsets := make([]int64, len(f.samples))
+	offset := mediaStart
+	for i, s := range f.samples {
+		offsets[i] = offset
+		offset += int64(len(s))
+	}
+	moov := f.moov(offsets)
+
+	out := append([]byte{}, ftyp...)
+	if f.moovFirst {
+		out = append(out, moov...)
+		out = append(out, mdat...)
+	} else {
+		out = append(out, mdat...)
+		out = append(out, moov...)
+	}
+	return append(out, f.trailer...)
+}
+
+// moov 生成包含样本表的 moov box
+func (f testFile) moov(offsets []int64) []byte {
+	count := uint32(len(f.samples))
+
+	stsz := binary.BigEndian.AppendUint32([]byte{0, 0, 0, 0, 0, 0, 0, 0}, count)
+	for _, s := range f.samples {
+		stsz = binary.BigEndian.AppendUint32(stsz, uint32(len(s)))
+	}
+	// 每个分片一个样本
+	stsc := binary.BigEndian.AppendUint32([]byte{0, 0, 0, 0}, 1)
+	stsc = binary.BigEndian.AppendUint32(stsc, 1)
+	stsc = binary.BigEndian.AppendUint32(stsc, 1)
+	stsc = binary.BigEndian.AppendUint32(stsc, 1)
+
+	offsetType := "stco"
+	chunkOffsets := binary.BigEndian.AppendUint32([]byte{0, 0, 0, 0}, count)
+	for _, o := range offsets {
+		if f.co64 {
+			chunkOffsets = binary.BigEndian.AppendUint64(chunkOffsets, uint64(o))
+		} else {
+			chunkOffsets = binary.BigEndian.AppendUint32(chunkOffsets, uint32(o))
+		}
+	}
+	if f.co64 {
+		offsetType = "co64"
+	}
+
+	stbl := encodeBox("stbl",
+		encodeBox("stsd", []byte{0, 0, 0, 0, 0, 0, 0, 0}),
+		encodeBox("stts", []byte{0, 0, 0, 0, 0, 0, 0, 0}),
+		encodeBox("stsc", stsc),
+		encodeBox("stsz", stsz),
+		encodeBox(offsetType, chunkOffsets),
+	)
+	trak := encodeBox("trak", encodeBox("mdia", encodeBox("minf", stbl)))
+	payloads := [][]byte{encodeBox("mvhd", make([]byte, 100)), trak}
+	if f.udta != nil {
+		payloads = append(payloads, f.udta)
+	}
+	return encodeBox("moov", payloads...)
+}
+
+// writeTestFile 将数据写入临时目录中的 MP4 文件
+func writeTestFile(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+	return path
+}
+
+// testSamples 生成 n 个内容各不相同的样本
+func testSamples(n, size int) [][]byte {
+	samples := make([][]byte, n)
+	for i := range samples {
+		samples[i] = bytes.Repeat([]byte{byte('a' + i)}, size)
+	}
+	return samples
+}
+
+// readStbl 返回文件中第一个轨道的 stbl 子 box
+func readStbl(t *testing.T, data []byte) []rawBox {
+	t.Helper()
+	top, err := parseBoxes(data)
+	if err != nil {
+		t.Fatalf("failed to parse top-level boxes: %v", err)
+	}
+	moov, ok := findRawBox(top, "moov")
+	if !ok {
+		t.Fatal("moov not found")
+	}
+	stbl, err := findPath(moov.Payload(), "trak", "mdia", "minf", "stbl")
+	if err != nil {
+		t.Fatalf("failed to find stbl: %v", err)
+	}
+	children, err := parseBoxes(stbl)
+	if err != nil {
+		t.Fatalf("failed to parse stbl: %v", err)
+	}
+	return children
+}
+
+// assertSamples 检查分片偏移仍指向原来的样本数据
+func assertSamples(t *testing.T, path string, samples [][]byte) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	offsets, err := readChunkOffsets(readStbl(t, data))
+	if err != nil {
+		t.Fatalf("failed to read chunk offsets: %v", err)
+	}
+	if len(offsets) != len(samples) {
+		t.Fatalf("expected %d chunk offsets, got %d", len(samples), len(offsets))
+	}
+	for i, offset := range offsets {
+		end := offset + int64(len(samples[i]))
+		if end > int64(len(data)) || !bytes.Equal(data[offset:end], samples[i]) {
+			t.Fatalf("chunk %d at offset %d does not point at its sample", i, offset)
+		}
+	}
+}
+
+func TestReadBoxes(t *testing.T) {
+	valid := testFile{samples: testSamples(2, 16)}.build()
+
+	largeSize := []byte{0, 0, 0, 1, 'm', 'd', 'a', 't', 0, 0, 0, 0, 0, 0, 0, 20, 1, 2, 3, 4}
+	toEnd := append(encodeBox("ftyp", []byte("isom")), 0, 0, 0, 0, 'm', 'd', 'a', 't', 1, 2, 3)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		types   []string
+		wantErr bool
+	}{
+		{name: "valid", data: valid, types: []string{"ftyp", "mdat", "moov"}},
+		{name: "64-bit size", data: largeSize, types: []string{"mdat"}},
+		{name: "size 0 extends to end", data: toEnd, types: []string{"ftyp", "mdat"}},
+		{name: "truncated header", data: valid[:4], wantErr: true},
+		{name: "box beyond end", data: valid[:len(valid)-1], types: []string{"ftyp", "mdat"}, wantErr: true},
+		{name: "size smaller than header", data: []byte{0, 0, 0, 4, 'f', 'r', 'e', 'e'}, wantErr: true},
+		{name: "truncated largesize", data: []byte{0, 0, 0, 1, 'm', 'd', 'a', 't', 0, 0}, wantErr: true},
+		{name: "largesize overflow", data: []byte{0, 0, 0, 1, 'm', 'd', 'a', 't', 0xff, 0, 0, 0, 0, 0, 0, 0}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boxes, err := ReadBoxes(byteReaderAt(tt.data), 0, int64(len(tt.data)))
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidBox) {
+				t.Fatalf("expected ErrInvalidBox, got %v", err)
+			}
+			if len(boxes) != len(tt.types) {
+				t.Fatalf("expected boxes %v, got %+v", tt.types, boxes)
+			}
+			for i, box := range boxes {
+				if box.Type != tt.types[i] {
+					t.Fatalf("expected box %d to be %q, got %q", i, tt.types[i], box.Type)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeBox(t *testing.T) {
+	box := encodeBox("free", []byte{1, 2}, []byte{3})
+	if !bytes.Equal(box, []byte{0, 0, 0, 11, 'f', 'r', 'e', 'e', 1, 2, 3}) {
+		t.Fatalf("unexpected box encoding: %v", box)
+	}
+
+	boxes, err := parseBoxes(append(box, encodeBox("skip")...))
+	if err != nil {
+		t.Fatalf("parseBoxes error: %v", err)
+	}
+	if len(boxes) != 2 || boxes[0].Type != "free" || !bytes.Equal(boxes[0].Payload(), []byte{1, 2, 3}) || boxes[1].Type != "skip" {
+		t.Fatalf("unexpected parsed boxes: %+v", boxes)
+	}
+}
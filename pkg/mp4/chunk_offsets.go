@@ -0,0 +1,116 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrOffsetOverflow 表示调整后的 32 位 stco 偏移超出范围
+var ErrOffsetOverflow = errors.New("chunk offset overflows stco")
+
+// sampleTableParents 从 moov 到 stbl 需要逐层进入的容器
+var sampleTableParents = map[string]bool{
+	"trak": true,
+	"mdia": true,
+	"minf": true,
+	"stbl": true,
+}
+
+// shiftChunkOffsets 将 moov 中所有不小于 from 的 stco/co64 偏移加上 delta
+// moov 为完整的 moov box（含头部），原地修改
+func shiftChunkOffsets(moov []byte, from, delta int64) error {
+	return shiftChunkOffsetRange(moov, from, math.MaxInt64, delta)
+}
+
+// shiftChunkOffsetRange 将 moov 中位于 [from, to) 的 stco/co64 偏移加上 delta
+func shiftChunkOffsetRange(moov []byte, from, to, delta int64) error {
+	if delta == 0 {
+		return nil
+	}
+	boxes, err := parseBoxes(moov[boxHeaderLen(moov):])
+	if err != nil {
+		return err
+	}
+	return shiftInBoxes(boxes, from, to, delta)
+}
+
+// boxHeaderLen 返回内存中 box 的头部长度
+func boxHeaderLen(data []byte) int {
+	if len(data) >= 8 && binary.BigEndian.Uint32(data[0:4]) == 1 {
+		return 16
+	}
+	return 8
+}
+
+func shiftInBoxes(boxes []rawBox, from, to, delta int64) error {
+	for _, box := range boxes {
+		switch {
+		case sampleTableParents[box.Type]:
+			children, err := parseBoxes(box.Payload())
+			if err != nil {
+				return err
+			}
+			if err := shiftInBoxes(children, from, to, delta); err != nil {
+				return err
+			}
+		case box.Type == "stco":
+			if err := shiftStco(box.Payload(), from, to, delta); err != nil {
+				return err
+			}
+		case box.Type == "co64":
+			if err := shiftCo64(box.Payload(), from, to, delta); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// chunkOffsetEntries 校验 stco/co64 的条目数并返回条目数据
+func chunkOffsetEntries(payload []byte, entrySize int) ([]byte, error) {
+	if len(payload) < 8 {
+		return nil, fmt.Errorf("%w: chunk offset box too short", ErrInvalidBox)
+	}
+	count := int64(binary.BigEndian.Uint32(payload[4:8]))
+	entries := payload[8:]
+	if count*int64(entrySize) > int64(len(entries)) {
+		return nil, fmt.Errorf("%w: chunk offset box has %d entries but only %d bytes", ErrInvalidBox, count, len(entries))
+	}
+	return entries[:count*int64(entrySize)], nil
+}
+
+func shiftStco(payload []byte, from, to, delta int64) error {
+	entries, err := chunkOffsetEntries(payload, 4)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(entries); i += 4 {
+		offset := int64(binary.BigEndian.Uint32(entries[i : i+4]))
+		if offset < from || offset >= to {
+			continue
+		}
+		shifted := offset + delta
+		if shifted < 0 || shifted > math.MaxUint32 {
+			return ErrOffsetOverflow
+		}
+		binary.BigEndian.PutUint32(entries[i:i+4], uint32(shifted))
+	}
+	return nil
+}
+
+func shiftCo64(payload []byte, from, to, delta int64) error {
+	entries, err := chunkOffsetEntries(payload, 8)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(entries); i += 8 {
+		offset := int64(binary.BigEndian.Uint64(entries[i : i+8]))
+		if offset < from || offset >= to {
+			continue
+		}
+		binary.BigEndian.PutUint64(entries[i:i+8], uint64(offset+delta))
+	}
+	return nil
+}
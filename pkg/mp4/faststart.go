@@ -0,0 +1,46 @@
+package mp4
+
+import "errors"
+
+// ErrNoMdat 表示文件中没有 mdat box
+var ErrNoMdat = errors.New("mp4 file has no mdat box")
+
+// FastStart 将位于媒体数据之后的 moov 移到第一个 mdat 之前，浏览器无需下载整个文件即可开始播放
+// moov 已在 mdat 之前时不修改文件并返回 false；写入先生成临时文件再替换原文件，失败时原文件不受影响
+func FastStart(path string) (bool, error) {
+	changed, _, err := FastStartHash(path)
+	return changed, err
+}
+
+// FastStartHash 与 FastStart 相同，修改文件时同时返回新文件内容的 SHA-256（十六进制）
+// 哈希在生成临时文件时计算；文件未修改时返回空字符串
+func FastStartHash(path string) (bool, string, error) {
+	boxes, moov, moovData, err := readMoov(path)
+	if err != nil {
+		return false, "", err
+	}
+	mdat, ok := FindBox(boxes, "mdat")
+	if !ok {
+		return false, "", ErrNoMdat
+	}
+	if moov.Offset < mdat.Offset {
+		return false, "", nil
+	}
+
+	// moov 插入到第一个 mdat 之前，原来位于 [mdat, moov) 的数据整体后移 moov 的大小
+	// moov 之后的数据位置不变（移走和插入的大小相同）
+	if err := shiftChunkOffsetRange(moovData, mdat.Offset, moov.Offset, moov.Size); err != nil {
+		return false, "", err
+	}
+
+	sum, err := rewriteFile(path, []segment{
+		{Start: 0, End: mdat.Offset},
+		{Data: moovData},
+		{Start: mdat.Offset, End: moov.Offset},
+		{Start: moov.End(), End: -1},
+	})
+	if err != nil {
+		return false, "", err
+	}
+	return true, sum, nil
+}
@@ -0,0 +1,207 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"testing"
+)
+
+// topLevelTypes 返回文件顶层 box 的类型
+func topLevelTypes(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	boxes, err := parseBoxes(data)
+	if err != nil {
+		t.Fatalf("failed to parse file: %v", err)
+	}
+	types := make([]string, len(boxes))
+	for i, box := range boxes {
+		types[i] = box.Type
+	}
+	return types
+}
+
+func TestFastStart(t *testing.T) {
+	samples := testSamples(3, 64)
+	trailer := encodeBox("free", []byte("trailer"))
+
+	tests := []struct {
+		name  string
+		file  testFile
+		types []string
+	}{
+		{name: "stco", file: testFile{samples: samples}, types: []string{"ftyp", "moov", "mdat"}},
+		{name: "co64", file: testFile{co64: true, samples: samples}, types: []string{"ftyp", "moov", "mdat"}},
+		{name: "with udta", file: testFile{udta: encodeBox("udta", encodeBox("free")), samples: samples}, types: []string{"ftyp", "moov", "mdat"}},
+		{name: "box after moov", file: testFile{samples: samples, trailer: trailer}, types: []string{"ftyp", "moov", "mdat", "free"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.file.build()
+			path := writeTestFile(t, data)
+
+			changed, err := FastStart(path)
+			if err != nil {
+				t.Fatalf("FastStart error: %v", err)
+			}
+			if !changed {
+				t.Fatal("expected file to be rewritten")
+			}
+
+			types := topLevelTypes(t, path)
+			if len(types) != len(tt.types) {
+				t.Fatalf("expected boxes %v, got %v", tt.types, types)
+			}
+			for i := range types {
+				if types[i] != tt.types[i] {
+					t.Fatalf("expected boxes %v, got %v", tt.types, types)
+				}
+			}
+			// 偏移后移 moov 的大小后仍指向原来的样本
+			assertSamples(t, path, samples)
+
+			out, _ := os.ReadFile(path)
+			if len(out) != len(data) {
+				t.Fatalf("expected size %d, got %d", len(data), len(out))
+			}
+			if !bytes.HasSuffix(out, tt.file.trailer) {
+				t.Fatal("expected data after moov to be kept")
+			}
+			report, err := Validate(path)
+			if err != nil || !report.Valid || !report.FastStart {
+				t.Fatalf("expected valid faststart file, got %+v (%v)", report, err)
+			}
+
+			// 已经是 faststart 布局时不再修改
+			changed, err = FastStart(path)
+			if err != nil || changed {
+				t.Fatalf("expected no change on second run, got %v (%v)", changed, err)
+			}
+			again, _ := os.ReadFile(path)
+			if !bytes.Equal(again, out) {
+				t.Fatal("expected file to be unchanged on second run")
+			}
+		})
+	}
+}
+
+func TestFastStartHash(t *testing.T) {
+	path := writeTestFile(t, testFile{samples: testSamples(3, 64)}.build())
+	changed, sum, err := FastStartHash(path)
+	if err != nil || !changed {
+		t.Fatalf("expected file to be rewritten, got %v (%v)", changed, err)
+	}
+	if want := fileSHA256(t, path); sum != want {
+		t.Fatalf("expected hash %s, got %s", want, sum)
+	}
+
+	// 未修改文件时不计算哈希
+	changed, sum, err = FastStartHash(path)
+	if err != nil || changed || sum != "" {
+		t.Fatalf("expected no change and no hash, got %v %q (%v)", changed, sum, err)
+	}
+}
+
+func TestFastStart_InvalidFiles(t *testing.T) {
+	valid := testFile{samples: testSamples(2, 16)}.build()
+	ftyp := encodeBox("ftyp", []byte("isom"))
+	moov := testFile{samples: testSamples(1, 16)}.moov([]int64{0})
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{name: "no moov", data: append(append([]byte{}, ftyp...), encodeBox("mdat", []byte{1, 2, 3})...), want: ErrNoMoov},
+		{name: "no mdat", data: append(append([]byte{}, ftyp...), moov...), want: ErrNoMdat},
+		{name: "fragmented", data: append(append([]byte{}, valid...), encodeBox("moof")...), want: ErrFragmented},
+		{name: "truncated", data: valid[:len(valid)-10], want: ErrInvalidBox},
+		{name: "malformed stco", data: patchUint32(t, valid, "stco", 4, 1000), want: ErrInvalidBox},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestFile(t, tt.data)
+			changed, err := FastStart(path)
+			if !errors.Is(err, tt.want) || changed {
+				t.Fatalf("expected %v, got %v (changed %v)", tt.want, err, changed)
+			}
+			// 失败时原文件不受影响，也不留下临时文件
+			data, _ := os.ReadFile(path)
+			if !bytes.Equal(data, tt.data) {
+				t.Fatal("expected file to be unchanged")
+			}
+			if _, err := os.Stat(path + ".mp4tmp"); !os.IsNotExist(err) {
+				t.Fatal("expected temp file to be removed")
+			}
+		})
+	}
+}
+
+func TestShiftChunkOffsetRange(t *testing.T) {
+	offsets := []int64{100, 200, math.MaxUint32 - 10}
+
+	tests := []struct {
+		name     string
+		co64     bool
+		from, to int64
+		delta    int64
+		want     []int64
+		wantErr  error
+	}{
+		{name: "shift range", from: 150, to: 300, delta: 50, want: []int64{100, 250, math.MaxUint32 - 10}},
+		{name: "negative delta", from: 0, to: 300, delta: -100, want: []int64{0, 100, math.MaxUint32 - 10}},
+		{name: "stco overflow", from: 0, to: math.MaxInt64, delta: 11, wantErr: ErrOffsetOverflow},
+		{name: "stco below zero", from: 0, to: 150, delta: -101, wantErr: ErrOffsetOverflow},
+		{name: "co64 beyond 32 bits", co64: true, from: 0, to: math.MaxInt64, delta: 11, want: []int64{111, 211, math.MaxUint32 + 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			moov := testFile{co64: tt.co64, samples: testSamples(len(offsets), 1)}.moov(offsets)
+			err := shiftChunkOffsetRange(moov, tt.from, tt.to, tt.delta)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			stbl, err := findPath(moov[8:], "trak", "mdia", "minf", "stbl")
+			if err != nil {
+				t.Fatalf("failed to find stbl: %v", err)
+			}
+			children, _ := parseBoxes(stbl)
+			got, err := readChunkOffsets(children)
+			if err != nil {
+				t.Fatalf("failed to read chunk offsets: %v", err)
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Fatalf("expected offsets %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestShiftChunkOffsets(t *testing.T) {
+	moov := testFile{samples: testSamples(2, 1)}.moov([]int64{10, 20})
+	if err := shiftChunkOffsets(moov, 15, 5); err != nil {
+		t.Fatalf("shiftChunkOffsets error: %v", err)
+	}
+	i := bytes.Index(moov, []byte("stco"))
+	if got := binary.BigEndian.Uint32(moov[i+12:]); got != 10 {
+		t.Fatalf("expected first offset unchanged, got %d", got)
+	}
+	if got := binary.BigEndian.Uint32(moov[i+16:]); got != 25 {
+		t.Fatalf("expected second offset 25, got %d", got)
+	}
+}
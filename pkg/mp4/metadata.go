@@ -0,0 +1,425 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrNoMoov 表示文件中没有 moov box
+var ErrNoMoov = errors.New("mp4 file has no moov box")
+
+// ErrFragmented 表示分片 MP4（包含 moof），修改 moov 大小会破坏片段偏移
+var ErrFragmented = errors.New("fragmented mp4 is not supported")
+
+// iTunes 元数据条目类型
+const (
+	itemTitle   = "\xa9nam"
+	itemArtist  = "\xa9ART"
+	itemComment = "\xa9cmt"
+	itemDate    = "\xa9day"
+	itemCover   = "covr"
+)
+
+// data box 的类型标识
+const (
+	dataTypeUTF8 = 1
+	dataTypeJPEG = 13
+	dataTypePNG  = 14
+)
+
+// quickTimeKeys iTunes 条目对应的 QuickTime 元数据键（meta 中带 keys 时使用）
+var quickTimeKeys = map[string]string{
+	itemTitle:   "com.apple.quicktime.title",
+	itemArtist:  "com.apple.quicktime.artist",
+	itemComment: "com.apple.quicktime.comment",
+	itemDate:    "com.apple.quicktime.creationdate",
+	itemCover:   "com.apple.quicktime.artwork",
+}
+
+// keyNamespaceMdta QuickTime 元数据键的命名空间
+const keyNamespaceMdta = "mdta"
+
+// Metadata 写入 moov/udta/meta/ilst 的元数据，空字段不写入
+type Metadata struct {
+	Title   string
+	Artist  string
+	Comment string
+	Date    string // 例如 2024-03-05
+	Cover   []byte // JPEG 或 PNG 图片
+}
+
+// metadataItem 一个待写入的元数据条目
+type metadataItem struct {
+	Type     string // iTunes 条目类型
+	DataType uint32
+	Value    []byte
+}
+
+// items 返回非空字段对应的条目
+func (m *Metadata) items() []metadataItem {
+	var items []metadataItem
+	addText := func(typ, value string) {
+		if value != "" {
+			items = append(items, metadataItem{Type: typ, DataType: dataTypeUTF8, Value: []byte(value)})
+		}
+	}
+	addText(itemTitle, m.Title)
+	addText(itemArtist, m.Artist)
+	addText(itemComment, m.Comment)
+	addText(itemDate, m.Date)
+	if len(m.Cover) > 0 {
+		dataType := uint32(dataTypeJPEG)
+		if bytes.HasPrefix(m.Cover, []byte("\x89PNG")) {
+			dataType = dataTypePNG
+		}
+		items = append(items, metadataItem{Type: itemCover, DataType: dataType, Value: m.Cover})
+	}
+	return items
+}
+
+// encodeItem 生成 ilst 条目：条目 box 内包含一个 data box
+func encodeItem(typ string, dataType uint32, value []byte) []byte {
+	var header [8]byte
+	binary.BigEndian.PutUint32(header[0:4], dataType) // 版本 0 + 类型标识
+	// header[4:8] 为语言区域，保持 0
+	return encodeBox(typ, encodeBox("data", header[:], value))
+}
+
+// encodeHdlr 生成 iTunes 元数据的 hdlr box
+func encodeHdlr() []byte {
+	payload := make([]byte, 0, 25)
+	payload = append(payload, 0, 0, 0, 0) // 版本和标志
+	payload = append(payload, 0, 0, 0, 0) // pre_defined
+	payload = append(payload, "mdir"...)
+	payload = append(payload, "appl"...)
+	payload = append(payload, 0, 0, 0, 0, 0, 0, 0, 0) // reserved
+	payload = append(payload, 0)                      // 空名称
+	return encodeBox("hdlr", payload)
+}
+
+// WriteMetadata 将元数据写入 MP4 文件的 moov/udta/meta/ilst
+// 同名条目会被替换，其余已有条目保留（带 keys 的 QuickTime 元数据按键名合并）；
+// moov 位于媒体数据之前时会同步调整 stco/co64 偏移
+// 写入先生成临时文件再替换原文件，失败时原文件不受影响
+func WriteMetadata(path string, meta *Metadata) error {
+	_, err := WriteMetadataHash(path, meta)
+	return err
+}
+
+// WriteMetadataHash 与 WriteMetadata 相同，并返回写入后文件内容的 SHA-256（十六进制）
+// 哈希在生成临时文件时计算，调用方不需要再读一遍文件
+func WriteMetadataHash(path string, meta *Metadata) (string, error) {
+	_, moov, moovData, err := readMoov(path)
+	if err != nil {
+		return "", err
+	}
+
+	newMoov, err := rebuildMoov(moovData, meta)
+	if err != nil {
+		return "", err
+	}
+
+	// moov 之后的媒体数据整体后移（或前移）delta 字节
+	delta := int64(len(newMoov)) - moov.Size
+	if err := shiftChunkOffsets(newMoov, moov.End(), delta); err != nil {
+		return "", err
+	}
+
+	return replaceRange(path, moov.Offset, moov.End(), newMoov)
+}
+
+// readMoov 读取文件的顶层 box 和 moov 内容，读取完成后关闭文件（Windows 下替换文件前必须关闭）
+func readMoov(path string) ([]Box, Box, []byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, Box{}, nil, fmt.Errorf("failed to open mp4 file: %w", err)
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, Box{}, nil, fmt.Errorf("failed to stat mp4 file: %w", err)
+	}
+
+	boxes, err := ReadBoxes(f, 0, stat.Size())
+	if err != nil {
+		return nil, Box{}, nil, err
+	}
+	if _, ok := FindBox(boxes, "moof"); ok {
+		return nil, Box{}, nil, ErrFragmented
+	}
+	moov, ok := FindBox(boxes, "moov")
+	if !ok {
+		return nil, Box{}, nil, ErrNoMoov
+	}
+
+	moovData := make([]byte, moov.Size)
+	if _, err := f.ReadAt(moovData, moov.Offset); err != nil {
+		return nil, Box{}, nil, fmt.Errorf("failed to read moov: %w", err)
+	}
+	return boxes, moov, moovData, nil
+}
+
+// rebuildMoov 生成替换了 udta 的新 moov box
+func rebuildMoov(moovData []byte, meta *Metadata) ([]byte, error) {
+	children, err := parseBoxes(moovData[boxHeaderLen(moovData):])
+	if err != nil {
+		return nil, err
+	}
+
+	var payloads [][]byte
+	var udtaPayload []byte
+	for _, child := range children {
+		if child.Type == "udta" {
+			udtaPayload = child.Payload()
+			continue
+		}
+		payloads = append(payloads, child.Data)
+	}
+
+	udta, err := rebuildUdta(udtaPayload, meta)
+	if err != nil {
+		return nil, err
+	}
+	payloads = append(payloads, udta)
+	return encodeBox("moov", payloads...), nil
+}
+
+// rebuildUdta 生成包含新 meta 的 udta box，保留 udta 中的其他 box
+func rebuildUdta(udtaPayload []byte, meta *Metadata) ([]byte, error) {
+	var payloads [][]byte
+	var metaPayload []byte
+	if len(udtaPayload) > 0 {
+		children, err := parseBoxes(udtaPayload)
+		if err != nil {
+			return nil, err
+		}
+		for _, child := range children {
+			if child.Type == "meta" {
+				metaPayload = child.Payload()
+				continue
+			}
+			payloads = append(payloads, child.Data)
+		}
+	}
+
+	newMeta, err := rebuildMeta(metaPayload, meta)
+	if err != nil {
+		return nil, err
+	}
+	payloads = append(payloads, newMeta)
+	return encodeBox("udta", payloads...), nil
+}
+
+// rebuildMeta 生成新的 meta box：保留已有的条目和其他子 box，同名条目替换为新值
+// 已有 meta 带 keys（QuickTime 元数据）时按键名合并，否则按 iTunes 条目类型合并
+func rebuildMeta(metaPayload []byte, meta *Metadata) ([]byte, error) {
+	items := meta.items()
+	if len(metaPayload) == 0 {
+		fullBoxHeader := []byte{0, 0, 0, 0}
+		return encodeBox("meta", fullBoxHeader, encodeHdlr(), encodeBox("ilst", encodeItems(items)...)), nil
+	}
+
+	header, children, err := parseMeta(metaPayload)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := findRawBox(children, "keys"); ok {
+		return rebuildKeyedMeta(header, children, items)
+	}
+
+	// iTunes 元数据：条目类型即字段
+	newItems := make(map[string][]byte, len(items))
+	var order []string
+	for _, item := range items {
+		newItems[item.Type] = encodeItem(item.Type, item.DataType, item.Value)
+		order = append(order, item.Type)
+	}
+	payloads, err := mergeIlst(header, children, newItems, order)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := findRawBox(children, "hdlr"); !ok {
+		// hdlr 必须是 meta 的第一个子 box
+		payloads = append([][]byte{payloads[0], encodeHdlr()}, payloads[1:]...)
+	}
+	return encodeBox("meta", payloads...), nil
+}
+
+// rebuildKeyedMeta 合并 QuickTime 元数据：ilst 条目类型为 keys 中的序号（从 1 开始）
+// 已有同名键时替换对应条目，否则追加新键
+func rebuildKeyedMeta(header []byte, children []rawBox, items []metadataItem) ([]byte, error) {
+	keysBox, _ := findRawBox(children, "keys")
+	keys, err := parseKeys(keysBox.Payload())
+	if err != nil {
+		return nil, err
+	}
+
+	newItems := make(map[string][]byte, len(items))
+	var order []string
+	for _, item := range items {
+		name := quickTimeKeys[item.Type]
+		index := 0
+		for i, key := range keys {
+			if key.Namespace == keyNamespaceMdta && key.Name == name {
+				index = i + 1
+				break
+			}
+		}
+		if index == 0 {
+			keys = append(keys, quickTimeKey{Namespace: keyNamespaceMdta, Name: name})
+			index = len(keys)
+		}
+
+		var index4 [4]byte
+		binary.BigEndian.PutUint32(index4[:], uint32(index))
+		typ := string(index4[:])
+		newItems[typ] = encodeItem(typ, item.DataType, item.Value)
+		order = append(order, typ)
+	}
+
+	payloads, err := mergeIlst(header, children, newItems, order)
+	if err != nil {
+		return nil, err
+	}
+	for i, child := range children {
+		if child.Type == "keys" {
+			// payloads[0] 为 meta 的版本和标志（可能为空）
+			payloads[i+1] = encodeKeys(keysBox.Payload()[:4], keys)
+		}
+	}
+	return encodeBox("meta", payloads...), nil
+}
+
+// mergeIlst 按原顺序返回 meta 的内容（第一项为 header），ilst 中 newItems 包含的条目替换为新数据，
+// 其余条目保留，尚不存在的新条目按 order 追加到 ilst 末尾；没有 ilst 时在末尾创建
+func mergeIlst(header []byte, children []rawBox, newItems map[string][]byte, order []string) ([][]byte, error) {
+	written := make(map[string]bool, len(newItems))
+	appendNew := func(ilstPayloads [][]byte) [][]byte {
+		for _, typ := range order {
+			if !written[typ] {
+				ilstPayloads = append(ilstPayloads, newItems[typ])
+				written[typ] = true
+			}
+		}
+		return ilstPayloads
+	}
+
+	payloads := [][]byte{header}
+	hasIlst := false
+	for _, child := range children {
+		if child.Type != "ilst" {
+			payloads = append(payloads, child.Data)
+			continue
+		}
+		hasIlst = true
+
+		existing, err := parseBoxes(child.Payload())
+		if err != nil {
+			return nil, fmt.Errorf("invalid ilst: %w", err)
+		}
+		var ilstPayloads [][]byte
+		for _, item := range existing {
+			if data, ok := newItems[item.Type]; ok {
+				if !written[item.Type] {
+					ilstPayloads = append(ilstPayloads, data)
+					written[item.Type] = true
+				}
+				continue
+			}
+			ilstPayloads = append(ilstPayloads, item.Data)
+		}
+		payloads = append(payloads, encodeBox("ilst", appendNew(ilstPayloads)...))
+	}
+	if !hasIlst {
+		payloads = append(payloads, encodeBox("ilst", appendNew(nil)...))
+	}
+	return payloads, nil
+}
+
+// encodeItems 将元数据编码为 iTunes ilst 条目
+func encodeItems(items []metadataItem) [][]byte {
+	encoded := make([][]byte, 0, len(items))
+	for _, item := range items {
+		encoded = append(encoded, encodeItem(item.Type, item.DataType, item.Value))
+	}
+	return encoded
+}
+
+// parseMeta 解析 meta box 的内容，返回版本和标志（QuickTime 的 meta 没有版本和标志时为空）以及子 box
+func parseMeta(metaPayload []byte) ([]byte, []rawBox, error) {
+	header := []byte{}
+	if len(metaPayload) < 8 || string(metaPayload[4:8]) != "hdlr" {
+		if len(metaPayload) < 4 {
+			return nil, nil, fmt.Errorf("%w: meta box too short", ErrInvalidBox)
+		}
+		header = metaPayload[:4]
+	}
+	children, err := parseBoxes(metaPayload[len(header):])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid meta: %w", err)
+	}
+	return header, children, nil
+}
+
+// quickTimeKey keys box 中的一个键
+type quickTimeKey struct {
+	Namespace string
+	Name      string
+}
+
+// parseKeys 解析 keys box 的内容
+func parseKeys(payload []byte) ([]quickTimeKey, error) {
+	if len(payload) < 8 {
+		return nil, fmt.Errorf("%w: keys box too short", ErrInvalidBox)
+	}
+	count := binary.BigEndian.Uint32(payload[4:8])
+	data := payload[8:]
+	var keys []quickTimeKey
+	for i := uint32(0); i < count; i++ {
+		if len(data) < 8 {
+			return nil, fmt.Errorf("%w: keys box has %d entries but ends after %d", ErrInvalidBox, count, i)
+		}
+		size := binary.BigEndian.Uint32(data[0:4])
+		if size < 8 || uint64(size) > uint64(len(data)) {
+			return nil, fmt.Errorf("%w: invalid key entry size %d", ErrInvalidBox, size)
+		}
+		keys = append(keys, quickTimeKey{Namespace: string(data[4:8]), Name: string(data[8:size])})
+		data = data[size:]
+	}
+	return keys, nil
+}
+
+// encodeKeys 生成 keys box，versionFlags 为原 keys box 的版本和标志
+func encodeKeys(versionFlags []byte, keys []quickTimeKey) []byte {
+	payload := append([]byte{}, versionFlags...)
+	payload = binary.BigEndian.AppendUint32(payload, uint32(len(keys)))
+	for _, key := range keys {
+		payload = binary.BigEndian.AppendUint32(payload, uint32(8+len(key.Name)))
+		payload = append(payload, key.Namespace...)
+		payload = append(payload, key.Name...)
+	}
+	return encodeBox("keys", payload)
+}
+
+// findRawBox 返回第一个指定类型的内存 box
+func findRawBox(boxes []rawBox, typ string) (rawBox, bool) {
+	for _, box := range boxes {
+		if box.Type == typ {
+			return box, true
+		}
+	}
+	return rawBox{}, false
+}
+
+// replaceRange 将文件中 [start, end) 的内容替换为 data，通过临时文件原子替换原文件，返回新文件的 SHA-256
+func replaceRange(path string, start, end int64, data []byte) (string, error) {
+	return rewriteFile(path, []segment{
+		{Start: 0, End: start},
+		{Data: data},
+		{Start: end, End: -1},
+	})
+}
@@ -0,0 +1,284 @@
+package mp4
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"os"
+	"runtime"
+	"testing"
+)
+
+// readIlst 返回 udta/meta 中的 keys（没有时为空）和 ilst 条目类型到值的映射
+func readIlst(t *testing.T, path string) ([]quickTimeKey, map[string]string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	top, err := parseBoxes(data)
+	if err != nil {
+		t.Fatalf("failed to parse file: %v", err)
+	}
+	moov, _ := findRawBox(top, "moov")
+	metaPayload, err := findPath(moov.Payload(), "udta", "meta")
+	if err != nil {
+		t.Fatalf("failed to find udta/meta: %v", err)
+	}
+	_, children, err := parseMeta(metaPayload)
+	if err != nil {
+		t.Fatalf("failed to parse meta: %v", err)
+	}
+	if hdlr, ok := findRawBox(children, "hdlr"); !ok || children[0].Type != "hdlr" {
+		t.Fatalf("expected hdlr as the first meta child, got %+v", hdlr)
+	}
+
+	var keys []quickTimeKey
+	if keysBox, ok := findRawBox(children, "keys"); ok {
+		if keys, err = parseKeys(keysBox.Payload()); err != nil {
+			t.Fatalf("failed to parse keys: %v", err)
+		}
+	}
+	ilst, ok := findRawBox(children, "ilst")
+	if !ok {
+		t.Fatal("ilst not found")
+	}
+	items, err := parseBoxes(ilst.Payload())
+	if err != nil {
+		t.Fatalf("failed to parse ilst: %v", err)
+	}
+	values := make(map[string]string, len(items))
+	for _, item := range items {
+		if _, dup := values[item.Type]; dup {
+			t.Fatalf("duplicate ilst item %q", item.Type)
+		}
+		value, err := findPath(item.Payload(), "data")
+		if err != nil || len(value) < 8 {
+			t.Fatalf("invalid ilst item %q", item.Type)
+		}
+		values[item.Type] = string(value[8:])
+	}
+	return keys, values
+}
+
+// keyIndex 返回 QuickTime 键序号对应的 ilst 条目类型
+func keyIndex(index uint32) string {
+	var typ [4]byte
+	binary.BigEndian.PutUint32(typ[:], index)
+	return string(typ[:])
+}
+
+func TestWriteMetadata(t *testing.T) {
+	samples := testSamples(3, 64)
+	meta := &Metadata{Title: "标题", Artist: "作者", Comment: "完整描述", Date: "2024-03-05", Cover: []byte("\x89PNG cover")}
+
+	for _, tt := range []struct {
+		name string
+		file testFile
+	}{
+		{name: "moov after mdat", file: testFile{samples: samples}},
+		{name: "moov before mdat", file: testFile{moovFirst: true, samples: samples}},
+		{name: "co64 moov before mdat", file: testFile{moovFirst: true, co64: true, samples: samples}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestFile(t, tt.file.build())
+
+			if err := WriteMetadata(path, meta); err != nil {
+				t.Fatalf("WriteMetadata error: %v", err)
+			}
+			assertSamples(t, path, samples)
+
+			_, values := readIlst(t, path)
+			want := map[string]string{
+				itemTitle:   "标题",
+				itemArtist:  "作者",
+				itemComment: "完整描述",
+				itemDate:    "2024-03-05",
+				itemCover:   "\x89PNG cover",
+			}
+			for typ, value := range want {
+				if values[typ] != value {
+					t.Fatalf("expected %q = %q, got %q", typ, value, values[typ])
+				}
+			}
+
+			// 再次写入替换已有条目，不产生重复条目
+			if err := WriteMetadata(path, &Metadata{Title: "新标题"}); err != nil {
+				t.Fatalf("second WriteMetadata error: %v", err)
+			}
+			assertSamples(t, path, samples)
+			_, values = readIlst(t, path)
+			if values[itemTitle] != "新标题" || values[itemArtist] != "作者" || len(values) != len(want) {
+				t.Fatalf("unexpected items after rewrite: %v", values)
+			}
+
+			report, err := Validate(path)
+			if err != nil || !report.Valid {
+				t.Fatalf("expected valid file after writing metadata, got %+v (%v)", report, err)
+			}
+		})
+	}
+}
+
+func TestWriteMetadata_MergesExistingItems(t *testing.T) {
+	ilst := encodeBox("ilst",
+		encodeItem("\xa9too", dataTypeUTF8, []byte("encoder")),
+		encodeItem(itemTitle, dataTypeUTF8, []byte("old title")),
+	)
+	udta := encodeBox("udta",
+		encodeBox("meta", []byte{0, 0, 0, 0}, encodeHdlr(), ilst, encodeBox("free", make([]byte, 4))),
+		encodeBox("\xa9xyz", []byte("location")),
+	)
+	samples := testSamples(2, 32)
+	path := writeTestFile(t, testFile{moovFirst: true, udta: udta, samples: samples}.build())
+
+	if err := WriteMetadata(path, &Metadata{Title: "new title"}); err != nil {
+		t.Fatalf("WriteMetadata error: %v", err)
+	}
+	assertSamples(t, path, samples)
+
+	_, values := readIlst(t, path)
+	if values["\xa9too"] != "encoder" || values[itemTitle] != "new title" || len(values) != 2 {
+		t.Fatalf("unexpected merged items: %v", values)
+	}
+
+	data, _ := os.ReadFile(path)
+	top, _ := parseBoxes(data)
+	moov, _ := findRawBox(top, "moov")
+	udtaPayload, err := findPath(moov.Payload(), "udta")
+	if err != nil {
+		t.Fatalf("udta not found: %v", err)
+	}
+	children, _ := parseBoxes(udtaPayload)
+	if _, ok := findRawBox(children, "\xa9xyz"); !ok {
+		t.Fatal("expected other udta boxes to be kept")
+	}
+	metaPayload, _ := findPath(udtaPayload, "meta")
+	_, metaChildren, _ := parseMeta(metaPayload)
+	if _, ok := findRawBox(metaChildren, "free"); !ok {
+		t.Fatal("expected other meta boxes to be kept")
+	}
+}
+
+func TestWriteMetadata_MergesQuickTimeKeys(t *testing.T) {
+	keys := []quickTimeKey{
+		{Namespace: keyNamespaceMdta, Name: "com.apple.quicktime.make"},
+		{Namespace: keyNamespaceMdta, Name: "com.apple.quicktime.title"},
+	}
+	mdtaHdlr := encodeBox("hdlr", []byte{0, 0, 0, 0, 0, 0, 0, 0}, []byte("mdta"), make([]byte, 13))
+	ilst := encodeBox("ilst",
+		encodeItem(keyIndex(1), dataTypeUTF8, []byte("Apple")),
+		encodeItem(keyIndex(2), dataTypeUTF8, []byte("old title")),
+	)
+	// QuickTime 的 meta 没有版本和标志
+	udta := encodeBox("udta", encodeBox("meta", mdtaHdlr, encodeKeys([]byte{0, 0, 0, 0}, keys), ilst))
+	samples := testSamples(2, 32)
+	path := writeTestFile(t, testFile{udta: udta, samples: samples}.build())
+
+	if err := WriteMetadata(path, &Metadata{Title: "new title", Artist: "作者"}); err != nil {
+		t.Fatalf("WriteMetadata error: %v", err)
+	}
+	assertSamples(t, path, samples)
+
+	gotKeys, values := readIlst(t, path)
+	wantKeys := append(keys, quickTimeKey{Namespace: keyNamespaceMdta, Name: "com.apple.quicktime.artist"})
+	if len(gotKeys) != len(wantKeys) {
+		t.Fatalf("expected keys %v, got %v", wantKeys, gotKeys)
+	}
+	for i := range wantKeys {
+		if gotKeys[i] != wantKeys[i] {
+			t.Fatalf("expected keys %v, got %v", wantKeys, gotKeys)
+		}
+	}
+	want := map[string]string{keyIndex(1): "Apple", keyIndex(2): "new title", keyIndex(3): "作者"}
+	if len(values) != len(want) {
+		t.Fatalf("expected items %q, got %q", want, values)
+	}
+	for typ, value := range want {
+		if values[typ] != value {
+			t.Fatalf("expected items %q, got %q", want, values)
+		}
+	}
+}
+
+// fileSHA256 读取文件计算 SHA-256（十六进制）
+func fileSHA256(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestWriteMetadataHash(t *testing.T) {
+	for _, moovFirst := range []bool{false, true} {
+		path := writeTestFile(t, testFile{moovFirst: moovFirst, samples: testSamples(2, 32)}.build())
+		sum, err := WriteMetadataHash(path, &Metadata{Title: "title", Cover: []byte{0xff, 0xd8, 0xff}})
+		if err != nil {
+			t.Fatalf("WriteMetadataHash error: %v", err)
+		}
+		if want := fileSHA256(t, path); sum != want {
+			t.Fatalf("expected hash %s, got %s (moovFirst %v)", want, sum, moovFirst)
+		}
+	}
+}
+
+func TestWriteMetadata_KeepsFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file mode is not preserved on windows")
+	}
+	path := writeTestFile(t, testFile{samples: testSamples(1, 16)}.build())
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatalf("chmod error: %v", err)
+	}
+
+	if err := WriteMetadata(path, &Metadata{Title: "title"}); err != nil {
+		t.Fatalf("WriteMetadata error: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat error: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("expected mode 0600, got %v", info.Mode().Perm())
+	}
+}
+
+func TestWriteMetadata_InvalidFiles(t *testing.T) {
+	valid := testFile{samples: testSamples(2, 16)}.build()
+	ftyp := encodeBox("ftyp", []byte("isom"))
+	badMeta := encodeBox("udta", encodeBox("meta", []byte{0, 0, 0, 0}, []byte{0, 0, 0, 99, 'i', 'l', 's', 't'}))
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{name: "no moov", data: append(append([]byte{}, ftyp...), encodeBox("mdat", []byte{1, 2, 3})...), want: ErrNoMoov},
+		{name: "fragmented", data: append(append([]byte{}, valid...), encodeBox("moof")...), want: ErrFragmented},
+		{name: "truncated", data: valid[:len(valid)-10], want: ErrInvalidBox},
+		{name: "invalid existing meta", data: testFile{udta: badMeta, samples: testSamples(1, 8)}.build(), want: ErrInvalidBox},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestFile(t, tt.data)
+			err := WriteMetadata(path, &Metadata{Title: "title"})
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+			// 失败时原文件不受影响，也不留下临时文件
+			data, _ := os.ReadFile(path)
+			if !bytes.Equal(data, tt.data) {
+				t.Fatal("expected file to be unchanged")
+			}
+			if _, err := os.Stat(path + ".mp4tmp"); !os.IsNotExist(err) {
+				t.Fatal("expected temp file to be removed")
+			}
+		})
+	}
+}
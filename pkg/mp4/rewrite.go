@@ -0,0 +1,82 @@
+package mp4
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// segment 重写文件时的一段内容：Data 非空时写入 Data，否则复制原文件的 [Start, End)
+// End 为 -1 表示到文件末尾
+type segment struct {
+	Data       []byte
+	Start, End int64
+}
+
+// rewriteFile 按 segments 顺序生成临时文件，再原子替换原文件，失败时原文件不受影响
+// 返回新文件内容的 SHA-256（十六进制），在写入临时文件时计算
+func rewriteFile(path string, segments []segment) (string, error) {
+	tmpPath := path + ".mp4tmp"
+	sum, err := writeSegments(path, tmpPath, segments)
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to replace mp4 file: %w", err)
+	}
+	return sum, nil
+}
+
+// writeSegments 将各段内容写入临时文件并同时计算 SHA-256，临时文件沿用原文件的权限
+// 返回前关闭所有文件句柄（Windows 下替换文件前必须关闭）
+func writeSegments(path, tmpPath string, segments []segment) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open mp4 file: %w", err)
+	}
+	defer src.Close()
+
+	stat, err := src.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat mp4 file: %w", err)
+	}
+
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer out.Close()
+
+	if err := out.Chmod(stat.Mode().Perm()); err != nil {
+		return "", fmt.Errorf("failed to set temp file mode: %w", err)
+	}
+
+	h := sha256.New()
+	w := io.MultiWriter(out, h)
+	for _, seg := range segments {
+		if seg.Data != nil {
+			if _, err := w.Write(seg.Data); err != nil {
+				return "", fmt.Errorf("failed to write temp file: %w", err)
+			}
+			continue
+		}
+		end := seg.End
+		if end < 0 {
+			end = stat.Size()
+		}
+		if _, err := io.Copy(w, io.NewSectionReader(src, seg.Start, end-seg.Start)); err != nil {
+			return "", fmt.Errorf("failed to write temp file: %w", err)
+		}
+	}
+	if err := out.Sync(); err != nil {
+		return "", fmt.Errorf("failed to sync temp file: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,455 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// EncryptedPrefixLen 视频号加密视频的加密前缀长度（前 128KB）
+const EncryptedPrefixLen = 131072
+
+// 校验问题代码
+const (
+	IssueEmpty           = "empty"
+	IssueEncryptedPrefix = "encrypted_prefix"
+	IssueInvalidBox      = "invalid_box"
+	IssueTruncated       = "truncated"
+	IssueMissingFtyp     = "missing_ftyp"
+	IssueMissingMoov     = "missing_moov"
+	IssueMissingMdat     = "missing_mdat"
+	IssueNoTracks        = "no_tracks"
+	IssueSampleTable     = "invalid_sample_table"
+	IssueChunkOutOfRange = "chunk_out_of_range"
+)
+
+// 修复建议
+const (
+	hintRedownload = "re-download the video"
+	hintDecrypt    = "the first 128KB were not decrypted: decrypt them with the video's decode key, or re-download with the key"
+	hintResume     = "the download is incomplete: resume or re-download the file"
+)
+
+// topLevelBoxes 文件开头允许出现的顶层 box 类型
+var topLevelBoxes = map[string]bool{
+	"ftyp": true, "styp": true, "free": true, "skip": true, "wide": true,
+	"pdin": true, "moov": true, "mdat": true, "uuid": true, "sidx": true,
+	"moof": true, "meta": true, "junk": true,
+}
+
+// Issue 校验发现的问题
+type Issue struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Report MP4 结构校验报告
+type Report struct {
+	Path       string   `json:"path,omitempty"`
+	Size       int64    `json:"size"`
+	Valid      bool     `json:"valid"`
+	Boxes      []string `json:"boxes"`      // 顶层 box 类型
+	Tracks     int      `json:"tracks"`     // 轨道数
+	FastStart  bool     `json:"fastStart"`  // moov 位于 mdat 之前，可边下边播
+	Fragmented bool     `json:"fragmented"` // 分片 MP4
+	Issues     []Issue  `json:"issues,omitempty"`
+	RepairHint string   `json:"repairHint,omitempty"`
+}
+
+// addIssue 记录问题，第一个问题的修复建议作为报告的修复建议
+func (r *Report) addIssue(code, hint, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, Issue{Code: code, Message: fmt.Sprintf(format, args...)})
+	if r.RepairHint == "" {
+		r.RepairHint = hint
+	}
+}
+
+// Validate 校验 MP4 文件结构：顶层 box 边界、ftyp/moov/mdat 是否存在、
+// 样本表中的分片偏移是否落在 mdat 内，以及前 128KB 是否未解密
+// 只有文件无法打开时才返回错误，结构问题记录在报告中
+func Validate(path string) (*Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mp4 file: %w", err)
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat mp4 file: %w", err)
+	}
+
+	report := ValidateReader(f, stat.Size())
+	report.Path = path
+	return report, nil
+}
+
+// ValidateReader 校验 r 中长度为 size 的 MP4 数据
+func ValidateReader(r io.ReaderAt, size int64) *Report {
+	report := &Report{Size: size, Boxes: []string{}}
+	defer func() {
+		report.Valid = len(report.Issues) == 0
+	}()
+
+	if size == 0 {
+		report.addIssue(IssueEmpty, hintRedownload, "file is empty")
+		return report
+	}
+
+	boxes, ok := validateTopLevel(r, size, report)
+	if !ok {
+		return report
+	}
+
+	if _, ok := FindBox(boxes, "ftyp"); !ok {
+		report.addIssue(IssueMissingFtyp, hintRedownload, "ftyp box not found")
+	}
+	_, report.Fragmented = FindBox(boxes, "moof")
+
+	var mdats []Box
+	for _, box := range boxes {
+		if box.Type == "mdat" {
+			mdats = append(mdats, box)
+		}
+	}
+	if len(mdats) == 0 && !report.Fragmented {
+		report.addIssue(IssueMissingMdat, hintRedownload, "mdat box not found")
+	}
+
+	moov, ok := FindBox(boxes, "moov")
+	if !ok {
+		hint := hintRedownload
+		if len(mdats) > 0 {
+			// moov 在文件末尾时，下载中断会丢失 moov
+			hint = hintResume
+		}
+		report.addIssue(IssueMissingMoov, hint, "moov box not found")
+		return report
+	}
+	if len(mdats) > 0 {
+		report.FastStart = moov.Offset < mdats[0].Offset
+	}
+
+	moovData := make([]byte, moov.Size)
+	if _, err := r.ReadAt(moovData, moov.Offset); err != nil {
+		report.addIssue(IssueInvalidBox, hintRedownload, "failed to read moov: %v", err)
+		return report
+	}
+	validateMoov(moovData, mdats, size, report)
+	return report
+}
+
+// validateTopLevel 读取顶层 box 并检查边界，返回是否可以继续校验
+func validateTopLevel(r io.ReaderAt, size int64, report *Report) ([]Box, bool) {
+	var boxes []Box
+	offset := int64(0)
+	for offset < size {
+		box, err := readBoxHeader(r, offset, size)
+		if err != nil {
+			// 头部有效但大小超出文件末尾，说明文件被截断
+			if box, headerErr := readBoxHeader(r, offset, math.MaxInt64); headerErr == nil && isFourCC(box.Type) && (offset > 0 || topLevelBoxes[box.Type]) {
+				report.Boxes = append(report.Boxes, box.Type)
+				report.addIssue(IssueTruncated, hintResume, "box %q at offset %d needs %d bytes but file ends after %d", box.Type, offset, box.Size, size-offset)
+				// 截断的 box 仍参与后续检查（例如 moov 完整但 mdat 不完整）
+				boxes = append(boxes, box)
+				return boxes, box.Type != "moov"
+			}
+			if offset == 0 {
+				report.addIssue(IssueEncryptedPrefix, hintDecrypt, "file does not start with a valid mp4 box")
+				return nil, false
+			}
+			report.addIssue(IssueInvalidBox, hintRedownload, "invalid box header at offset %d: %v", offset, err)
+			return boxes, false
+		}
+
+		if offset == 0 && !topLevelBoxes[box.Type] {
+			report.addIssue(IssueEncryptedPrefix, hintDecrypt, "file starts with unknown box %q", box.Type)
+			return nil, false
+		}
+		if !isFourCC(box.Type) {
+			report.addIssue(IssueInvalidBox, hintRedownload, "invalid box type at offset %d", offset)
+			return boxes, false
+		}
+
+		report.Boxes = append(report.Boxes, box.Type)
+		boxes = append(boxes, box)
+		offset = box.End()
+	}
+	return boxes, true
+}
+
+// isFourCC 检查 box 类型是否由可打印字符组成（允许 iTunes 的 © 前缀）
+func isFourCC(typ string) bool {
+	if len(typ) != 4 {
+		return false
+	}
+	for i := 0; i < 4; i++ {
+		c := typ[i]
+		if (c < 0x20 || c > 0x7e) && c != 0xa9 {
+			return false
+		}
+	}
+	return true
+}
+
+// validateMoov 校验 moov 中每个轨道的样本表
+func validateMoov(moovData []byte, mdats []Box, size int64, report *Report) {
+	children, err := parseBoxes(moovData[boxHeaderLen(moovData):])
+	if err != nil {
+		report.addIssue(IssueInvalidBox, hintRedownload, "invalid moov: %v", err)
+		return
+	}
+	if _, ok := findRawBox(children, "mvhd"); !ok {
+		report.addIssue(IssueInvalidBox, hintRedownload, "mvhd box not found in moov")
+	}
+
+	for _, child := range children {
+		if child.Type != "trak" {
+			continue
+		}
+		report.Tracks++
+		if report.Fragmented {
+			// 分片 MP4 的样本在 moof 中描述
+			continue
+		}
+		stbl, err := findPath(child.Payload(), "mdia", "minf", "stbl")
+		if err != nil {
+			report.addIssue(IssueSampleTable, hintRedownload, "track %d: %v", report.Tracks, err)
+			continue
+		}
+		validateSampleTable(stbl, report.Tracks, mdats, size, report)
+	}
+
+	if report.Tracks == 0 {
+		report.addIssue(IssueNoTracks, hintRedownload, "moov contains no tracks")
+	}
+}
+
+// findPath 按路径查找嵌套的 box，返回最后一级的内容
+func findPath(payload []byte, path ...string) ([]byte, error) {
+	for _, typ := range path {
+		children, err := parseBoxes(payload)
+		if err != nil {
+			return nil, err
+		}
+		box, ok := findRawBox(children, typ)
+		if !ok {
+			return nil, fmt.Errorf("%s box not found", typ)
+		}
+		payload = box.Payload()
+	}
+	return payload, nil
+}
+
+// validateSampleTable 根据 stco/co64、stsc、stsz 计算每个分片的范围，检查是否落在 mdat 内
+func validateSampleTable(stbl []byte, track int, mdats []Box, size int64, report *Report) {
+	children, err := parseBoxes(stbl)
+	if err != nil {
+		report.addIssue(IssueSampleTable, hintRedownload, "track %d: invalid stbl: %v", track, err)
+		return
+	}
+
+	offsets, err := readChunkOffsets(children)
+	if err != nil {
+		report.addIssue(IssueSampleTable, hintRedownload, "track %d: %v", track, err)
+		return
+	}
+	samples, err := readSampleSizes(children, size)
+	if err != nil {
+		report.addIssue(IssueSampleTable, hintRedownload, "track %d: %v", track, err)
+		return
+	}
+	chunkSizes, err := chunkSizesFromStsc(children, len(offsets), samples)
+	if err != nil {
+		report.addIssue(IssueSampleTable, hintRedownload, "track %d: %v", track, err)
+		return
+	}
+
+	outOfRange := 0
+	beyondEOF := 0
+	for i, offset := range offsets {
+		// 先比较再相加，co64 中接近上限的偏移不会溢出
+		if offset > size || chunkSizes[i] > size-offset {
+			beyondEOF++
+			continue
+		}
+		if !inMdat(offset, offset+chunkSizes[i], mdats) {
+			outOfRange++
+		}
+	}
+
+	if beyondEOF > 0 {
+		report.addIssue(IssueTruncated, hintResume, "track %d: %d of %d chunks extend beyond the end of the file", track, beyondEOF, len(offsets))
+	}
+	if outOfRange > 0 {
+		report.addIssue(IssueChunkOutOfRange, hintRedownload, "track %d: %d of %d chunks lie outside mdat", track, outOfRange, len(offsets))
+	}
+}
+
+// inMdat 检查 [start, end) 是否完整落在某个 mdat 的内容范围内
+func inMdat(start, end int64, mdats []Box) bool {
+	for _, mdat := range mdats {
+		if start >= mdat.PayloadOffset() && end <= mdat.End() {
+			return true
+		}
+	}
+	return false
+}
+
+// readChunkOffsets 读取 stco 或 co64 中的分片偏移
+func readChunkOffsets(children []rawBox) ([]int64, error) {
+	if box, ok := findRawBox(children, "stco"); ok {
+		entries, err := chunkOffsetEntries(box.Payload(), 4)
+		if err != nil {
+			return nil, err
+		}
+		offsets := make([]int64, 0, len(entries)/4)
+		for i := 0; i < len(entries); i += 4 {
+			offsets = append(offsets, int64(binary.BigEndian.Uint32(entries[i:i+4])))
+		}
+		return offsets, nil
+	}
+	if box, ok := findRawBox(children, "co64"); ok {
+		entries, err := chunkOffsetEntries(box.Payload(), 8)
+		if err != nil {
+			return nil, err
+		}
+		offsets := make([]int64, 0, len(entries)/8)
+		for i := 0; i < len(entries); i += 8 {
+			offset := binary.BigEndian.Uint64(entries[i : i+8])
+			if offset > math.MaxInt64 {
+				return nil, fmt.Errorf("co64 offset overflows")
+			}
+			offsets = append(offsets, int64(offset))
+		}
+		return offsets, nil
+	}
+	return nil, fmt.Errorf("stco/co64 box not found")
+}
+
+// sampleSizes 样本表中的样本大小；所有样本大小相同时不展开为切片
+type sampleSizes struct {
+	count   int64
+	uniform int64   // 统一的样本大小，为 0 时使用 sizes
+	sizes   []int64 // 每个样本的大小
+}
+
+// sum 返回从 start 开始的 n 个样本的总大小（调用方需保证范围有效）
+func (s *sampleSizes) sum(start, n int64) int64 {
+	if s.sizes == nil {
+		return s.uniform * n
+	}
+	var total int64
+	for _, size := range s.sizes[start : start+n] {
+		total += size
+	}
+	return total
+}
+
+// readSampleSizes 读取 stsz 或 stz2 中的样本大小
+// 在任何乘法和分配之前检查样本数和大小，损坏的样本表不会导致溢出或分配过大的内存
+func readSampleSizes(children []rawBox, fileSize int64) (*sampleSizes, error) {
+	if box, ok := findRawBox(children, "stsz"); ok {
+		payload := box.Payload()
+		if len(payload) < 12 {
+			return nil, fmt.Errorf("stsz box too short")
+		}
+		uniform := int64(binary.BigEndian.Uint32(payload[4:8]))
+		count := int64(binary.BigEndian.Uint32(payload[8:12]))
+		if uniform != 0 {
+			// 统一大小时没有表项，样本总大小不可能超过文件大小
+			if count > fileSize || (count > 0 && uniform > fileSize/count) {
+				return nil, fmt.Errorf("stsz describes %d samples of %d bytes but file has %d bytes", count, uniform, fileSize)
+			}
+			return &sampleSizes{count: count, uniform: uniform}, nil
+		}
+		if count > int64(len(payload)-12)/4 {
+			return nil, fmt.Errorf("stsz has %d samples but only %d bytes", count, len(payload)-12)
+		}
+		sizes := make([]int64, count)
+		for i := range sizes {
+			sizes[i] = int64(binary.BigEndian.Uint32(payload[12+4*i:]))
+		}
+		return &sampleSizes{count: count, sizes: sizes}, nil
+	}
+
+	if box, ok := findRawBox(children, "stz2"); ok {
+		payload := box.Payload()
+		if len(payload) < 12 {
+			return nil, fmt.Errorf("stz2 box too short")
+		}
+		fieldSize := int64(payload[7])
+		count := int64(binary.BigEndian.Uint32(payload[8:12]))
+		if fieldSize != 4 && fieldSize != 8 && fieldSize != 16 {
+			return nil, fmt.Errorf("stz2 has invalid field size %d", fieldSize)
+		}
+		if (count*fieldSize+7)/8 > int64(len(payload)-12) {
+			return nil, fmt.Errorf("stz2 has %d samples but only %d bytes", count, len(payload)-12)
+		}
+		table := payload[12:]
+		sizes := make([]int64, count)
+		for i := range sizes {
+			switch fieldSize {
+			case 4:
+				b := table[i/2]
+				if i%2 == 0 {
+					sizes[i] = int64(b >> 4)
+				} else {
+					sizes[i] = int64(b & 0x0f)
+				}
+			case 8:
+				sizes[i] = int64(table[i])
+			case 16:
+				sizes[i] = int64(binary.BigEndian.Uint16(table[2*i:]))
+			}
+		}
+		return &sampleSizes{count: count, sizes: sizes}, nil
+	}
+	return nil, fmt.Errorf("stsz/stz2 box not found")
+}
+
+// chunkSizesFromStsc 根据 stsc 将样本分配到分片，返回每个分片的字节数
+func chunkSizesFromStsc(children []rawBox, chunkCount int, samples *sampleSizes) ([]int64, error) {
+	box, ok := findRawBox(children, "stsc")
+	if !ok {
+		return nil, fmt.Errorf("stsc box not found")
+	}
+	payload := box.Payload()
+	if len(payload) < 8 {
+		return nil, fmt.Errorf("stsc box too short")
+	}
+	entryCount := int(binary.BigEndian.Uint32(payload[4:8]))
+	if int64(entryCount)*12 > int64(len(payload)-8) {
+		return nil, fmt.Errorf("stsc has %d entries but only %d bytes", entryCount, len(payload)-8)
+	}
+
+	chunkSizes := make([]int64, chunkCount)
+	var sample int64
+	for e := 0; e < entryCount; e++ {
+		entry := payload[8+12*e:]
+		firstChunk := int(binary.BigEndian.Uint32(entry[0:4]))
+		samplesPerChunk := int64(binary.BigEndian.Uint32(entry[4:8]))
+		lastChunk := chunkCount
+		if e+1 < entryCount {
+			lastChunk = int(binary.BigEndian.Uint32(payload[8+12*(e+1):])) - 1
+		}
+		if firstChunk < 1 || lastChunk > chunkCount || firstChunk > lastChunk+1 {
+			return nil, fmt.Errorf("stsc entry %d references invalid chunk range %d-%d", e+1, firstChunk, lastChunk)
+		}
+
+		for chunk := firstChunk; chunk <= lastChunk; chunk++ {
+			if sample+samplesPerChunk > samples.count {
+				return nil, fmt.Errorf("stsc references %d+ samples but stsz has %d", sample+samplesPerChunk, samples.count)
+			}
+			chunkSizes[chunk-1] += samples.sum(sample, samplesPerChunk)
+			sample += samplesPerChunk
+		}
+	}
+
+	if sample != samples.count {
+		return nil, fmt.Errorf("stsc covers %d samples but stsz has %d", sample, samples.count)
+	}
+	return chunkSizes, nil
+}
@@ -0,0 +1,143 @@
+package mp4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// patchUint32 修改第一个 typ box 内容中 offset 处的 32 位值
+func patchUint32(t *testing.T, data []byte, typ string, offset int, value uint32) []byte {
+	t.Helper()
+	patched := append([]byte{}, data...)
+	i := bytes.Index(patched, []byte(typ))
+	if i < 4 {
+		t.Fatalf("box %q not found", typ)
+	}
+	binary.BigEndian.PutUint32(patched[i+4+offset:], value)
+	return patched
+}
+
+func TestValidateReader(t *testing.T) {
+	samples := testSamples(3, 64)
+	moovLast := testFile{samples: samples}.build()
+	moovFirst := testFile{moovFirst: true, samples: samples}.build()
+
+	encrypted := append([]byte{}, moovFirst...)
+	for i := 0; i < 16; i++ {
+		encrypted[i] ^= 0x5a
+	}
+
+	tests := []struct {
+		name      string
+		data      []byte
+		valid     bool
+		fastStart bool
+		issues    []string
+		hint      string
+	}{
+		{name: "moov after mdat", data: moovLast, valid: true},
+		{name: "moov before mdat", data: moovFirst, valid: true, fastStart: true},
+		{name: "co64", data: testFile{moovFirst: true, co64: true, samples: samples}.build(), valid: true, fastStart: true},
+		{name: "empty", data: []byte{}, issues: []string{IssueEmpty}, hint: hintRedownload},
+		{name: "encrypted prefix", data: encrypted, issues: []string{IssueEncryptedPrefix}, hint: hintDecrypt},
+		{
+			name:      "truncated mdat",
+			data:      moovFirst[:len(moovFirst)-10],
+			fastStart: true,
+			issues:    []string{IssueTruncated, IssueTruncated},
+			hint:      hintResume,
+		},
+		{name: "truncated moov", data: moovLast[:len(moovLast)-10], issues: []string{IssueTruncated}, hint: hintResume},
+		{
+			name:   "missing moov",
+			data:   append(encodeBox("ftyp", []byte("isom")), encodeBox("mdat", []byte{1, 2, 3})...),
+			issues: []string{IssueMissingMoov},
+			hint:   hintResume,
+		},
+		{
+			name:   "chunk outside mdat",
+			data:   patchUint32(t, moovLast, "stco", 8, 0),
+			issues: []string{IssueChunkOutOfRange},
+			hint:   hintRedownload,
+		},
+		{
+			name:   "stsc references missing samples",
+			data:   patchUint32(t, moovLast, "stsc", 12, 2),
+			issues: []string{IssueSampleTable},
+			hint:   hintRedownload,
+		},
+		{
+			name:   "stsz table beyond box",
+			data:   patchUint32(t, moovLast, "stsz", 8, 1000),
+			issues: []string{IssueSampleTable},
+			hint:   hintRedownload,
+		},
+		{
+			// 统一大小的样本数和大小都取最大值，相乘会溢出
+			name:   "uniform stsz overflow",
+			data:   patchUint32(t, patchUint32(t, moovLast, "stsz", 4, 0xffffffff), "stsz", 8, 0xffffffff),
+			issues: []string{IssueSampleTable},
+			hint:   hintRedownload,
+		},
+		{
+			name:   "uniform stsz larger than file",
+			data:   patchUint32(t, patchUint32(t, moovLast, "stsz", 4, 1), "stsz", 8, 4000000000),
+			issues: []string{IssueSampleTable},
+			hint:   hintRedownload,
+		},
+		{
+			name:   "invalid box after ftyp",
+			data:   append(encodeBox("ftyp", []byte("isom")), 0, 0, 0, 9, 0, 1, 2, 3, 4),
+			issues: []string{IssueInvalidBox},
+			hint:   hintRedownload,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := ValidateReader(byteReaderAt(tt.data), int64(len(tt.data)))
+			if report.Valid != tt.valid {
+				t.Fatalf("expected valid %v, got %+v", tt.valid, report)
+			}
+			if report.FastStart != tt.fastStart {
+				t.Fatalf("expected fastStart %v, got %v", tt.fastStart, report.FastStart)
+			}
+			if len(report.Issues) != len(tt.issues) {
+				t.Fatalf("expected issues %v, got %+v", tt.issues, report.Issues)
+			}
+			for i, issue := range report.Issues {
+				if issue.Code != tt.issues[i] {
+					t.Fatalf("expected issues %v, got %+v", tt.issues, report.Issues)
+				}
+			}
+			if report.RepairHint != tt.hint {
+				t.Fatalf("expected hint %q, got %q", tt.hint, report.RepairHint)
+			}
+			if tt.valid && report.Tracks != 1 {
+				t.Fatalf("expected 1 track, got %d", report.Tracks)
+			}
+		})
+	}
+}
+
+func TestReadSampleSizes_Uniform(t *testing.T) {
+	stsz := encodeBox("stsz", []byte{0, 0, 0, 0, 0, 0, 0, 16, 0, 0, 0, 4})
+	children, err := parseBoxes(stsz)
+	if err != nil {
+		t.Fatalf("parseBoxes error: %v", err)
+	}
+
+	samples, err := readSampleSizes(children, 1024)
+	if err != nil {
+		t.Fatalf("readSampleSizes error: %v", err)
+	}
+	// 统一大小时不展开为切片
+	if samples.count != 4 || samples.sizes != nil || samples.sum(1, 3) != 48 {
+		t.Fatalf("unexpected sample sizes: %+v", samples)
+	}
+
+	if _, err := readSampleSizes(children, 63); err == nil {
+		t.Fatal("expected error when samples exceed the file size")
+	}
+}